@@ -40,11 +40,11 @@ const (
 	maxChunkSize     = 8388608 // at 256 KiB and 8 MiB.
 	chunkStreams     = 0       // Streams to use for reading
 
-	bufferSize          = 8388608
-	heuristicBytes      = 1048576
-	minCompressionRatio = 1.1
+	bufferSize = 8388608
 
 	gzFileExt           = ".gz"
+	zstdFileExt         = ".zst"
+	lz4FileExt          = ".lz4"
 	metaFileExt         = ".json"
 	uncompressedFileExt = ".bin"
 )
@@ -53,6 +53,8 @@ const (
 const (
 	Uncompressed = 0
 	Gzip         = 2
+	Zstd         = 3
+	Lz4          = 4
 )
 
 var nameRegexp = regexp.MustCompile(`^(.+?)\.([A-Za-z0-9-_]{11})$`)
@@ -65,6 +67,14 @@ func init() {
 			Value: "gzip",
 			Help:  "Standard gzip compression with fastest parameters.",
 		},
+		{
+			Value: "zstd",
+			Help:  "Zstandard compression - faster and smaller than gzip.",
+		},
+		{
+			Value: "lz4",
+			Help:  "LZ4 compression - much faster than gzip but larger.",
+		},
 	}
 
 	// Register our remote
@@ -80,13 +90,19 @@ func init() {
 			Help:     "Remote to compress.",
 			Required: true,
 		}, {
-			Name:     "mode",
-			Help:     "Compression mode.",
+			Name: "mode",
+			Help: `Compression mode.
+
+This is only used for new files. The mode of each file is stored in
+its metadata so files compressed with a different mode can still be
+read.`,
 			Default:  "gzip",
 			Examples: compressionModeOptions,
 		}, {
 			Name: "level",
-			Help: `GZIP compression level (-2 to 9).
+			Help: `Compression level.
+
+For gzip this is -2 to 9.
 
 Generally -1 (default, equivalent to 5) is recommended.
 Levels 1 to 9 increase compression at the cost of speed. Going past 6 
@@ -94,7 +110,13 @@ generally offers very little return.
 
 Level -2 uses Huffman encoding only. Only use if you know what you
 are doing.
-Level 0 turns off compression.`,
+Level 0 turns off compression.
+
+For zstd this is 1 to 22, and levels below 1 use the default of 3.
+Levels above 3 increase compression at the cost of speed.
+
+For lz4 this is 0 to 9. Levels below 1 use the fast compressor and
+levels 1 to 9 use the slower high compression one.`,
 			Default:  sgzip.DefaultCompression,
 			Advanced: true,
 		}, {
@@ -107,6 +129,28 @@ Files smaller than this limit will be cached in RAM, files larger than
 this limit will be cached on disk.`,
 			Default:  fs.SizeSuffix(20 * 1024 * 1024),
 			Advanced: true,
+		}, {
+			Name: "min_compression_ratio",
+			Help: `Minimum compression ratio needed to compress a file.
+
+The start of each file (see sample_size) is compressed first and if
+its size divided by its compressed size is below this ratio the file
+is stored uncompressed. This stops CPU being wasted on files which are
+already compressed or encrypted, such as JPEGs and zip files. Whether
+each file was compressed is stored in its metadata.
+
+Set to 0 to compress all files without sampling them.`,
+			Default:  1.1,
+			Advanced: true,
+		}, {
+			Name: "sample_size",
+			Help: `Amount of data at the start of each file to sample.
+
+This is compressed to find whether the file is worth compressing -
+see min_compression_ratio. It is also used to find the MIME type of
+the file.`,
+			Default:  fs.SizeSuffix(1024 * 1024),
+			Advanced: true,
 		}},
 	})
 }
@@ -117,6 +161,8 @@ type Options struct {
 	CompressionMode  string        `config:"mode"`
 	CompressionLevel int           `config:"level"`
 	RAMCacheLimit    fs.SizeSuffix `config:"ram_cache_limit"`
+	MinRatio         float64       `config:"min_compression_ratio"`
+	SampleSize       fs.SizeSuffix `config:"sample_size"`
 }
 
 /*** FILESYSTEM FUNCTIONS ***/
@@ -141,6 +187,10 @@ func NewFs(ctx context.Context, name, rpath string, m configmap.Mapper) (fs.Fs,
 		return nil, err
 	}
 
+	if opt.SampleSize <= 0 {
+		return nil, errors.New("compress: sample_size must be greater than 0")
+	}
+
 	remote := opt.Remote
 	if strings.HasPrefix(remote, name+":") {
 		return nil, errors.New("can't point press remote at itself - check the value of the remote setting")
@@ -216,6 +266,10 @@ func compressionModeFromName(name string) int {
 	switch name {
 	case "gzip":
 		return Gzip
+	case "zstd":
+		return Zstd
+	case "lz4":
+		return Lz4
 	default:
 		return Uncompressed
 	}
@@ -258,7 +312,7 @@ func processFileName(compressedFileName string) (origFileName string, extension
 	if err != nil {
 		return "", "", 0, errors.New("could not decode size")
 	}
-	return match[1], gzFileExt, size, nil
+	return match[1], extension, size, nil
 }
 
 // Generates the file name for a metadata file
@@ -283,10 +337,15 @@ func unwrapMetadataFile(filename string) (string, bool) {
 
 // makeDataName generates the file name for a data file with specified compression mode
 func makeDataName(remote string, size int64, mode int) (newRemote string) {
-	if mode != Uncompressed {
-		newRemote = remote + "." + int64ToBase64(size) + gzFileExt
-	} else {
+	switch mode {
+	case Uncompressed:
 		newRemote = remote + uncompressedFileExt
+	case Zstd:
+		newRemote = remote + "." + int64ToBase64(size) + zstdFileExt
+	case Lz4:
+		newRemote = remote + "." + int64ToBase64(size) + lz4FileExt
+	default:
+		newRemote = remote + "." + int64ToBase64(size) + gzFileExt
 	}
 	return newRemote
 }
@@ -406,41 +465,50 @@ func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
 
 // checkCompressAndType checks if an object is compressible and determines it's mime type
 // returns a multireader with the bytes that were read to determine mime type
-func checkCompressAndType(in io.Reader) (newReader io.Reader, compressible bool, mimeType string, err error) {
+//
+// ratio is the compression ratio of the sample or 0 if it wasn't
+// measured.
+func (f *Fs) checkCompressAndType(in io.Reader) (newReader io.Reader, compressible bool, ratio float64, mimeType string, err error) {
 	in, wrap := accounting.UnWrap(in)
-	buf := make([]byte, heuristicBytes)
-	n, err := in.Read(buf)
+	buf := make([]byte, f.opt.SampleSize)
+	n, err := io.ReadFull(in, buf)
 	buf = buf[:n]
-	if err != nil && err != io.EOF {
-		return nil, false, "", err
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, false, 0, "", err
 	}
 	mime := mimetype.Detect(buf)
-	compressible, err = isCompressible(bytes.NewReader(buf))
-	if err != nil {
-		return nil, false, "", err
+	compressible = true
+	if f.opt.MinRatio > 0 {
+		ratio, err = f.compressionRatio(buf)
+		if err != nil {
+			return nil, false, 0, "", err
+		}
+		compressible = ratio >= f.opt.MinRatio
 	}
 	in = io.MultiReader(bytes.NewReader(buf), in)
-	return wrap(in), compressible, mime.String(), nil
+	return wrap(in), compressible, ratio, mime.String(), nil
 }
 
-// isCompressible checks the compression ratio of the provided data and returns true if the ratio exceeds
-// the configured threshold
-func isCompressible(r io.Reader) (bool, error) {
+// compressionRatio returns the size of data divided by its size when
+// compressed with the mode of f
+func (f *Fs) compressionRatio(data []byte) (float64, error) {
 	var b bytes.Buffer
-	w, err := sgzip.NewWriterLevel(&b, sgzip.DefaultCompression)
+	w, err := f.newCompressor(&b)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
-	n, err := io.Copy(w, r)
+	_, err = w.Write(data)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 	err = w.Close()
 	if err != nil {
-		return false, err
+		return 0, err
+	}
+	if b.Len() == 0 {
+		return 0, nil
 	}
-	ratio := float64(n) / float64(b.Len())
-	return ratio > minCompressionRatio, nil
+	return float64(len(data)) / float64(b.Len()), nil
 }
 
 // verifyObjectHash verifies the Objects hash
@@ -468,6 +536,24 @@ type compressionResult struct {
 	meta sgzip.GzipMetadata
 }
 
+// compressor compresses data written to it and returns the metadata
+// needed to read the compressed data from an offset
+type compressor interface {
+	io.WriteCloser
+	MetaData() sgzip.GzipMetadata
+}
+
+// newCompressor returns a compressor for the mode of f writing to w
+func (f *Fs) newCompressor(w io.Writer) (compressor, error) {
+	switch f.mode {
+	case Zstd:
+		return newZstdWriter(w, f.opt.CompressionLevel)
+	case Lz4:
+		return newLz4Writer(w, f.opt.CompressionLevel)
+	}
+	return sgzip.NewWriterLevel(w, f.opt.CompressionLevel)
+}
+
 // replicating some of operations.Rcat functionality because we want to support remotes without streaming
 // support and of course cannot know the size of a compressed file before compressing it.
 func (f *Fs) rcat(ctx context.Context, dstFileName string, in io.ReadCloser, modTime time.Time, options []fs.OpenOption) (o fs.Object, err error) {
@@ -528,8 +614,9 @@ func (f *Fs) putCompress(ctx context.Context, in io.Reader, src fs.ObjectInfo, o
 	pipeReader, pipeWriter := io.Pipe()
 	results := make(chan compressionResult)
 	go func() {
-		gz, err := sgzip.NewWriterLevel(pipeWriter, f.opt.CompressionLevel)
+		gz, err := f.newCompressor(pipeWriter)
 		if err != nil {
+			_ = pipeWriter.CloseWithError(err)
 			results <- compressionResult{err: err, meta: sgzip.GzipMetadata{}}
 			return
 		}
@@ -681,7 +768,7 @@ func (f *Fs) putMetadata(ctx context.Context, meta *ObjectMetadata, src fs.Objec
 // The putData function will only be used when the object is not compressible if the
 // data is compressible this parameter will be ignored.
 func (f *Fs) putWithCustomFunctions(ctx context.Context, in io.Reader, src fs.ObjectInfo, options []fs.OpenOption,
-	putData putFn, putMeta putFn, compressible bool, mimeType string) (*Object, error) {
+	putData putFn, putMeta putFn, compressible bool, ratio float64, mimeType string) (*Object, error) {
 	// Put file then metadata
 	var dataObject fs.Object
 	var meta *ObjectMetadata
@@ -689,11 +776,13 @@ func (f *Fs) putWithCustomFunctions(ctx context.Context, in io.Reader, src fs.Ob
 	if compressible {
 		dataObject, meta, err = f.putCompress(ctx, in, src, options, mimeType)
 	} else {
+		fs.Debugf(src, "Storing uncompressed as the sample compression ratio %.2f is below %.2f", ratio, f.opt.MinRatio)
 		dataObject, meta, err = f.putUncompress(ctx, in, src, putData, options, mimeType)
 	}
 	if err != nil {
 		return nil, err
 	}
+	meta.SampleRatio = ratio
 
 	mo, err := f.putMetadata(ctx, meta, src, options, putMeta)
 
@@ -720,11 +809,11 @@ func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options .
 	o, err := f.NewObject(ctx, src.Remote())
 	if err == fs.ErrorObjectNotFound {
 		// Get our file compressibility
-		in, compressible, mimeType, err := checkCompressAndType(in)
+		in, compressible, ratio, mimeType, err := f.checkCompressAndType(in)
 		if err != nil {
 			return nil, err
 		}
-		return f.putWithCustomFunctions(ctx, in, src, options, f.Fs.Put, f.Fs.Put, compressible, mimeType)
+		return f.putWithCustomFunctions(ctx, in, src, options, f.Fs.Put, f.Fs.Put, compressible, ratio, mimeType)
 	}
 	if err != nil {
 		return nil, err
@@ -740,11 +829,11 @@ func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, opt
 	}
 	found := err == nil
 
-	in, compressible, mimeType, err := checkCompressAndType(in)
+	in, compressible, ratio, mimeType, err := f.checkCompressAndType(in)
 	if err != nil {
 		return nil, err
 	}
-	newObj, err := f.putWithCustomFunctions(ctx, in, src, options, f.Fs.Features().PutStream, f.Fs.Put, compressible, mimeType)
+	newObj, err := f.putWithCustomFunctions(ctx, in, src, options, f.Fs.Features().PutStream, f.Fs.Put, compressible, ratio, mimeType)
 	if err != nil {
 		return nil, err
 	}
@@ -1064,6 +1153,7 @@ type ObjectMetadata struct {
 	MD5                 string // MD5 hash of the file.
 	MimeType            string // Mime type of the file
 	CompressionMetadata sgzip.GzipMetadata
+	SampleRatio         float64 `json:",omitempty"` // Compression ratio of the sample used to choose the mode, 0 if not sampled
 }
 
 // Object with external metadata
@@ -1134,7 +1224,7 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		return o.mo, o.mo.Update(ctx, in, src, options...)
 	}
 
-	in, compressible, mimeType, err := checkCompressAndType(in)
+	in, compressible, ratio, mimeType, err := o.f.checkCompressAndType(in)
 	if err != nil {
 		return err
 	}
@@ -1144,7 +1234,7 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	var newObject *Object
 	origName := o.Remote()
 	if o.meta.Mode != Uncompressed || compressible {
-		newObject, err = o.f.putWithCustomFunctions(ctx, in, o.f.wrapInfo(src, origName, src.Size()), options, o.f.Fs.Put, updateMeta, compressible, mimeType)
+		newObject, err = o.f.putWithCustomFunctions(ctx, in, o.f.wrapInfo(src, origName, src.Size()), options, o.f.Fs.Put, updateMeta, compressible, ratio, mimeType)
 		if err != nil {
 			return err
 		}
@@ -1160,7 +1250,7 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 			return o.Object, o.Object.Update(ctx, in, src, options...)
 		}
 		// If we are, just update the object and metadata
-		newObject, err = o.f.putWithCustomFunctions(ctx, in, src, options, update, updateMeta, compressible, mimeType)
+		newObject, err = o.f.putWithCustomFunctions(ctx, in, src, options, update, updateMeta, compressible, ratio, mimeType)
 		if err != nil {
 			return err
 		}
@@ -1366,12 +1456,25 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (rc io.Read
 	chunkedReader := chunkedreader.New(ctx, o.Object, initialChunkSize, maxChunkSize, chunkStreams)
 	// Get file handle
 	var file io.Reader
-	if offset != 0 {
+	var closer io.Closer = chunkedReader
+	switch {
+	case o.meta.Mode == Zstd:
+		var zr io.ReadCloser
+		zr, err = newZstdReader(chunkedReader, &o.meta.CompressionMetadata, offset)
+		file = zr
+		closer = multiCloser{zr, chunkedReader}
+	case o.meta.Mode == Lz4:
+		var lr io.ReadCloser
+		lr, err = newLz4Reader(chunkedReader, &o.meta.CompressionMetadata, offset)
+		file = lr
+		closer = multiCloser{lr, chunkedReader}
+	case offset != 0:
 		file, err = sgzip.NewReaderAt(chunkedReader, &o.meta.CompressionMetadata, offset)
-	} else {
+	default:
 		file, err = sgzip.NewReader(chunkedReader)
 	}
 	if err != nil {
+		_ = chunkedReader.Close()
 		return nil, err
 	}
 
@@ -1382,7 +1485,20 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (rc io.Read
 		fileReader = file
 	}
 	// Return a ReadCloser
-	return ReadCloserWrapper{Reader: fileReader, Closer: chunkedReader}, nil
+	return ReadCloserWrapper{Reader: fileReader, Closer: closer}, nil
+}
+
+// multiCloser closes all its Closers returning the first error
+type multiCloser []io.Closer
+
+// Close all the Closers
+func (mc multiCloser) Close() (err error) {
+	for _, c := range mc {
+		if closeErr := c.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
 
 // ObjectInfo describes a wrapped fs.ObjectInfo for being the source
@@ -2,16 +2,26 @@
 package compress
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	_ "github.com/rclone/rclone/backend/drive"
 	_ "github.com/rclone/rclone/backend/local"
 	_ "github.com/rclone/rclone/backend/s3"
 	_ "github.com/rclone/rclone/backend/swift"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/object"
 	"github.com/rclone/rclone/fstest"
 	"github.com/rclone/rclone/fstest/fstests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var defaultOpt = fstests.Opt{
@@ -53,3 +63,164 @@ func TestRemoteGzip(t *testing.T) {
 	opt.QuickTestOK = true
 	fstests.Run(t, &opt)
 }
+
+// TestRemoteZstd tests Zstandard compression
+func TestRemoteZstd(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	tempdir := filepath.Join(os.TempDir(), "rclone-compress-test-zstd")
+	name := "TestCompressZstd"
+	opt := defaultOpt
+	opt.RemoteName = name + ":"
+	opt.ExtraConfig = []fstests.ExtraConfigItem{
+		{Name: name, Key: "type", Value: "compress"},
+		{Name: name, Key: "remote", Value: tempdir},
+		{Name: name, Key: "mode", Value: "zstd"},
+	}
+	opt.QuickTestOK = true
+	fstests.Run(t, &opt)
+}
+
+// TestRemoteLz4 tests LZ4 compression
+func TestRemoteLz4(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	tempdir := filepath.Join(os.TempDir(), "rclone-compress-test-lz4")
+	name := "TestCompressLz4"
+	opt := defaultOpt
+	opt.RemoteName = name + ":"
+	opt.ExtraConfig = []fstests.ExtraConfigItem{
+		{Name: name, Key: "type", Value: "compress"},
+		{Name: name, Key: "remote", Value: tempdir},
+		{Name: name, Key: "mode", Value: "lz4"},
+	}
+	opt.QuickTestOK = true
+	fstests.Run(t, &opt)
+}
+
+// newTestFs makes a compress remote of dir with the config key value
+// pairs given
+func newTestFs(t *testing.T, dir string, config ...string) fs.Fs {
+	remote := fmt.Sprintf(":compress,remote=%q", dir)
+	for i := 0; i+1 < len(config); i += 2 {
+		remote += fmt.Sprintf(",%s=%q", config[i], config[i+1])
+	}
+	f, err := fs.NewFs(context.Background(), remote+":")
+	require.NoError(t, err)
+	return f
+}
+
+// TestMixedModes checks files compressed with one mode can be read
+// by a remote using another, including ranged reads
+func TestMixedModes(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	newFs := func(mode string) fs.Fs {
+		return newTestFs(t, dir, "mode", mode)
+	}
+	gzipFs, zstdFs, lz4Fs := newFs("gzip"), newFs("zstd"), newFs("lz4")
+	fses := []fs.Fs{gzipFs, zstdFs, lz4Fs}
+	names := map[fs.Fs]string{gzipFs: "file-gzip", zstdFs: "file-zstd", lz4Fs: "file-lz4"}
+
+	// Compressible data spanning several frames
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < 2*frameSize+12345; i++ {
+		_, _ = fmt.Fprintf(&buf, "%08d the quick brown fox jumped over the lazy dog\n", i)
+	}
+	data := buf.Bytes()
+	modTime := time.Now()
+	for _, f := range fses {
+		src := object.NewStaticObjectInfo(names[f], modTime, int64(len(data)), true, nil, nil)
+		_, err := f.Put(ctx, bytes.NewReader(data), src)
+		require.NoError(t, err)
+	}
+	_, err := os.Stat(filepath.Join(dir, makeDataName("file-gzip", int64(len(data)), Gzip)))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, makeDataName("file-zstd", int64(len(data)), Zstd)))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, makeDataName("file-lz4", int64(len(data)), Lz4)))
+	require.NoError(t, err)
+
+	for _, f := range fses {
+		for _, remote := range []string{"file-gzip", "file-zstd", "file-lz4"} {
+			o, err := f.NewObject(ctx, remote)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(data)), o.Size())
+			for _, test := range []struct {
+				start, end int64
+			}{
+				{0, -1},
+				{10, 100},
+				{frameSize - 10, frameSize + 10},
+				{2*frameSize + 1, -1},
+				{int64(len(data)) - 1, -1},
+			} {
+				what := fmt.Sprintf("%s reading %s from %d to %d", f.Name(), remote, test.start, test.end)
+				in, err := o.Open(ctx, &fs.RangeOption{Start: test.start, End: test.end})
+				require.NoError(t, err, what)
+				got, err := io.ReadAll(in)
+				require.NoError(t, err, what)
+				require.NoError(t, in.Close(), what)
+				end := test.end + 1
+				if test.end < 0 {
+					end = int64(len(data))
+				}
+				assert.True(t, bytes.Equal(data[test.start:end], got), what)
+			}
+		}
+	}
+}
+
+// TestSampling checks the decision whether to compress a file
+func TestSampling(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	text := bytes.Repeat([]byte("the quick brown fox jumped over the lazy dog\n"), 10000)
+	// Random bytes rather than random text as that compresses a bit
+	random := make([]byte, len(text))
+	_, err := rand.Read(random)
+	require.NoError(t, err)
+	for _, test := range []struct {
+		mode     string
+		minRatio string
+		data     []byte
+		wantMode int
+	}{
+		{mode: "gzip", minRatio: "1.1", data: text, wantMode: Gzip},
+		{mode: "zstd", minRatio: "1.1", data: text, wantMode: Zstd},
+		{mode: "gzip", minRatio: "1.1", data: random, wantMode: Uncompressed},
+		{mode: "zstd", minRatio: "1.1", data: random, wantMode: Uncompressed},
+		{mode: "zstd", minRatio: "0", data: random, wantMode: Zstd},
+		{mode: "zstd", minRatio: "1000", data: text, wantMode: Uncompressed},
+		{mode: "lz4", minRatio: "1.1", data: text, wantMode: Lz4},
+		{mode: "lz4", minRatio: "1.1", data: random, wantMode: Uncompressed},
+	} {
+		what := fmt.Sprintf("mode=%s min_compression_ratio=%s", test.mode, test.minRatio)
+		f := newTestFs(t, dir, "mode", test.mode, "min_compression_ratio", test.minRatio, "sample_size", "4k")
+		src := object.NewStaticObjectInfo("file", time.Now(), int64(len(test.data)), true, nil, nil)
+		o, err := f.Put(ctx, bytes.NewReader(test.data), src)
+		require.NoError(t, err, what)
+		meta := o.(*Object).meta
+		assert.Equal(t, test.wantMode, meta.Mode, what)
+		if test.minRatio == "0" {
+			assert.Equal(t, 0.0, meta.SampleRatio, what)
+		} else {
+			assert.NotEqual(t, 0.0, meta.SampleRatio, what)
+		}
+
+		// Check the decision was stored in the metadata
+		o, err = f.NewObject(ctx, "file")
+		require.NoError(t, err, what)
+		require.NoError(t, o.(*Object).loadMetadataIfNotLoaded(ctx), what)
+		assert.Equal(t, test.wantMode, o.(*Object).meta.Mode, what)
+		in, err := o.Open(ctx)
+		require.NoError(t, err, what)
+		got, err := io.ReadAll(in)
+		require.NoError(t, err, what)
+		require.NoError(t, in.Close(), what)
+		assert.True(t, bytes.Equal(test.data, got), what)
+		require.NoError(t, o.Remove(ctx), what)
+	}
+}
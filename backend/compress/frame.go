@@ -0,0 +1,238 @@
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/buengese/sgzip"
+)
+
+// frameSize is the amount of uncompressed data in each frame of the
+// zstd and lz4 modes.
+//
+// Each frame is compressed independently so a ranged read only needs
+// to decompress from the start of the frame containing the offset.
+const frameSize = 1048576
+
+// frameWriter compresses data into a series of independent frames
+// each holding frameSize bytes of uncompressed data (except the
+// last).
+//
+// The metadata it produces has the same layout as that of sgzip so
+// it is stored in the same place, but BlockData holds the compressed
+// size of each frame.
+type frameWriter struct {
+	w         io.Writer
+	name      string                                // name of the compression for errors
+	encode    func(dst, src []byte) ([]byte, error) // compress src into a frame appended to dst
+	release   func() error                          // release the encoder if set
+	buf       []byte                                // uncompressed data for the current frame
+	out       []byte                                // compressed frame
+	size      int64                                 // total uncompressed size
+	blockData []uint32                              // compressed size of each frame
+	err       error
+}
+
+// newFrameWriter makes a frameWriter writing to w which compresses
+// each frame with encode
+func newFrameWriter(w io.Writer, name string, encode func(dst, src []byte) ([]byte, error), release func() error) *frameWriter {
+	return &frameWriter{
+		w:       w,
+		name:    name,
+		encode:  encode,
+		release: release,
+		buf:     make([]byte, 0, frameSize),
+	}
+}
+
+// flush compresses the buffered data as a frame and writes it
+func (z *frameWriter) flush() (err error) {
+	if len(z.buf) == 0 {
+		return nil
+	}
+	z.out, err = z.encode(z.out[:0], z.buf)
+	if err != nil {
+		return fmt.Errorf("%s: failed to compress frame: %w", z.name, err)
+	}
+	_, err = z.w.Write(z.out)
+	if err != nil {
+		return err
+	}
+	z.blockData = append(z.blockData, uint32(len(z.out)))
+	z.buf = z.buf[:0]
+	return nil
+}
+
+// Write compresses p
+func (z *frameWriter) Write(p []byte) (n int, err error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	for len(p) > 0 {
+		chunk := min(len(p), frameSize-len(z.buf))
+		z.buf = append(z.buf, p[:chunk]...)
+		p = p[chunk:]
+		n += chunk
+		z.size += int64(chunk)
+		if len(z.buf) == frameSize {
+			if z.err = z.flush(); z.err != nil {
+				return n, z.err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close writes the last frame but doesn't close the underlying writer
+func (z *frameWriter) Close() error {
+	if z.err == nil {
+		z.err = z.flush()
+	}
+	var closeErr error
+	if z.release != nil {
+		closeErr = z.release()
+		z.release = nil
+	}
+	if z.err != nil {
+		return z.err
+	}
+	z.err = fmt.Errorf("%s: writer is closed", z.name)
+	return closeErr
+}
+
+// MetaData returns the metadata needed to read from an offset
+func (z *frameWriter) MetaData() sgzip.GzipMetadata {
+	return sgzip.GzipMetadata{
+		BlockSize: frameSize,
+		Size:      z.size,
+		BlockData: z.blockData,
+	}
+}
+
+// frameReader decompresses a series of frames written by a
+// frameWriter one frame at a time
+type frameReader struct {
+	r       io.Reader
+	name    string                      // name of the compression for errors
+	decode  func(dst, src []byte) error // decompress the frame src filling dst
+	release func() error                // release the decoder if set
+	meta    *sgzip.GzipMetadata
+	frame   int    // index of the next frame to read
+	in      []byte // compressed frame
+	buf     []byte // uncompressed frame
+	data    []byte // unread part of buf
+}
+
+// newFrameReader returns a reader for the uncompressed data from
+// offset onwards in the frames in r described by meta, decompressing
+// each frame with decode.
+//
+// It seeks r to the start of the frame containing offset. If it
+// returns an error release has been called.
+func newFrameReader(r io.ReadSeeker, meta *sgzip.GzipMetadata, offset int64, name string, decode func(dst, src []byte) error, release func() error) (rc io.ReadCloser, err error) {
+	defer func() {
+		if err != nil && release != nil {
+			_ = release()
+		}
+	}()
+	if offset >= meta.Size {
+		if release != nil {
+			_ = release()
+		}
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	if meta.BlockSize <= 0 {
+		return nil, fmt.Errorf("%s: invalid block size in metadata", name)
+	}
+	frame := offset / int64(meta.BlockSize)
+	if frame >= int64(len(meta.BlockData)) {
+		return nil, fmt.Errorf("%s: offset beyond the end of the block data in metadata", name)
+	}
+	var start int64
+	for _, size := range meta.BlockData[:frame] {
+		start += int64(size)
+	}
+	if start != 0 {
+		_, err = r.Seek(start, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fr := &frameReader{
+		r:       r,
+		name:    name,
+		decode:  decode,
+		release: release,
+		meta:    meta,
+		frame:   int(frame),
+	}
+	// Skip to the offset in the frame
+	skip := offset - frame*int64(meta.BlockSize)
+	err = fr.next()
+	if err != nil {
+		return nil, err
+	}
+	fr.data = fr.data[skip:]
+	return fr, nil
+}
+
+// next reads and decompresses the next frame into fr.data
+func (fr *frameReader) next() error {
+	if fr.frame >= len(fr.meta.BlockData) {
+		return io.EOF
+	}
+	size := min(int64(fr.meta.BlockSize), fr.meta.Size-int64(fr.frame)*int64(fr.meta.BlockSize))
+	if size <= 0 {
+		return errors.New(fr.name + ": more frames than the size in metadata")
+	}
+	fr.in = grow(fr.in, int(fr.meta.BlockData[fr.frame]))
+	_, err := io.ReadFull(fr.r, fr.in)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("%s: failed to read frame %d: %w", fr.name, fr.frame, err)
+	}
+	fr.buf = grow(fr.buf, int(size))
+	err = fr.decode(fr.buf, fr.in)
+	if err != nil {
+		return fmt.Errorf("%s: failed to decompress frame %d: %w", fr.name, fr.frame, err)
+	}
+	fr.data = fr.buf
+	fr.frame++
+	return nil
+}
+
+// grow returns b resized to n bytes, reallocating if necessary
+func grow(b []byte, n int) []byte {
+	if cap(b) < n {
+		return make([]byte, n)
+	}
+	return b[:n]
+}
+
+// Read reads uncompressed data into p
+func (fr *frameReader) Read(p []byte) (n int, err error) {
+	for len(fr.data) == 0 {
+		err = fr.next()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n = copy(p, fr.data)
+	fr.data = fr.data[n:]
+	return n, nil
+}
+
+// Close releases the resources of the decoder but doesn't close the
+// underlying reader
+func (fr *frameReader) Close() error {
+	if fr.release == nil {
+		return nil
+	}
+	err := fr.release()
+	fr.release = nil
+	return err
+}
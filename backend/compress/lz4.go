@@ -0,0 +1,72 @@
+package compress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/buengese/sgzip"
+	"github.com/pierrec/lz4/v4"
+)
+
+// lz4Levels are the lz4 compression levels indexed by level
+var lz4Levels = []lz4.CompressionLevel{
+	lz4.Fast, lz4.Level1, lz4.Level2, lz4.Level3, lz4.Level4,
+	lz4.Level5, lz4.Level6, lz4.Level7, lz4.Level8, lz4.Level9,
+}
+
+// newLz4Writer makes a frameWriter writing lz4 frames to w at the lz4
+// level given. Levels below 1 use the fast compressor and levels above
+// 9 are the same as 9.
+func newLz4Writer(w io.Writer, level int) (*frameWriter, error) {
+	level = max(0, min(level, len(lz4Levels)-1))
+	enc := lz4.NewWriter(nil)
+	err := enc.Apply(
+		lz4.BlockSizeOption(lz4.Block1Mb),
+		lz4.CompressionLevelOption(lz4Levels[level]),
+		lz4.ConcurrencyOption(1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make lz4 encoder: %w", err)
+	}
+	encode := func(dst, src []byte) ([]byte, error) {
+		out := bytes.NewBuffer(dst)
+		enc.Reset(out)
+		_, err := enc.Write(src)
+		if err != nil {
+			return nil, err
+		}
+		err = enc.Close()
+		if err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+	return newFrameWriter(w, "lz4", encode, nil), nil
+}
+
+// newLz4Reader returns a reader for the uncompressed data from
+// offset onwards in the lz4 frames in r described by meta.
+//
+// The lz4 reader stops at the end of each frame so the frames are
+// decompressed one by one.
+func newLz4Reader(r io.ReadSeeker, meta *sgzip.GzipMetadata, offset int64) (io.ReadCloser, error) {
+	dec := lz4.NewReader(nil)
+	decode := func(dst, src []byte) error {
+		dec.Reset(bytes.NewReader(src))
+		_, err := io.ReadFull(dec, dst)
+		if err != nil {
+			return err
+		}
+		// Read the end of the frame to check its checksum
+		var extra [1]byte
+		_, err = dec.Read(extra[:])
+		if err == nil {
+			return fmt.Errorf("frame has more than %d bytes", len(dst))
+		} else if err != io.EOF {
+			return err
+		}
+		return nil
+	}
+	return newFrameReader(r, meta, offset, "lz4", decode, nil)
+}
@@ -0,0 +1,57 @@
+package compress
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/buengese/sgzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// newZstdWriter makes a frameWriter writing zstd frames to w at the
+// zstd level given. Levels below 1 use the default level.
+func newZstdWriter(w io.Writer, level int) (*frameWriter, error) {
+	encLevel := zstd.SpeedDefault
+	if level > 0 {
+		encLevel = zstd.EncoderLevelFromZstd(level)
+	}
+	enc, err := zstd.NewWriter(nil,
+		zstd.WithEncoderLevel(encLevel),
+		zstd.WithEncoderConcurrency(1),
+		zstd.WithWindowSize(frameSize),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make zstd encoder: %w", err)
+	}
+	encode := func(dst, src []byte) ([]byte, error) {
+		return enc.EncodeAll(src, dst), nil
+	}
+	return newFrameWriter(w, "zstd", encode, enc.Close), nil
+}
+
+// newZstdReader returns a reader for the uncompressed data from
+// offset onwards in the zstd frames in r described by meta.
+func newZstdReader(r io.ReadSeeker, meta *sgzip.GzipMetadata, offset int64) (io.ReadCloser, error) {
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to make zstd decoder: %w", err)
+	}
+	decode := func(dst, src []byte) error {
+		out, err := dec.DecodeAll(src, dst[:0])
+		if err != nil {
+			return err
+		}
+		if len(out) != len(dst) {
+			return fmt.Errorf("frame has %d bytes, expecting %d", len(out), len(dst))
+		}
+		if &out[0] != &dst[0] {
+			copy(dst, out)
+		}
+		return nil
+	}
+	closeDec := func() error {
+		dec.Close()
+		return nil
+	}
+	return newFrameReader(r, meta, offset, "zstd", decode, closeDec)
+}
@@ -6,8 +6,11 @@ import (
 	"crypto/aes"
 	gocipher "crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base32"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -24,6 +27,7 @@ import (
 	"github.com/rclone/rclone/lib/readers"
 	"github.com/rclone/rclone/lib/version"
 	"github.com/rfjakob/eme"
+	"golang.org/x/crypto/hkdf"
 	"golang.org/x/crypto/nacl/secretbox"
 	"golang.org/x/crypto/scrypt"
 )
@@ -32,9 +36,14 @@ import (
 const (
 	nameCipherBlockSize = aes.BlockSize
 	fileMagic           = "RCLONE\x00\x00"
+	fileMagicV2         = "RCLONE\x00\x01"
 	fileMagicSize       = len(fileMagic)
 	fileNonceSize       = 24
 	fileHeaderSize      = fileMagicSize + fileNonceSize
+	fileSeedSize        = 16
+	fileWrappedSeedSize = fileSeedSize + keyWrapBlockSize
+	keyWrapBlockSize    = 8
+	masterKeySize       = 32
 	blockHeaderSize     = secretbox.Overhead
 	blockDataSize       = 64 * 1024
 	blockSize           = blockHeaderSize + blockDataSize
@@ -56,13 +65,20 @@ var (
 	ErrorNotAnEncryptedFile      = errors.New("not an encrypted file - does not match suffix")
 	ErrorBadSeek                 = errors.New("Seek beyond end of file")
 	ErrorSuffixMissingDot        = errors.New("suffix config setting should include a '.'")
+	ErrorNoMasterKey             = errors.New("file is encrypted with a master key but master_password is not set")
+	ErrorBadMasterKey            = errors.New("failed to unwrap file key - bad master password?")
+	ErrorOriginalFormat          = errors.New("file was written without a master key so its key can't be rotated")
 	defaultSalt                  = []byte{0xA8, 0x0D, 0xF4, 0x3A, 0x8F, 0xBD, 0x03, 0x08, 0xA7, 0xCA, 0xB8, 0x3E, 0x58, 0x1F, 0x86, 0xB1}
 	obfuscQuoteRune              = '!'
 )
 
 // Global variables
 var (
-	fileMagicBytes = []byte(fileMagic)
+	fileMagicBytes   = []byte(fileMagic)
+	fileMagicV2Bytes = []byte(fileMagicV2)
+	keyWrapIV        = [keyWrapBlockSize]byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+	masterKeyInfo    = []byte("rclone crypt master key")
+	fileKeyInfo      = []byte("rclone crypt file key")
 )
 
 // ReadSeekCloser is the interface of the read handles
@@ -170,17 +186,19 @@ func NewNameEncoding(s string) (enc fileNameEncoding, err error) {
 
 // Cipher defines an encoding and decoding cipher for the crypt backend
 type Cipher struct {
-	dataKey         [32]byte                  // Key for secretbox
-	nameKey         [32]byte                  // 16,24 or 32 bytes
-	nameTweak       [nameCipherBlockSize]byte // used to tweak the name crypto
-	block           gocipher.Block
-	mode            NameEncryptionMode
-	fileNameEnc     fileNameEncoding
-	buffers         sync.Pool // encrypt/decrypt buffers
-	cryptoRand      io.Reader // read crypto random numbers from here
-	dirNameEncrypt  bool
-	passBadBlocks   bool // if set passed bad blocks as zeroed blocks
-	encryptedSuffix string
+	dataKey           [32]byte                  // Key for secretbox
+	nameKey           [32]byte                  // 16,24 or 32 bytes
+	nameTweak         [nameCipherBlockSize]byte // used to tweak the name crypto
+	block             gocipher.Block
+	masterKey         gocipher.Block // if set, wraps the keys of new files
+	previousMasterKey gocipher.Block // if set, unwraps the keys of files not rotated yet
+	mode              NameEncryptionMode
+	fileNameEnc       fileNameEncoding
+	buffers           sync.Pool // encrypt/decrypt buffers
+	cryptoRand        io.Reader // read crypto random numbers from here
+	dirNameEncrypt    bool
+	passBadBlocks     bool // if set passed bad blocks as zeroed blocks
+	encryptedSuffix   string
 }
 
 // newCipher initialises the cipher.  If salt is "" then it uses a built in salt val
@@ -251,6 +269,50 @@ func (c *Cipher) Key(password, salt string) (err error) {
 	return err
 }
 
+// setMasterKeys creates the master keys from the passwords passed in
+// using scrypt.
+//
+// If password is "" then new files are written in the original format
+// with the data key made by Key. Otherwise each new file gets its own
+// key which is stored in its header wrapped with the master key.
+//
+// previousPassword is used to read files whose keys are wrapped with
+// the master key before it was rotated.
+func (c *Cipher) setMasterKeys(password, previousPassword, salt string) (err error) {
+	if password == "" {
+		if previousPassword != "" {
+			return errors.New("previous master password set without master password")
+		}
+		c.masterKey, c.previousMasterKey = nil, nil
+		return nil
+	}
+	var saltBytes = defaultSalt
+	if salt != "" {
+		saltBytes = []byte(salt)
+	}
+	// Use a different salt from Key so the master key is different
+	// from the data key even if the passwords are the same
+	saltBytes = append(append([]byte{}, saltBytes...), masterKeyInfo...)
+	c.masterKey, err = newMasterKey(password, saltBytes)
+	if err != nil {
+		return err
+	}
+	c.previousMasterKey = nil
+	if previousPassword != "" {
+		c.previousMasterKey, err = newMasterKey(previousPassword, saltBytes)
+	}
+	return err
+}
+
+// newMasterKey makes an AES-256 cipher keyed from password
+func newMasterKey(password string, salt []byte) (gocipher.Block, error) {
+	key, err := scrypt.Key([]byte(password), salt, 16384, 8, 1, masterKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return aes.NewCipher(key)
+}
+
 // getBlock gets a block from the pool of size blockSize
 func (c *Cipher) getBlock() *[blockSize]byte {
 	return c.buffers.Get().(*[blockSize]byte)
@@ -677,11 +739,185 @@ func (n *nonce) add(x uint64) {
 	}
 }
 
+// keyWrap wraps key with kek using the AES Key Wrap algorithm from
+// RFC 3394.
+//
+// key must be a multiple of 8 bytes long and at least 16 bytes long.
+func keyWrap(kek gocipher.Block, key []byte) []byte {
+	n := len(key) / keyWrapBlockSize
+	out := make([]byte, keyWrapBlockSize+len(key))
+	a := out[:keyWrapBlockSize]
+	copy(a, keyWrapIV[:])
+	copy(out[keyWrapBlockSize:], key)
+	var b [aes.BlockSize]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			r := out[i*keyWrapBlockSize : (i+1)*keyWrapBlockSize]
+			copy(b[:keyWrapBlockSize], a)
+			copy(b[keyWrapBlockSize:], r)
+			kek.Encrypt(b[:], b[:])
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(b[:keyWrapBlockSize])^t)
+			copy(r, b[keyWrapBlockSize:])
+		}
+	}
+	return out
+}
+
+// keyUnwrap unwraps wrapped with kek using the AES Key Wrap algorithm
+// from RFC 3394.
+//
+// It returns false if the integrity check fails which means wrapped
+// wasn't wrapped with kek or is corrupted.
+func keyUnwrap(kek gocipher.Block, wrapped []byte) (key []byte, ok bool) {
+	n := len(wrapped)/keyWrapBlockSize - 1
+	if n < 2 || len(wrapped)%keyWrapBlockSize != 0 {
+		return nil, false
+	}
+	var a [keyWrapBlockSize]byte
+	copy(a[:], wrapped)
+	key = make([]byte, n*keyWrapBlockSize)
+	copy(key, wrapped[keyWrapBlockSize:])
+	var b [aes.BlockSize]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			r := key[(i-1)*keyWrapBlockSize : i*keyWrapBlockSize]
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:keyWrapBlockSize], binary.BigEndian.Uint64(a[:])^t)
+			copy(b[keyWrapBlockSize:], r)
+			kek.Decrypt(b[:], b[:])
+			copy(a[:], b[:keyWrapBlockSize])
+			copy(r, b[keyWrapBlockSize:])
+		}
+	}
+	if subtle.ConstantTimeCompare(a[:], keyWrapIV[:]) != 1 {
+		return nil, false
+	}
+	return key, true
+}
+
+// fileHeader is the header at the start of each encrypted file
+//
+// In the original format this is the magic followed by the nonce of
+// the first block and the data is encrypted with the data key made
+// from the password.
+//
+// In version 2 the magic is followed by a random seed for the file
+// wrapped with the master key. The key and the nonce of the first
+// block for the data are derived from the seed, so rotating the
+// master key only means wrapping the seed again.
+type fileHeader [fileHeaderSize]byte
+
+// isV2 returns true if the file key is wrapped with a master key
+func (h *fileHeader) isV2() bool {
+	return bytes.Equal(h[:fileMagicSize], fileMagicV2Bytes)
+}
+
+// zeroHeader returns a header in the original format with a zero nonce
+func zeroHeader() (h fileHeader) {
+	copy(h[:], fileMagicBytes)
+	return h
+}
+
+// newHeader makes the header for a new file
+func (c *Cipher) newHeader() (h fileHeader, err error) {
+	if c.masterKey == nil {
+		var n nonce
+		err = n.fromReader(c.cryptoRand)
+		if err != nil {
+			return h, err
+		}
+		copy(h[:], fileMagicBytes)
+		copy(h[fileMagicSize:], n[:])
+		return h, nil
+	}
+	var seed [fileSeedSize]byte
+	read, err := readers.ReadFill(c.cryptoRand, seed[:])
+	if read != fileSeedSize {
+		return h, fmt.Errorf("short read of file key seed: %w", err)
+	}
+	return c.wrapSeed(seed[:]), nil
+}
+
+// wrapSeed makes a version 2 header with seed wrapped with the
+// master key
+func (c *Cipher) wrapSeed(seed []byte) (h fileHeader) {
+	copy(h[:], fileMagicV2Bytes)
+	copy(h[fileMagicSize:], keyWrap(c.masterKey, seed))
+	return h
+}
+
+// unwrapSeed returns the seed from a version 2 header and whether it
+// was wrapped with the current master key.
+func (c *Cipher) unwrapSeed(h *fileHeader) (seed []byte, current bool, err error) {
+	if c.masterKey == nil {
+		return nil, false, ErrorNoMasterKey
+	}
+	wrapped := h[fileMagicSize : fileMagicSize+fileWrappedSeedSize]
+	if seed, ok := keyUnwrap(c.masterKey, wrapped); ok {
+		return seed, true, nil
+	}
+	if c.previousMasterKey != nil {
+		if seed, ok := keyUnwrap(c.previousMasterKey, wrapped); ok {
+			return seed, false, nil
+		}
+	}
+	return nil, false, ErrorBadMasterKey
+}
+
+// fileKey returns the key and the nonce of the first block for the
+// data of the file with header h
+func (c *Cipher) fileKey(h *fileHeader) (key *[32]byte, n nonce, err error) {
+	switch {
+	case bytes.Equal(h[:fileMagicSize], fileMagicBytes):
+		n.fromBuf(h[fileMagicSize:])
+		return &c.dataKey, n, nil
+	case h.isV2():
+		seed, _, err := c.unwrapSeed(h)
+		if err != nil {
+			return nil, n, err
+		}
+		key = new([32]byte)
+		kdf := hkdf.New(sha256.New, seed, nil, fileKeyInfo)
+		_, err = io.ReadFull(kdf, key[:])
+		if err == nil {
+			_, err = io.ReadFull(kdf, n[:])
+		}
+		if err != nil {
+			return nil, n, fmt.Errorf("failed to derive file key: %w", err)
+		}
+		return key, n, nil
+	}
+	return nil, n, ErrorEncryptedBadMagic
+}
+
+// rotateHeader returns h with the file key wrapped with the current
+// master key.
+//
+// It returns false if h is already wrapped with the current master
+// key. Headers in the original format can't be rotated as the data
+// key is made from the password.
+func (c *Cipher) rotateHeader(h *fileHeader) (newHeader fileHeader, rotated bool, err error) {
+	if !h.isV2() {
+		return *h, false, ErrorOriginalFormat
+	}
+	seed, current, err := c.unwrapSeed(h)
+	if err != nil {
+		return *h, false, err
+	}
+	if current {
+		return *h, false, nil
+	}
+	return c.wrapSeed(seed), true, nil
+}
+
 // encrypter encrypts an io.Reader on the fly
 type encrypter struct {
 	mu       sync.Mutex
 	in       io.Reader
 	c        *Cipher
+	key      *[32]byte
+	header   fileHeader
 	nonce    nonce
 	buf      *[blockSize]byte
 	readBuf  *[blockSize]byte
@@ -691,7 +927,10 @@ type encrypter struct {
 }
 
 // newEncrypter creates a new file handle encrypting on the fly
-func (c *Cipher) newEncrypter(in io.Reader, nonce *nonce) (*encrypter, error) {
+//
+// If header is nil a new one is made, otherwise the data is encrypted
+// with the key and nonce from the header.
+func (c *Cipher) newEncrypter(in io.Reader, header *fileHeader) (*encrypter, error) {
 	fh := &encrypter{
 		in:      in,
 		c:       c,
@@ -699,19 +938,23 @@ func (c *Cipher) newEncrypter(in io.Reader, nonce *nonce) (*encrypter, error) {
 		readBuf: c.getBlock(),
 		bufSize: fileHeaderSize,
 	}
-	// Initialise nonce
-	if nonce != nil {
-		fh.nonce = *nonce
+	// Initialise header
+	var err error
+	if header != nil {
+		fh.header = *header
 	} else {
-		err := fh.nonce.fromReader(c.cryptoRand)
+		fh.header, err = c.newHeader()
 		if err != nil {
 			return nil, err
 		}
 	}
-	// Copy magic into buffer
-	copy((*fh.buf)[:], fileMagicBytes)
-	// Copy nonce into buffer
-	copy((*fh.buf)[fileMagicSize:], fh.nonce[:])
+	// Find the key and the nonce
+	fh.key, fh.nonce, err = c.fileKey(&fh.header)
+	if err != nil {
+		return nil, err
+	}
+	// Copy header into buffer
+	copy((*fh.buf)[:], fh.header[:])
 	return fh, nil
 }
 
@@ -734,7 +977,7 @@ func (fh *encrypter) Read(p []byte) (n int, err error) {
 		// possibly err != nil here, but we will process the
 		// data and the next call to ReadFill will return 0, err
 		// Encrypt the block using the nonce
-		secretbox.Seal((*fh.buf)[:0], readBuf[:n], fh.nonce.pointer(), &fh.c.dataKey)
+		secretbox.Seal((*fh.buf)[:0], readBuf[:n], fh.nonce.pointer(), fh.key)
 		fh.bufIndex = 0
 		fh.bufSize = blockHeaderSize + n
 		fh.nonce.increment()
@@ -777,6 +1020,8 @@ func (c *Cipher) EncryptData(in io.Reader) (io.Reader, error) {
 type decrypter struct {
 	mu           sync.Mutex
 	rc           io.ReadCloser
+	key          *[32]byte
+	header       fileHeader
 	nonce        nonce
 	initialNonce nonce
 	c            *Cipher
@@ -798,7 +1043,7 @@ func (c *Cipher) newDecrypter(rc io.ReadCloser) (*decrypter, error) {
 		readBuf: c.getBlock(),
 		limit:   -1,
 	}
-	// Read file header (magic + nonce)
+	// Read file header (magic + nonce or wrapped key)
 	readBuf := (*fh.readBuf)[:fileHeaderSize]
 	n, err := readers.ReadFill(fh.rc, readBuf)
 	if n < fileHeaderSize && err == io.EOF {
@@ -807,12 +1052,12 @@ func (c *Cipher) newDecrypter(rc io.ReadCloser) (*decrypter, error) {
 	} else if err != io.EOF && err != nil {
 		return nil, fh.finishAndClose(err)
 	}
-	// check the magic
-	if !bytes.Equal(readBuf[:fileMagicSize], fileMagicBytes) {
-		return nil, fh.finishAndClose(ErrorEncryptedBadMagic)
+	// check the magic and retrieve the key and the nonce
+	copy(fh.header[:], readBuf)
+	fh.key, fh.nonce, err = c.fileKey(&fh.header)
+	if err != nil {
+		return nil, fh.finishAndClose(err)
 	}
-	// retrieve the nonce
-	fh.nonce.fromBuf(readBuf[fileMagicSize:])
 	fh.initialNonce = fh.nonce
 	return fh, nil
 }
@@ -877,7 +1122,7 @@ func (fh *decrypter) fillBuffer() (err error) {
 		return ErrorEncryptedFileBadHeader
 	}
 	// Decrypt the block using the nonce
-	_, ok := secretbox.Open((*fh.buf)[:0], (*readBuf)[:n], fh.nonce.pointer(), &fh.c.dataKey)
+	_, ok := secretbox.Open((*fh.buf)[:0], (*readBuf)[:n], fh.nonce.pointer(), fh.key)
 	if !ok {
 		if err != nil && err != io.EOF {
 			return err // return pending error as it is likely more accurate
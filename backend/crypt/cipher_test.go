@@ -3,8 +3,10 @@ package crypt
 import (
 	"bytes"
 	"context"
+	"crypto/aes"
 	"encoding/base32"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -1170,6 +1172,163 @@ func TestEncryptData(t *testing.T) {
 	}
 }
 
+func TestKeyWrap(t *testing.T) {
+	// Test vector from RFC 3394 section 4.6
+	mustHex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		return b
+	}
+	kek, err := aes.NewCipher(mustHex("000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F"))
+	require.NoError(t, err)
+	key := mustHex("00112233445566778899AABBCCDDEEFF")
+	wrapped := keyWrap(kek, key)
+	assert.Equal(t, mustHex("64E8C3F9CE0F5BA263E9777905818A2A93C8191E7D6E8AE7"), wrapped)
+
+	unwrapped, ok := keyUnwrap(kek, wrapped)
+	assert.True(t, ok)
+	assert.Equal(t, key, unwrapped)
+
+	// Corruption should fail the integrity check
+	for i := range wrapped {
+		wrapped[i] ^= 0x1
+		_, ok = keyUnwrap(kek, wrapped)
+		assert.False(t, ok, i)
+		wrapped[i] ^= 0x1
+	}
+
+	// As should the wrong key
+	otherKek, err := aes.NewCipher(make([]byte, 32))
+	require.NoError(t, err)
+	_, ok = keyUnwrap(otherKek, wrapped)
+	assert.False(t, ok)
+
+	// And bad lengths
+	_, ok = keyUnwrap(kek, wrapped[:16])
+	assert.False(t, ok)
+	_, ok = keyUnwrap(kek, wrapped[:23])
+	assert.False(t, ok)
+}
+
+// newMasterKeyCipher makes a cipher with the master passwords given
+func newMasterKeyCipher(t *testing.T, master, previous string) *Cipher {
+	c, err := newCipher(NameEncryptionStandard, "", "", true, nil)
+	require.NoError(t, err)
+	require.NoError(t, c.setMasterKeys(master, previous, ""))
+	return c
+}
+
+// encryptDecrypt encrypts in with enc and decrypts it with dec
+func encryptDecrypt(t *testing.T, enc, dec *Cipher, in []byte) (ciphertext []byte, err error) {
+	encrypted, err := enc.EncryptData(bytes.NewReader(in))
+	require.NoError(t, err)
+	ciphertext, err = io.ReadAll(encrypted)
+	require.NoError(t, err)
+	decrypted, err := dec.DecryptData(io.NopCloser(bytes.NewReader(ciphertext)))
+	if err != nil {
+		return ciphertext, err
+	}
+	out, err := io.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.Equal(t, in, out)
+	return ciphertext, nil
+}
+
+func TestMasterKey(t *testing.T) {
+	plaintext, err := io.ReadAll(newRandomSource(150000))
+	require.NoError(t, err)
+
+	c, err := newCipher(NameEncryptionStandard, "", "", true, nil)
+	require.NoError(t, err)
+	assert.ErrorContains(t, c.setMasterKeys("", "old", ""), "previous master password set without master password")
+
+	v1 := newMasterKeyCipher(t, "", "")
+	old := newMasterKeyCipher(t, "old", "")
+	current := newMasterKeyCipher(t, "new", "old")
+	rotated := newMasterKeyCipher(t, "new", "")
+
+	// Files without a master key are in the original format
+	ciphertext, err := encryptDecrypt(t, v1, v1, plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, fileMagicBytes, ciphertext[:fileMagicSize])
+
+	// and can still be read with a master key
+	_, err = encryptDecrypt(t, v1, current, plaintext)
+	require.NoError(t, err)
+
+	// Files with a master key are version 2 and the same size
+	ciphertext, err = encryptDecrypt(t, old, old, plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, fileMagicV2Bytes, ciphertext[:fileMagicSize])
+	assert.Equal(t, v1.EncryptedSize(int64(len(plaintext))), int64(len(ciphertext)))
+
+	// Each file has its own key
+	ciphertext2, err := encryptDecrypt(t, old, old, plaintext)
+	require.NoError(t, err)
+	assert.NotEqual(t, ciphertext[fileHeaderSize:], ciphertext2[fileHeaderSize:])
+
+	// Check reading with the wrong keys
+	_, err = encryptDecrypt(t, old, v1, plaintext)
+	assert.Equal(t, ErrorNoMasterKey, err)
+	_, err = encryptDecrypt(t, old, rotated, plaintext)
+	assert.Equal(t, ErrorBadMasterKey, err)
+
+	// Check reading with the previous key
+	_, err = encryptDecrypt(t, old, current, plaintext)
+	require.NoError(t, err)
+
+	// Rotate the header
+	var header fileHeader
+	copy(header[:], ciphertext)
+	newHeader, didRotate, err := current.rotateHeader(&header)
+	require.NoError(t, err)
+	assert.True(t, didRotate)
+	assert.Equal(t, fileMagicV2Bytes, newHeader[:fileMagicSize])
+	assert.NotEqual(t, header, newHeader)
+
+	// Already rotated
+	_, didRotate, err = current.rotateHeader(&newHeader)
+	require.NoError(t, err)
+	assert.False(t, didRotate)
+
+	// Original format can't be rotated
+	var v1Header fileHeader
+	copy(v1Header[:], file0)
+	_, _, err = current.rotateHeader(&v1Header)
+	assert.Equal(t, ErrorOriginalFormat, err)
+
+	// The data with the new header can be read with just the new key
+	rotatedCiphertext := append(newHeader[:], ciphertext[fileHeaderSize:]...)
+	decrypted, err := rotated.DecryptData(io.NopCloser(bytes.NewReader(rotatedCiphertext)))
+	require.NoError(t, err)
+	out, err := io.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, out)
+
+	// Check seeking works with a version 2 header
+	open := func(ctx context.Context, underlyingOffset, underlyingLimit int64) (io.ReadCloser, error) {
+		end := int64(len(rotatedCiphertext))
+		if underlyingLimit >= 0 && underlyingOffset+underlyingLimit < end {
+			end = underlyingOffset + underlyingLimit
+		}
+		return io.NopCloser(bytes.NewReader(rotatedCiphertext[underlyingOffset:end])), nil
+	}
+	const offset, limit = 70000, 1000
+	rc, err := rotated.DecryptDataSeek(context.Background(), open, offset, limit)
+	require.NoError(t, err)
+	out, err = io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext[offset:offset+limit], out)
+	require.NoError(t, rc.Close())
+
+	// Check encrypting with the header gives the same data
+	encrypted, err := rotated.newEncrypter(bytes.NewReader(plaintext), &newHeader)
+	require.NoError(t, err)
+	out, err = io.ReadAll(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, rotatedCiphertext, out)
+}
+
 func TestNewEncrypter(t *testing.T) {
 	c, err := newCipher(NameEncryptionStandard, "", "", true, nil)
 	assert.NoError(t, err)
@@ -1256,7 +1415,11 @@ func TestNewDecrypter(t *testing.T) {
 		cd := newCloseDetector(bytes.NewBuffer(file0copy))
 		fh, err := c.newDecrypter(cd)
 		assert.Nil(t, fh)
-		assert.EqualError(t, err, ErrorEncryptedBadMagic.Error())
+		if string(file0copy[:fileMagicSize]) == fileMagicV2 {
+			assert.EqualError(t, err, ErrorNoMasterKey.Error())
+		} else {
+			assert.EqualError(t, err, ErrorEncryptedBadMagic.Error())
+		}
 		file0copy[i] ^= 0x1
 		assert.Equal(t, 1, cd.closed)
 	}
@@ -2,12 +2,14 @@
 package crypt
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rclone/rclone/fs"
@@ -18,6 +20,10 @@ import (
 	"github.com/rclone/rclone/fs/config/obscure"
 	"github.com/rclone/rclone/fs/fspath"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/rclone/rclone/lib/random"
+	"golang.org/x/sync/errgroup"
 )
 
 // Globals
@@ -76,6 +82,29 @@ NB If filename_encryption is "off" then this option will do nothing.`,
 			Name:       "password2",
 			Help:       "Password or pass phrase for salt.\n\nOptional but recommended.\nShould be different to the previous password.",
 			IsPassword: true,
+		}, {
+			Name: "master_password",
+			Help: `Password or pass phrase for the master key.
+
+If this is set then each new file is encrypted with its own random key
+which is stored in the file header wrapped with a master key made from
+this password. The master key can be changed by setting this to a new
+password, the old one to previous_master_password and running the
+rotate backend command which only rewrites the file headers.
+
+Files written without a master key are still read using password.
+File and directory names are always encrypted using password and
+password2.`,
+			IsPassword: true,
+			Advanced:   true,
+		}, {
+			Name: "previous_master_password",
+			Help: `The master password before it was rotated.
+
+This is used to read files whose headers haven't been rewritten with
+the current master_password yet by the rotate backend command.`,
+			IsPassword: true,
+			Advanced:   true,
 		}, {
 			Name:    "server_side_across_configs",
 			Default: false,
@@ -203,6 +232,23 @@ func newCipherForConfig(opt *Options) (*Cipher, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to make cipher: %w", err)
 	}
+	var masterPassword, previousMasterPassword string
+	if opt.MasterPassword != "" {
+		masterPassword, err = obscure.Reveal(opt.MasterPassword)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt master_password: %w", err)
+		}
+	}
+	if opt.PreviousMasterPassword != "" {
+		previousMasterPassword, err = obscure.Reveal(opt.PreviousMasterPassword)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt previous_master_password: %w", err)
+		}
+	}
+	err = cipher.setMasterKeys(masterPassword, previousMasterPassword, salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make master key: %w", err)
+	}
 	cipher.setEncryptedSuffix(opt.Suffix)
 	cipher.setPassBadBlocks(opt.PassBadBlocks)
 	return cipher, nil
@@ -304,6 +350,8 @@ type Options struct {
 	NoDataEncryption        bool   `config:"no_data_encryption"`
 	Password                string `config:"password"`
 	Password2               string `config:"password2"`
+	MasterPassword          string `config:"master_password"`
+	PreviousMasterPassword  string `config:"previous_master_password"`
 	ServerSideAcrossConfigs bool   `config:"server_side_across_configs"`
 	ShowMapping             bool   `config:"show_mapping"`
 	PassBadBlocks           bool   `config:"pass_bad_blocks"`
@@ -465,7 +513,7 @@ func (f *Fs) put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options [
 	ci := fs.GetConfig(ctx)
 
 	if f.opt.NoDataEncryption {
-		o, err := put(ctx, in, f.newObjectInfo(src, nonce{}), options...)
+		o, err := put(ctx, in, f.newObjectInfo(src, zeroHeader()), options...)
 		if err == nil && o != nil {
 			o = f.newObject(o)
 		}
@@ -500,7 +548,7 @@ func (f *Fs) put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options [
 	}
 
 	// Transfer the data
-	o, err := put(ctx, wrappedIn, f.newObjectInfo(src, encrypter.nonce), options...)
+	o, err := put(ctx, wrappedIn, f.newObjectInfo(src, encrypter.header), options...)
 	if err != nil {
 		return nil, err
 	}
@@ -691,7 +739,7 @@ func (f *Fs) PutUnchecked(ctx context.Context, in io.Reader, src fs.ObjectInfo,
 	if err != nil {
 		return nil, err
 	}
-	o, err := do(ctx, wrappedIn, f.newObjectInfo(src, encrypter.nonce))
+	o, err := do(ctx, wrappedIn, f.newObjectInfo(src, encrypter.header))
 	if err != nil {
 		return nil, err
 	}
@@ -744,11 +792,12 @@ func (f *Fs) DecryptFileName(encryptedFileName string) (string, error) {
 	return f.cipher.DecryptFileName(encryptedFileName)
 }
 
-// computeHashWithNonce takes the nonce and encrypts the contents of
-// src with it, and calculates the hash given by HashType on the fly
+// computeHashWithHeader takes the file header and encrypts the
+// contents of src with it, and calculates the hash given by HashType
+// on the fly
 //
 // Note that we break lots of encapsulation in this function.
-func (f *Fs) computeHashWithNonce(ctx context.Context, nonce nonce, src fs.Object, hashType hash.Type) (hashStr string, err error) {
+func (f *Fs) computeHashWithHeader(ctx context.Context, header fileHeader, src fs.Object, hashType hash.Type) (hashStr string, err error) {
 	// Open the src for input
 	in, err := src.Open(ctx)
 	if err != nil {
@@ -756,8 +805,8 @@ func (f *Fs) computeHashWithNonce(ctx context.Context, nonce nonce, src fs.Objec
 	}
 	defer fs.CheckClose(in, &err)
 
-	// Now encrypt the src with the nonce
-	out, err := f.cipher.newEncrypter(in, &nonce)
+	// Now encrypt the src with the header
+	out, err := f.cipher.newEncrypter(in, &header)
 	if err != nil {
 		return "", fmt.Errorf("failed to make encrypter: %w", err)
 	}
@@ -795,13 +844,13 @@ func (f *Fs) ComputeHash(ctx context.Context, o *Object, src fs.Object, hashType
 		_ = in.Close()
 		return "", fmt.Errorf("failed to open object to read nonce: %w", err)
 	}
-	nonce := d.nonce
-	// fs.Debugf(o, "Read nonce % 2x", nonce)
+	header := d.header
+	// fs.Debugf(o, "Read header % 2x", header)
 
 	// Check nonce isn't all zeros
 	isZero := true
-	for i := range nonce {
-		if nonce[i] != 0 {
+	for _, b := range header[fileMagicSize:] {
+		if b != 0 {
 			isZero = false
 		}
 	}
@@ -815,7 +864,7 @@ func (f *Fs) ComputeHash(ctx context.Context, o *Object, src fs.Object, hashType
 		return "", fmt.Errorf("failed to close nonce read: %w", err)
 	}
 
-	return f.computeHashWithNonce(ctx, nonce, src, hashType)
+	return f.computeHashWithHeader(ctx, header, src, hashType)
 }
 
 // MergeDirs merges the contents of all the directories passed
@@ -911,6 +960,35 @@ Usage Example:
 
     rclone backend decode crypt: encryptedfile1 [encryptedfile2...]
     rclone rc backend/command command=decode fs=crypt: encryptedfile1 [encryptedfile2...]
+`,
+	},
+	{
+		Name:  "rotate",
+		Short: "Rewrap the file keys with the current master key",
+		Long: `This rewrites the header of each file under the path given whose key
+is wrapped with the previous_master_password so that it is wrapped with
+the master_password instead.
+
+Usage Example:
+
+    rclone backend rotate crypt:path
+    rclone rc backend/command command=rotate fs=crypt:path
+
+The file data is not decrypted or re-encrypted, only the header is
+changed. On remotes which can copy parts of files server-side (such as
+s3) each file is rewritten with a multipart upload where only the
+first chunk, holding the new header, is uploaded and the rest is
+copied server-side.
+
+On other remotes, and for files which fit in one chunk, each file is
+streamed to a temporary file with the new header which is then moved
+over the original, server-side if the remote supports it. This is
+logged for each file.
+
+Files written without a master key can't be rotated and are reported.
+Use --dry-run to see which files would be rotated.
+
+It returns a list of the files with the status of each.
 `,
 	},
 }
@@ -943,11 +1021,274 @@ func (f *Fs) Command(ctx context.Context, name string, arg []string, opt map[str
 			out = append(out, encryptedFileName)
 		}
 		return out, nil
+	case "rotate":
+		return f.rotate(ctx)
 	default:
 		return nil, fs.ErrorCommandNotFound
 	}
 }
 
+// rotate rewraps the keys of all the files with the current master key
+func (f *Fs) rotate(ctx context.Context) (out interface{}, err error) {
+	if f.opt.NoDataEncryption {
+		return nil, errors.New("can't rotate keys with no_data_encryption set")
+	}
+	if f.cipher.masterKey == nil {
+		return nil, errors.New("can't rotate keys without master_password set")
+	}
+	type status struct {
+		Object string
+		Status string
+	}
+	var (
+		outMu    sync.Mutex
+		statuses = []status{}
+		ci       = fs.GetConfig(ctx)
+	)
+	err = walk.ListR(ctx, f, "", false, ci.MaxDepth, walk.ListObjects, func(entries fs.DirEntries) error {
+		// Remember this may be run --checkers times concurrently
+		entries.ForObject(func(obj fs.Object) {
+			st := status{Object: obj.Remote(), Status: "ROTATED"}
+			defer func() {
+				outMu.Lock()
+				statuses = append(statuses, st)
+				outMu.Unlock()
+			}()
+			o, ok := obj.(*Object)
+			if !ok {
+				st.Status = "Not a crypt object"
+				return
+			}
+			rotated, err := f.rotateObject(ctx, o)
+			switch {
+			case errors.Is(err, ErrorOriginalFormat):
+				fs.Logf(o, "Not rotating key: %v", err)
+				st.Status = "ORIGINAL FORMAT"
+			case err != nil:
+				fs.Errorf(o, "Failed to rotate key: %v", err)
+				st.Status = err.Error()
+			case !rotated:
+				st.Status = "CURRENT"
+			case ci.DryRun:
+				st.Status = "SKIPPED"
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		return statuses, err
+	}
+	return statuses, nil
+}
+
+// readHeader reads the file header of the underlying object o
+func (f *Fs) readHeader(ctx context.Context, o fs.Object) (header fileHeader, err error) {
+	in, err := o.Open(ctx, &fs.RangeOption{Start: 0, End: int64(fileHeaderSize) - 1})
+	if err != nil {
+		return header, fmt.Errorf("failed to open object to read header: %w", err)
+	}
+	defer fs.CheckClose(in, &err)
+	_, err = io.ReadFull(in, header[:])
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return header, ErrorEncryptedFileTooShort
+	} else if err != nil {
+		return header, fmt.Errorf("failed to read header: %w", err)
+	}
+	return header, nil
+}
+
+// rotateObject rewrites the header of o with its key wrapped with the
+// current master key.
+//
+// It returns false if the key is already wrapped with the current
+// master key.
+//
+// With --dry-run it returns true if the header needs rewriting
+// without rewriting it.
+func (f *Fs) rotateObject(ctx context.Context, o *Object) (rotated bool, err error) {
+	header, err := f.readHeader(ctx, o.Object)
+	if err != nil {
+		return false, err
+	}
+	newHeader, rotated, err := f.cipher.rotateHeader(&header)
+	if err != nil || !rotated {
+		return false, err
+	}
+	if fs.GetConfig(ctx).DryRun {
+		fs.Logf(o, "Skipped rotating key as --dry-run is set")
+		return true, nil
+	}
+
+	// Only the header changes so copy the rest server-side if possible
+	err = f.rotateServerSide(ctx, o.Object, &newHeader)
+	if err == nil {
+		return true, nil
+	} else if errors.Is(err, errRotateOneChunk) {
+		fs.Debugf(o, "Rewriting the whole object to rotate its key: %v", err)
+	} else if errors.Is(err, errServerSideRotateUnsupported) {
+		fs.Logf(o, "Rewriting the whole object to rotate its key: %v", err)
+	} else {
+		return false, err
+	}
+
+	// Stream the data after the header to a temporary object
+	in, err := o.Object.Open(ctx, &fs.SeekOption{Offset: int64(fileHeaderSize)})
+	if err != nil {
+		return false, fmt.Errorf("failed to open object: %w", err)
+	}
+	defer fs.CheckClose(in, &err)
+	metadata, err := fs.GetMetadata(ctx, o.Object)
+	if err != nil {
+		return false, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	remote := o.Object.Remote()
+	tmpRemote := remote + "." + random.String(8) + ".rotate"
+	src := object.NewStaticObjectInfo(tmpRemote, o.Object.ModTime(ctx), o.Object.Size(), true, nil, f.Fs).WithMetadata(metadata)
+	tmpObj, err := f.Fs.Put(ctx, io.MultiReader(bytes.NewReader(newHeader[:]), in), src)
+	if err != nil {
+		return false, fmt.Errorf("failed to upload rotated object: %w", err)
+	}
+
+	// Then replace the original with it
+	err = f.replaceObject(ctx, o.Object, tmpObj)
+	if removeErr := tmpObj.Remove(ctx); removeErr != nil && !errors.Is(removeErr, fs.ErrorObjectNotFound) {
+		fs.Errorf(tmpObj, "Failed to remove temporary object: %v", removeErr)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to replace object with rotated object: %w", err)
+	}
+	return true, nil
+}
+
+// errServerSideRotateUnsupported is returned if the wrapped remote
+// can't rewrite the header of an object server-side
+var errServerSideRotateUnsupported = errors.New("remote can't copy the data server-side")
+
+// errRotateOneChunk is returned if the object is too small to copy
+// any of it server-side
+var errRotateOneChunk = fmt.Errorf("%w: object fits in one chunk", errServerSideRotateUnsupported)
+
+// rotateServerSide replaces the header of the underlying object o with
+// newHeader without downloading and uploading all of the data.
+//
+// This uses a multipart upload to the same name. The first chunk is
+// the new header and the data following the old one, which is
+// uploaded, and the other chunks are copied from o server-side.
+//
+// It returns an error wrapping errServerSideRotateUnsupported if the
+// wrapped remote can't do this.
+func (f *Fs) rotateServerSide(ctx context.Context, o fs.Object, newHeader *fileHeader) (err error) {
+	features := f.Fs.Features()
+	if features.OpenChunkWriter == nil || !features.ChunkWriterCopies {
+		return errServerSideRotateUnsupported
+	}
+	metadata, err := fs.GetMetadata(ctx, o)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata: %w", err)
+	}
+	src := object.NewStaticObjectInfo(o.Remote(), o.ModTime(ctx), o.Size(), true, nil, f.Fs).WithMetadata(metadata)
+	info, w, err := features.OpenChunkWriter(ctx, o.Remote(), src)
+	if err != nil {
+		return fmt.Errorf("failed to open chunk writer: %w", err)
+	}
+	defer func() {
+		if err != nil && !info.LeavePartsOnError {
+			if abortErr := w.Abort(ctx); abortErr != nil {
+				fs.Errorf(o, "Failed to abort rotating key: %v", abortErr)
+			}
+		}
+	}()
+	err = writeRotatedChunks(ctx, info, w, o, newHeader)
+	if err != nil {
+		return err
+	}
+	err = w.Close(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to finalise rotated object: %w", err)
+	}
+	return nil
+}
+
+// writeRotatedChunks writes o with its header replaced by newHeader
+// to w, copying all the chunks but the first server-side.
+func writeRotatedChunks(ctx context.Context, info fs.ChunkWriterInfo, w fs.ChunkWriter, o fs.Object, newHeader *fileHeader) error {
+	copier, ok := w.(fs.ChunkWriterCopier)
+	if !ok {
+		return errServerSideRotateUnsupported
+	}
+	size, chunkSize := o.Size(), info.ChunkSize
+	if size <= chunkSize || chunkSize < int64(fileHeaderSize) {
+		return errRotateOneChunk
+	}
+
+	// Upload the first chunk with the new header
+	in, err := o.Open(ctx, &fs.RangeOption{Start: int64(fileHeaderSize), End: chunkSize - 1})
+	if err != nil {
+		return fmt.Errorf("failed to open object: %w", err)
+	}
+	first := make([]byte, chunkSize)
+	copy(first, newHeader[:])
+	_, err = io.ReadFull(in, first[fileHeaderSize:])
+	_ = in.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read first chunk: %w", err)
+	}
+	_, err = w.WriteChunk(ctx, 0, bytes.NewReader(first))
+	if err != nil {
+		return err
+	}
+
+	// Then copy the rest
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(max(info.Concurrency, 1))
+	for i, pos := 1, chunkSize; pos < size; i, pos = i+1, pos+chunkSize {
+		i, pos := i, pos
+		g.Go(func() error {
+			_, err := copier.CopyChunk(gCtx, i, o, pos, min(chunkSize, size-pos))
+			if errors.Is(err, fs.ErrorCantCopy) {
+				return fmt.Errorf("%w: %v", errServerSideRotateUnsupported, err)
+			}
+			return err
+		})
+	}
+	return g.Wait()
+}
+
+// replaceObject replaces the contents of dst with those of src
+//
+// This is done server-side if the wrapped remote can Move or Copy.
+// src may or may not exist afterwards.
+func (f *Fs) replaceObject(ctx context.Context, dst, src fs.Object) (err error) {
+	features := f.Fs.Features()
+	serverSide := func(do func(context.Context, fs.Object, string) (fs.Object, error), errCant error) (bool, error) {
+		_, err := do(ctx, src, dst.Remote())
+		if errors.Is(err, errCant) {
+			return false, nil
+		}
+		if err == nil && features.DuplicateFiles {
+			// The old object is still there so remove it
+			err = dst.Remove(ctx)
+		}
+		return true, err
+	}
+	if features.Move != nil {
+		if done, err := serverSide(features.Move, fs.ErrorCantMove); done {
+			return err
+		}
+	}
+	if features.Copy != nil {
+		if done, err := serverSide(features.Copy, fs.ErrorCantCopy); done {
+			return err
+		}
+	}
+	in, err := src.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(in, &err)
+	return dst.Update(ctx, in, src)
+}
+
 // Object describes a wrapped for being read from the Fs
 //
 // This decrypts the remote name and decrypts the data
@@ -1107,15 +1448,15 @@ func (f *Fs) Shutdown(ctx context.Context) error {
 // This encrypts the remote name and adjusts the size
 type ObjectInfo struct {
 	fs.ObjectInfo
-	f     *Fs
-	nonce nonce
+	f      *Fs
+	header fileHeader
 }
 
-func (f *Fs) newObjectInfo(src fs.ObjectInfo, nonce nonce) *ObjectInfo {
+func (f *Fs) newObjectInfo(src fs.ObjectInfo, header fileHeader) *ObjectInfo {
 	return &ObjectInfo{
 		ObjectInfo: src,
 		f:          f,
-		nonce:      nonce,
+		header:     header,
 	}
 }
 
@@ -1160,7 +1501,7 @@ func (o *ObjectInfo) Hash(ctx context.Context, hash hash.Type) (string, error) {
 	if srcObj.Fs().Features().IsLocal {
 		// Read the data and encrypt it to calculate the hash
 		fs.Debugf(o, "Computing %v hash of encrypted source", hash)
-		return o.f.computeHashWithNonce(ctx, o.nonce, srcObj, hash)
+		return o.f.computeHashWithHeader(ctx, o.header, srcObj, hash)
 	}
 	return "", nil
 }
@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"encoding/json"
 	"fmt"
 	"io"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/obscure"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/object"
 	"github.com/rclone/rclone/lib/random"
@@ -61,7 +64,7 @@ func testObjectInfo(t *testing.T, f *Fs, wrap bool) {
 	var outBuf bytes.Buffer
 	enc, err := f.cipher.newEncrypter(inBuf, nil)
 	require.NoError(t, err)
-	nonce := enc.nonce // read the nonce at the start
+	header := enc.header // read the header at the start
 	_, err = io.Copy(&outBuf, enc)
 	require.NoError(t, err)
 
@@ -71,9 +74,9 @@ func testObjectInfo(t *testing.T, f *Fs, wrap bool) {
 		oi = fs.NewOverrideRemote(oi, "new_remote")
 	}
 
-	// wrap the object in a crypt for upload using the nonce we
+	// wrap the object in a crypt for upload using the header we
 	// saved from the encrypter
-	src := f.newObjectInfo(oi, nonce)
+	src := f.newObjectInfo(oi, header)
 
 	// Test ObjectInfo methods
 	if !f.opt.NoDataEncryption {
@@ -119,9 +122,187 @@ func testComputeHash(t *testing.T, f *Fs) {
 	assert.Equal(t, remoteObjHash, computedHash)
 }
 
+// newRotateFs makes a crypt Fs over dir with the master passwords given
+func newRotateFs(t *testing.T, dir, master, previous string) *Fs {
+	connectionString := fmt.Sprintf(":crypt,remote=%q,password=%q", dir, obscure.MustObscure("potato"))
+	if master != "" {
+		connectionString += fmt.Sprintf(",master_password=%q", obscure.MustObscure(master))
+	}
+	if previous != "" {
+		connectionString += fmt.Sprintf(",previous_master_password=%q", obscure.MustObscure(previous))
+	}
+	f, err := fs.NewFs(context.Background(), connectionString+":")
+	require.NoError(t, err)
+	return f.(*Fs)
+}
+
+// Test the rotate command
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	v1Fs := newRotateFs(t, dir, "", "")
+	oldFs := newRotateFs(t, dir, "old", "")
+	currentFs := newRotateFs(t, dir, "new", "old")
+	rotatedFs := newRotateFs(t, dir, "new", "")
+
+	contents := random.String(100)
+	t1 := time.Date(2012, time.December, 17, 18, 32, 31, 0, time.UTC)
+	put := func(f *Fs, remote string) {
+		src := object.NewStaticObjectInfo(remote, t1, int64(len(contents)), true, nil, nil)
+		_, err := f.Put(ctx, bytes.NewBufferString(contents), src)
+		require.NoError(t, err)
+	}
+	put(v1Fs, "v1")
+	put(oldFs, "old")
+	put(currentFs, "current")
+
+	read := func(f *Fs, remote string) (string, error) {
+		o, err := f.NewObject(ctx, remote)
+		require.NoError(t, err)
+		in, err := o.Open(ctx)
+		if err != nil {
+			return "", err
+		}
+		data, err := io.ReadAll(in)
+		require.NoError(t, err)
+		require.NoError(t, in.Close())
+		return string(data), nil
+	}
+	_, err := read(rotatedFs, "old")
+	assert.Equal(t, ErrorBadMasterKey, err)
+
+	// Rotating needs a master key
+	_, err = v1Fs.Command(ctx, "rotate", nil, nil)
+	assert.Error(t, err)
+
+	statuses := func(out interface{}) map[string]string {
+		data, err := json.Marshal(out)
+		require.NoError(t, err)
+		var list []struct{ Object, Status string }
+		require.NoError(t, json.Unmarshal(data, &list))
+		result := map[string]string{}
+		for _, item := range list {
+			result[item.Object] = item.Status
+		}
+		return result
+	}
+
+	// Check --dry-run doesn't change anything
+	dryCtx, ci := fs.AddConfig(ctx)
+	ci.DryRun = true
+	out, err := currentFs.Command(dryCtx, "rotate", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"v1": "ORIGINAL FORMAT", "old": "SKIPPED", "current": "CURRENT"}, statuses(out))
+	_, err = read(rotatedFs, "old")
+	assert.Equal(t, ErrorBadMasterKey, err)
+
+	out, err = currentFs.Command(ctx, "rotate", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"v1": "ORIGINAL FORMAT", "old": "ROTATED", "current": "CURRENT"}, statuses(out))
+
+	out, err = currentFs.Command(ctx, "rotate", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"v1": "ORIGINAL FORMAT", "old": "CURRENT", "current": "CURRENT"}, statuses(out))
+
+	// All the files can now be read without the previous password
+	for _, remote := range []string{"v1", "old", "current"} {
+		got, err := read(rotatedFs, remote)
+		require.NoError(t, err, remote)
+		assert.Equal(t, contents, got, remote)
+		o, err := rotatedFs.NewObject(ctx, remote)
+		require.NoError(t, err)
+		assert.True(t, t1.Equal(o.ModTime(ctx)), remote)
+	}
+
+	// Check no temporary files are left behind
+	entries, err := rotatedFs.Fs.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(entries))
+}
+
 // InternalTest is called by fstests.Run to extra tests
 func (f *Fs) InternalTest(t *testing.T) {
 	t.Run("ObjectInfo", func(t *testing.T) { testObjectInfo(t, f, false) })
 	t.Run("ObjectInfoWrap", func(t *testing.T) { testObjectInfo(t, f, true) })
 	t.Run("ComputeHash", func(t *testing.T) { testComputeHash(t, f) })
 }
+
+// chunkAssembler is a fs.ChunkWriter which assembles the chunks
+// written or copied to it in memory
+type chunkAssembler struct {
+	mu       sync.Mutex
+	chunks   map[int][]byte
+	copied   []int
+	cantCopy bool
+}
+
+func (c *chunkAssembler) add(chunkNumber int, data []byte, copied bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chunks[chunkNumber] = data
+	if copied {
+		c.copied = append(c.copied, chunkNumber)
+	}
+}
+
+func (c *chunkAssembler) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return -1, err
+	}
+	c.add(chunkNumber, data, false)
+	return int64(len(data)), nil
+}
+
+func (c *chunkAssembler) CopyChunk(ctx context.Context, chunkNumber int, src fs.Object, offset, length int64) (int64, error) {
+	if c.cantCopy {
+		return -1, fs.ErrorCantCopy
+	}
+	in, err := src.Open(ctx, &fs.RangeOption{Start: offset, End: offset + length - 1})
+	if err != nil {
+		return -1, err
+	}
+	data, err := io.ReadAll(in)
+	_ = in.Close()
+	if err != nil {
+		return -1, err
+	}
+	c.add(chunkNumber, data, true)
+	return int64(len(data)), nil
+}
+
+func (c *chunkAssembler) Close(ctx context.Context) error { return nil }
+
+func (c *chunkAssembler) Abort(ctx context.Context) error { return nil }
+
+// Test rewriting the header copies all but the first chunk
+func TestWriteRotatedChunks(t *testing.T) {
+	ctx := context.Background()
+	localFs := makeTempLocalFs(t)
+	const chunkSize = 100
+	contents := random.String(3*chunkSize + 10)
+	o := uploadFile(t, localFs, "file", contents)
+	var newHeader fileHeader
+	for i := range newHeader {
+		newHeader[i] = byte(i)
+	}
+	info := fs.ChunkWriterInfo{ChunkSize: chunkSize, Concurrency: 2}
+
+	w := &chunkAssembler{chunks: map[int][]byte{}}
+	require.NoError(t, writeRotatedChunks(ctx, info, w, o, &newHeader))
+	var got []byte
+	for i := 0; i < len(w.chunks); i++ {
+		got = append(got, w.chunks[i]...)
+	}
+	assert.Equal(t, string(newHeader[:])+contents[fileHeaderSize:], string(got))
+	assert.ElementsMatch(t, []int{1, 2, 3}, w.copied)
+
+	// Chunks which can't be copied or objects too small to copy
+	w = &chunkAssembler{chunks: map[int][]byte{}, cantCopy: true}
+	err := writeRotatedChunks(ctx, info, w, o, &newHeader)
+	assert.ErrorIs(t, err, errServerSideRotateUnsupported)
+	w = &chunkAssembler{chunks: map[int][]byte{}}
+	info.ChunkSize = int64(len(contents))
+	err = writeRotatedChunks(ctx, info, w, o, &newHeader)
+	assert.ErrorIs(t, err, errRotateOneChunk)
+}
@@ -0,0 +1,136 @@
+package dedup
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// gear is the table of random values used by the rolling hash.
+//
+// It is generated from a fixed seed so that the chunk boundaries of
+// a given stream never change between versions of rclone - if they
+// did, nothing uploaded previously would deduplicate.
+var gear = func() (table [256]uint64) {
+	// splitmix64
+	state := uint64(0x7263_6c6f_6e65_6464) // "rclonedd"
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// chunker splits a stream into content defined chunks using the
+// FastCDC algorithm with normalized chunking.
+//
+// The boundaries depend only on the content near them, so an insert
+// or delete in the stream only changes the chunks around it.
+type chunker struct {
+	in    io.Reader
+	buf   []byte
+	start int    // start of unread data in buf
+	end   int    // end of data in buf
+	eof   bool   // set when in is exhausted
+	min   int    // minimum chunk size
+	avg   int    // target average chunk size
+	max   int    // maximum chunk size
+	maskS uint64 // harder mask used before avg bytes
+	maskL uint64 // easier mask used after avg bytes
+}
+
+// newChunker makes a chunker reading from in
+func newChunker(in io.Reader, minSize, avgSize, maxSize int) (*chunker, error) {
+	if minSize <= 0 || minSize > avgSize || avgSize > maxSize {
+		return nil, errors.New("chunk sizes must satisfy 0 < min <= avg <= max")
+	}
+	n := bits.Len(uint(avgSize)) - 1 // log2(avg)
+	return &chunker{
+		in:    in,
+		buf:   make([]byte, 2*maxSize),
+		min:   minSize,
+		avg:   avgSize,
+		max:   maxSize,
+		maskS: mask(n + 2),
+		maskL: mask(n - 2),
+	}, nil
+}
+
+// mask returns a mask with n bits set in the top of the word which is
+// where the gear hash keeps the most context.
+func mask(n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	if n > 64 {
+		n = 64
+	}
+	return ^uint64(0) << (64 - n)
+}
+
+// fill reads more data into the buffer so at least max bytes are
+// available unless the input has run out.
+func (c *chunker) fill() error {
+	if c.eof || c.end-c.start >= c.max {
+		return nil
+	}
+	copy(c.buf, c.buf[c.start:c.end])
+	c.end -= c.start
+	c.start = 0
+	n, err := io.ReadFull(c.in, c.buf[c.end:])
+	c.end += n
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		c.eof = true
+		err = nil
+	}
+	return err
+}
+
+// Next returns the next chunk or io.EOF when there are no more.
+//
+// The returned slice is only valid until the next call.
+func (c *chunker) Next() ([]byte, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+	n := c.cut(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+	return chunk, nil
+}
+
+// cut returns the length of the first chunk in data
+func (c *chunker) cut(data []byte) int {
+	n := len(data)
+	if n <= c.min {
+		return n
+	}
+	if n > c.max {
+		n = c.max
+	}
+	normal := c.avg
+	if normal > n {
+		normal = n
+	}
+	var h uint64
+	i := c.min
+	for ; i < normal; i++ {
+		h = (h << 1) + gear[data[i]]
+		if h&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		h = (h << 1) + gear[data[i]]
+		if h&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
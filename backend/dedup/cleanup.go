@@ -0,0 +1,129 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+	"golang.org/x/sync/errgroup"
+)
+
+// CleanUp removes the chunks which no manifest in the repository
+// refers to.
+//
+// This reads every manifest in the repository, not just the ones under
+// the root of f. Chunks newer than cleanup_grace are kept as they may
+// belong to an upload which hasn't written its manifest yet. Uploads
+// refresh the modification time of the chunks they reuse so this
+// covers those too.
+func (f *Fs) CleanUp(ctx context.Context) error {
+	// Work out the cutoff before reading the manifests so that any
+	// chunk used by a manifest written after they were read is newer
+	cutoff := time.Now().Add(-time.Duration(f.opt.CleanupGrace))
+	referenced, err := f.referencedChunks(ctx)
+	if err != nil {
+		return fmt.Errorf("cleanup: failed to read manifests: %w", err)
+	}
+	fs.Infof(f, "Found %d chunks in use", len(referenced))
+	return f.removeChunks(ctx, referenced, cutoff)
+}
+
+// removeChunks removes the chunks not in referenced which are older
+// than cutoff
+func (f *Fs) removeChunks(ctx context.Context, referenced map[string]struct{}, cutoff time.Time) error {
+	var (
+		mu      sync.Mutex
+		removed int
+		size    int64
+	)
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(fs.GetConfig(ctx).Checkers)
+	err := walk.ListR(ctx, f.chunks, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			if _, ok := referenced[path.Base(o.Remote())]; ok {
+				return
+			}
+			if o.ModTime(ctx).After(cutoff) {
+				fs.Debugf(o, "Keeping unreferenced chunk as it is too new")
+				return
+			}
+			g.Go(func() error {
+				// Read the chunk again in case an upload has
+				// reused it since it was listed
+				o, err := f.chunks.NewObject(gCtx, o.Remote())
+				if errors.Is(err, fs.ErrorObjectNotFound) {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				if o.ModTime(gCtx).After(cutoff) {
+					fs.Debugf(o, "Keeping unreferenced chunk as it has been reused")
+					return nil
+				}
+				if err := operations.DeleteFile(gCtx, o); err != nil {
+					return err
+				}
+				mu.Lock()
+				removed++
+				size += o.Size()
+				mu.Unlock()
+				return nil
+			})
+		})
+		return nil
+	})
+	if errors.Is(err, fs.ErrorDirNotFound) {
+		err = nil
+	}
+	if waitErr := g.Wait(); err == nil {
+		err = waitErr
+	}
+	fs.Infof(f, "Removed %d unreferenced chunks using %s", removed, fs.SizeSuffix(size))
+	return err
+}
+
+// referencedChunks returns the set of chunks referred to by all the
+// manifests in the repository
+func (f *Fs) referencedChunks(ctx context.Context) (map[string]struct{}, error) {
+	var mu sync.Mutex
+	referenced := map[string]struct{}{}
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(fs.GetConfig(ctx).Checkers)
+	err := walk.ListR(ctx, f.repo, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			remote := o.Remote()
+			if strings.HasPrefix(remote, chunkDir+"/") {
+				return
+			}
+			g.Go(func() error {
+				m, err := readManifest(gCtx, o)
+				if errors.Is(err, errNotManifest) {
+					fs.Debugf(o, "Ignoring file: %v", err)
+					return nil
+				} else if err != nil {
+					// Any manifest we can't read might refer to
+					// chunks so give up rather than delete them
+					return fmt.Errorf("%s: %w", remote, err)
+				}
+				mu.Lock()
+				for _, ref := range m.Chunks {
+					referenced[ref.Hash] = struct{}{}
+				}
+				mu.Unlock()
+				return nil
+			})
+		})
+		return nil
+	})
+	if waitErr := g.Wait(); err == nil {
+		err = waitErr
+	}
+	return referenced, err
+}
@@ -0,0 +1,403 @@
+// Package dedup implements a backend which deduplicates the data
+// stored on another remote using content defined chunking.
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/configstruct"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/hash"
+	libcache "github.com/rclone/rclone/lib/cache"
+	"golang.org/x/sync/errgroup"
+)
+
+// chunkDir is the directory in the root of the repository where the
+// chunks are stored
+const chunkDir = ".dedup"
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "dedup",
+		Description: "Deduplicate files on another remote",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name:     "remote",
+			Required: true,
+			Help: `Remote to store the deduplicated files in.
+
+Normally should contain a ':' and a path, e.g. "myremote:path/to/dir",
+"myremote:bucket" or maybe "myremote:" (not recommended).
+
+The chunks are stored in a "` + chunkDir + `" directory in the root of this
+remote and are shared by all the files stored under it.`,
+		}, {
+			Name:    "avg_chunk_size",
+			Default: fs.SizeSuffix(1024 * 1024),
+			Help: `Target average size of the chunks files are split into.
+
+Smaller chunks find more duplicated data but need more transactions
+and make the manifests bigger.
+
+This must not be changed once there is data in the remote or the new
+uploads will not deduplicate against the old ones.`,
+			Advanced: true,
+		}, {
+			Name:     "min_chunk_size",
+			Default:  fs.SizeSuffix(256 * 1024),
+			Help:     "Minimum size of a chunk.\n\nThis must not be changed once there is data in the remote.",
+			Advanced: true,
+		}, {
+			Name:     "max_chunk_size",
+			Default:  fs.SizeSuffix(4 * 1024 * 1024),
+			Help:     "Maximum size of a chunk.\n\nThis must not be changed once there is data in the remote.",
+			Advanced: true,
+		}, {
+			Name:    "cleanup_grace",
+			Default: fs.Duration(time.Hour),
+			Help: `Don't remove unreferenced chunks newer than this in cleanup.
+
+Chunks are uploaded before the manifest which refers to them so this
+stops ` + "`rclone cleanup`" + ` removing the chunks of an upload which is
+still in progress.`,
+			Advanced: true,
+		}},
+	})
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	Remote       string        `config:"remote"`
+	AvgChunkSize fs.SizeSuffix `config:"avg_chunk_size"`
+	MinChunkSize fs.SizeSuffix `config:"min_chunk_size"`
+	MaxChunkSize fs.SizeSuffix `config:"max_chunk_size"`
+	CleanupGrace fs.Duration   `config:"cleanup_grace"`
+}
+
+// Fs represents a wrapped fs.Fs storing manifests which refer to
+// deduplicated chunks
+type Fs struct {
+	fs.Fs             // where the manifests are stored
+	name      string  // name of this remote
+	root      string  // the path we are working on
+	opt       Options // parsed options
+	features  *fs.Features
+	wrapper   fs.Fs
+	repo      fs.Fs           // the root of the repository
+	chunks    fs.Fs           // where the chunks are stored
+	manifests *libcache.Cache // manifests read recently indexed by remote
+}
+
+// NewFs constructs an Fs from the path, container:path
+func NewFs(ctx context.Context, name, rpath string, m configmap.Mapper) (fs.Fs, error) {
+	opt := Options{}
+	err := configstruct.Set(m, &opt)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(opt.Remote, name+":") {
+		return nil, errors.New("can't point dedup remote at itself - check the value of the remote setting")
+	}
+	if opt.MinChunkSize <= 0 || opt.MinChunkSize > opt.AvgChunkSize || opt.AvgChunkSize > opt.MaxChunkSize {
+		return nil, errors.New("chunk sizes must satisfy 0 < min_chunk_size <= avg_chunk_size <= max_chunk_size")
+	}
+
+	repo, err := cache.Get(ctx, opt.Remote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make remote %q to wrap: %w", opt.Remote, err)
+	}
+	chunks, err := cache.Get(ctx, fspath.JoinRootPath(opt.Remote, chunkDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to make chunk remote: %w", err)
+	}
+	baseFs, err := cache.Get(ctx, fspath.JoinRootPath(opt.Remote, rpath))
+	if err != nil && err != fs.ErrorIsFile {
+		return nil, fmt.Errorf("failed to make remote %q to wrap: %w", opt.Remote, err)
+	}
+
+	f := &Fs{
+		Fs:        baseFs,
+		name:      name,
+		root:      rpath,
+		opt:       opt,
+		repo:      repo,
+		chunks:    chunks,
+		manifests: libcache.New(),
+	}
+	// Correct root if definitely pointing to a file
+	if err == fs.ErrorIsFile {
+		f.root = path.Dir(f.root)
+		if f.root == "." || f.root == "/" {
+			f.root = ""
+		}
+	}
+	f.features = (&fs.Features{
+		CanHaveEmptyDirectories: true,
+	}).Fill(ctx, f).Mask(ctx, baseFs).WrapsFs(f, baseFs)
+	// Cleanup and streaming uploads are done by this backend so
+	// don't depend on the wrapped remote supporting them
+	f.features.CleanUp = f.CleanUp
+	f.features.PutStream = f.PutStream
+
+	cache.PinUntilFinalized(f.Fs, f)
+	return f, err
+}
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string { return f.name }
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string { return f.root }
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features { return f.features }
+
+// String returns a description of the FS
+func (f *Fs) String() string {
+	return fmt.Sprintf("Deduplicated '%s:%s'", f.name, f.root)
+}
+
+// Precision of the ModTimes in this Fs
+//
+// The modification times are stored in the manifests.
+func (f *Fs) Precision() time.Duration { return time.Nanosecond }
+
+// Hashes returns the supported hash sets.
+//
+// These are calculated while chunking and stored in the manifests.
+func (f *Fs) Hashes() hash.Set { return hash.NewHashSet(hash.MD5, hash.SHA256) }
+
+// UnWrap returns the Fs that this Fs is wrapping
+func (f *Fs) UnWrap() fs.Fs { return f.Fs }
+
+// WrapFs returns the Fs that is wrapping this Fs
+func (f *Fs) WrapFs() fs.Fs { return f.wrapper }
+
+// SetWrapper sets the Fs that is wrapping this Fs
+func (f *Fs) SetWrapper(wrapper fs.Fs) { f.wrapper = wrapper }
+
+// reserved returns true if remote is inside the chunk store
+func (f *Fs) reserved(remote string) bool {
+	p := path.Join(f.root, remote)
+	return p == chunkDir || strings.HasPrefix(p, chunkDir+"/")
+}
+
+// sameRepo returns true if src stores its chunks in the same place as f
+func (f *Fs) sameRepo(src *Fs) bool {
+	return fs.ConfigString(f.chunks) == fs.ConfigString(src.chunks)
+}
+
+// readManifests reads the manifests of the base objects in entries
+// replacing them with Objects.
+//
+// Entries which aren't manifests are dropped.
+func (f *Fs) readManifests(ctx context.Context, entries fs.DirEntries) (fs.DirEntries, error) {
+	objects := make([]*Object, len(entries))
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(fs.GetConfig(ctx).Checkers)
+	for i, entry := range entries {
+		baseObj, ok := entry.(fs.Object)
+		if !ok || f.reserved(entry.Remote()) {
+			continue
+		}
+		i := i
+		g.Go(func() error {
+			o, err := f.newObject(gCtx, baseObj)
+			if errors.Is(err, errNotManifest) {
+				fs.Debugf(baseObj, "Ignoring file: %v", err)
+				return nil
+			}
+			objects[i] = o
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	newEntries := entries[:0] // work in place
+	for i, entry := range entries {
+		switch {
+		case f.reserved(entry.Remote()):
+		case objects[i] != nil:
+			newEntries = append(newEntries, objects[i])
+		case isDirectory(entry):
+			newEntries = append(newEntries, entry)
+		}
+	}
+	return newEntries, nil
+}
+
+// isDirectory returns true if entry is a directory
+func isDirectory(entry fs.DirEntry) bool {
+	_, ok := entry.(fs.Directory)
+	return ok
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	if f.reserved(dir) {
+		return nil, fs.ErrorDirNotFound
+	}
+	entries, err = f.Fs.List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	return f.readManifests(ctx, entries)
+}
+
+// NewObject finds the Object at remote.  If it can't be found
+// it returns the error fs.ErrorObjectNotFound.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	if f.reserved(remote) {
+		return nil, fs.ErrorObjectNotFound
+	}
+	baseObj, err := f.Fs.NewObject(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	return f.newObject(ctx, baseObj)
+}
+
+// Put in to the remote path with the modTime given of the given size
+//
+// May create the object even if it returns an error - if so
+// will return the object and the error, otherwise will return
+// nil and the error
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	if f.reserved(src.Remote()) {
+		return nil, fmt.Errorf("can't upload into the chunk store %q", chunkDir)
+	}
+	o := &Object{
+		f:      f,
+		remote: src.Remote(),
+	}
+	return o, o.upload(ctx, in, src)
+}
+
+// PutStream uploads to the remote path with the modTime given of indeterminate size
+//
+// The chunks are stored as they are read so this needs no buffering.
+func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	return f.Put(ctx, in, src, options...)
+}
+
+// Mkdir makes the directory (container, bucket)
+//
+// Shouldn't return an error if it already exists
+func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	if f.reserved(dir) {
+		return fmt.Errorf("can't create directory in the chunk store %q", chunkDir)
+	}
+	return f.Fs.Mkdir(ctx, dir)
+}
+
+// Purge all files in the directory specified
+//
+// Only the manifests are removed - the chunks they used are removed
+// by the next cleanup if nothing else refers to them.
+func (f *Fs) Purge(ctx context.Context, dir string) error {
+	do := f.Fs.Features().Purge
+	if do == nil || path.Join(f.root, dir) == "" {
+		// Don't purge the root of the repository as it contains the chunks
+		return fs.ErrorCantPurge
+	}
+	return do(ctx, dir)
+}
+
+// Copy src to this remote using server-side copy operations.
+//
+// This copies the manifest only as the chunks are shared.
+func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	do := f.Fs.Features().Copy
+	srcObj, ok := src.(*Object)
+	if do == nil || !ok || !f.sameRepo(srcObj.f) {
+		return nil, fs.ErrorCantCopy
+	}
+	if f.reserved(remote) {
+		return nil, fs.ErrorCantCopy
+	}
+	baseObj, err := do(ctx, srcObj.base, remote)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{f: f, remote: remote, base: baseObj, m: srcObj.m}, nil
+}
+
+// Move src to this remote using server-side move operations.
+//
+// This moves the manifest only as the chunks are shared.
+func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	do := f.Fs.Features().Move
+	srcObj, ok := src.(*Object)
+	if do == nil || !ok || !f.sameRepo(srcObj.f) {
+		return nil, fs.ErrorCantMove
+	}
+	if f.reserved(remote) {
+		return nil, fs.ErrorCantMove
+	}
+	baseObj, err := do(ctx, srcObj.base, remote)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{f: f, remote: remote, base: baseObj, m: srcObj.m}, nil
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote
+// using server-side move operations.
+//
+// If it isn't possible then return fs.ErrorCantDirMove
+//
+// If destination exists then return fs.ErrorDirExists
+func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string) error {
+	do := f.Fs.Features().DirMove
+	srcFs, ok := src.(*Fs)
+	if do == nil || !ok || !f.sameRepo(srcFs) {
+		return fs.ErrorCantDirMove
+	}
+	if f.reserved(dstRemote) || srcFs.reserved(srcRemote) {
+		return fs.ErrorCantDirMove
+	}
+	return do(ctx, srcFs.Fs, srcRemote, dstRemote)
+}
+
+// About gets quota information from the Fs
+func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
+	do := f.Fs.Features().About
+	if do == nil {
+		return nil, errors.New("not supported by underlying remote")
+	}
+	return do(ctx)
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs          = (*Fs)(nil)
+	_ fs.Purger      = (*Fs)(nil)
+	_ fs.Copier      = (*Fs)(nil)
+	_ fs.Mover       = (*Fs)(nil)
+	_ fs.DirMover    = (*Fs)(nil)
+	_ fs.PutStreamer = (*Fs)(nil)
+	_ fs.CleanUpper  = (*Fs)(nil)
+	_ fs.Abouter     = (*Fs)(nil)
+	_ fs.UnWrapper   = (*Fs)(nil)
+	_ fs.Wrapper     = (*Fs)(nil)
+	_ fs.Object      = (*Object)(nil)
+)
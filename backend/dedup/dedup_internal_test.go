@@ -0,0 +1,248 @@
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/fstest/fstests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkSizes splits data and returns the sizes of the chunks
+func chunkSizes(t *testing.T, data []byte, minSize, avgSize, maxSize int) (sizes []int) {
+	c, err := newChunker(bytes.NewReader(data), minSize, avgSize, maxSize)
+	require.NoError(t, err)
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			return sizes
+		}
+		require.NoError(t, err)
+		sizes = append(sizes, len(chunk))
+	}
+}
+
+func TestChunker(t *testing.T) {
+	data := make([]byte, 1024*1024)
+	_, _ = rand.New(rand.NewSource(1)).Read(data)
+
+	sizes := chunkSizes(t, data, 1024, 4096, 16384)
+	total := 0
+	for i, size := range sizes {
+		total += size
+		assert.LessOrEqual(t, size, 16384)
+		if i < len(sizes)-1 {
+			assert.GreaterOrEqual(t, size, 1024)
+		}
+	}
+	assert.Equal(t, len(data), total)
+	avg := len(data) / len(sizes)
+	assert.Greater(t, avg, 2048)
+	assert.Less(t, avg, 8192)
+
+	// Check the chunking is deterministic
+	assert.Equal(t, sizes, chunkSizes(t, data, 1024, 4096, 16384))
+
+	// Check nothing comes out of an empty stream
+	assert.Nil(t, chunkSizes(t, nil, 1024, 4096, 16384))
+
+	// Check bad sizes are rejected
+	_, err := newChunker(bytes.NewReader(data), 4096, 1024, 16384)
+	assert.Error(t, err)
+}
+
+// chunkCount returns the number of chunks stored
+func (f *Fs) chunkCount(ctx context.Context, t *testing.T) (n int) {
+	err := walk.ListR(ctx, f.chunks, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		n += len(entries)
+		return nil
+	})
+	if err == fs.ErrorDirNotFound {
+		return 0
+	}
+	require.NoError(t, err)
+	return n
+}
+
+// putData uploads data to remote returning the object
+func (f *Fs) putData(ctx context.Context, t *testing.T, remote string, data []byte) fs.Object {
+	item := fstest.Item{Path: remote, ModTime: fstest.Time("2001-02-03T04:05:06.499999999Z")}
+	return fstests.PutTestContents(ctx, t, f, &item, string(data), true)
+}
+
+// Test an insert only stores the chunks around it and that cleanup
+// removes the chunks which are no longer used.
+func (f *Fs) testDedupAndCleanUp(t *testing.T) {
+	ctx := context.Background()
+	if f.opt.AvgChunkSize > 64*1024 {
+		t.Skip("chunk size too large for test")
+	}
+	data := make([]byte, 64*int(f.opt.AvgChunkSize))
+	_, _ = rand.New(rand.NewSource(2)).Read(data)
+	grace := f.opt.CleanupGrace
+	f.opt.CleanupGrace = 0
+	defer func() { f.opt.CleanupGrace = grace }()
+	cleanUp := f.Features().CleanUp
+	require.NotNil(t, cleanUp, "cleanup should be supported whatever the base remote")
+	require.NoError(t, cleanUp(ctx))
+	before := f.chunkCount(ctx, t)
+
+	o1 := f.putData(ctx, t, "dedup/file1", data)
+	afterOne := f.chunkCount(ctx, t)
+	chunks := afterOne - before
+	assert.Greater(t, chunks, 16)
+
+	// Insert some bytes near the start
+	edited := append(append(append([]byte{}, data[:1000]...), "inserted"...), data[1000:]...)
+	o2 := f.putData(ctx, t, "dedup/file2", edited)
+	afterTwo := f.chunkCount(ctx, t)
+	assert.LessOrEqual(t, afterTwo-afterOne, 2, "an insert should only change the chunks near it")
+
+	// An identical file stores no new chunks
+	o3 := f.putData(ctx, t, "dedup/file3", data)
+	assert.Equal(t, afterTwo, f.chunkCount(ctx, t))
+
+	// Check a ranged read across chunks
+	in, err := o2.Open(ctx, &fs.RangeOption{Start: 900, End: 100000})
+	require.NoError(t, err)
+	got, err := io.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.Equal(t, edited[900:100001], got)
+
+	// Removing a file and cleaning up removes only its own chunks
+	require.NoError(t, o2.Remove(ctx))
+	require.NoError(t, cleanUp(ctx))
+	assert.Equal(t, afterOne, f.chunkCount(ctx, t))
+
+	// Check the remaining files are intact
+	for _, o := range []fs.Object{o1, o3} {
+		in, err := o.Open(ctx)
+		require.NoError(t, err)
+		got, err := io.ReadAll(in)
+		require.NoError(t, err)
+		require.NoError(t, in.Close())
+		assert.True(t, bytes.Equal(data, got), o.Remote())
+	}
+
+	require.NoError(t, operations.Purge(ctx, f, "dedup"))
+	require.NoError(t, cleanUp(ctx))
+	assert.Equal(t, before, f.chunkCount(ctx, t))
+}
+
+// Test an upload which reuses old unreferenced chunks while a cleanup
+// is running doesn't lose them.
+func (f *Fs) testCleanUpDuringUpload(t *testing.T) {
+	ctx := context.Background()
+	if f.opt.AvgChunkSize > 64*1024 {
+		t.Skip("chunk size too large for test")
+	}
+	if f.chunks.Precision() == fs.ModTimeNotSupported {
+		t.Skip("base remote doesn't support modification times")
+	}
+	data := make([]byte, 16*int(f.opt.AvgChunkSize))
+	_, _ = rand.New(rand.NewSource(3)).Read(data)
+	grace := time.Duration(f.opt.CleanupGrace)
+
+	// Leave some unreferenced chunks older than the grace period
+	o1 := f.putData(ctx, t, "dedup/file1", data)
+	old := time.Now().Add(-2 * grace)
+	err := walk.ListR(ctx, f.chunks, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			require.NoError(t, o.SetModTime(ctx, old))
+		})
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, o1.Remove(ctx))
+
+	// The cleanup reads the manifests before the upload writes its one
+	cutoff := time.Now().Add(-grace)
+	referenced, err := f.referencedChunks(ctx)
+	require.NoError(t, err)
+	o2 := f.putData(ctx, t, "dedup/file2", data)
+	require.NoError(t, f.removeChunks(ctx, referenced, cutoff))
+
+	// Check the upload is intact
+	in, err := o2.Open(ctx)
+	require.NoError(t, err)
+	got, err := io.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.True(t, bytes.Equal(data, got))
+
+	require.NoError(t, operations.Purge(ctx, f, "dedup"))
+	f.opt.CleanupGrace = 0
+	defer func() { f.opt.CleanupGrace = fs.Duration(grace) }()
+	require.NoError(t, f.CleanUp(ctx))
+}
+
+// Test PutStream is supported whatever the base remote
+func (f *Fs) testPutStream(t *testing.T) {
+	assert.NotNil(t, f.Features().PutStream)
+}
+
+// Test the chunk store is hidden and can't be written to
+func (f *Fs) testReserved(t *testing.T) {
+	ctx := context.Background()
+	if f.root != "" {
+		t.Skip("not at the root of the repository")
+	}
+	entries, err := f.List(ctx, "")
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotEqual(t, chunkDir, entry.Remote())
+	}
+	_, err = f.NewObject(ctx, chunkDir+"/ab/abcd")
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+	assert.Error(t, f.Mkdir(ctx, chunkDir+"/potato"))
+}
+
+// Test a chunk whose contents don't match its hash fails the read
+func (f *Fs) testCorruptChunk(t *testing.T) {
+	ctx := context.Background()
+	data := []byte("some data which will be corrupted")
+	o := f.putData(ctx, t, "dedup/corrupt", data)
+	defer func() {
+		require.NoError(t, operations.Purge(ctx, f, "dedup"))
+	}()
+	chunks := o.(*Object).m.Chunks
+	require.Len(t, chunks, 1)
+
+	// Overwrite the chunk with data of the same size
+	bad := bytes.ToUpper(data)
+	remote := chunkRemote(chunks[0].Hash)
+	info := object.NewStaticObjectInfo(remote, time.Now(), int64(len(bad)), true, nil, f.chunks)
+	_, err := f.chunks.Put(ctx, bytes.NewReader(bad), info)
+	require.NoError(t, err)
+
+	for _, options := range [][]fs.OpenOption{nil, {&fs.RangeOption{Start: 5, End: 10}}} {
+		in, err := o.Open(ctx, options...)
+		require.NoError(t, err)
+		_, err = io.ReadAll(in)
+		assert.ErrorContains(t, err, "hash mismatch")
+		require.NoError(t, in.Close())
+	}
+	require.NoError(t, operations.Purge(ctx, f.chunks, chunks[0].Hash[:2]))
+}
+
+// InternalTest dispatches all internal tests
+func (f *Fs) InternalTest(t *testing.T) {
+	t.Run("DedupAndCleanUp", f.testDedupAndCleanUp)
+	t.Run("CleanUpDuringUpload", f.testCleanUpDuringUpload)
+	t.Run("PutStream", f.testPutStream)
+	t.Run("Reserved", f.testReserved)
+	t.Run("CorruptChunk", f.testCorruptChunk)
+}
+
+var _ fstests.InternalTester = (*Fs)(nil)
@@ -0,0 +1,423 @@
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	gohash "hash"
+	"io"
+	"path"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/object"
+)
+
+const (
+	manifestFormat  = "rclone-dedup"
+	manifestVersion = 1
+)
+
+// errNotManifest is returned when a file in the base remote isn't a
+// dedup manifest
+var errNotManifest = errors.New("not a dedup manifest")
+
+// manifest describes a file as the list of chunks it is made from
+type manifest struct {
+	Format  string     `json:"format"`
+	Version int        `json:"ver"`
+	Size    int64      `json:"size"`
+	ModTime time.Time  `json:"mtime"`
+	MD5     string     `json:"md5"`
+	SHA256  string     `json:"sha256"`
+	Chunks  []chunkRef `json:"chunks"`
+}
+
+// chunkRef refers to a chunk by the SHA-256 of its contents
+type chunkRef struct {
+	Hash string `json:"h"`
+	Size int64  `json:"n"`
+}
+
+// readManifest reads and checks the manifest in baseObj
+func readManifest(ctx context.Context, baseObj fs.Object) (m *manifest, err error) {
+	in, err := baseObj.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.CheckClose(in, &err)
+	m = new(manifest)
+	if err = json.NewDecoder(in).Decode(m); err != nil || m.Format != manifestFormat {
+		return nil, errNotManifest
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported dedup manifest version %d", m.Version)
+	}
+	return m, nil
+}
+
+// chunkRemote returns the path of the chunk with hash h in the chunk store
+func chunkRemote(h string) string {
+	return path.Join(h[:2], h)
+}
+
+// Object describes a deduplicated file
+type Object struct {
+	f      *Fs
+	remote string
+	base   fs.Object // the manifest in the base remote
+	m      *manifest // the contents of the manifest
+}
+
+// cachedManifest is a manifest stored in Fs.manifests
+type cachedManifest struct {
+	fingerprint string    // fingerprint of the manifest object it was read from
+	m           *manifest // the contents of the manifest
+}
+
+// cachedManifest returns the manifest in baseObj from the cache if
+// baseObj hasn't changed since it was read, or nil.
+func (f *Fs) cachedManifest(ctx context.Context, baseObj fs.Object) *manifest {
+	value, found := f.manifests.GetMaybe(baseObj.Remote())
+	if !found {
+		return nil
+	}
+	cached := value.(cachedManifest)
+	if cached.fingerprint != fs.Fingerprint(ctx, baseObj, true) {
+		return nil
+	}
+	return cached.m
+}
+
+// cacheManifest stores m as the contents of baseObj in the cache
+func (f *Fs) cacheManifest(ctx context.Context, baseObj fs.Object, m *manifest) {
+	f.manifests.Put(baseObj.Remote(), cachedManifest{
+		fingerprint: fs.Fingerprint(ctx, baseObj, true),
+		m:           m,
+	})
+}
+
+// newObject makes an Object from the manifest in baseObj
+//
+// The manifest is only read if it isn't in the cache already.
+func (f *Fs) newObject(ctx context.Context, baseObj fs.Object) (*Object, error) {
+	m := f.cachedManifest(ctx, baseObj)
+	if m == nil {
+		var err error
+		m, err = readManifest(ctx, baseObj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", baseObj.Remote(), err)
+		}
+		f.cacheManifest(ctx, baseObj, m)
+	}
+	return &Object{
+		f:      f,
+		remote: baseObj.Remote(),
+		base:   baseObj,
+		m:      m,
+	}, nil
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *Object) Fs() fs.Info { return o.f }
+
+// Remote returns the remote path
+func (o *Object) Remote() string { return o.remote }
+
+// String returns a description of the Object
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Size returns the size of the file
+func (o *Object) Size() int64 { return o.m.Size }
+
+// ModTime returns the modification time of the file
+func (o *Object) ModTime(ctx context.Context) time.Time { return o.m.ModTime }
+
+// Storable returns whether object is storable
+func (o *Object) Storable() bool { return true }
+
+// Hash returns the selected checksum of the file
+func (o *Object) Hash(ctx context.Context, ht hash.Type) (string, error) {
+	switch ht {
+	case hash.MD5:
+		return o.m.MD5, nil
+	case hash.SHA256:
+		return o.m.SHA256, nil
+	}
+	return "", hash.ErrUnsupported
+}
+
+// SetModTime sets the modification time of the file by rewriting
+// the manifest
+func (o *Object) SetModTime(ctx context.Context, t time.Time) error {
+	m := *o.m
+	m.ModTime = t
+	return o.putManifest(ctx, &m)
+}
+
+// Remove the manifest.
+//
+// The chunks are left for cleanup to remove as they may be shared.
+func (o *Object) Remove(ctx context.Context) error {
+	o.f.manifests.Delete(o.base.Remote())
+	return o.base.Remove(ctx)
+}
+
+// Update the object with the contents of the io.Reader, modTime and size
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	return o.upload(ctx, in, src)
+}
+
+// upload splits in into chunks, stores the ones the repository
+// doesn't have yet, then writes the manifest.
+func (o *Object) upload(ctx context.Context, in io.Reader, src fs.ObjectInfo) error {
+	hasher, err := hash.NewMultiHasherTypes(o.f.Hashes())
+	if err != nil {
+		return err
+	}
+	c, err := newChunker(io.TeeReader(in, hasher), int(o.f.opt.MinChunkSize), int(o.f.opt.AvgChunkSize), int(o.f.opt.MaxChunkSize))
+	if err != nil {
+		return err
+	}
+	m := &manifest{
+		Format:  manifestFormat,
+		Version: manifestVersion,
+		ModTime: src.ModTime(ctx),
+		Chunks:  []chunkRef{},
+	}
+	// chunks stored or refreshed by this upload
+	stored := map[string]struct{}{}
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(chunk)
+		ref := chunkRef{
+			Hash: hex.EncodeToString(sum[:]),
+			Size: int64(len(chunk)),
+		}
+		if _, ok := stored[ref.Hash]; !ok {
+			if err = o.f.putChunk(ctx, ref.Hash, chunk); err != nil {
+				return fmt.Errorf("failed to store chunk: %w", err)
+			}
+			stored[ref.Hash] = struct{}{}
+		}
+		m.Chunks = append(m.Chunks, ref)
+		m.Size += ref.Size
+	}
+	if size := src.Size(); size >= 0 && size != m.Size {
+		return fmt.Errorf("upload size mismatch: expecting %d but read %d", size, m.Size)
+	}
+	sums := hasher.Sums()
+	m.MD5 = sums[hash.MD5]
+	m.SHA256 = sums[hash.SHA256]
+	return o.putManifest(ctx, m)
+}
+
+// putManifest writes m as the manifest of o
+func (o *Object) putManifest(ctx context.Context, m *manifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	info := object.NewStaticObjectInfo(o.remote, m.ModTime, int64(len(data)), true, nil, o.f.Fs)
+	if o.base != nil {
+		err = o.base.Update(ctx, bytes.NewReader(data), info)
+	} else {
+		o.base, err = o.f.Fs.Put(ctx, bytes.NewReader(data), info)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	o.f.cacheManifest(ctx, o.base, m)
+	o.m = m
+	return nil
+}
+
+// putChunk stores the chunk with hash h unless it exists already.
+//
+// If it exists its modification time is set to now so that a cleanup
+// running at the same time as this upload doesn't remove it before the
+// manifest which refers to it is written.
+func (f *Fs) putChunk(ctx context.Context, h string, chunk []byte) error {
+	remote := chunkRemote(h)
+	now := time.Now()
+	o, err := f.chunks.NewObject(ctx, remote)
+	switch {
+	case err == nil:
+		fs.Debugf(f, "Chunk %s already stored", h)
+		err = o.SetModTime(ctx, now)
+		if !errors.Is(err, fs.ErrorCantSetModTime) && !errors.Is(err, fs.ErrorCantSetModTimeWithoutDelete) {
+			return err
+		}
+		// Upload it again to refresh the modification time
+		fs.Debugf(f, "Chunk %s can't have its modification time set - uploading it again", h)
+	case errors.Is(err, fs.ErrorObjectNotFound):
+	default:
+		return err
+	}
+	info := object.NewStaticObjectInfo(remote, now, int64(len(chunk)), true, nil, f.chunks)
+	_, err = f.chunks.Put(ctx, bytes.NewReader(chunk), info)
+	return err
+}
+
+// Open an object for read
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	var offset, limit int64 = 0, -1
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.SeekOption:
+			offset = x.Offset
+		case *fs.RangeOption:
+			offset, limit = x.Decode(o.Size())
+		default:
+			if option.Mandatory() {
+				fs.Logf(o, "Unsupported mandatory option: %v", option)
+			}
+		}
+	}
+	// Skip the chunks before offset
+	chunks := o.m.Chunks
+	for len(chunks) > 0 && offset >= chunks[0].Size {
+		offset -= chunks[0].Size
+		chunks = chunks[1:]
+	}
+	return &chunkReader{
+		ctx:    ctx,
+		f:      o.f,
+		chunks: chunks,
+		offset: offset,
+		limit:  limit,
+	}, nil
+}
+
+// chunkReader reads a sequence of chunks
+//
+// Each chunk is read in full, even when the read starts or finishes
+// part way through it, and its SHA-256 checked against its name so
+// corrupted chunks return an error rather than bad data.
+type chunkReader struct {
+	ctx    context.Context
+	f      *Fs
+	chunks []chunkRef    // chunks still to read
+	offset int64         // offset into the first chunk
+	limit  int64         // bytes still to return or -1 for all
+	ref    chunkRef      // the chunk being read
+	in     io.ReadCloser // the chunk being read or nil
+	hasher gohash.Hash   // SHA-256 of the chunk being read
+}
+
+// open the next chunk
+func (r *chunkReader) open() error {
+	ref := r.chunks[0]
+	r.chunks = r.chunks[1:]
+	o, err := r.f.chunks.NewObject(r.ctx, chunkRemote(ref.Hash))
+	if err != nil {
+		return fmt.Errorf("failed to find chunk %s: %w", ref.Hash, err)
+	}
+	if o.Size() != ref.Size {
+		return fmt.Errorf("chunk %s is corrupted: expecting size %d but is %d", ref.Hash, ref.Size, o.Size())
+	}
+	r.in, err = o.Open(r.ctx)
+	if err != nil {
+		return err
+	}
+	r.ref = ref
+	r.hasher = sha256.New()
+	// Skip to the offset hashing the data skipped
+	if r.offset > 0 {
+		_, err = io.CopyN(r.hasher, r.in, r.offset)
+		r.offset = 0
+		if err != nil {
+			return r.fail(fmt.Errorf("failed to read chunk %s: %w", ref.Hash, err))
+		}
+	}
+	return nil
+}
+
+// check the hash of the chunk just read and close it
+func (r *chunkReader) check() error {
+	err := r.in.Close()
+	r.in = nil
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(r.hasher.Sum(nil)); got != r.ref.Hash {
+		return fmt.Errorf("chunk %s is corrupted: hash mismatch: got %s", r.ref.Hash, got)
+	}
+	return nil
+}
+
+// fail closes the chunk being read and returns err
+func (r *chunkReader) fail(err error) error {
+	_ = r.in.Close()
+	r.in = nil
+	return err
+}
+
+// Read bytes from the chunks
+func (r *chunkReader) Read(p []byte) (n int, err error) {
+	if r.limit == 0 {
+		// Read the rest of the chunk so its hash can be checked
+		if r.in != nil {
+			if _, err = io.Copy(r.hasher, r.in); err != nil {
+				return 0, r.fail(err)
+			}
+			if err = r.check(); err != nil {
+				return 0, err
+			}
+		}
+		return 0, io.EOF
+	}
+	if r.limit > 0 && int64(len(p)) > r.limit {
+		p = p[:r.limit]
+	}
+	defer func() {
+		if r.limit > 0 {
+			r.limit -= int64(n)
+		}
+	}()
+	for {
+		if r.in == nil {
+			if len(r.chunks) == 0 {
+				return 0, io.EOF
+			}
+			if err = r.open(); err != nil {
+				return 0, err
+			}
+		}
+		n, err = r.in.Read(p)
+		_, _ = r.hasher.Write(p[:n])
+		if err == io.EOF {
+			err = r.check()
+			if n > 0 || err != nil {
+				return n, err
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Close the chunk being read
+func (r *chunkReader) Close() error {
+	if r.in == nil {
+		return nil
+	}
+	err := r.in.Close()
+	r.in = nil
+	return err
+}
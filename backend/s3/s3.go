@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	v4signer "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
@@ -2635,6 +2636,114 @@ Rclone limitations with Directory Buckets:
 			Default:  false,
 			Advanced: true,
 			Provider: "AWS",
+		}, {
+			Name: "object_lock_mode",
+			Help: strings.ReplaceAll(`Object Lock mode to set on uploaded objects.
+
+The bucket must have Object Lock enabled. This must be set with
+|object_lock_retain_until_date| and stops the objects being deleted or
+overwritten until that date.
+
+This can be overridden for each object with the |object-lock-mode|
+metadata key.
+`, "|", "`"),
+			Default:  "",
+			Advanced: true,
+			Examples: []fs.OptionExample{{
+				Value: "",
+				Help:  "None",
+			}, {
+				Value: "GOVERNANCE",
+				Help:  "Users with the s3:BypassGovernanceRetention permission can remove the lock",
+			}, {
+				Value: "COMPLIANCE",
+				Help:  "Nobody can remove the lock, including the root user",
+			}},
+		}, {
+			Name: "object_lock_retain_until_date",
+			Help: strings.ReplaceAll(`Date until which uploaded objects are locked.
+
+This is either a time like |2030-01-02T15:04:05Z| or a duration like
+|30d| which is measured from the time the object is uploaded. It must
+be set with |object_lock_mode|.
+
+This can be overridden for each object with the
+|object-lock-retain-until-date| metadata key.
+`, "|", "`"),
+			Default:  "",
+			Advanced: true,
+		}, {
+			Name: "object_lock_legal_hold_status",
+			Help: strings.ReplaceAll(`Legal hold status to set on uploaded objects.
+
+An object with a legal hold can't be deleted or overwritten until the
+legal hold is removed, whatever its retention date is. The bucket must
+have Object Lock enabled.
+
+This can be overridden for each object with the
+|object-lock-legal-hold-status| metadata key.
+`, "|", "`"),
+			Default:  "",
+			Advanced: true,
+			Examples: []fs.OptionExample{{
+				Value: "",
+				Help:  "None",
+			}, {
+				Value: "ON",
+				Help:  "Place a legal hold on the objects",
+			}, {
+				Value: "OFF",
+				Help:  "Don't place a legal hold on the objects",
+			}},
+		}, {
+			Name: "checksum_algorithm",
+			Help: strings.ReplaceAll(`Additional checksum algorithm to use as the hash of objects.
+
+S3 can store a checksum of each object as well as the ETag and return
+it in the |x-amz-checksum-*| headers. If this is set then rclone
+reads this checksum and uses it as a hash of the backend as well as
+MD5, so |rclone check|, |rclone hashsum| and |--checksum| work
+without downloading the objects.
+
+When uploading objects which aren't multipart rclone sends this
+checksum if the source can provide it so S3 checks the data and stores
+it. Server side copies which aren't multipart ask S3 to calculate it.
+
+Multipart uploads with CRC32, CRC32C or CRC64NVME ask S3 for a
+FULL_OBJECT checksum and send the checksum of each part, and of the
+whole object if the source can provide it, so these objects get a
+checksum of the whole object too.
+
+SHA1 and SHA256 can only be COMPOSITE checksums for multipart
+uploads, which are made from the checksums of the parts and can't be
+used as hashes, so multipart uploads don't send them and objects
+uploaded as multipart have no SHA1 or SHA256 hash. Whether multipart
+copies get a checksum which covers the whole object depends on the
+provider.
+
+Reading the checksum needs a HEAD request for each object.
+`, "|", "`"),
+			Default:  "",
+			Advanced: true,
+			Examples: []fs.OptionExample{{
+				Value: "",
+				Help:  "Use the MD5 from the ETag or metadata",
+			}, {
+				Value: "CRC32",
+				Help:  "CRC-32",
+			}, {
+				Value: "CRC32C",
+				Help:  "CRC-32C (Castagnoli)",
+			}, {
+				Value: "CRC64NVME",
+				Help:  "CRC-64/NVME",
+			}, {
+				Value: "SHA1",
+				Help:  "SHA-1",
+			}, {
+				Value: "SHA256",
+				Help:  "SHA-256",
+			}},
 		}, {
 			Name: "sdk_log_mode",
 			Help: strings.ReplaceAll(`Set to debug the SDK
@@ -2750,6 +2859,21 @@ var systemMetadataInfo = map[string]fs.MetadataHelp{
 		Example:  "2006-01-02T15:04:05.999999999Z07:00",
 		ReadOnly: true,
 	},
+	"object-lock-mode": {
+		Help:    "Object Lock mode, set with object-lock-retain-until-date",
+		Type:    "string",
+		Example: "GOVERNANCE",
+	},
+	"object-lock-retain-until-date": {
+		Help:    "Time until which the object is locked, set with object-lock-mode",
+		Type:    "RFC 3339",
+		Example: "2030-01-02T15:04:05Z",
+	},
+	"object-lock-legal-hold-status": {
+		Help:    "Object Lock legal hold status",
+		Type:    "string",
+		Example: "ON",
+	},
 }
 
 // Options defines the configuration for this backend
@@ -2810,6 +2934,10 @@ type Options struct {
 	UseUnsignedPayload    fs.Tristate          `config:"use_unsigned_payload"`
 	SDKLogMode            sdkLogMode           `config:"sdk_log_mode"`
 	DirectoryBucket       bool                 `config:"directory_bucket"`
+	ObjectLockMode        string               `config:"object_lock_mode"`
+	ObjectLockRetainUntil string               `config:"object_lock_retain_until_date"`
+	ObjectLockLegalHold   string               `config:"object_lock_legal_hold_status"`
+	ChecksumAlgorithm     string               `config:"checksum_algorithm"`
 }
 
 // Fs represents a remote s3 server
@@ -2831,6 +2959,7 @@ type Fs struct {
 	versioningMu   sync.Mutex
 	versioning     fs.Tristate // if set bucket is using versions
 	warnCompressed sync.Once   // warn once about compressed files
+	checksumHash   hash.Type   // hash to read from the x-amz-checksum-* headers or hash.None
 }
 
 // Object describes a s3 object
@@ -2854,6 +2983,14 @@ type Object struct {
 	contentDisposition *string // Content-Disposition: header
 	contentEncoding    *string // Content-Encoding: header
 	contentLanguage    *string // Content-Language: header
+
+	// Object Lock status
+	objectLockMode            *string    // GOVERNANCE or COMPLIANCE
+	objectLockRetainUntilDate *time.Time // locked until this time
+	objectLockLegalHoldStatus *string    // ON or OFF
+
+	// Checksums read from the x-amz-checksum-* headers, nil if not read
+	checksums map[hash.Type]string
 }
 
 // safely dereference the pointer, returning a zero T if nil
@@ -3204,6 +3341,69 @@ func checkCopyCutoff(cs fs.SizeSuffix) error {
 	return nil
 }
 
+// parseRetainUntil parses an Object Lock retain until date which is
+// either a time or a duration from now
+func parseRetainUntil(s string, now time.Time) (t time.Time, err error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		d, durationErr := fs.ParseDuration(s)
+		if durationErr != nil {
+			return t, fmt.Errorf("%q is not a time or a duration", s)
+		}
+		t = now.Add(d)
+	}
+	if !t.After(now) {
+		return t, fmt.Errorf("%q is not in the future", s)
+	}
+	return t, nil
+}
+
+// checkObjectLock checks and normalises the Object Lock options
+func checkObjectLock(opt *Options) error {
+	opt.ObjectLockMode = strings.ToUpper(opt.ObjectLockMode)
+	switch types.ObjectLockMode(opt.ObjectLockMode) {
+	case "", types.ObjectLockModeGovernance, types.ObjectLockModeCompliance:
+	default:
+		return fmt.Errorf("object_lock_mode must be GOVERNANCE or COMPLIANCE not %q", opt.ObjectLockMode)
+	}
+	if (opt.ObjectLockMode == "") != (opt.ObjectLockRetainUntil == "") {
+		return errors.New("object_lock_mode and object_lock_retain_until_date must be set together")
+	}
+	if opt.ObjectLockRetainUntil != "" {
+		_, err := parseRetainUntil(opt.ObjectLockRetainUntil, time.Now())
+		if err != nil {
+			return fmt.Errorf("object_lock_retain_until_date: %w", err)
+		}
+	}
+	opt.ObjectLockLegalHold = strings.ToUpper(opt.ObjectLockLegalHold)
+	switch types.ObjectLockLegalHoldStatus(opt.ObjectLockLegalHold) {
+	case "", types.ObjectLockLegalHoldStatusOn, types.ObjectLockLegalHoldStatusOff:
+	default:
+		return fmt.Errorf("object_lock_legal_hold_status must be ON or OFF not %q", opt.ObjectLockLegalHold)
+	}
+	return nil
+}
+
+// objectLockDefaults returns the Object Lock settings from the config
+// for an object uploaded at now
+func (f *Fs) objectLockDefaults(now time.Time) (mode types.ObjectLockMode, retainUntil *time.Time, legalHold types.ObjectLockLegalHoldStatus) {
+	if f.opt.ObjectLockRetainUntil != "" {
+		// This was checked in NewFs
+		t, err := parseRetainUntil(f.opt.ObjectLockRetainUntil, now)
+		if err != nil {
+			fs.Errorf(f, "Not setting Object Lock retention: %v", err)
+		} else {
+			mode, retainUntil = types.ObjectLockMode(f.opt.ObjectLockMode), &t
+		}
+	}
+	return mode, retainUntil, types.ObjectLockLegalHoldStatus(f.opt.ObjectLockLegalHold)
+}
+
 func checkUploadCutoff(cs fs.SizeSuffix) error {
 	if cs > maxUploadCutoff {
 		return fmt.Errorf("%s is greater than %s", cs, maxUploadCutoff)
@@ -3526,6 +3726,14 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 	if opt.BucketACL == "" {
 		opt.BucketACL = opt.ACL
 	}
+	err = checkObjectLock(opt)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	checksumHash, err := parseChecksumAlgorithm(opt.ChecksumAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
 	if opt.SSECustomerKeyBase64 != "" && opt.SSECustomerKey != "" {
 		return nil, errors.New("s3: can't use sse_customer_key and sse_customer_key_base64 at the same time")
 	} else if opt.SSECustomerKeyBase64 != "" {
@@ -3567,6 +3775,8 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 		cache:   bucket.NewCache(),
 		srv:     srv,
 		srvRest: rest.NewClient(fshttp.NewClient(ctx)),
+
+		checksumHash: checksumHash,
 	}
 	if opt.ServerSideEncryption == "aws:kms" || opt.SSECustomerAlgorithm != "" {
 		// From: https://docs.aws.amazon.com/AmazonS3/latest/API/RESTCommonResponseHeaders.html
@@ -3598,6 +3808,7 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 		SetTier:           true,
 		GetTier:           true,
 		SlowModTime:       true,
+		ChunkWriterCopies: true,
 	}).Fill(ctx, f)
 	if opt.Provider == "Storj" {
 		f.features.SetTier = false
@@ -3613,6 +3824,7 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 	if !opt.UseMultipartUploads.Value {
 		fs.Debugf(f, "Disabling multipart uploads")
 		f.features.OpenChunkWriter = nil
+		f.features.ChunkWriterCopies = false
 	}
 
 	if f.rootBucket != "" && f.rootDirectory != "" && !opt.NoHeadObject && !strings.HasSuffix(root, "/") {
@@ -4641,6 +4853,10 @@ func (f *Fs) copy(ctx context.Context, req *s3.CopyObjectInput, dstBucket, dstPa
 	if src.bytes >= int64(f.opt.CopyCutoff) {
 		return f.copyMultipart(ctx, req, dstBucket, dstPath, srcBucket, srcPath, src)
 	}
+	if f.checksumHash != hash.None {
+		// Ask S3 to calculate the checksum of the copy
+		req.ChecksumAlgorithm = types.ChecksumAlgorithm(checksumAlgorithmName(f.checksumHash))
+	}
 	return f.pacer.Call(func() (bool, error) {
 		_, err := f.c.CopyObject(ctx, req)
 		return f.shouldRetry(ctx, err)
@@ -4808,6 +5024,9 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 		}
 		setFrom_s3CopyObjectInput_s3PutObjectInput(&req, ui.req)
 		req.MetadataDirective = types.MetadataDirectiveReplace
+	} else {
+		// Object Lock settings aren't copied from the source
+		req.ObjectLockMode, req.ObjectLockRetainUntilDate, req.ObjectLockLegalHoldStatus = f.objectLockDefaults(time.Now())
 	}
 
 	err = f.copy(ctx, &req, dstBucket, dstPath, srcBucket, srcPath, srcObj)
@@ -4819,6 +5038,9 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 
 // Hashes returns the supported hash sets.
 func (f *Fs) Hashes() hash.Set {
+	if f.checksumHash != hash.None {
+		return hash.NewHashSet(hash.MD5, f.checksumHash)
+	}
 	return hash.Set(hash.MD5)
 }
 
@@ -5016,6 +5238,59 @@ supplied.
 It may return "Enabled", "Suspended" or "Unversioned". Note that once versioning
 has been enabled the status can't be set back to "Unversioned".
 `,
+}, {
+	Name:  "retention",
+	Short: "Set or extend the Object Lock retention of objects.",
+	Long: `This command sets the Object Lock retention of one or more objects
+in a bucket with Object Lock enabled.
+
+Usage Examples:
+
+    rclone backend retention s3:bucket/path/to/object -o retain-until-date=2030-01-02T15:04:05Z
+    rclone backend retention s3:bucket/path/to/directory -o mode=COMPLIANCE -o retain-until-date=365d
+
+The retain-until-date is either a time or a duration from now. If mode
+isn't given then the current mode of the object is kept, or the
+object_lock_mode from the config is used if it doesn't have one.
+
+The retention can always be extended, but can only be shortened or
+removed from objects in GOVERNANCE mode using -o bypass-governance,
+which needs the s3:BypassGovernanceRetention permission.
+
+This command obeys the filters and, with --s3-versions, can be used on
+old versions. Test first with --interactive/-i or --dry-run flags.
+
+It returns a list of status dictionaries with Remote and Status
+keys. The Status will be OK if it was successful or an error message
+if not.
+
+The Object Lock settings of objects can be read as metadata, for
+example with rclone lsjson -M.
+`,
+	Opts: map[string]string{
+		"retain-until-date": "Time or duration from now to keep the objects locked until",
+		"mode":              "Object Lock mode: GOVERNANCE|COMPLIANCE",
+		"bypass-governance": "Set to allow shortening the retention of objects in GOVERNANCE mode",
+	},
+}, {
+	Name:  "legal-hold",
+	Short: "Set or remove the Object Lock legal hold on objects.",
+	Long: `This command places or removes a legal hold on one or more
+objects in a bucket with Object Lock enabled. An object with a legal
+hold can't be deleted or overwritten until it is removed.
+
+Usage Examples:
+
+    rclone backend legal-hold s3:bucket/path/to/object ON
+    rclone backend legal-hold s3:bucket/path/to/directory OFF
+
+This command obeys the filters and, with --s3-versions, can be used on
+old versions. Test first with --interactive/-i or --dry-run flags.
+
+It returns a list of status dictionaries with Remote and Status
+keys. The Status will be OK if it was successful or an error message
+if not.
+`,
 }, {
 	Name:  "set",
 	Short: "Set command for updating the config parameters.",
@@ -5137,6 +5412,10 @@ func (f *Fs) Command(ctx context.Context, name string, arg []string, opt map[str
 		return nil, f.CleanUpHidden(ctx)
 	case "versioning":
 		return f.setGetVersioning(ctx, arg...)
+	case "retention":
+		return f.setRetention(ctx, opt)
+	case "legal-hold":
+		return f.setLegalHold(ctx, arg)
 	case "set":
 		newOpt := f.opt
 		err := configstruct.Set(configmap.Simple(opt), &newOpt)
@@ -5160,6 +5439,140 @@ func (f *Fs) Command(ctx context.Context, name string, arg []string, opt map[str
 	}
 }
 
+// Returned from "retention" and "legal-hold"
+type objectLockStatus struct {
+	Status string
+	Remote string
+}
+
+// objectLockCommand runs fn on each object for the Object Lock
+// commands returning the status of each
+func (f *Fs) objectLockCommand(ctx context.Context, what string, fn func(o *Object) error) (out []objectLockStatus, err error) {
+	var outMu sync.Mutex
+	out = []objectLockStatus{}
+	err = operations.ListFn(ctx, f, func(obj fs.Object) {
+		// Remember this is run --checkers times concurrently
+		st := objectLockStatus{Status: "OK", Remote: obj.Remote()}
+		defer func() {
+			outMu.Lock()
+			out = append(out, st)
+			outMu.Unlock()
+		}()
+		if operations.SkipDestructive(ctx, obj, what) {
+			return
+		}
+		o, ok := obj.(*Object)
+		if !ok {
+			st.Status = "Not an S3 object"
+			return
+		}
+		err := fn(o)
+		if err != nil {
+			st.Status = err.Error()
+		}
+	})
+	return out, err
+}
+
+// setRetention sets the Object Lock retention on objects for the
+// "retention" command
+func (f *Fs) setRetention(ctx context.Context, opt map[string]string) (out []objectLockStatus, err error) {
+	if opt["retain-until-date"] == "" {
+		return nil, errors.New("need -o retain-until-date")
+	}
+	retainUntil, err := parseRetainUntil(opt["retain-until-date"], time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("bad retain-until-date: %w", err)
+	}
+	mode := strings.ToUpper(opt["mode"])
+	switch types.ObjectLockRetentionMode(mode) {
+	case "", types.ObjectLockRetentionModeGovernance, types.ObjectLockRetentionModeCompliance:
+	default:
+		return nil, fmt.Errorf("bad mode %q: must be GOVERNANCE or COMPLIANCE", mode)
+	}
+	_, bypass := opt["bypass-governance"]
+	return f.objectLockCommand(ctx, "set retention", func(o *Object) error {
+		mode := mode
+		if mode == "" {
+			// Keep the current mode if there is one
+			err := o.readMetaData(ctx)
+			if err != nil {
+				return err
+			}
+			mode = deref(o.objectLockMode)
+		}
+		if mode == "" {
+			mode = f.opt.ObjectLockMode
+		}
+		if mode == "" {
+			return errors.New("no Object Lock mode: use -o mode")
+		}
+		bucket, bucketPath := o.split()
+		req := s3.PutObjectRetentionInput{
+			Bucket:    &bucket,
+			Key:       &bucketPath,
+			VersionId: o.versionID,
+			Retention: &types.ObjectLockRetention{
+				Mode:            types.ObjectLockRetentionMode(mode),
+				RetainUntilDate: &retainUntil,
+			},
+		}
+		if bypass {
+			req.BypassGovernanceRetention = aws.Bool(true)
+		}
+		if f.opt.RequesterPays {
+			req.RequestPayer = types.RequestPayerRequester
+		}
+		err := f.pacer.Call(func() (bool, error) {
+			_, err := f.c.PutObjectRetention(ctx, &req)
+			return f.shouldRetry(ctx, err)
+		})
+		if err != nil {
+			return err
+		}
+		o.objectLockMode = &mode
+		o.objectLockRetainUntilDate = &retainUntil
+		return nil
+	})
+}
+
+// setLegalHold sets or removes the Object Lock legal hold on objects
+// for the "legal-hold" command
+func (f *Fs) setLegalHold(ctx context.Context, arg []string) (out []objectLockStatus, err error) {
+	if len(arg) != 1 {
+		return nil, errors.New("need ON or OFF argument")
+	}
+	status := strings.ToUpper(arg[0])
+	switch types.ObjectLockLegalHoldStatus(status) {
+	case types.ObjectLockLegalHoldStatusOn, types.ObjectLockLegalHoldStatusOff:
+	default:
+		return nil, fmt.Errorf("bad legal hold status %q: must be ON or OFF", arg[0])
+	}
+	return f.objectLockCommand(ctx, "set legal hold", func(o *Object) error {
+		bucket, bucketPath := o.split()
+		req := s3.PutObjectLegalHoldInput{
+			Bucket:    &bucket,
+			Key:       &bucketPath,
+			VersionId: o.versionID,
+			LegalHold: &types.ObjectLockLegalHold{
+				Status: types.ObjectLockLegalHoldStatus(status),
+			},
+		}
+		if f.opt.RequesterPays {
+			req.RequestPayer = types.RequestPayerRequester
+		}
+		err := f.pacer.Call(func() (bool, error) {
+			_, err := f.c.PutObjectLegalHold(ctx, &req)
+			return f.shouldRetry(ctx, err)
+		})
+		if err != nil {
+			return err
+		}
+		o.objectLockLegalHoldStatus = &status
+		return nil
+	})
+}
+
 // Returned from "restore-status"
 type restoreStatusOut struct {
 	Remote        string
@@ -5529,15 +5942,28 @@ func (o *Object) setMD5FromEtag(etag string) {
 	o.md5 = hash
 }
 
-// Hash returns the Md5sum of an object returning a lowercase hex string
+// Hash returns the Md5sum of an object or the checksum set with
+// checksum_algorithm returning a lowercase hex string
 func (o *Object) Hash(ctx context.Context, t hash.Type) (string, error) {
-	if t != hash.MD5 {
+	if t != hash.MD5 && !o.fs.Hashes().Contains(t) {
 		return "", hash.ErrUnsupported
 	}
 	// If decompressing, erase the hash
 	if o.bytes < 0 {
 		return "", nil
 	}
+	if t != hash.MD5 {
+		// Read the checksums if we haven't already
+		if o.checksums == nil {
+			resp, err := o.headObject(ctx)
+			if err != nil {
+				return "", err
+			}
+			o.setMetaData(resp)
+			o.checksums = checksumsFromResponse(resp)
+		}
+		return o.checksums[t], nil
+	}
 	// If we haven't got an MD5, then check the metadata
 	if o.md5 == "" {
 		err := o.readMetaData(ctx)
@@ -5548,6 +5974,105 @@ func (o *Object) Hash(ctx context.Context, t hash.Type) (string, error) {
 	return o.md5, nil
 }
 
+// checksumAlgorithms maps the S3 checksum algorithms onto rclone hashes
+var checksumAlgorithms = map[string]hash.Type{
+	"CRC32":     hash.CRC32,
+	"CRC32C":    hash.CRC32C,
+	"CRC64NVME": hash.CRC64NVME,
+	"SHA1":      hash.SHA1,
+	"SHA256":    hash.SHA256,
+}
+
+// parseChecksumAlgorithm returns the hash for the checksum_algorithm
+// option or hash.None if it isn't set
+func parseChecksumAlgorithm(algorithm string) (hash.Type, error) {
+	if algorithm == "" {
+		return hash.None, nil
+	}
+	ht, found := checksumAlgorithms[strings.ToUpper(algorithm)]
+	if !found {
+		return hash.None, fmt.Errorf("unknown checksum_algorithm %q", algorithm)
+	}
+	return ht, nil
+}
+
+// checksumAlgorithmName returns the S3 name of the checksum algorithm for ht
+func checksumAlgorithmName(ht hash.Type) string {
+	for name, checksumHash := range checksumAlgorithms {
+		if checksumHash == ht {
+			return name
+		}
+	}
+	return ""
+}
+
+// fullObjectChecksum returns true if the checksum_algorithm can be
+// used as a FULL_OBJECT checksum for multipart uploads.
+//
+// S3 can combine the CRC checksums of the parts into a checksum of
+// the whole object, but not the SHA checksums which can only be
+// COMPOSITE checksums made from the checksums of the parts.
+func (f *Fs) fullObjectChecksum() bool {
+	switch f.checksumHash {
+	case hash.CRC32, hash.CRC32C, hash.CRC64NVME:
+		return true
+	}
+	return false
+}
+
+// checksumHeader returns an API option to send the base64 encoded
+// checksum_algorithm checksum in the x-amz-checksum-* header
+//
+// This is used rather than the fields in s3.PutObjectInput as the SDK
+// doesn't know about all the algorithms.
+func (f *Fs) checksumHeader(checksumBase64 string) func(*middleware.Stack) error {
+	return smithyhttp.AddHeaderValue("X-Amz-Checksum-"+strings.ToLower(checksumAlgorithmName(f.checksumHash)), checksumBase64)
+}
+
+// checksumToHex converts the base64 checksum value from S3 for ht to
+// hex, returning "" if it isn't a checksum of the whole object
+func checksumToHex(ht hash.Type, value string) string {
+	// The checksums of multipart objects made from the checksums
+	// of the parts have the number of parts appended as -N
+	if value == "" || strings.Contains(value, "-") {
+		return ""
+	}
+	checksum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(checksum) != hash.Width(ht, false)/2 {
+		return ""
+	}
+	return hex.EncodeToString(checksum)
+}
+
+// checksumsFromResponse reads the x-amz-checksum-* headers from resp
+// which should have been made with ChecksumMode enabled.
+//
+// It always returns a non nil map.
+func checksumsFromResponse(resp *s3.HeadObjectOutput) map[hash.Type]string {
+	values := map[hash.Type]string{
+		hash.CRC32:  deref(resp.ChecksumCRC32),
+		hash.CRC32C: deref(resp.ChecksumCRC32C),
+		hash.SHA1:   deref(resp.ChecksumSHA1),
+		hash.SHA256: deref(resp.ChecksumSHA256),
+	}
+	composite := false
+	// The SDK doesn't know about these headers yet so read them raw
+	if raw, ok := awsmiddleware.GetRawResponse(resp.ResultMetadata).(*smithyhttp.Response); ok {
+		values[hash.CRC64NVME] = raw.Header.Get("X-Amz-Checksum-Crc64nvme")
+		composite = strings.EqualFold(raw.Header.Get("X-Amz-Checksum-Type"), "COMPOSITE")
+	}
+	checksums := make(map[hash.Type]string, 1)
+	if composite {
+		return checksums
+	}
+	for ht, value := range values {
+		if checksum := checksumToHex(ht, value); checksum != "" {
+			checksums[ht] = checksum
+		}
+	}
+	return checksums
+}
+
 // Size returns the size of an object in bytes
 func (o *Object) Size() int64 {
 	return o.bytes
@@ -5576,6 +6101,9 @@ func (f *Fs) headObject(ctx context.Context, req *s3.HeadObjectInput) (resp *s3.
 	if f.opt.SSECustomerKeyMD5 != "" {
 		req.SSECustomerKeyMD5 = &f.opt.SSECustomerKeyMD5
 	}
+	if f.checksumHash != hash.None {
+		req.ChecksumMode = types.ChecksumModeEnabled
+	}
 	err = f.pacer.Call(func() (bool, error) {
 		var err error
 		resp, err = f.c.HeadObject(ctx, req)
@@ -5605,6 +6133,9 @@ func (o *Object) readMetaData(ctx context.Context) (err error) {
 		return err
 	}
 	o.setMetaData(resp)
+	if o.fs.checksumHash != hash.None {
+		o.checksums = checksumsFromResponse(resp)
+	}
 	// resp.ETag, resp.ContentLength, resp.LastModified, resp.Metadata, resp.ContentType, resp.StorageClass)
 	return nil
 }
@@ -5665,6 +6196,15 @@ func (o *Object) setMetaData(resp *s3.HeadObjectOutput) {
 	o.contentDisposition = resp.ContentDisposition
 	o.contentEncoding = resp.ContentEncoding
 	o.contentLanguage = resp.ContentLanguage
+	o.objectLockMode = nil
+	if resp.ObjectLockMode != "" {
+		o.objectLockMode = (*string)(&resp.ObjectLockMode)
+	}
+	o.objectLockRetainUntilDate = resp.ObjectLockRetainUntilDate
+	o.objectLockLegalHoldStatus = nil
+	if resp.ObjectLockLegalHoldStatus != "" {
+		o.objectLockLegalHoldStatus = (*string)(&resp.ObjectLockLegalHoldStatus)
+	}
 
 	// If decompressing then size and md5sum are unknown
 	if o.fs.opt.Decompress && deref(o.contentEncoding) == "gzip" {
@@ -5912,6 +6452,7 @@ type s3ChunkWriter struct {
 	versionID            string
 	md5sMu               sync.Mutex
 	md5s                 []byte
+	checksumHash         hash.Type // if set send this checksum with each part for a FULL_OBJECT checksum
 	ui                   uploadInfo
 	o                    *Object
 }
@@ -5958,9 +6499,49 @@ func (f *Fs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectIn
 		chunkSize = chunksize.Calculator(src, size, uploadParts, chunkSize)
 	}
 
+	chunkWriter := &s3ChunkWriter{
+		chunkSize:            int64(chunkSize),
+		size:                 size,
+		f:                    f,
+		multiPartUploadInput: &mReq,
+		completedParts:       make([]types.CompletedPart, 0),
+		ui:                   ui,
+		o:                    o,
+	}
+	info = fs.ChunkWriterInfo{
+		ChunkSize:         int64(chunkSize),
+		Concurrency:       o.fs.opt.UploadConcurrency,
+		LeavePartsOnError: o.fs.opt.LeavePartsOnError,
+	}
+
+	// Ask for a checksum of the whole object rather than one made
+	// from the checksums of the parts so it can be used as a hash
+	var createOptions []func(*s3.Options)
+	if f.fullObjectChecksum() {
+		chunkWriter.checksumHash = f.checksumHash
+		mReq.ChecksumAlgorithm = types.ChecksumAlgorithm(checksumAlgorithmName(f.checksumHash))
+		createOptions = append(createOptions, s3.WithAPIOptions(smithyhttp.AddHeaderValue("X-Amz-Checksum-Type", "FULL_OBJECT")))
+	}
+
+	// Carry on with an earlier upload if asked, or abort it if it
+	// can't be carried on so its parts don't use storage
+	for _, option := range options {
+		resume, ok := option.(*fs.ResumeOption)
+		if !ok {
+			continue
+		}
+		if !resume.Abort && resume.ChunkSize == int64(chunkSize) {
+			if chunkWriter.resume(ctx, resume) {
+				return info, chunkWriter, nil
+			}
+		} else {
+			chunkWriter.abortStale(ctx, resume.ID)
+		}
+	}
+
 	var mOut *s3.CreateMultipartUploadOutput
 	err = f.pacer.Call(func() (bool, error) {
-		mOut, err = f.c.CreateMultipartUpload(ctx, &mReq)
+		mOut, err = f.c.CreateMultipartUpload(ctx, &mReq, createOptions...)
 		if err == nil {
 			if mOut == nil {
 				err = fserrors.RetryErrorf("internal error: no info from multipart upload")
@@ -5974,35 +6555,141 @@ func (f *Fs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectIn
 		return info, nil, fmt.Errorf("create multipart upload failed: %w", err)
 	}
 
-	chunkWriter := &s3ChunkWriter{
-		chunkSize:            int64(chunkSize),
-		size:                 size,
-		f:                    f,
-		bucket:               mOut.Bucket,
-		key:                  mOut.Key,
-		uploadID:             mOut.UploadId,
-		multiPartUploadInput: &mReq,
-		completedParts:       make([]types.CompletedPart, 0),
-		ui:                   ui,
-		o:                    o,
-	}
-	info = fs.ChunkWriterInfo{
-		ChunkSize:         int64(chunkSize),
-		Concurrency:       o.fs.opt.UploadConcurrency,
-		LeavePartsOnError: o.fs.opt.LeavePartsOnError,
-	}
+	chunkWriter.bucket = mOut.Bucket
+	chunkWriter.key = mOut.Key
+	chunkWriter.uploadID = mOut.UploadId
 	fs.Debugf(o, "open chunk writer: started multipart upload: %v", *mOut.UploadId)
 	return info, chunkWriter, err
 }
 
-// add a part number and etag to the completed parts
+// resume sets up w to carry on with the multipart upload in resume.
+//
+// It checks the upload still exists and which of the parts written
+// are still there. It returns false if the upload can't be resumed.
+func (w *s3ChunkWriter) resume(ctx context.Context, resume *fs.ResumeOption) bool {
+	bucket, key := w.multiPartUploadInput.Bucket, w.multiPartUploadInput.Key
+	uploadID := aws.String(resume.ID)
+	parts := map[int32]types.Part{}
+	req := s3.ListPartsInput{
+		Bucket:               bucket,
+		Key:                  key,
+		UploadId:             uploadID,
+		RequestPayer:         w.multiPartUploadInput.RequestPayer,
+		SSECustomerAlgorithm: w.multiPartUploadInput.SSECustomerAlgorithm,
+		SSECustomerKey:       w.multiPartUploadInput.SSECustomerKey,
+		SSECustomerKeyMD5:    w.multiPartUploadInput.SSECustomerKeyMD5,
+	}
+	for {
+		var resp *s3.ListPartsOutput
+		err := w.f.pacer.Call(func() (bool, error) {
+			var err error
+			resp, err = w.f.c.ListParts(ctx, &req)
+			return w.f.shouldRetry(ctx, err)
+		})
+		if err != nil {
+			fs.Debugf(w.o, "Not resuming multipart upload %q: %v", resume.ID, err)
+			return false
+		}
+		for _, part := range resp.Parts {
+			if part.PartNumber != nil && part.ETag != nil {
+				parts[*part.PartNumber] = part
+			}
+		}
+		if !aws.ToBool(resp.IsTruncated) || resp.NextPartNumberMarker == nil {
+			break
+		}
+		req.PartNumberMarker = resp.NextPartNumberMarker
+	}
+
+	// Only use the parts we know the md5 of which are still there
+	for chunkNumber, state := range resume.Chunks {
+		md5sumHex, eTag, ok := strings.Cut(state, ":")
+		if !ok {
+			continue
+		}
+		md5sumBinary, err := hex.DecodeString(md5sumHex)
+		if err != nil || len(md5sumBinary) != md5.Size {
+			continue
+		}
+		partNumber := int32(chunkNumber + 1)
+		part, ok := parts[partNumber]
+		if !ok || *part.ETag != eTag {
+			continue
+		}
+		w.addMd5(&md5sumBinary, int64(chunkNumber))
+		w.addCompletedPart(aws.Int32(partNumber), part.ETag)
+	}
+	w.bucket = bucket
+	w.key = key
+	w.uploadID = uploadID
+	fs.Debugf(w.o, "open chunk writer: resumed multipart upload: %v with %d parts", resume.ID, len(w.completedParts))
+	return true
+}
+
+// abortStale aborts the earlier multipart upload with uploadID which
+// can't be resumed.
+//
+// Errors are only logged as the upload may have gone already.
+func (w *s3ChunkWriter) abortStale(ctx context.Context, uploadID string) {
+	err := w.f.pacer.Call(func() (bool, error) {
+		_, err := w.f.c.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:       w.multiPartUploadInput.Bucket,
+			Key:          w.multiPartUploadInput.Key,
+			UploadId:     aws.String(uploadID),
+			RequestPayer: w.multiPartUploadInput.RequestPayer,
+		})
+		return w.f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		fs.Debugf(w.o, "Failed to abort stale multipart upload %q: %v", uploadID, err)
+		return
+	}
+	fs.Debugf(w.o, "Aborted stale multipart upload %q", uploadID)
+}
+
+// ResumeID returns the ID of the multipart upload
+func (w *s3ChunkWriter) ResumeID() string {
+	return aws.ToString(w.uploadID)
+}
+
+// ChunkState returns the md5sum and etag of chunkNumber so the
+// upload can be finished after a restart.
+func (w *s3ChunkWriter) ChunkState(chunkNumber int) string {
+	partNumber := int32(chunkNumber + 1)
+	var eTag string
+	w.completedPartsMu.Lock()
+	for _, part := range w.completedParts {
+		if aws.ToInt32(part.PartNumber) == partNumber {
+			eTag = aws.ToString(part.ETag)
+			break
+		}
+	}
+	w.completedPartsMu.Unlock()
+	w.md5sMu.Lock()
+	defer w.md5sMu.Unlock()
+	start := chunkNumber * md5.Size
+	if eTag == "" || start+md5.Size > len(w.md5s) {
+		return ""
+	}
+	return hex.EncodeToString(w.md5s[start:start+md5.Size]) + ":" + eTag
+}
+
+// add a part number and etag to the completed parts replacing any
+// part with the same number
 func (w *s3ChunkWriter) addCompletedPart(partNum *int32, eTag *string) {
 	w.completedPartsMu.Lock()
 	defer w.completedPartsMu.Unlock()
-	w.completedParts = append(w.completedParts, types.CompletedPart{
+	part := types.CompletedPart{
 		PartNumber: partNum,
 		ETag:       eTag,
-	})
+	}
+	for i := range w.completedParts {
+		if *w.completedParts[i].PartNumber == *partNum {
+			w.completedParts[i] = part
+			return
+		}
+	}
+	w.completedParts = append(w.completedParts, part)
 }
 
 // addMd5 adds a binary md5 to the md5 calculated so far
@@ -6040,7 +6727,20 @@ func (w *s3ChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader
 	// currently there is no way to calculate the md5 without reading the chunk a 2nd time (1st read is in uploadMultipart)
 	// possible in AWS SDK v2 with trailers?
 	m := md5.New()
-	currentChunkSize, err := io.Copy(m, reader)
+	var (
+		out        io.Writer = m
+		checksums  *hash.MultiHasher
+		apiOptions []func(*s3.Options)
+	)
+	if w.checksumHash != hash.None {
+		var err error
+		checksums, err = hash.NewMultiHasherTypes(hash.NewHashSet(w.checksumHash))
+		if err != nil {
+			return -1, err
+		}
+		out = io.MultiWriter(m, checksums)
+	}
+	currentChunkSize, err := io.Copy(out, reader)
 	if err != nil {
 		return -1, err
 	}
@@ -6051,6 +6751,15 @@ func (w *s3ChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader
 	md5sumBinary := m.Sum([]byte{})
 	w.addMd5(&md5sumBinary, int64(chunkNumber))
 	md5sum := base64.StdEncoding.EncodeToString(md5sumBinary)
+	if checksums != nil {
+		// S3 checks the part with this and combines them into
+		// the checksum of the object
+		checksum, err := checksums.SumString(w.checksumHash, true)
+		if err != nil {
+			return -1, err
+		}
+		apiOptions = append(apiOptions, s3.WithAPIOptions(w.f.checksumHeader(checksum)))
+	}
 
 	// S3 requires 1 <= PartNumber <= 10000
 	s3PartNumber := aws.Int32(int32(chunkNumber + 1))
@@ -6078,7 +6787,7 @@ func (w *s3ChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader
 		if err != nil {
 			return false, err
 		}
-		uout, err = w.f.c.UploadPart(ctx, uploadPartReq)
+		uout, err = w.f.c.UploadPart(ctx, uploadPartReq, apiOptions...)
 		if err != nil {
 			if chunkNumber <= 8 {
 				return w.f.shouldRetry(ctx, err)
@@ -6098,6 +6807,71 @@ func (w *s3ChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader
 	return currentChunkSize, err
 }
 
+// CopyChunk writes chunk number chunkNumber by copying length bytes
+// starting at offset from src server-side with UploadPartCopy
+func (w *s3ChunkWriter) CopyChunk(ctx context.Context, chunkNumber int, src fs.Object, offset, length int64) (int64, error) {
+	if chunkNumber < 0 {
+		err := fmt.Errorf("invalid chunk number provided: %v", chunkNumber)
+		return -1, err
+	}
+	srcObj, ok := src.(*Object)
+	if !ok || srcObj.fs.Name() != w.f.Name() || length <= 0 {
+		return -1, fs.ErrorCantCopy
+	}
+	srcBucket, srcPath := srcObj.split()
+	source := pathEscape(bucket.Join(srcBucket, srcPath))
+	if srcObj.versionID != nil {
+		source += fmt.Sprintf("?versionId=%s", *srcObj.versionID)
+	}
+
+	// S3 requires 1 <= PartNumber <= 10000
+	s3PartNumber := aws.Int32(int32(chunkNumber + 1))
+	req := &s3.UploadPartCopyInput{
+		Bucket:               w.bucket,
+		Key:                  w.key,
+		PartNumber:           s3PartNumber,
+		UploadId:             w.uploadID,
+		CopySource:           &source,
+		CopySourceRange:      aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+		RequestPayer:         w.multiPartUploadInput.RequestPayer,
+		SSECustomerAlgorithm: w.multiPartUploadInput.SSECustomerAlgorithm,
+		SSECustomerKey:       w.multiPartUploadInput.SSECustomerKey,
+		SSECustomerKeyMD5:    w.multiPartUploadInput.SSECustomerKeyMD5,
+	}
+	if w.f.opt.SSECustomerAlgorithm != "" {
+		req.CopySourceSSECustomerAlgorithm = &w.f.opt.SSECustomerAlgorithm
+	}
+	if w.f.opt.SSECustomerKeyBase64 != "" {
+		req.CopySourceSSECustomerKey = &w.f.opt.SSECustomerKeyBase64
+	}
+	if w.f.opt.SSECustomerKeyMD5 != "" {
+		req.CopySourceSSECustomerKeyMD5 = &w.f.opt.SSECustomerKeyMD5
+	}
+	var uout *s3.UploadPartCopyOutput
+	err := w.f.pacer.Call(func() (bool, error) {
+		var err error
+		uout, err = w.f.c.UploadPartCopy(ctx, req)
+		if err == nil && (uout == nil || uout.CopyPartResult == nil || uout.CopyPartResult.ETag == nil) {
+			err = fserrors.RetryErrorf("internal error: no ETag from upload part copy")
+		}
+		return w.f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return -1, fmt.Errorf("failed to copy chunk %d with %v bytes: %w", chunkNumber+1, length, err)
+	}
+	eTag := uout.CopyPartResult.ETag
+
+	// The ETag of a copied part is its md5sum unless encrypted with KMS
+	md5sumBinary, err := hex.DecodeString(strings.Trim(*eTag, `"`))
+	if err == nil && len(md5sumBinary) == md5.Size {
+		w.addMd5(&md5sumBinary, int64(chunkNumber))
+	}
+	w.addCompletedPart(s3PartNumber, eTag)
+
+	fs.Debugf(w.o, "multipart upload copied chunk %d with %v bytes and etag %v", chunkNumber+1, length, *eTag)
+	return length, nil
+}
+
 // Abort the multipart upload
 func (w *s3ChunkWriter) Abort(ctx context.Context) error {
 	err := w.f.pacer.Call(func() (bool, error) {
@@ -6122,6 +6896,17 @@ func (w *s3ChunkWriter) Close(ctx context.Context) (err error) {
 	sort.Slice(w.completedParts, func(i, j int) bool {
 		return *w.completedParts[i].PartNumber < *w.completedParts[j].PartNumber
 	})
+	// S3 combines the part checksums it has checked into the
+	// FULL_OBJECT checksum so they aren't listed here, but the
+	// checksum of the whole object is sent if known so S3 checks
+	// the combined checksum matches it.
+	var options []func(*s3.Options)
+	if w.checksumHash != hash.None {
+		options = append(options, s3.WithAPIOptions(smithyhttp.AddHeaderValue("X-Amz-Checksum-Type", "FULL_OBJECT")))
+		if w.ui.checksumBase != "" {
+			options = append(options, s3.WithAPIOptions(w.f.checksumHeader(w.ui.checksumBase)))
+		}
+	}
 	var resp *s3.CompleteMultipartUploadOutput
 	err = w.f.pacer.Call(func() (bool, error) {
 		resp, err = w.f.c.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
@@ -6132,7 +6917,7 @@ func (w *s3ChunkWriter) Close(ctx context.Context) (err error) {
 			},
 			RequestPayer: w.multiPartUploadInput.RequestPayer,
 			UploadId:     w.uploadID,
-		})
+		}, options...)
 		return w.f.shouldRetry(ctx, err)
 	})
 	if err != nil {
@@ -6170,9 +6955,13 @@ func (o *Object) uploadMultipart(ctx context.Context, src fs.ObjectInfo, in io.R
 }
 
 // Upload a single part using PutObject
-func (o *Object) uploadSinglepartPutObject(ctx context.Context, req *s3.PutObjectInput, size int64, in io.Reader) (etag string, lastModified time.Time, versionID *string, err error) {
+func (o *Object) uploadSinglepartPutObject(ctx context.Context, ui uploadInfo, size int64, in io.Reader) (etag string, lastModified time.Time, versionID *string, err error) {
+	req := ui.req
 	req.Body = io.NopCloser(in)
 	var options = []func(*s3.Options){}
+	if ui.checksumBase != "" {
+		options = append(options, s3.WithAPIOptions(o.fs.checksumHeader(ui.checksumBase)))
+	}
 	if o.fs.opt.UseUnsignedPayload.Value {
 		options = append(options, s3.WithAPIOptions(
 			// avoids operation error S3: PutObject, failed to compute payload hash: failed to seek body to start, request stream is not seekable
@@ -6200,9 +6989,14 @@ func (o *Object) uploadSinglepartPutObject(ctx context.Context, req *s3.PutObjec
 }
 
 // Upload a single part using a presigned request
-func (o *Object) uploadSinglepartPresignedRequest(ctx context.Context, req *s3.PutObjectInput, size int64, in io.Reader) (etag string, lastModified time.Time, versionID *string, err error) {
+func (o *Object) uploadSinglepartPresignedRequest(ctx context.Context, ui uploadInfo, size int64, in io.Reader) (etag string, lastModified time.Time, versionID *string, err error) {
 	// Create the presigned request
-	putReq, err := s3.NewPresignClient(o.fs.c).PresignPutObject(ctx, req, s3.WithPresignExpires(15*time.Minute))
+	var options = []func(*s3.PresignOptions){s3.WithPresignExpires(15 * time.Minute)}
+	if ui.checksumBase != "" {
+		// Sign the checksum header too
+		options = append(options, s3.WithPresignClientFromClientOptions(s3.WithAPIOptions(o.fs.checksumHeader(ui.checksumBase))))
+	}
+	putReq, err := s3.NewPresignClient(o.fs.c).PresignPutObject(ctx, ui.req, options...)
 	if err != nil {
 		return etag, lastModified, nil, fmt.Errorf("s3 upload: sign request: %w", err)
 	}
@@ -6257,8 +7051,18 @@ func (o *Object) uploadSinglepartPresignedRequest(ctx context.Context, req *s3.P
 
 // Info needed for an upload
 type uploadInfo struct {
-	req       *s3.PutObjectInput
-	md5sumHex string
+	req          *s3.PutObjectInput
+	md5sumHex    string
+	checksumHex  string // checksum_algorithm checksum to send as hex or ""
+	checksumBase string // the same checksum base64 encoded as sent to S3
+}
+
+// lockNeedsChecksum returns true if the upload sets Object Lock
+// options but has no Content-MD5 or checksum to send, which S3
+// requires with them.
+func (ui *uploadInfo) lockNeedsChecksum() bool {
+	locked := ui.req.ObjectLockMode != "" || ui.req.ObjectLockLegalHoldStatus != ""
+	return locked && ui.req.ContentMD5 == nil && ui.checksumBase == ""
 }
 
 // Prepare object for being uploaded
@@ -6280,6 +7084,7 @@ func (o *Object) prepareUpload(ctx context.Context, src fs.ObjectInfo, options [
 		ACL:    types.ObjectCannedACL(o.fs.opt.ACL),
 		Key:    &bucketPath,
 	}
+	ui.req.ObjectLockMode, ui.req.ObjectLockRetainUntilDate, ui.req.ObjectLockLegalHoldStatus = o.fs.objectLockDefaults(time.Now())
 
 	// Fetch metadata if --metadata is in use
 	meta, err := fs.GetMetadataOptions(ctx, o.fs, src, options)
@@ -6321,11 +7126,31 @@ func (o *Object) prepareUpload(ctx context.Context, src fs.ObjectInfo, options [
 		case "btime":
 			// write as metadata since we can't set it
 			ui.req.Metadata[k] = v
+		case "object-lock-mode":
+			ui.req.ObjectLockMode = types.ObjectLockMode(strings.ToUpper(v))
+		case "object-lock-retain-until-date":
+			retainUntil, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				fs.Debugf(o, "failed to parse metadata %s: %q: %v", k, v, err)
+			} else if retainUntil.Before(time.Now()) {
+				fs.Debugf(o, "ignoring metadata %s: %q as it is in the past", k, v)
+			} else {
+				ui.req.ObjectLockRetainUntilDate = &retainUntil
+			}
+		case "object-lock-legal-hold-status":
+			ui.req.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatus(strings.ToUpper(v))
 		default:
 			ui.req.Metadata[k] = v
 		}
 	}
 
+	// S3 needs the mode and date of the retention together
+	if (ui.req.ObjectLockMode == "") != (ui.req.ObjectLockRetainUntilDate == nil) {
+		fs.Logf(o, "Not setting Object Lock retention as object-lock-mode and object-lock-retain-until-date must both be set")
+		ui.req.ObjectLockMode = ""
+		ui.req.ObjectLockRetainUntilDate = nil
+	}
+
 	// Set the mtime in the meta data
 	ui.req.Metadata[metaMtime] = swift.TimeToFloatString(modTime)
 
@@ -6355,6 +7180,20 @@ func (o *Object) prepareUpload(ctx context.Context, src fs.ObjectInfo, options [
 		}
 	}
 
+	// read the checksum_algorithm checksum if available so S3
+	// can check it and store it - for multipart only if it is a
+	// FULL_OBJECT checksum as otherwise it is made from the parts
+	if !noHash && (!multipart || o.fs.fullObjectChecksum()) && o.fs.checksumHash != hash.None {
+		checksumHex, err := src.Hash(ctx, o.fs.checksumHash)
+		if err == nil && checksumHex != "" {
+			checksumBytes, err := hex.DecodeString(checksumHex)
+			if err == nil && len(checksumBytes) == hash.Width(o.fs.checksumHash, false)/2 {
+				ui.checksumHex = checksumHex
+				ui.checksumBase = base64.StdEncoding.EncodeToString(checksumBytes)
+			}
+		}
+	}
+
 	// Set the content type if it isn't set already
 	if ui.req.ContentType == nil {
 		ui.req.ContentType = aws.String(fs.MimeType(ctx, src))
@@ -6452,10 +7291,17 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 			return fmt.Errorf("failed to prepare upload: %w", err)
 		}
 
-		if o.fs.opt.UsePresignedRequest {
-			gotETag, lastModified, versionID, err = o.uploadSinglepartPresignedRequest(ctx, ui.req, size, in)
-		} else {
-			gotETag, lastModified, versionID, err = o.uploadSinglepartPutObject(ctx, ui.req, size, in)
+		switch {
+		case ui.lockNeedsChecksum():
+			// The parts of a multipart upload are always sent
+			// with their MD5 so use one when the source has no
+			// MD5 to send with the Object Lock options.
+			fs.Debugf(o, "Using multipart upload to send checksums with Object Lock options")
+			wantETag, gotETag, versionID, ui, err = o.uploadMultipart(ctx, src, in, options...)
+		case o.fs.opt.UsePresignedRequest:
+			gotETag, lastModified, versionID, err = o.uploadSinglepartPresignedRequest(ctx, ui, size, in)
+		default:
+			gotETag, lastModified, versionID, err = o.uploadSinglepartPutObject(ctx, ui, size, in)
 		}
 	}
 	if err != nil {
@@ -6487,6 +7333,11 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		}
 		head.LastModified = &lastModified
 		head.VersionId = versionID
+		o.checksums = nil
+		if ui.checksumHex != "" {
+			// S3 checked the checksum we sent so it is correct
+			o.checksums = map[hash.Type]string{o.fs.checksumHash: ui.checksumHex}
+		}
 	} else {
 		// Read the metadata from the newly created object
 		o.meta = nil // wipe old metadata
@@ -6494,6 +7345,10 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		if err != nil {
 			return err
 		}
+		o.checksums = nil
+		if o.fs.checksumHash != hash.None {
+			o.checksums = checksumsFromResponse(head)
+		}
 	}
 	o.setMetaData(head)
 
@@ -6526,9 +7381,33 @@ func (o *Object) Remove(ctx context.Context) error {
 		_, err := o.fs.c.DeleteObject(ctx, &req)
 		return o.fs.shouldRetry(ctx, err)
 	})
+	if err != nil && o.versionID != nil && getHTTPStatusCode(err) == http.StatusForbidden {
+		// Deleting a version fails if it is locked so say why
+		if lock := o.lockDescription(ctx); lock != "" {
+			return fmt.Errorf("can't delete version %q as it is protected by Object Lock (%s): %w", *o.versionID, lock, err)
+		}
+	}
 	return err
 }
 
+// lockDescription returns a description of the Object Lock protecting
+// the object or "" if it isn't locked or the lock can't be read
+func (o *Object) lockDescription(ctx context.Context) string {
+	err := o.readMetaData(ctx)
+	if err != nil {
+		fs.Debugf(o, "Failed to read Object Lock status: %v", err)
+		return ""
+	}
+	var locks []string
+	if o.objectLockRetainUntilDate != nil && o.objectLockRetainUntilDate.After(time.Now()) {
+		locks = append(locks, fmt.Sprintf("%s mode until %s", deref(o.objectLockMode), o.objectLockRetainUntilDate.Format(time.RFC3339)))
+	}
+	if deref(o.objectLockLegalHoldStatus) == string(types.ObjectLockLegalHoldStatusOn) {
+		locks = append(locks, "legal hold")
+	}
+	return strings.Join(locks, " and ")
+}
+
 // MimeType of an Object if known, "" otherwise
 func (o *Object) MimeType(ctx context.Context) string {
 	err := o.readMetaData(ctx)
@@ -6607,6 +7486,11 @@ func (o *Object) Metadata(ctx context.Context) (metadata fs.Metadata, err error)
 	setMetadata("content-disposition", o.contentDisposition)
 	setMetadata("content-encoding", o.contentEncoding)
 	setMetadata("content-language", o.contentLanguage)
+	setMetadata("object-lock-mode", o.objectLockMode)
+	if o.objectLockRetainUntilDate != nil && !o.fs.opt.NoSystemMetadata {
+		metadata["object-lock-retain-until-date"] = o.objectLockRetainUntilDate.Format(time.RFC3339Nano)
+	}
+	setMetadata("object-lock-legal-hold-status", o.objectLockLegalHoldStatus)
 	metadata["tier"] = o.GetTier()
 
 	return metadata, nil
@@ -6614,17 +7498,19 @@ func (o *Object) Metadata(ctx context.Context) (metadata fs.Metadata, err error)
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs              = &Fs{}
-	_ fs.Purger          = &Fs{}
-	_ fs.Copier          = &Fs{}
-	_ fs.PutStreamer     = &Fs{}
-	_ fs.ListRer         = &Fs{}
-	_ fs.Commander       = &Fs{}
-	_ fs.CleanUpper      = &Fs{}
-	_ fs.OpenChunkWriter = &Fs{}
-	_ fs.Object          = &Object{}
-	_ fs.MimeTyper       = &Object{}
-	_ fs.GetTierer       = &Object{}
-	_ fs.SetTierer       = &Object{}
-	_ fs.Metadataer      = &Object{}
+	_ fs.Fs                 = &Fs{}
+	_ fs.Purger             = &Fs{}
+	_ fs.Copier             = &Fs{}
+	_ fs.PutStreamer        = &Fs{}
+	_ fs.ListRer            = &Fs{}
+	_ fs.Commander          = &Fs{}
+	_ fs.CleanUpper         = &Fs{}
+	_ fs.OpenChunkWriter    = &Fs{}
+	_ fs.Object             = &Object{}
+	_ fs.ChunkWriterResumer = &s3ChunkWriter{}
+	_ fs.ChunkWriterCopier  = &s3ChunkWriter{}
+	_ fs.MimeTyper          = &Object{}
+	_ fs.GetTierer          = &Object{}
+	_ fs.SetTierer          = &Object{}
+	_ fs.Metadataer         = &Object{}
 )
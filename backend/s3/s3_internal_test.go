@@ -163,6 +163,162 @@ func TestVersionLess(t *testing.T) {
 	}
 }
 
+func TestParseRetainUntil(t *testing.T) {
+	now := fstest.Time("2024-06-01T12:00:00Z")
+	for _, test := range []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "2030-01-02T15:04:05Z", want: "2030-01-02T15:04:05Z"},
+		{in: "2030-01-02", want: "2030-01-02T00:00:00Z"},
+		{in: "30d", want: "2024-07-01T12:00:00Z"},
+		{in: "1h", want: "2024-06-01T13:00:00Z"},
+		{in: "2020-01-02T15:04:05Z", wantErr: true},
+		{in: "-1d", wantErr: true},
+		{in: "potato", wantErr: true},
+	} {
+		got, err := parseRetainUntil(test.in, now)
+		if test.wantErr {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, fstest.Time(test.want), got, test.in)
+	}
+}
+
+func TestCheckObjectLock(t *testing.T) {
+	for _, test := range []struct {
+		opt     Options
+		wantErr bool
+	}{
+		{opt: Options{}},
+		{opt: Options{ObjectLockMode: "governance", ObjectLockRetainUntil: "1d", ObjectLockLegalHold: "on"}},
+		{opt: Options{ObjectLockMode: "COMPLIANCE", ObjectLockRetainUntil: "2099-01-01"}},
+		{opt: Options{ObjectLockLegalHold: "OFF"}},
+		{opt: Options{ObjectLockMode: "GOVERNANCE"}, wantErr: true},
+		{opt: Options{ObjectLockRetainUntil: "1d"}, wantErr: true},
+		{opt: Options{ObjectLockMode: "potato", ObjectLockRetainUntil: "1d"}, wantErr: true},
+		{opt: Options{ObjectLockMode: "GOVERNANCE", ObjectLockRetainUntil: "2000-01-01"}, wantErr: true},
+		{opt: Options{ObjectLockLegalHold: "maybe"}, wantErr: true},
+	} {
+		opt := test.opt
+		err := checkObjectLock(&opt)
+		if test.wantErr {
+			assert.Error(t, err, fmt.Sprintf("%+v", test.opt))
+			continue
+		}
+		require.NoError(t, err, fmt.Sprintf("%+v", test.opt))
+		assert.Equal(t, strings.ToUpper(test.opt.ObjectLockMode), opt.ObjectLockMode)
+		assert.Equal(t, strings.ToUpper(test.opt.ObjectLockLegalHold), opt.ObjectLockLegalHold)
+	}
+}
+
+func TestLockNeedsChecksum(t *testing.T) {
+	md5sum := "XrY7u+Ae7tCTyyK7j1rNww=="
+	for _, test := range []struct {
+		req          s3.PutObjectInput
+		checksumBase string
+		want         bool
+	}{
+		{req: s3.PutObjectInput{}},
+		{req: s3.PutObjectInput{ObjectLockMode: types.ObjectLockModeGovernance}, want: true},
+		{req: s3.PutObjectInput{ObjectLockLegalHoldStatus: types.ObjectLockLegalHoldStatusOn}, want: true},
+		{req: s3.PutObjectInput{ObjectLockMode: types.ObjectLockModeGovernance, ContentMD5: &md5sum}},
+		{req: s3.PutObjectInput{ObjectLockLegalHoldStatus: types.ObjectLockLegalHoldStatusOn}, checksumBase: "AAAAAA=="},
+	} {
+		ui := uploadInfo{req: &test.req, checksumBase: test.checksumBase}
+		assert.Equal(t, test.want, ui.lockNeedsChecksum(), fmt.Sprintf("%+v", test))
+	}
+}
+
+func TestSetMetaDataObjectLock(t *testing.T) {
+	o := &Object{fs: &Fs{}}
+	o.setMetaData(&s3.HeadObjectOutput{})
+	metadata, err := o.Metadata(context.Background())
+	require.NoError(t, err)
+	assert.NotContains(t, metadata, "object-lock-mode")
+	assert.NotContains(t, metadata, "object-lock-legal-hold-status")
+
+	retainUntil := fstest.Time("2099-01-02T03:04:05Z")
+	o.setMetaData(&s3.HeadObjectOutput{
+		ObjectLockMode:            types.ObjectLockModeCompliance,
+		ObjectLockRetainUntilDate: &retainUntil,
+		ObjectLockLegalHoldStatus: types.ObjectLockLegalHoldStatusOn,
+	})
+	metadata, err = o.Metadata(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "COMPLIANCE", metadata["object-lock-mode"])
+	assert.Equal(t, "2099-01-02T03:04:05Z", metadata["object-lock-retain-until-date"])
+	assert.Equal(t, "ON", metadata["object-lock-legal-hold-status"])
+}
+
+func TestParseChecksumAlgorithm(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		want    hash.Type
+		wantErr bool
+	}{
+		{in: "", want: hash.None},
+		{in: "CRC32", want: hash.CRC32},
+		{in: "crc32c", want: hash.CRC32C},
+		{in: "CRC64NVME", want: hash.CRC64NVME},
+		{in: "sha1", want: hash.SHA1},
+		{in: "SHA256", want: hash.SHA256},
+		{in: "MD5", wantErr: true},
+	} {
+		got, err := parseChecksumAlgorithm(test.in)
+		if test.wantErr {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.want, got, test.in)
+		if test.want != hash.None {
+			assert.Equal(t, strings.ToUpper(test.in), checksumAlgorithmName(got))
+		}
+	}
+}
+
+func TestChecksumHashes(t *testing.T) {
+	for _, test := range []struct {
+		ht         hash.Type
+		want       hash.Set
+		fullObject bool
+	}{
+		{ht: hash.None, want: hash.Set(hash.MD5)},
+		{ht: hash.CRC32, want: hash.NewHashSet(hash.MD5, hash.CRC32), fullObject: true},
+		{ht: hash.CRC32C, want: hash.NewHashSet(hash.MD5, hash.CRC32C), fullObject: true},
+		{ht: hash.CRC64NVME, want: hash.NewHashSet(hash.MD5, hash.CRC64NVME), fullObject: true},
+		{ht: hash.SHA1, want: hash.NewHashSet(hash.MD5, hash.SHA1)},
+		{ht: hash.SHA256, want: hash.NewHashSet(hash.MD5, hash.SHA256)},
+	} {
+		f := &Fs{checksumHash: test.ht}
+		assert.Equal(t, test.want, f.Hashes(), test.ht.String())
+		assert.Equal(t, test.fullObject, f.fullObjectChecksum(), test.ht.String())
+	}
+}
+
+func TestChecksumsFromResponse(t *testing.T) {
+	assert.Equal(t, "4d8ae017", checksumToHex(hash.CRC32C, "TYrgFw=="))
+	assert.Equal(t, "6870c3fff5245563", checksumToHex(hash.CRC64NVME, "aHDD//UkVWM="))
+	assert.Equal(t, "", checksumToHex(hash.CRC32C, ""))
+	assert.Equal(t, "", checksumToHex(hash.CRC32C, "TYrgFw==-3"), "composite")
+	assert.Equal(t, "", checksumToHex(hash.CRC32C, "aHDD//UkVWM="), "wrong length")
+	assert.Equal(t, "", checksumToHex(hash.CRC32C, "potato!"), "bad base64")
+
+	checksums := checksumsFromResponse(&s3.HeadObjectOutput{})
+	assert.NotNil(t, checksums)
+	assert.Len(t, checksums, 0)
+
+	checksums = checksumsFromResponse(&s3.HeadObjectOutput{
+		ChecksumCRC32C: aws.String("TYrgFw=="),
+		ChecksumSHA256: aws.String("47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=-2"),
+	})
+	assert.Equal(t, map[hash.Type]string{hash.CRC32C: "4d8ae017"}, checksums)
+}
+
 func TestMergeDeleteMarkers(t *testing.T) {
 	key1 := "key1"
 	key2 := "key2"
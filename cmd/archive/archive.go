@@ -0,0 +1,114 @@
+// Package archive provides the archive command.
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/spf13/cobra"
+)
+
+var (
+	format = ""
+	prefix = ""
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	commandDefinition.AddCommand(createCommand)
+	commandDefinition.AddCommand(extractCommand)
+	commandDefinition.AddCommand(listCommand)
+	for _, command := range []*cobra.Command{createCommand, extractCommand, listCommand} {
+		cmdFlags := command.Flags()
+		flags.StringVarP(cmdFlags, &format, "format", "", format, "Archive format tar|tar.gz|zip (default: guess from the file name)", "")
+	}
+	flags.StringVarP(createCommand.Flags(), &prefix, "prefix", "", prefix, "Directory to put the files in inside the archive", "")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "archive <subcommand>",
+	Short: `Create, extract and list archives on remotes.`,
+	Long: `Rclone archive reads and writes tar and zip archives directly to and
+from remotes, streaming the data without staging a copy on local disk.
+
+Select which archive command you want with the subcommand, e.g.
+
+    rclone archive create remote:dir remote:backup/dir.tar.gz
+    rclone archive list remote:backup/dir.tar.gz
+    rclone archive extract remote:backup/dir.tar.gz remote:restored
+
+The format is guessed from the extension of the archive name:
+` + "`.tar`, `.tar.gz` or `.tgz` and `.zip`" + `. Use ` + "`--format`" + ` to set it
+explicitly.
+
+The filter flags (e.g. ` + "`--include`" + `) select which files are put into
+or extracted from the archive.
+
+Modification times are preserved. If ` + "`--metadata`" + ` is set then
+metadata is preserved too for tar archives, where it is stored in PAX
+records. Zip archives can't store metadata.
+
+Files whose size isn't known in advance are spooled to a temporary
+file when writing tar archives as the size is needed in the header.
+
+Symbolic and hard links in archives are skipped with a notice when
+listing or extracting as remotes can't store them.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+}
+
+// Archive formats
+const (
+	formatTar   = "tar"
+	formatTarGz = "tar.gz"
+	formatZip   = "zip"
+)
+
+// paxMetadataPrefix is used to store metadata in tar PAX records
+const paxMetadataPrefix = "RCLONE.metadata."
+
+// getFormat works out the archive format from --format or the name of
+// the archive.
+func getFormat(name string) (string, error) {
+	if format != "" {
+		switch format {
+		case formatTar, formatTarGz, formatZip:
+			return format, nil
+		case "tgz":
+			return formatTarGz, nil
+		}
+		return "", fmt.Errorf("unknown archive format %q", format)
+	}
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return formatTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return formatTar, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return formatZip, nil
+	}
+	return "", fmt.Errorf("can't guess archive format from %q - use --format", name)
+}
+
+// cleanName makes a name read from an archive safe to use as a remote
+//
+// It returns an error if the name would escape the destination.
+func cleanName(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = strings.TrimLeft(name, "/")
+	cleaned := path.Clean(name)
+	if cleaned == "." {
+		return "", nil
+	}
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errors.New("name is outside the archive root")
+	}
+	return cleaned, nil
+}
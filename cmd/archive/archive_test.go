@@ -0,0 +1,243 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	t1 = fstest.Time("2001-02-03T04:05:06.499999999Z")
+	t2 = fstest.Time("2011-12-25T12:59:59.123456789Z")
+)
+
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func TestGetFormat(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		format string
+		want   string
+		err    bool
+	}{
+		{name: "a.tar", want: formatTar},
+		{name: "a.TAR.GZ", want: formatTarGz},
+		{name: "a.tgz", want: formatTarGz},
+		{name: "a.zip", want: formatZip},
+		{name: "a.rar", err: true},
+		{name: "a.rar", format: "zip", want: formatZip},
+		{name: "a.zip", format: "tgz", want: formatTarGz},
+		{name: "a.zip", format: "rar", err: true},
+	} {
+		format = test.format
+		got, err := getFormat(test.name)
+		if test.err {
+			assert.Error(t, err, test.name)
+		} else {
+			require.NoError(t, err, test.name)
+			assert.Equal(t, test.want, got, test.name)
+		}
+	}
+	format = ""
+}
+
+func TestCleanName(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "file", want: "file"},
+		{in: "/abs/file", want: "abs/file"},
+		{in: "dir/", want: "dir"},
+		{in: "./dir/../file", want: "file"},
+		{in: "dir\\file", want: "dir/file"},
+		{in: "./", want: ""},
+		{in: "../file", err: true},
+		{in: "dir/../../file", err: true},
+	} {
+		got, err := cleanName(test.in)
+		if test.err {
+			assert.Error(t, err, test.in)
+		} else {
+			require.NoError(t, err, test.in)
+			assert.Equal(t, test.want, got, test.in)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, archiveFormat := range []string{formatTar, formatTarGz, formatZip} {
+		t.Run(archiveFormat, func(t *testing.T) {
+			ctx := context.Background()
+			r := fstest.NewRun(t)
+			file1 := r.WriteFile("file1", "hello", t1)
+			file2 := r.WriteFile("dir/file2", "hello world", t2)
+			file3 := r.WriteFile("dir/sub/file3", "potato", t1)
+			r.CheckLocalItems(t, file1, file2, file3)
+
+			archiveName := "archive." + archiveFormat
+			require.NoError(t, Create(ctx, r.Flocal, r.Fremote, archiveName, archiveFormat, "prefix"))
+
+			var out bytes.Buffer
+			require.NoError(t, List(ctx, r.Fremote, archiveName, archiveFormat, &out))
+			listing := out.String()
+			assert.Contains(t, listing, fmt.Sprintf("%9d %s", 11, t2.Local().Format("2006-01-02 15:04:05")))
+			assert.Contains(t, listing, " prefix/dir/file2\n")
+			assert.Contains(t, listing, " prefix/dir/sub/\n")
+
+			fextract, err := fs.NewFs(ctx, r.Fremote.Root()+"/extract")
+			require.NoError(t, err)
+			require.NoError(t, Extract(ctx, r.Fremote, archiveName, fextract, archiveFormat))
+
+			precision := fs.GetModifyWindow(ctx, fextract)
+			if archiveFormat == formatZip {
+				// zip only stores times to the second
+				precision = time.Second
+			}
+			items := []fstest.Item{file1, file2, file3}
+			for i := range items {
+				items[i].Path = "prefix/" + items[i].Path
+			}
+			fstest.CheckListingWithPrecision(t, fextract, items, []string{"prefix", "prefix/dir", "prefix/dir/sub"}, precision)
+		})
+	}
+}
+
+func TestFilters(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	file1 := r.WriteFile("file1.txt", "hello", t1)
+	r.WriteFile("file2.jpg", "hello world", t2)
+
+	ctx, fi := filter.AddConfig(ctx)
+	require.NoError(t, fi.AddRule("+ *.txt"))
+	require.NoError(t, fi.AddRule("- *"))
+
+	require.NoError(t, Create(ctx, r.Flocal, r.Fremote, "archive.tar", formatTar, ""))
+	var out bytes.Buffer
+	require.NoError(t, List(context.Background(), r.Fremote, "archive.tar", formatTar, &out))
+	assert.Contains(t, out.String(), "file1.txt")
+	assert.NotContains(t, out.String(), "file2.jpg")
+
+	fextract, err := fs.NewFs(ctx, r.Fremote.Root()+"/extract")
+	require.NoError(t, err)
+	require.NoError(t, Extract(ctx, r.Fremote, "archive.tar", fextract, formatTar))
+	fstest.CheckListingWithPrecision(t, fextract, []fstest.Item{file1}, nil, fs.GetModifyWindow(ctx, fextract))
+}
+
+func TestListFilterDirs(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteFile("file1", "hello", t1)
+	r.WriteFile("dir/file2", "hello world", t2)
+	require.NoError(t, Create(ctx, r.Flocal, r.Fremote, "archive.tar", formatTar, ""))
+
+	ctx, fi := filter.AddConfig(ctx)
+	require.NoError(t, fi.AddRule("- /dir/**"))
+	var out bytes.Buffer
+	require.NoError(t, List(ctx, r.Fremote, "archive.tar", formatTar, &out))
+	assert.Contains(t, out.String(), " file1\n")
+	assert.NotContains(t, out.String(), "dir")
+}
+
+func TestRoundTripMetadata(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	file1 := r.WriteFile("file1", "hello", t1)
+	ctx, ci := fs.AddConfig(ctx)
+	ci.Metadata = true
+
+	o, err := r.Flocal.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+	metadata, err := fs.GetMetadata(ctx, o)
+	require.NoError(t, err)
+	require.NotEmpty(t, metadata["mode"])
+
+	require.NoError(t, Create(ctx, r.Flocal, r.Fremote, "archive.tar", formatTar, ""))
+
+	// Check the metadata is stored in the PAX records
+	archive, err := r.Fremote.NewObject(ctx, "archive.tar")
+	require.NoError(t, err)
+	var got fs.Metadata
+	require.NoError(t, readArchive(ctx, archive, formatTar, func(entry *archiveEntry, in io.Reader) error {
+		if entry.Name == file1.Path {
+			got = entry.Metadata
+		}
+		return nil
+	}))
+	assert.Equal(t, metadata["mode"], got["mode"])
+	assert.Equal(t, metadata["mtime"], got["mtime"])
+
+	// Check it is restored on extract
+	fextract, err := fs.NewFs(ctx, r.Fremote.Root()+"/extract")
+	require.NoError(t, err)
+	require.NoError(t, Extract(ctx, r.Fremote, "archive.tar", fextract, formatTar))
+	o, err = fextract.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+	extracted, err := fs.GetMetadata(ctx, o)
+	require.NoError(t, err)
+	assert.Equal(t, metadata["mode"], extracted["mode"])
+}
+
+// unknownSizeObject is an object whose size isn't known
+type unknownSizeObject struct {
+	fs.Object
+}
+
+// Size returns -1 as the size is unknown
+func (unknownSizeObject) Size() int64 { return -1 }
+
+func TestTarUnknownSize(t *testing.T) {
+	ctx := context.Background()
+	o := unknownSizeObject{object.NewMemoryObject("file", t1, []byte("hello world"))}
+	var buf bytes.Buffer
+	w := newTarWriter(&buf)
+	require.NoError(t, w.addObject(ctx, "file", o, nil))
+	require.NoError(t, w.Close())
+
+	tr := tar.NewReader(&buf)
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "file", hdr.Name)
+	assert.Equal(t, int64(11), hdr.Size)
+	data, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
+
+func TestTarSkipLinks(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "file", ModTime: t1}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "file", Size: 5, ModTime: t1}))
+	_, err := tw.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	r := fstest.NewRun(t)
+	r.WriteFile("archive.tar", buf.String(), t1)
+	o, err := r.Flocal.NewObject(ctx, "archive.tar")
+	require.NoError(t, err)
+	var names []string
+	require.NoError(t, readTar(ctx, o, false, func(entry *archiveEntry, in io.Reader) error {
+		names = append(names, entry.Name)
+		return nil
+	}))
+	assert.Equal(t, []string{"file"}, names)
+}
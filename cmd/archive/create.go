@@ -0,0 +1,306 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/spf13/cobra"
+)
+
+var createCommand = &cobra.Command{
+	Use:   "create source:path dest:path/archive",
+	Short: `Create an archive of source:path on dest:path.`,
+	Long: `This reads all the files in source:path which pass the filters and
+writes them into a single archive at dest:path/archive.
+
+The archive is streamed to the destination as it is made, so the
+destination must support streaming uploads or it will be buffered
+according to ` + "`--streaming-upload-cutoff`" + ` (see ` + "`rclone rcat`" + `).
+
+Use ` + "`--prefix`" + ` to put all the files in a directory inside the archive.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+	RunE: func(command *cobra.Command, args []string) error {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc := cmd.NewFsSrc(args)
+		fdst, dstFileName := cmd.NewFsDstFile(args[1:])
+		cmd.Run(false, true, command, func() error {
+			archiveFormat, err := getFormat(dstFileName)
+			if err != nil {
+				return err
+			}
+			return Create(context.Background(), fsrc, fdst, dstFileName, archiveFormat, prefix)
+		})
+		return nil
+	},
+}
+
+// archiveWriter writes entries into an archive
+type archiveWriter interface {
+	// addDir adds a directory entry
+	addDir(ctx context.Context, name string, dir fs.Directory) error
+	// addObject adds the contents of the object with its metadata if set
+	addObject(ctx context.Context, name string, o fs.Object, metadata fs.Metadata) error
+	// Close finishes the archive
+	Close() error
+}
+
+// Create writes all the files in fsrc which pass the filters into an
+// archive called dstFileName on fdst.
+//
+// Every name in the archive is put under prefix if set.
+func Create(ctx context.Context, fsrc fs.Fs, fdst fs.Fs, dstFileName string, archiveFormat string, prefix string) error {
+	ci := fs.GetConfig(ctx)
+
+	// Read the entries to put in the archive sorted so the archive is reproducible
+	var entries fs.DirEntries
+	err := walk.ListR(ctx, fsrc, "", false, ci.MaxDepth, walk.ListAll, func(dirEntries fs.DirEntries) error {
+		entries = append(entries, dirEntries...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list source: %w", err)
+	}
+	sort.Sort(entries)
+
+	pr, pw := io.Pipe()
+	go func() {
+		var aw archiveWriter
+		var out io.WriteCloser = nopWriteCloser{pw}
+		switch archiveFormat {
+		case formatTarGz:
+			out = gzip.NewWriter(pw)
+			aw = newTarWriter(out)
+		case formatTar:
+			aw = newTarWriter(out)
+		case formatZip:
+			aw = newZipWriter(out)
+		default:
+			_ = pw.CloseWithError(fmt.Errorf("unknown archive format %q", archiveFormat))
+			return
+		}
+		err := writeEntries(ctx, aw, entries, prefix)
+		if closeErr := aw.Close(); err == nil {
+			err = closeErr
+		}
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	_, err = operations.Rcat(ctx, fdst, dstFileName, pr, time.Now(), nil)
+	if err != nil {
+		_ = pr.CloseWithError(err)
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// writeEntries writes entries into aw
+func writeEntries(ctx context.Context, aw archiveWriter, entries fs.DirEntries, prefix string) error {
+	for _, entry := range entries {
+		name := path.Join(prefix, entry.Remote())
+		switch x := entry.(type) {
+		case fs.Directory:
+			if err := aw.addDir(ctx, name, x); err != nil {
+				return fmt.Errorf("failed to add directory %q: %w", entry.Remote(), err)
+			}
+		case fs.Object:
+			if err := addObject(ctx, aw, name, x); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addObject adds the object to the archive with accounting
+func addObject(ctx context.Context, aw archiveWriter, name string, o fs.Object) (err error) {
+	tr := accounting.Stats(ctx).NewTransfer(o, nil)
+	defer func() {
+		tr.Done(ctx, err)
+	}()
+	var metadata fs.Metadata
+	if fs.GetConfig(ctx).Metadata {
+		metadata, err = fs.GetMetadata(ctx, o)
+		if err != nil {
+			err = fs.CountError(err)
+			fs.Errorf(o, "Failed to read metadata: %v", err)
+			return err
+		}
+	}
+	if err = aw.addObject(ctx, name, &accountedObject{Object: o, tr: tr}, metadata); err != nil {
+		err = fs.CountError(err)
+		fs.Errorf(o, "Failed to add to archive: %v", err)
+		return err
+	}
+	fs.Infof(o, "Added to archive")
+	return nil
+}
+
+// accountedObject opens the object with accounting
+type accountedObject struct {
+	fs.Object
+	tr *accounting.Transfer
+}
+
+// Open the object for reading with accounting
+func (o *accountedObject) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	for _, option := range fs.GetConfig(ctx).DownloadHeaders {
+		options = append(options, option)
+	}
+	in, err := operations.Open(ctx, o.Object, options...)
+	if err != nil {
+		return nil, err
+	}
+	return o.tr.Account(ctx, in).WithBuffer(), nil
+}
+
+// nopWriteCloser adds a no-op Close to an io.Writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close does nothing
+func (nopWriteCloser) Close() error { return nil }
+
+// tarWriter writes tar archives
+type tarWriter struct {
+	tw *tar.Writer
+}
+
+func newTarWriter(out io.Writer) *tarWriter {
+	return &tarWriter{tw: tar.NewWriter(out)}
+}
+
+func (w *tarWriter) addDir(ctx context.Context, name string, dir fs.Directory) error {
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0755,
+		ModTime:  dir.ModTime(ctx),
+		Format:   tar.FormatPAX,
+	})
+}
+
+func (w *tarWriter) addObject(ctx context.Context, name string, o fs.Object, metadata fs.Metadata) (err error) {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     o.Size(),
+		ModTime:  o.ModTime(ctx),
+		Format:   tar.FormatPAX,
+	}
+	if len(metadata) > 0 {
+		hdr.PAXRecords = make(map[string]string, len(metadata))
+		for k, v := range metadata {
+			hdr.PAXRecords[paxMetadataPrefix+k] = v
+		}
+	}
+	var in io.ReadCloser
+	in, err = o.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(in, &err)
+	if hdr.Size < 0 {
+		// The tar header needs the size so spool the data to
+		// find it
+		var spooled *os.File
+		spooled, hdr.Size, err = spool(in)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = spooled.Close()
+			_ = os.Remove(spooled.Name())
+		}()
+		in = spooled
+	}
+	if err = w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(w.tw, in)
+	return err
+}
+
+// spool copies in to a temporary file returning it rewound with its
+// size. The caller should close and remove it.
+func spool(in io.Reader) (f *os.File, size int64, err error) {
+	f, err = os.CreateTemp("", "rclone-archive-")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to make temporary file for object of unknown size: %w", err)
+	}
+	size, err = io.Copy(f, in)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, 0, fmt.Errorf("failed to spool object of unknown size: %w", err)
+	}
+	return f, size, nil
+}
+
+func (w *tarWriter) Close() error {
+	return w.tw.Close()
+}
+
+// zipWriter writes zip archives
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func newZipWriter(out io.Writer) *zipWriter {
+	return &zipWriter{zw: zip.NewWriter(out)}
+}
+
+func (w *zipWriter) addDir(ctx context.Context, name string, dir fs.Directory) error {
+	_, err := w.zw.CreateHeader(&zip.FileHeader{
+		Name:     name + "/",
+		Modified: dir.ModTime(ctx),
+	})
+	return err
+}
+
+func (w *zipWriter) addObject(ctx context.Context, name string, o fs.Object, metadata fs.Metadata) (err error) {
+	in, err := o.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(in, &err)
+	hdr := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: o.ModTime(ctx),
+	}
+	hdr.SetMode(0644)
+	out, err := w.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
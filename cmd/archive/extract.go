@@ -0,0 +1,87 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+var extractCommand = &cobra.Command{
+	Use:   "extract source:path/archive dest:path",
+	Short: `Extract an archive on source:path into dest:path.`,
+	Long: `This reads the archive at source:path/archive and writes each file
+which passes the filters into dest:path.
+
+Tar archives are streamed from the source. Zip archives need random
+access to read their directory so the source must support ranged
+reads, which nearly all remotes do.
+
+Entries whose names would place them outside dest:path are skipped.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+	RunE: func(command *cobra.Command, args []string) error {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc, srcFileName, fdst := cmd.NewFsSrcFileDst(args)
+		cmd.Run(false, true, command, func() error {
+			if srcFileName == "" {
+				return errors.New("source must be an archive file")
+			}
+			archiveFormat, err := getFormat(srcFileName)
+			if err != nil {
+				return err
+			}
+			return Extract(context.Background(), fsrc, srcFileName, fdst, archiveFormat)
+		})
+		return nil
+	},
+}
+
+// Extract writes every entry which passes the filters from the archive
+// srcFileName on fsrc into fdst.
+func Extract(ctx context.Context, fsrc fs.Fs, srcFileName string, fdst fs.Fs, archiveFormat string) error {
+	o, err := fsrc.NewObject(ctx, srcFileName)
+	if err != nil {
+		return fmt.Errorf("failed to find archive: %w", err)
+	}
+	ci := fs.GetConfig(ctx)
+	fi := filter.GetConfig(ctx)
+	includeDirectory := fi.IncludeDirectory(ctx, fdst)
+	return readArchive(ctx, o, archiveFormat, func(entry *archiveEntry, in io.Reader) error {
+		if entry.Dir {
+			include, err := includeDirectory(entry.Name)
+			if err != nil {
+				return err
+			}
+			if !include {
+				fs.Debugf(entry.Name, "Excluded from extract")
+				return nil
+			}
+			if err := operations.Mkdir(ctx, fdst, entry.Name); err != nil {
+				return fmt.Errorf("failed to make directory %q: %w", entry.Name, err)
+			}
+			return nil
+		}
+		if !fi.Include(entry.Name, entry.Size, entry.ModTime, entry.Metadata) {
+			fs.Debugf(entry.Name, "Excluded from extract")
+			return nil
+		}
+		var meta fs.Metadata
+		if ci.Metadata {
+			meta = entry.Metadata
+		}
+		_, err := operations.RcatSize(ctx, fdst, entry.Name, io.NopCloser(in), entry.Size, entry.ModTime, meta)
+		if err != nil {
+			return fmt.Errorf("failed to extract %q: %w", entry.Name, err)
+		}
+		return nil
+	})
+}
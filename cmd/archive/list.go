@@ -0,0 +1,72 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+var listCommand = &cobra.Command{
+	Use:   "list source:path/archive",
+	Short: `List the contents of an archive on source:path.`,
+	Long: `This lists the entries in the archive at source:path/archive which
+pass the filters, showing the size, modification time and name of each,
+in the same format as ` + "`rclone lsl`" + `. Directories are shown with a
+trailing ` + "`/`" + `.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+	RunE: func(command *cobra.Command, args []string) error {
+		cmd.CheckArgs(1, 1, command, args)
+		fsrc, srcFileName := cmd.NewFsFile(args[0])
+		cmd.Run(false, false, command, func() error {
+			if srcFileName == "" {
+				return errors.New("source must be an archive file")
+			}
+			archiveFormat, err := getFormat(srcFileName)
+			if err != nil {
+				return err
+			}
+			return List(context.Background(), fsrc, srcFileName, archiveFormat, os.Stdout)
+		})
+		return nil
+	},
+}
+
+// List writes the entries of the archive srcFileName on fsrc which
+// pass the filters to out.
+func List(ctx context.Context, fsrc fs.Fs, srcFileName string, archiveFormat string, out io.Writer) error {
+	o, err := fsrc.NewObject(ctx, srcFileName)
+	if err != nil {
+		return fmt.Errorf("failed to find archive: %w", err)
+	}
+	ci := fs.GetConfig(ctx)
+	fi := filter.GetConfig(ctx)
+	includeDirectory := fi.IncludeDirectory(ctx, fsrc)
+	return readArchive(ctx, o, archiveFormat, func(entry *archiveEntry, in io.Reader) error {
+		name := entry.Name
+		if entry.Dir {
+			include, err := includeDirectory(entry.Name)
+			if err != nil {
+				return err
+			}
+			if !include {
+				return nil
+			}
+			name += "/"
+		} else if !fi.Include(entry.Name, entry.Size, entry.ModTime, entry.Metadata) {
+			return nil
+		}
+		_, err := fmt.Fprintf(out, "%s %s %s\n", operations.SizeStringField(entry.Size, ci.HumanReadable, 9), entry.ModTime.Local().Format("2006-01-02 15:04:05.000000000"), name)
+		return err
+	})
+}
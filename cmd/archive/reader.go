@@ -0,0 +1,210 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// archiveEntry describes an entry read from an archive
+type archiveEntry struct {
+	Name     string      // cleaned name of the entry
+	Dir      bool        // set if this is a directory
+	Size     int64       // size of the entry
+	ModTime  time.Time   // modification time of the entry
+	Metadata fs.Metadata // metadata if stored in the archive
+}
+
+// entryFn is called with each entry of an archive
+//
+// in reads the contents of file entries and is nil for directories
+type entryFn func(entry *archiveEntry, in io.Reader) error
+
+// readArchive calls fn with each entry of the archive in o
+func readArchive(ctx context.Context, o fs.Object, archiveFormat string, fn entryFn) error {
+	switch archiveFormat {
+	case formatTar, formatTarGz:
+		return readTar(ctx, o, archiveFormat == formatTarGz, fn)
+	case formatZip:
+		return readZip(ctx, o, fn)
+	}
+	return fmt.Errorf("unknown archive format %q", archiveFormat)
+}
+
+// readTar calls fn with each entry of the tar archive in o
+func readTar(ctx context.Context, o fs.Object, gzipped bool, fn entryFn) (err error) {
+	rc, err := operations.Open(ctx, o)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(rc, &err)
+	var in io.Reader = rc
+	if gzipped {
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return fmt.Errorf("failed to read gzip header: %w", err)
+		}
+		defer fs.CheckClose(gz, &err)
+		in = gz
+	}
+	tr := tar.NewReader(in)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+		name, err := cleanName(hdr.Name)
+		if err != nil {
+			fs.Errorf(hdr.Name, "Skipping archive entry: %v", err)
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		entry := &archiveEntry{
+			Name:    name,
+			Size:    hdr.Size,
+			ModTime: hdr.ModTime,
+		}
+		for k, v := range hdr.PAXRecords {
+			if key, found := strings.CutPrefix(k, paxMetadataPrefix); found {
+				if entry.Metadata == nil {
+					entry.Metadata = fs.Metadata{}
+				}
+				entry.Metadata[key] = v
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			entry.Dir = true
+			err = fn(entry, nil)
+		case tar.TypeReg:
+			err = fn(entry, tr)
+		case tar.TypeSymlink, tar.TypeLink:
+			fs.Logf(name, "Skipping link to %q in archive as links can't be extracted", hdr.Linkname)
+		default:
+			fs.Debugf(hdr.Name, "Skipping archive entry of unsupported type %q", hdr.Typeflag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readZip calls fn with each entry of the zip archive in o
+func readZip(ctx context.Context, o fs.Object, fn entryFn) error {
+	ra := &objectReaderAt{ctx: ctx, o: o}
+	defer func() { _ = ra.Close() }()
+	zr, err := zip.NewReader(ra, o.Size())
+	if err != nil {
+		return fmt.Errorf("failed to read zip directory: %w", err)
+	}
+	for _, file := range zr.File {
+		name, err := cleanName(file.Name)
+		if err != nil {
+			fs.Errorf(file.Name, "Skipping archive entry: %v", err)
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		entry := &archiveEntry{
+			Name:    name,
+			Dir:     file.FileInfo().IsDir(),
+			Size:    int64(file.UncompressedSize64),
+			ModTime: file.Modified,
+		}
+		if file.Mode()&os.ModeSymlink != 0 {
+			fs.Logf(name, "Skipping link in archive as links can't be extracted")
+			continue
+		}
+		if entry.Dir {
+			err = fn(entry, nil)
+		} else {
+			err = readZipFile(file, entry, fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readZipFile opens a single zip entry and calls fn with it
+func readZipFile(file *zip.File, entry *archiveEntry, fn entryFn) (err error) {
+	in, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %q in archive: %w", file.Name, err)
+	}
+	defer fs.CheckClose(in, &err)
+	return fn(entry, in)
+}
+
+// objectReaderAt reads an object at arbitrary offsets.
+//
+// Zip archives need random access to read the central directory, but
+// the entries are then mostly read in order, so this keeps a stream
+// open and only re-opens the object when a read isn't sequential.
+type objectReaderAt struct {
+	ctx context.Context
+	o   fs.Object
+
+	mu  sync.Mutex
+	in  io.ReadCloser // current stream or nil
+	pos int64         // offset of in
+}
+
+// ReadAt reads len(p) bytes at offset off
+func (r *objectReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if off >= r.o.Size() {
+		return 0, io.EOF
+	}
+	if r.in == nil || off != r.pos {
+		if r.in != nil {
+			_ = r.in.Close()
+			r.in = nil
+		}
+		r.in, err = operations.Open(r.ctx, r.o, &fs.RangeOption{Start: off, End: -1})
+		if err != nil {
+			return 0, err
+		}
+		r.pos = off
+	}
+	n, err = io.ReadFull(r.in, p)
+	r.pos += int64(n)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	if err != nil {
+		_ = r.in.Close()
+		r.in = nil
+	}
+	return n, err
+}
+
+// Close the current stream if any
+func (r *objectReaderAt) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.in == nil {
+		return nil
+	}
+	err := r.in.Close()
+	r.in = nil
+	return err
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/rclone/rclone/fs/rc/rcserver"
 	fssync "github.com/rclone/rclone/fs/sync"
+	"github.com/rclone/rclone/fs/tracing"
 	"github.com/rclone/rclone/lib/atexit"
 	"github.com/rclone/rclone/lib/buildinfo"
 	"github.com/rclone/rclone/lib/exitcode"
@@ -418,6 +419,13 @@ func initConfig() {
 		fs.Debugf("rclone", "systemd logging support activated")
 	}
 
+	// Start tracing if configured
+	stopTracing, err := tracing.Start(ctx, &tracing.Opt)
+	if err != nil {
+		fs.Fatalf(nil, "Failed to start tracing: %v", err)
+	}
+	atexit.Register(stopTracing)
+
 	// Start the remote control server if configured
 	_, err = rcserver.Start(ctx, &rc.Opt)
 	if err != nil {
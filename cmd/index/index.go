@@ -0,0 +1,200 @@
+// Package index provides the index command.
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/index"
+	"github.com/rclone/rclone/lib/atexit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watch        = false
+	pollInterval = time.Minute
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	commandDefinition.AddCommand(buildCommand)
+	commandDefinition.AddCommand(verifyCommand)
+	commandDefinition.AddCommand(dropCommand)
+	cmdFlags := buildCommand.Flags()
+	flags.BoolVarP(cmdFlags, &watch, "watch", "", watch, "Keep running and invalidate listings as changes are notified", "")
+	flags.DurationVarP(cmdFlags, &pollInterval, "poll-interval", "", pollInterval, "Time to wait between polling for changes when using --watch", "")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "index <subcommand>",
+	Short: `Manage the persistent listing index.`,
+	Long: `The persistent listing index records the listing of each directory
+of a remote so that ` + "`--list-index`" + ` can avoid listing directories
+which are proven to be unchanged when marching through a remote, for
+example in ` + "`sync`, `copy` and `check`" + `.
+
+A recorded listing is used instead of listing the remote if any of
+these are true
+
+- it is younger than ` + "`--list-index-max-age`" + `
+- ` + "`rclone index build --watch`" + ` has been running since it was recorded
+- ` + "`--list-index-dir-fingerprint`" + ` is set and the modification time,
+  size and ID of the directory in its parent listing are unchanged
+
+Only use ` + "`--list-index-dir-fingerprint`" + ` with remotes which update
+the modification time of a directory whenever its contents change,
+e.g. the local backend.
+
+The index is stored in the ` + "`kv`" + ` directory of the cache directory, one
+database per remote.
+
+Select which index command you want with the subcommand, e.g.
+
+    rclone index build remote:path
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+}
+
+var buildCommand = &cobra.Command{
+	Use:   "build remote:path",
+	Short: `Record the listing of every directory in remote:path in the index.`,
+	Long: `This lists every directory in remote:path and records the listings in
+the persistent listing index, replacing any which were there before.
+
+If ` + "`--watch`" + ` is set then it keeps running afterwards, using the
+change notification support of the remote to invalidate listings
+which change. While it is running other rclone processes using
+` + "`--list-index`" + ` will trust listings recorded after it started.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+	RunE: func(command *cobra.Command, args []string) error {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsDir(args)
+		cmd.Run(false, false, command, func() error {
+			ctx, cancel := context.WithCancel(command.Context())
+			defer cancel()
+			idx, err := index.Open(ctx, f)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = idx.Close() }()
+			if watch && f.Features().ChangeNotify == nil {
+				return errors.New("--watch is not supported by this remote")
+			}
+			if watch {
+				// Start watching before building so no changes are missed
+				watchErr := make(chan error, 1)
+				go func() {
+					watchErr <- idx.Watch(ctx, pollInterval)
+				}()
+				// Stop watching on SIGINT/SIGTERM and wait for the
+				// watcher to remove its record from the index
+				stopped := make(chan struct{})
+				handle := atexit.Register(func() {
+					cancel()
+					<-stopped
+				})
+				defer atexit.Unregister(handle)
+				err := build(ctx, f, idx)
+				if err != nil {
+					cancel()
+				}
+				if wErr := <-watchErr; err == nil {
+					err = wErr
+				}
+				close(stopped)
+				return err
+			}
+			return build(ctx, f, idx)
+		})
+		return nil
+	},
+}
+
+// build the index logging the result
+func build(ctx context.Context, f fs.Fs, idx *index.Index) error {
+	dirs, err := idx.Build(ctx, "")
+	if err != nil {
+		return err
+	}
+	fs.Logf(f, "Recorded %d directory listings in %s", dirs, idx.Path())
+	return nil
+}
+
+var verifyCommand = &cobra.Command{
+	Use:   "verify remote:path",
+	Short: `Check the index for remote:path is up to date.`,
+	Long: `This lists every directory in remote:path and compares it with the
+listing recorded in the persistent listing index.
+
+Any listings which are out of date are logged and replaced with the
+fresh listing and listings of directories which no longer exist are
+removed. If any were out of date it returns with an error.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+	RunE: func(command *cobra.Command, args []string) error {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsDir(args)
+		cmd.Run(false, false, command, func() error {
+			ctx := context.Background()
+			idx, err := index.Open(ctx, f)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = idx.Close() }()
+			dirs, changed, err := idx.Verify(ctx, "")
+			if err != nil {
+				return err
+			}
+			fs.Logf(f, "%d directory listings checked, %d out of date", dirs, changed)
+			if changed > 0 {
+				return fmt.Errorf("%d directory listings were out of date", changed)
+			}
+			return nil
+		})
+		return nil
+	},
+}
+
+var dropCommand = &cobra.Command{
+	Use:   "drop remote:path",
+	Short: `Remove the listings for remote:path from the index.`,
+	Long: `This removes the listings of remote:path and every directory below it
+from the persistent listing index.
+
+Use the root of the remote, e.g. ` + "`remote:`" + ` to remove the database
+file for the remote entirely.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+	RunE: func(command *cobra.Command, args []string) error {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsDir(args)
+		cmd.Run(false, false, command, func() error {
+			ctx := context.Background()
+			idx, err := index.Open(ctx, f)
+			if err != nil {
+				return err
+			}
+			if f.Root() == "" {
+				fs.Logf(f, "Removing listing index %s", idx.Path())
+				return idx.Drop()
+			}
+			defer func() { _ = idx.Close() }()
+			return idx.Invalidate(ctx, "", true)
+		})
+		return nil
+	},
+}
@@ -268,3 +268,59 @@ func (d *Dir) Mknod(ctx context.Context, req *fuse.MknodRequest) (node fusefs.No
 	}
 	return node, nil
 }
+
+// Getxattr gets an extended attribute by the given name from the
+// node.
+//
+// If there is no xattr by that name, returns fuse.ErrNoXattr.
+func (d *Dir) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) (err error) {
+	if !d.fsys.opt.Xattr {
+		return syscall.ENOSYS
+	}
+	defer log.Trace(d, "name=%q", req.Name)("value=%q, err=%v", &resp.Xattr, &err)
+	resp.Xattr, err = d.Dir.GetXattr(req.Name)
+	return translateError(err)
+}
+
+var _ fusefs.NodeGetxattrer = (*Dir)(nil)
+
+// Listxattr lists the extended attributes recorded for the node.
+func (d *Dir) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) (err error) {
+	if !d.fsys.opt.Xattr {
+		return syscall.ENOSYS
+	}
+	defer log.Trace(d, "")("err=%v", &err)
+	names, err := d.Dir.ListXattr()
+	if err != nil {
+		return translateError(err)
+	}
+	resp.Append(names...)
+	return nil
+}
+
+var _ fusefs.NodeListxattrer = (*Dir)(nil)
+
+// Setxattr sets an extended attribute with the given name and
+// value for the node.
+func (d *Dir) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) (err error) {
+	if !d.fsys.opt.Xattr {
+		return syscall.ENOSYS
+	}
+	defer log.Trace(d, "name=%q, value=%q", req.Name, req.Xattr)("err=%v", &err)
+	return translateError(d.Dir.SetXattr(req.Name, req.Xattr))
+}
+
+var _ fusefs.NodeSetxattrer = (*Dir)(nil)
+
+// Removexattr removes an extended attribute for the name.
+//
+// If there is no xattr by that name, returns fuse.ErrNoXattr.
+func (d *Dir) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) (err error) {
+	if !d.fsys.opt.Xattr {
+		return syscall.ENOSYS
+	}
+	defer log.Trace(d, "name=%q", req.Name)("err=%v", &err)
+	return translateError(d.Dir.RemoveXattr(req.Name))
+}
+
+var _ fusefs.NodeRemovexattrer = (*Dir)(nil)
@@ -100,6 +100,12 @@ func translateError(err error) error {
 		return syscall.ENOSYS
 	case vfs.EINVAL:
 		return fuse.Errno(syscall.EINVAL)
+	case vfs.ENOATTR:
+		return fuse.ErrNoXattr
+	case vfs.ENOTSUP:
+		return fuse.Errno(syscall.ENOTSUP)
+	case vfs.ELOCKED:
+		return fuse.Errno(syscall.EBUSY)
 	}
 	fs.Errorf(nil, "IO error: %v", err)
 	return err
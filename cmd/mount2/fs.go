@@ -9,6 +9,7 @@ import (
 	"syscall"
 	"time"
 
+	fusefs "github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/rclone/rclone/cmd/mountlib"
 	"github.com/rclone/rclone/fs"
@@ -128,6 +129,12 @@ func translateError(err error) syscall.Errno {
 		return syscall.ENOSYS
 	case vfs.EINVAL:
 		return syscall.EINVAL
+	case vfs.ENOATTR:
+		return fusefs.ENOATTR
+	case vfs.ENOTSUP:
+		return syscall.ENOTSUP
+	case vfs.ELOCKED:
+		return syscall.EBUSY
 	}
 	fs.Errorf(nil, "IO error: %v", err)
 	return syscall.EIO
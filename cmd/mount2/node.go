@@ -411,8 +411,19 @@ var _ = (fusefs.NodeRenamer)((*Node)(nil))
 // `dest` and return the number of bytes. If `dest` is too
 // small, it should return ERANGE and the size of the attribute.
 // If not defined, Getxattr will return ENOATTR.
-func (n *Node) Getxattr(ctx context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
-	return 0, syscall.ENOSYS // we never implement this
+func (n *Node) Getxattr(ctx context.Context, attr string, dest []byte) (size uint32, errno syscall.Errno) {
+	if !n.fsys.opt.Xattr {
+		return 0, syscall.ENOSYS
+	}
+	defer log.Trace(n, "attr=%q", attr)("size=%d, errno=%v", &size, &errno)
+	value, err := n.node.GetXattr(attr)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	if len(value) > len(dest) {
+		return uint32(len(value)), syscall.ERANGE
+	}
+	return uint32(copy(dest, value)), 0
 }
 
 var _ fusefs.NodeGetxattrer = (*Node)(nil)
@@ -420,16 +431,24 @@ var _ fusefs.NodeGetxattrer = (*Node)(nil)
 // Setxattr should store data for the given attribute.  See
 // setxattr(2) for information about flags.
 // If not defined, Setxattr will return ENOATTR.
-func (n *Node) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) syscall.Errno {
-	return syscall.ENOSYS // we never implement this
+func (n *Node) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) (errno syscall.Errno) {
+	if !n.fsys.opt.Xattr {
+		return syscall.ENOSYS
+	}
+	defer log.Trace(n, "attr=%q, data=%q, flags=%d", attr, data, flags)("errno=%v", &errno)
+	return translateError(n.node.SetXattr(attr, data))
 }
 
 var _ fusefs.NodeSetxattrer = (*Node)(nil)
 
 // Removexattr should delete the given attribute.
 // If not defined, Removexattr will return ENOATTR.
-func (n *Node) Removexattr(ctx context.Context, attr string) syscall.Errno {
-	return syscall.ENOSYS // we never implement this
+func (n *Node) Removexattr(ctx context.Context, attr string) (errno syscall.Errno) {
+	if !n.fsys.opt.Xattr {
+		return syscall.ENOSYS
+	}
+	defer log.Trace(n, "attr=%q", attr)("errno=%v", &errno)
+	return translateError(n.node.RemoveXattr(attr))
 }
 
 var _ fusefs.NodeRemovexattrer = (*Node)(nil)
@@ -438,8 +457,24 @@ var _ fusefs.NodeRemovexattrer = (*Node)(nil)
 // `dest`. If the `dest` buffer is too small, it should return ERANGE
 // and the correct size.  If not defined, return an empty list and
 // success.
-func (n *Node) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
-	return 0, syscall.ENOSYS // we never implement this
+func (n *Node) Listxattr(ctx context.Context, dest []byte) (size uint32, errno syscall.Errno) {
+	if !n.fsys.opt.Xattr {
+		return 0, syscall.ENOSYS
+	}
+	defer log.Trace(n, "")("size=%d, errno=%v", &size, &errno)
+	names, err := n.node.ListXattr()
+	if err != nil {
+		return 0, translateError(err)
+	}
+	var buf []byte
+	for _, name := range names {
+		buf = append(buf, name...)
+		buf = append(buf, 0)
+	}
+	if len(buf) > len(dest) {
+		return uint32(len(buf)), syscall.ERANGE
+	}
+	return uint32(copy(dest, buf)), 0
 }
 
 var _ fusefs.NodeListxattrer = (*Node)(nil)
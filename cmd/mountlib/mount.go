@@ -138,6 +138,11 @@ var OptionsInfo = fs.Options{{
 	Default: false,
 	Help:    "Ignore all \"com.apple.*\" extended attributes (supported on OSX only)",
 	Groups:  "Mount",
+}, {
+	Name:    "xattr",
+	Default: false,
+	Help:    "Show the metadata of files as \"user.rclone.*\" extended attributes",
+	Groups:  "Mount",
 }, {
 	Name:    "network_mode",
 	Default: false,
@@ -184,6 +189,7 @@ type Options struct {
 	VolumeName         string        `config:"volname"`
 	NoAppleDouble      bool          `config:"noappledouble"`
 	NoAppleXattr       bool          `config:"noapplexattr"`
+	Xattr              bool          `config:"xattr"`
 	DaemonTimeout      fs.Duration   `config:"daemon_timeout"` // OSXFUSE only
 	AsyncRead          bool          `config:"async_read"`
 	NetworkMode        bool          `config:"network_mode"` // Windows only
@@ -19,6 +19,7 @@ import (
 	"github.com/rclone/rclone/cmd/serve/proxy/proxyflags"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/config/flags"
 	libhttp "github.com/rclone/rclone/lib/http"
 	"github.com/rclone/rclone/lib/http/serve"
 	"github.com/rclone/rclone/lib/systemd"
@@ -30,9 +31,10 @@ import (
 
 // Options required for http server
 type Options struct {
-	Auth     libhttp.AuthConfig
-	HTTP     libhttp.Config
-	Template libhttp.TemplateConfig
+	Auth       libhttp.AuthConfig
+	HTTP       libhttp.Config
+	Template   libhttp.TemplateConfig
+	AllowWrite bool
 }
 
 // DefaultOpt is the default values used for Options
@@ -56,6 +58,7 @@ func init() {
 	libhttp.AddTemplateFlagsPrefix(flagSet, flagPrefix, &Opt.Template)
 	vfsflags.AddFlags(flagSet)
 	proxyflags.AddFlags(flagSet)
+	flags.BoolVarP(flagSet, &Opt.AllowWrite, "allow-write", "", false, "Allow uploading, making directories and deleting from the web page", "")
 }
 
 // Command definition for cobra
@@ -74,6 +77,36 @@ The server will log errors.  Use ` + "`-v`" + ` to see access logs.
 ` + "`--bwlimit`" + ` will be respected for file transfers.  Use ` + "`--stats`" + ` to
 control the stats printing.
 
+### Downloading and uploading
+
+Any directory can be downloaded as a zip file using the link at the
+top of its listing, or by adding ` + "`?download=zip`" + ` to its URL.
+The zip is streamed as it is made so it has no Content-Length.
+
+If ` + "`--allow-write`" + ` is set then the web page can be used to upload
+files, either with the upload button or by dragging and dropping them
+onto the page, make directories and delete files and empty
+directories. This isn't allowed if ` + "`--read-only`" + ` is set. These
+are done with a ` + "`multipart/form-data`" + ` POST to the directory with
+these fields, which can also be used from scripts:
+
+- ` + "`file`" + ` - a file to upload, which may be repeated
+- ` + "`mkdir`" + ` - the name of a directory to make
+- ` + "`delete`" + ` - the name of a file or empty directory to delete
+
+for example
+
+    curl -u user:pass -H "Origin: http://localhost:8080" -F file=@report.pdf http://localhost:8080/dir/
+
+POST requests from web pages on other sites are refused so that they
+can't use the credentials cached by the browser. To make sure of this
+a POST must have an ` + "`Origin`" + ` or ` + "`Referer`" + ` header, which
+browsers send, for the host it was sent to, so scripts need to add
+one as above. When behind a reverse proxy the host in
+` + "`X-Forwarded-Host`" + ` is allowed too. Use the
+` + "`--user`" + ` and ` + "`--pass`" + ` flags or ` + "`--htpasswd`" + ` to restrict who
+can make changes.
+
 ` + libhttp.Help(flagPrefix) + libhttp.TemplateHelp(flagPrefix) + libhttp.AuthHelp(flagPrefix) + vfs.Help() + proxy.Help,
 	Annotations: map[string]string{
 		"versionIntroduced": "v1.39",
@@ -167,6 +200,9 @@ func run(ctx context.Context, f fs.Fs, opt Options) (s *HTTP, err error) {
 	)
 	router.Get("/*", s.handler)
 	router.Head("/*", s.handler)
+	if s.opt.AllowWrite {
+		router.Post("/*", s.handlePost)
+	}
 
 	s.server.Serve()
 
@@ -206,6 +242,10 @@ func (s *HTTP) serveDir(w http.ResponseWriter, r *http.Request, dirRemote string
 		return
 	}
 	dir := node.(*vfs.Dir)
+	if r.URL.Query().Get("download") == "zip" {
+		s.serveZip(w, r, dir)
+		return
+	}
 	dirEntries, err := dir.ReadDirAll()
 	if err != nil {
 		serve.Error(dirRemote, w, "Failed to list directory", err)
@@ -214,6 +254,8 @@ func (s *HTTP) serveDir(w http.ResponseWriter, r *http.Request, dirRemote string
 
 	// Make the entries for display
 	directory := serve.NewDirectory(dirRemote, s.server.HTMLTemplate())
+	directory.AllowZip = true
+	directory.AllowWrite = s.opt.AllowWrite && !VFS.Opt.ReadOnly
 	for _, node := range dirEntries {
 		if vfscommon.Opt.NoModTime {
 			directory.AddHTMLEntry(node.Path(), node.IsDir(), node.Size(), time.Time{})
@@ -1,10 +1,14 @@
 package http
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"flag"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,6 +20,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/filter"
 	libhttp "github.com/rclone/rclone/lib/http"
+	"github.com/rclone/rclone/vfs/vfscommon"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -267,3 +272,244 @@ func TestGET(t *testing.T) {
 func TestAuthProxy(t *testing.T) {
 	testGET(t, true)
 }
+
+// startWrite starts a server with --allow-write on a temporary
+// directory using the default template
+func startWrite(ctx context.Context, t *testing.T, readOnly bool) (f fs.Fs, testURL string) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("existing"), 0666))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0777))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "deep.txt"), []byte("deep"), 0666))
+	f, err := fs.NewFs(ctx, dir)
+	require.NoError(t, err)
+
+	oldReadOnly := vfscommon.Opt.ReadOnly
+	vfscommon.Opt.ReadOnly = readOnly
+	defer func() {
+		vfscommon.Opt.ReadOnly = oldReadOnly
+	}()
+	opts := Options{
+		HTTP:       libhttp.DefaultCfg(),
+		AllowWrite: true,
+	}
+	opts.HTTP.ListenAddr = []string{testBindAddress}
+	s, err := run(ctx, f, opts)
+	require.NoError(t, err, "failed to start server")
+	t.Cleanup(func() {
+		assert.NoError(t, s.server.Shutdown())
+	})
+	return f, s.server.URLs()[0]
+}
+
+// post sends a multipart/form-data POST with fields to testURL
+//
+// It has the Origin of testURL as a browser would send unless headers
+// changes it. Headers with empty values are removed.
+func post(t *testing.T, testURL string, headers map[string]string, fields ...[2]string) (*http.Response, string) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, field := range fields {
+		if field[0] == "file" {
+			name, contents, _ := strings.Cut(field[1], "=")
+			w, err := mw.CreateFormFile("file", name)
+			require.NoError(t, err)
+			_, err = w.Write([]byte(contents))
+			require.NoError(t, err)
+		} else {
+			require.NoError(t, mw.WriteField(field[0], field[1]))
+		}
+	}
+	require.NoError(t, mw.Close())
+	req, err := http.NewRequest("POST", testURL, &body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Origin", req.URL.Scheme+"://"+req.URL.Host)
+	for k, v := range headers {
+		if v == "" {
+			req.Header.Del(k)
+		} else {
+			req.Header.Set(k, v)
+		}
+	}
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	return resp, string(respBody)
+}
+
+func TestAllowWrite(t *testing.T) {
+	ctx := context.Background()
+	f, testURL := startWrite(ctx, t, false)
+
+	// The listing has the upload controls
+	resp, err := http.Get(testURL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Contains(t, string(body), `name="mkdir"`)
+	assert.Contains(t, string(body), `name="delete" value="existing.txt"`)
+
+	// Upload two files and make a directory
+	resp, text := post(t, testURL+"sub/", nil, [2]string{"file", "a.txt=hello"}, [2]string{"file", "b.txt=world"}, [2]string{"mkdir", "newdir"})
+	require.Equal(t, http.StatusSeeOther, resp.StatusCode, text)
+	assert.Equal(t, "./", resp.Header.Get("Location"))
+	for remote, want := range map[string]string{"sub/a.txt": "hello", "sub/b.txt": "world"} {
+		o, err := f.NewObject(ctx, remote)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(want)), o.Size(), remote)
+	}
+	entries, err := f.List(ctx, "sub/newdir")
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+
+	// Delete a file and an empty directory but not a full one
+	resp, text = post(t, testURL, nil, [2]string{"delete", "existing.txt"})
+	assert.Equal(t, http.StatusSeeOther, resp.StatusCode, text)
+	_, err = f.NewObject(ctx, "existing.txt")
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+	resp, text = post(t, testURL+"sub/", nil, [2]string{"delete", "newdir/"})
+	assert.Equal(t, http.StatusSeeOther, resp.StatusCode, text)
+	resp, _ = post(t, testURL, nil, [2]string{"delete", "sub"})
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// Bad names and requests
+	resp, _ = post(t, testURL, nil, [2]string{"mkdir", ".."})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = post(t, testURL, nil, [2]string{"delete", "notfound"})
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = post(t, testURL+"notfound/", nil, [2]string{"mkdir", "x"})
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = post(t, testURL+"sub/a.txt", nil, [2]string{"mkdir", "x"})
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	// Cross origin requests are refused
+	resp, _ = post(t, testURL, map[string]string{"Origin": "http://example.com"}, [2]string{"mkdir", "evil"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, _ = post(t, testURL, map[string]string{"Sec-Fetch-Site": "cross-site"}, [2]string{"mkdir", "evil"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, _ = post(t, testURL, map[string]string{"Origin": "", "Referer": "http://example.com/"}, [2]string{"mkdir", "evil"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, _ = post(t, testURL, map[string]string{"Origin": "null"}, [2]string{"mkdir", "evil"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// As are requests with no Origin or Referer
+	resp, _ = post(t, testURL, map[string]string{"Origin": ""}, [2]string{"mkdir", "evil"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_, err = f.List(ctx, "evil")
+	assert.Equal(t, fs.ErrorDirNotFound, err)
+
+	// A Referer from this server or a reverse proxy in front of it is allowed
+	resp, text = post(t, testURL, map[string]string{"Origin": "", "Referer": testURL + "sub/"}, [2]string{"mkdir", "fromreferer"})
+	assert.Equal(t, http.StatusSeeOther, resp.StatusCode, text)
+	resp, text = post(t, testURL, map[string]string{"Origin": "https://files.example.com", "X-Forwarded-Host": "files.example.com"}, [2]string{"mkdir", "fromproxy"})
+	assert.Equal(t, http.StatusSeeOther, resp.StatusCode, text)
+}
+
+func TestSameOrigin(t *testing.T) {
+	for _, test := range []struct {
+		headers map[string]string
+		baseURL string
+		want    bool
+	}{
+		{map[string]string{}, "", false},
+		{map[string]string{"Origin": "http://localhost:8080"}, "", true},
+		{map[string]string{"Origin": "http://LOCALHOST:8080"}, "", true},
+		{map[string]string{"Origin": "http://localhost:8081"}, "", false},
+		{map[string]string{"Origin": "null"}, "", false},
+		{map[string]string{"Origin": "http://localhost:8080", "Sec-Fetch-Site": "cross-site"}, "", false},
+		{map[string]string{"Referer": "http://localhost:8080/dir/"}, "", true},
+		{map[string]string{"Referer": "http://localhost:8080/prefix/dir/"}, "/prefix", true},
+		{map[string]string{"Referer": "http://localhost:8080/prefix"}, "prefix/", true},
+		{map[string]string{"Referer": "http://localhost:8080/other/dir/"}, "/prefix", false},
+		{map[string]string{"Referer": "http://localhost:8080/prefixed/"}, "/prefix", false},
+		{map[string]string{"Referer": "/dir/"}, "", false},
+		{map[string]string{"Origin": "https://example.com", "X-Forwarded-Host": "example.com"}, "", true},
+		{map[string]string{"Origin": "https://example.com", "X-Forwarded-Host": "proxy.com, example.com"}, "", true},
+		{map[string]string{"Origin": "https://example.com", "X-Forwarded-Host": "proxy.com"}, "", false},
+	} {
+		r := httptest.NewRequest("POST", "http://localhost:8080/dir/", nil)
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+		assert.Equal(t, test.want, sameOrigin(r, test.baseURL), "%v baseURL=%q", test.headers, test.baseURL)
+	}
+}
+
+func TestAllowWriteReadOnly(t *testing.T) {
+	ctx := context.Background()
+	f, testURL := startWrite(ctx, t, true)
+
+	resp, err := http.Get(testURL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.NotContains(t, string(body), `name="mkdir"`)
+
+	resp, _ = post(t, testURL, nil, [2]string{"delete", "existing.txt"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_, err = f.NewObject(ctx, "existing.txt")
+	assert.NoError(t, err)
+}
+
+func TestZip(t *testing.T) {
+	ctx := context.Background()
+	_, testURL := startWrite(ctx, t, false)
+
+	resp, err := http.Get(testURL + "?download=zip")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/zip", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=download.zip`, resp.Header.Get("Content-Disposition"))
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	got := map[string]string{}
+	for _, file := range zr.File {
+		in, err := file.Open()
+		require.NoError(t, err)
+		contents, err := io.ReadAll(in)
+		require.NoError(t, err)
+		require.NoError(t, in.Close())
+		got[file.Name] = string(contents)
+	}
+	assert.Equal(t, map[string]string{
+		"existing.txt": "existing",
+		"sub/":         "",
+		"sub/deep.txt": "deep",
+	}, got)
+
+	resp, err = http.Head(testURL + "sub/?download=zip")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, `attachment; filename=sub.zip`, resp.Header.Get("Content-Disposition"))
+}
+
+func TestZipName(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"file.txt", "file.txt"},
+		{"dir/file.txt", "dir/file.txt"},
+		{"/etc/passwd", "etc/passwd"},
+		{"../../etc/passwd", "etc/passwd"},
+		{"dir/../../file.txt", "file.txt"},
+		{"..\\..\\file.txt", "file.txt"},
+		{"..", ""},
+		{"", ""},
+	} {
+		assert.Equal(t, test.want, zipName(test.in), test.in)
+	}
+}
@@ -0,0 +1,247 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/http/serve"
+	"github.com/rclone/rclone/vfs"
+)
+
+// maxFormValue is the largest form value other than a file accepted
+const maxFormValue = 4096
+
+// sameOrigin returns true only if the request came from a page on
+// this server.
+//
+// This stops other sites using the credentials the browser has cached
+// for this server to upload or delete files. The request must have an
+// Origin or Referer header, which browsers send with form posts, for
+// the host the request was sent to, or the host in X-Forwarded-Host
+// if it came through a reverse proxy. A Referer must also be under
+// baseURL.
+func sameOrigin(r *http.Request, baseURL string) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return false
+	}
+	source := r.Header.Get("Origin")
+	isReferer := false
+	if source == "" {
+		source = r.Header.Get("Referer")
+		isReferer = true
+	}
+	if source == "" {
+		return false
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if isReferer {
+		baseURL = "/" + strings.Trim(baseURL, "/")
+		if baseURL != "/" && u.Path != baseURL && !strings.HasPrefix(u.Path, baseURL+"/") {
+			return false
+		}
+	}
+	hosts := []string{r.Host}
+	for _, host := range strings.Split(r.Header.Get("X-Forwarded-Host"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	for _, host := range hosts {
+		if strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLeaf returns an error if name can't be used as the name of an
+// entry in a directory
+func checkLeaf(name string) error {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	return nil
+}
+
+// writeError writes a response for err which came from the VFS
+func writeError(w http.ResponseWriter, remote string, text string, err error) {
+	switch {
+	case errors.Is(err, vfs.ENOENT):
+		http.Error(w, text+": not found.", http.StatusNotFound)
+	case errors.Is(err, vfs.EEXIST), errors.Is(err, vfs.ENOTEMPTY):
+		http.Error(w, text+": "+err.Error()+".", http.StatusConflict)
+	case errors.Is(err, vfs.EROFS), errors.Is(err, vfs.EPERM):
+		http.Error(w, text+": permission denied.", http.StatusForbidden)
+	default:
+		serve.Error(remote, w, text, err)
+	}
+}
+
+// handlePost uploads files, makes directories and deletes entries in
+// the directory of the request.
+//
+// The body is multipart/form-data as sent by a form so it is read a
+// part at a time with
+//
+//	file   - a file to upload into the directory
+//	mkdir  - the name of a directory to make
+//	delete - the name of a file or empty directory to delete
+//
+// On success it redirects back to the directory listing.
+func (s *HTTP) handlePost(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dirRemote := strings.Trim(r.URL.Path, "/")
+	if !sameOrigin(r, s.opt.HTTP.BaseURL) {
+		fs.Errorf(dirRemote, "%s: Refusing cross origin POST from Origin %q Referer %q", r.RemoteAddr, r.Header.Get("Origin"), r.Header.Get("Referer"))
+		http.Error(w, "Cross origin request refused", http.StatusForbidden)
+		return
+	}
+	VFS, err := s.getVFS(r.Context())
+	if err != nil {
+		http.Error(w, "Root directory not found", http.StatusNotFound)
+		fs.Errorf(nil, "Failed to serve directory: %v", err)
+		return
+	}
+	if VFS.Opt.ReadOnly {
+		http.Error(w, "Read only", http.StatusForbidden)
+		return
+	}
+	node, err := VFS.Stat(dirRemote)
+	if err != nil {
+		writeError(w, dirRemote, "Failed to find directory", err)
+		return
+	}
+	if !node.IsDir() {
+		http.Error(w, "Not a directory", http.StatusNotFound)
+		return
+	}
+	dir := node.(*vfs.Dir)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expecting multipart/form-data", http.StatusBadRequest)
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			http.Error(w, "Failed to read form", http.StatusBadRequest)
+			return
+		}
+		switch part.FormName() {
+		case "file":
+			// An empty file input is sent with no file name
+			if part.FileName() == "" {
+				continue
+			}
+			err = s.upload(w, r, dir, part.FileName(), part)
+		case "mkdir":
+			err = s.mkdir(w, r, dir, part)
+		case "delete":
+			err = s.delete(w, r, dir, part)
+		}
+		if err != nil {
+			return
+		}
+	}
+	// This is relative to the URL the browser used so works with --baseurl
+	w.Header().Set("Location", "./")
+	w.WriteHeader(http.StatusSeeOther)
+}
+
+// readValue reads a form value from part, writing a response and
+// returning an error if it isn't a valid name
+func readValue(w http.ResponseWriter, part io.Reader) (name string, err error) {
+	value, err := io.ReadAll(io.LimitReader(part, maxFormValue+1))
+	if err == nil && len(value) > maxFormValue {
+		err = errors.New("form value too long")
+	}
+	if err == nil {
+		name = strings.TrimSuffix(string(value), "/")
+		err = checkLeaf(name)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad form value: %v", err), http.StatusBadRequest)
+		return "", err
+	}
+	return name, nil
+}
+
+// upload writes in to the file leaf in dir
+func (s *HTTP) upload(w http.ResponseWriter, r *http.Request, dir *vfs.Dir, leaf string, in io.Reader) error {
+	if err := checkLeaf(leaf); err != nil {
+		http.Error(w, fmt.Sprintf("Bad file name: %v", err), http.StatusBadRequest)
+		return err
+	}
+	remote := path.Join(dir.Path(), leaf)
+	fs.Infof(remote, "%s: Uploading file", r.RemoteAddr)
+	handle, err := dir.VFS().OpenFile(remote, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		writeError(w, remote, "Failed to create file", err)
+		return err
+	}
+	_, err = io.Copy(handle, in)
+	closeErr := handle.Close()
+	if err != nil {
+		// Don't leave a partial upload behind
+		if node, statErr := dir.Stat(leaf); statErr == nil {
+			_ = node.Remove()
+		}
+		writeError(w, remote, "Failed to upload file", err)
+		return err
+	}
+	if closeErr != nil {
+		writeError(w, remote, "Failed to upload file", closeErr)
+		return closeErr
+	}
+	return nil
+}
+
+// mkdir makes the directory named in part in dir
+func (s *HTTP) mkdir(w http.ResponseWriter, r *http.Request, dir *vfs.Dir, part io.Reader) error {
+	leaf, err := readValue(w, part)
+	if err != nil {
+		return err
+	}
+	remote := path.Join(dir.Path(), leaf)
+	fs.Infof(remote, "%s: Making directory", r.RemoteAddr)
+	_, err = dir.Mkdir(leaf)
+	if err != nil {
+		writeError(w, remote, "Failed to make directory", err)
+		return err
+	}
+	return nil
+}
+
+// delete removes the file or empty directory named in part from dir
+func (s *HTTP) delete(w http.ResponseWriter, r *http.Request, dir *vfs.Dir, part io.Reader) error {
+	leaf, err := readValue(w, part)
+	if err != nil {
+		return err
+	}
+	remote := path.Join(dir.Path(), leaf)
+	fs.Infof(remote, "%s: Deleting", r.RemoteAddr)
+	node, err := dir.Stat(leaf)
+	if err == nil {
+		err = node.Remove()
+	}
+	if err != nil {
+		writeError(w, remote, "Failed to delete", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/vfs"
+)
+
+// serveZip streams dir and everything in it to the client as a zip
+// file
+func (s *HTTP) serveZip(w http.ResponseWriter, r *http.Request, dir *vfs.Dir) {
+	name := path.Base(dir.Path())
+	if dir.Path() == "" {
+		name = "download"
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".zip"}))
+	w.Header().Set("Last-Modified", dir.ModTime().UTC().Format(http.TimeFormat))
+	if r.Method == "HEAD" {
+		return
+	}
+	fs.Infof(dir.Path(), "%s: Serving directory as zip", r.RemoteAddr)
+	zw := zip.NewWriter(w)
+	err := addDirToZip(r.Context(), zw, dir, "")
+	if err != nil {
+		// The status has been sent so all we can do is log the
+		// error and not finish the zip so the client sees it
+		// is broken
+		fs.Errorf(dir.Path(), "Didn't finish writing zip: %v", err)
+		return
+	}
+	err = zw.Close()
+	if err != nil {
+		fs.Errorf(dir.Path(), "Failed to finish writing zip: %v", err)
+	}
+}
+
+// addDirToZip adds the contents of dir to zw with names starting
+// with prefix
+func addDirToZip(ctx context.Context, zw *zip.Writer, dir *vfs.Dir, prefix string) error {
+	nodes, err := dir.ReadDirAll()
+	if err != nil {
+		return fmt.Errorf("failed to list %q: %w", dir.Path(), err)
+	}
+	for _, node := range nodes {
+		name := zipName(path.Join(prefix, node.Name()))
+		if name == "" || (prefix != "" && !strings.HasPrefix(name, prefix+"/")) {
+			fs.Logf(node.Path(), "Not adding to zip as name is unsafe")
+			continue
+		}
+		if node.IsDir() {
+			_, err = zw.CreateHeader(&zip.FileHeader{
+				Name:     name + "/",
+				Modified: node.ModTime(),
+			})
+			if err == nil {
+				err = addDirToZip(ctx, zw, node.(*vfs.Dir), name)
+			}
+		} else {
+			err = addFileToZip(ctx, zw, node.(*vfs.File), name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zipName returns name cleaned so that it can't be extracted outside
+// the directory the zip is extracted into.
+//
+// Backslashes are made into slashes as some extractors treat them as
+// separators.
+func zipName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	return strings.TrimLeft(path.Clean("/"+name), "/")
+}
+
+// addFileToZip adds file to zw as name
+func addFileToZip(ctx context.Context, zw *zip.Writer, file *vfs.File, name string) (err error) {
+	obj, ok := file.DirEntry().(fs.Object)
+	if !ok {
+		fs.Logf(file.Path(), "Not adding file being written to zip")
+		return nil
+	}
+	in, err := file.Open(os.O_RDONLY)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", file.Path(), err)
+	}
+	defer fs.CheckClose(in, &err)
+
+	// Account the transfer
+	tr := accounting.Stats(ctx).NewTransfer(obj, nil)
+	defer func() {
+		tr.Done(ctx, err)
+	}()
+
+	out, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: file.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return fmt.Errorf("failed to add %q: %w", file.Path(), err)
+	}
+	return nil
+}
@@ -14,12 +14,14 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	billy "github.com/go-git/go-billy/v5"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/lib/encoder"
 	"github.com/rclone/rclone/lib/file"
+	"github.com/rclone/rclone/vfs"
 	"github.com/willscott/go-nfs"
 	nfshelper "github.com/willscott/go-nfs/helpers"
 )
@@ -57,11 +59,24 @@ func (h *Handler) getCache() (c Cache, err error) {
 	return nil, errors.New("unknown handle cache type")
 }
 
+var (
+	// handleGCInterval is how often the disk cache removes old handles
+	handleGCInterval = time.Hour
+
+	// handleTouchInterval is how often the modification time of a
+	// handle in use is updated to show it is still in use
+	handleTouchInterval = time.Hour
+)
+
 // diskHandler implements an on disk NFS file handle cache
 type diskHandler struct {
 	mu       sync.RWMutex
 	cacheDir string
 	billyFS  billy.Filesystem
+	maxAge   time.Duration                                 // remove handles of deleted files unused for this long
+	list     func(dir string) (map[string]struct{}, error) // names in dir or vfs.ENOENT if it doesn't exist
+	stop     chan struct{}                                 // close to stop the garbage collector
+	stopOnce sync.Once
 }
 
 // Create a new disk handler
@@ -83,8 +98,32 @@ func newDiskHandler(h *Handler) (dh *diskHandler, err error) {
 	dh = &diskHandler{
 		cacheDir: cacheDir,
 		billyFS:  h.billyFS,
+		maxAge:   time.Duration(h.opt.HandleMaxAge),
+		list: func(dir string) (names map[string]struct{}, err error) {
+			node, err := h.vfs.Stat(dir)
+			if err != nil {
+				return nil, err
+			}
+			d, ok := node.(*vfs.Dir)
+			if !ok {
+				return nil, vfs.ENOENT
+			}
+			nodes, err := d.ReadDirAll()
+			if err != nil {
+				return nil, err
+			}
+			names = make(map[string]struct{}, len(nodes))
+			for _, node := range nodes {
+				names[node.Name()] = struct{}{}
+			}
+			return names, nil
+		},
+		stop: make(chan struct{}),
 	}
 	fs.Infof("nfs", "Storing handle cache in %q", dh.cacheDir)
+	if dh.maxAge > 0 {
+		go dh.gcLoop()
+	}
 	return dh, nil
 }
 
@@ -120,7 +159,18 @@ func (dh *diskHandler) ToHandle(f billy.Filesystem, splitPath []string) (fh []by
 		fs.Errorf("nfs", "Couldn't create cache file handle directory: %v", err)
 		return fh
 	}
-	err = os.WriteFile(cachePath, []byte(fullPath), 0600)
+	// Don't rewrite the handle if it is there already
+	if oldPath, err := os.ReadFile(cachePath); err == nil && string(oldPath) == fullPath {
+		dh.touch(cachePath)
+		return fh
+	}
+	// Write to a temporary file then rename so a crash can't
+	// leave a partially written handle
+	tmpPath := cachePath + ".tmp"
+	err = os.WriteFile(tmpPath, []byte(fullPath), 0600)
+	if err == nil {
+		err = os.Rename(tmpPath, cachePath)
+	}
 	if err != nil {
 		fs.Errorf("nfs", "Couldn't create cache file handle: %v", err)
 		return fh
@@ -128,6 +178,20 @@ func (dh *diskHandler) ToHandle(f billy.Filesystem, splitPath []string) (fh []by
 	return fh
 }
 
+// touch updates the modification time of the handle at cachePath
+// to show it is in use if it hasn't been done recently
+func (dh *diskHandler) touch(cachePath string) {
+	fi, err := os.Stat(cachePath)
+	if err != nil || time.Since(fi.ModTime()) < handleTouchInterval {
+		return
+	}
+	now := time.Now()
+	err = os.Chtimes(cachePath, now, now)
+	if err != nil {
+		fs.Debugf("nfs", "Couldn't update time of cache file handle: %v", err)
+	}
+}
+
 var errStaleHandle = &nfs.NFSStatusError{NFSStatus: nfs.NFSStatusStale}
 
 // FromHandle converts from an opaque handle to the file it represents
@@ -140,6 +204,7 @@ func (dh *diskHandler) FromHandle(fh []byte) (f billy.Filesystem, splitPath []st
 		fs.Errorf("nfs", "Stale handle %q: %v", cachePath, err)
 		return nil, nil, errStaleHandle
 	}
+	dh.touch(cachePath)
 	splitPath = strings.Split(string(fullPathBytes), "/")
 	return dh.billyFS, splitPath, nil
 }
@@ -160,3 +225,116 @@ func (dh *diskHandler) InvalidateHandle(f billy.Filesystem, fh []byte) error {
 func (dh *diskHandler) HandleLimit() int {
 	return math.MaxInt
 }
+
+// gcLoop removes old handles from the cache periodically until
+// shutdown is called
+func (dh *diskHandler) gcLoop() {
+	ticker := time.NewTicker(handleGCInterval)
+	defer ticker.Stop()
+	for {
+		dh.gc()
+		select {
+		case <-ticker.C:
+		case <-dh.stop:
+			return
+		}
+	}
+}
+
+// gcHandle is a handle which the garbage collector may remove
+type gcHandle struct {
+	cachePath string // path of the handle in the cache
+	leaf      string // name of the file the handle refers to
+}
+
+// gc removes handles which haven't been used for maxAge if the file
+// they refer to doesn't exist any more.
+//
+// Handles of files which still exist are kept so clients holding them
+// don't get stale handle errors. The old handles are grouped by
+// directory so each directory is only listed once rather than
+// looking up every file.
+func (dh *diskHandler) gc() {
+	old := map[string][]gcHandle{}
+	err := filepath.WalkDir(dh.cacheDir, func(cachePath string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil || time.Since(fi.ModTime()) < dh.maxAge {
+			return nil
+		}
+		// Remove temporary files left by a crash
+		if strings.HasSuffix(cachePath, ".tmp") {
+			_ = os.Remove(cachePath)
+			return nil
+		}
+		fullPathBytes, err := os.ReadFile(cachePath)
+		if err != nil {
+			return nil
+		}
+		fullPath := string(fullPathBytes)
+		if fullPath == "" {
+			// The root always exists
+			dh.refresh(cachePath)
+			return nil
+		}
+		dir, leaf := path.Split(fullPath)
+		dir = strings.TrimSuffix(dir, "/")
+		old[dir] = append(old[dir], gcHandle{cachePath: cachePath, leaf: leaf})
+		return nil
+	})
+	if err != nil {
+		fs.Errorf("nfs", "Failed to garbage collect handle cache: %v", err)
+		return
+	}
+	var removed, kept int
+	for dir, handles := range old {
+		names, err := dh.list(dir)
+		if err != nil && !errors.Is(err, vfs.ENOENT) {
+			fs.Debugf("nfs", "Keeping %d old handles in %q as couldn't list it: %v", len(handles), dir, err)
+			continue
+		}
+		for _, handle := range handles {
+			if _, found := names[handle.leaf]; found {
+				dh.refresh(handle.cachePath)
+				kept++
+			} else if dh.removeOld(handle.cachePath) {
+				removed++
+			}
+		}
+	}
+	if removed > 0 {
+		fs.Infof("nfs", "Removed %d handles of deleted files from the handle cache, checked %d still in use", removed, kept)
+	}
+}
+
+// refresh sets the modification time of the handle at cachePath to
+// now to show the file it refers to still exists
+func (dh *diskHandler) refresh(cachePath string) {
+	now := time.Now()
+	_ = os.Chtimes(cachePath, now, now)
+}
+
+// removeOld removes the handle at cachePath if it hasn't been used
+// for maxAge returning true if it was removed
+func (dh *diskHandler) removeOld(cachePath string) bool {
+	dh.mu.Lock()
+	defer dh.mu.Unlock()
+	// Check the handle wasn't used while we were looking
+	if fi, err := os.Stat(cachePath); err != nil || time.Since(fi.ModTime()) < dh.maxAge {
+		return false
+	}
+	if err := os.Remove(cachePath); err != nil {
+		fs.Errorf("nfs", "Failed to remove old handle %q: %v", cachePath, err)
+		return false
+	}
+	return true
+}
+
+// shutdown stops the garbage collector
+func (dh *diskHandler) shutdown() {
+	dh.stopOnce.Do(func() {
+		close(dh.stop)
+	})
+}
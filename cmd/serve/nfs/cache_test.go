@@ -4,11 +4,16 @@ package nfs
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -132,3 +137,70 @@ func TestCache(t *testing.T) {
 		})
 	}
 }
+
+func TestDiskHandlerGC(t *testing.T) {
+	h := &Handler{
+		billyFS: &FS{nil},
+	}
+	h.opt.HandleCache = cacheDisk
+	h.opt.HandleCacheDir = t.TempDir()
+	c, err := h.getCache()
+	require.NoError(t, err)
+	dh := c.(*diskHandler)
+	dh.maxAge = time.Hour
+	var listed []string
+	dh.list = func(dir string) (map[string]struct{}, error) {
+		listed = append(listed, dir)
+		switch dir {
+		case "dir":
+			return map[string]struct{}{"kept": {}}, nil
+		case "error":
+			return nil, errors.New("list failed")
+		}
+		return nil, vfs.ENOENT
+	}
+
+	fhKept := dh.ToHandle(h.billyFS, []string{"dir", "kept"})
+	fhGone := dh.ToHandle(h.billyFS, []string{"dir", "gone"})
+	fhRecent := dh.ToHandle(h.billyFS, []string{"dir", "recent"})
+	fhDirGone := dh.ToHandle(h.billyFS, []string{"gone", "file"})
+	fhError := dh.ToHandle(h.billyFS, []string{"error", "file"})
+
+	// Make all but the recent handle look unused
+	old := time.Now().Add(-2 * time.Hour)
+	for _, fh := range [][]byte{fhKept, fhGone, fhDirGone, fhError} {
+		require.NoError(t, os.Chtimes(dh.handleToPath(fh), old, old))
+	}
+
+	dh.gc()
+
+	// Each directory is only listed once
+	sort.Strings(listed)
+	assert.Equal(t, []string{"dir", "error", "gone"}, listed)
+
+	// Handles of files which exist are kept and refreshed
+	_, splitPath, err := dh.FromHandle(fhKept)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir", "kept"}, splitPath)
+	fi, err := os.Stat(dh.handleToPath(fhKept))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), fi.ModTime(), time.Minute)
+
+	// Old handles of files which have gone are removed
+	_, _, err = dh.FromHandle(fhGone)
+	assert.Equal(t, errStaleHandle, err)
+
+	// Recently used handles are kept whether the file exists or not
+	_, splitPath, err = dh.FromHandle(fhRecent)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir", "recent"}, splitPath)
+
+	// Handles in directories which have gone are removed
+	_, _, err = dh.FromHandle(fhDirGone)
+	assert.Equal(t, errStaleHandle, err)
+
+	// Handles in directories which can't be listed are kept
+	_, splitPath, err = dh.FromHandle(fhError)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"error", "file"}, splitPath)
+}
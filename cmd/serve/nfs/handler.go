@@ -53,6 +53,13 @@ func NewHandler(ctx context.Context, vfs *vfs.VFS, opt *Options) (handler nfs.Ha
 	return h, nil
 }
 
+// Shutdown stops any background tasks of the handler
+func (h *Handler) Shutdown() {
+	if dh, ok := h.Cache.(*diskHandler); ok {
+		dh.shutdown()
+	}
+}
+
 // Mount backs Mount RPC Requests, allowing for access control policies.
 func (h *Handler) Mount(ctx context.Context, conn net.Conn, req nfs.MountRequest) (status nfs.MountStatus, hndl billy.Filesystem, auths []nfs.AuthFlavor) {
 	auths = []nfs.AuthFlavor{nfs.AuthFlavorNull}
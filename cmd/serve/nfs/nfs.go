@@ -12,6 +12,7 @@ package nfs
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/rclone/rclone/cmd"
 	"github.com/rclone/rclone/fs"
@@ -40,6 +41,10 @@ var OptionsInfo = fs.Options{{
 	Name:    "nfs_cache_dir",
 	Default: "",
 	Help:    "The directory the NFS handle cache will use if set",
+}, {
+	Name:    "nfs_cache_handle_max_age",
+	Default: fs.Duration(24 * time.Hour),
+	Help:    "Remove unused handles of deleted files from the disk cache after this long (0 to keep forever)",
 }}
 
 func init() {
@@ -66,10 +71,11 @@ func (handleCacheChoices) Choices() []string {
 
 // Options contains options for the NFS Server
 type Options struct {
-	ListenAddr     string      `config:"addr"`                   // Port to listen on
-	HandleLimit    int         `config:"nfs_cache_handle_limit"` // max file handles cached by go-nfs CachingHandler
-	HandleCache    handleCache `config:"nfs_cache_type"`         // what kind of handle cache to use
-	HandleCacheDir string      `config:"nfs_cache_dir"`          // where the handle cache should be stored
+	ListenAddr     string      `config:"addr"`                     // Port to listen on
+	HandleLimit    int         `config:"nfs_cache_handle_limit"`   // max file handles cached by go-nfs CachingHandler
+	HandleCache    handleCache `config:"nfs_cache_type"`           // what kind of handle cache to use
+	HandleCacheDir string      `config:"nfs_cache_dir"`            // where the handle cache should be stored
+	HandleMaxAge   fs.Duration `config:"nfs_cache_handle_max_age"` // how long to keep unused handles of deleted files
 }
 
 // Opt is the default set of serve nfs options
@@ -106,6 +112,7 @@ var Command = &cobra.Command{
 	Long: strings.ReplaceAll(`Create an NFS server that serves the given remote over the network.
 	
 This implements an NFSv3 server to serve any rclone remote via NFS.
+NFSv4 isn't supported so clients must be configured to use NFSv3.
 
 The primary purpose for this command is to enable the [mount
 command](/commands/rclone_mount/) on recent macOS versions where
@@ -140,6 +147,14 @@ hash. These hashes are stored on disk the directory controlled by
 |--nfs-cache-dir|. Using this means that the NFS server can be
 restarted at will without affecting the connected clients.
 
+Handles are kept while the files they refer to exist. Once a handle
+hasn't been used for |--nfs-cache-handle-max-age| (default 24h) rclone
+checks whether its file still exists and removes the handle if not, so
+the cache doesn't grow without limit when files are deleted on the
+remote directly. The old handles are checked by listing each directory
+they are in once (using the VFS directory cache) rather than looking up
+every file. Set it to |0| to never remove handles.
+
 |--nfs-cache-type symlink| is similar to |--nfs-cache-type disk| in
 that it uses an on disk cache, but the cache entries are held as
 symlinks. Rclone will use the handle of the underlying file as the NFS
@@ -55,6 +55,9 @@ func (s *Server) Addr() net.Addr {
 
 // Shutdown stops the server
 func (s *Server) Shutdown() error {
+	if h, ok := s.handler.(*Handler); ok {
+		h.Shutdown()
+	}
 	return s.listener.Close()
 }
 
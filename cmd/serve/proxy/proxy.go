@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
@@ -48,8 +49,19 @@ config.
 This config generated must have this extra parameter
 - |_root| - root to use for the backend
 
-And it may have this parameter
+And it may have these parameters
 - |_obscure| - comma separated strings for parameters to obscure
+- |_read_only| - set to |true| to only allow the user to read
+
+If |_read_only| is |true| then the user's VFS is read only, so any
+attempt to write, delete or rename files is refused as if
+|--read-only| had been given for that user only. It defaults to
+|false|. rclone refuses to log the user in if it isn't a valid
+boolean.
+
+Some serve commands accept further parameters starting with |_| which
+are described in their documentation. Other parameters starting with
+|_| are ignored.
 
 If password authentication was used by the client, input to the proxy
 process (on STDIN) would look similar to this:
@@ -128,6 +140,7 @@ type Proxy struct {
 type cacheEntry struct {
 	vfs    *vfs.VFS          // stored VFS
 	pwHash [sha256.Size]byte // sha256 hash of the password/publicKey
+	params configmap.Simple  // parameters starting with _ returned by the proxy
 }
 
 // New creates a new proxy with the Options passed in
@@ -211,6 +224,14 @@ func (p *Proxy) call(user, auth string, isPublicKey bool) (value interface{}, er
 		return nil, errors.New("proxy: _root not set in result")
 	}
 
+	vfsOpt := vfscommon.Opt
+	if readOnly, ok := config.Get("_read_only"); ok {
+		vfsOpt.ReadOnly, err = strconv.ParseBool(readOnly)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: invalid _read_only in result: %w", err)
+		}
+	}
+
 	// Find the backend
 	fsInfo, err := fs.Find(fsName)
 	if err != nil {
@@ -242,8 +263,14 @@ func (p *Proxy) call(user, auth string, isPublicKey bool) (value interface{}, er
 		// need to in memory. An attacker would find it easier to go
 		// after the unencrypted password in memory most likely.
 		entry := cacheEntry{
-			vfs:    vfs.New(f, &vfscommon.Opt),
+			vfs:    vfs.New(f, &vfsOpt),
 			pwHash: sha256.Sum256([]byte(auth)),
+			params: configmap.Simple{},
+		}
+		for k, v := range config {
+			if strings.HasPrefix(k, "_") {
+				entry.params[k] = v
+			}
 		}
 		return entry, true, nil
 	})
@@ -256,6 +283,15 @@ func (p *Proxy) call(user, auth string, isPublicKey bool) (value interface{}, er
 // Call runs the auth proxy with the username and password/public key provided
 // returning a *vfs.VFS and the key used in the VFS cache.
 func (p *Proxy) Call(user, auth string, isPublicKey bool) (VFS *vfs.VFS, vfsKey string, err error) {
+	VFS, vfsKey, _, err = p.CallParams(user, auth, isPublicKey)
+	return VFS, vfsKey, err
+}
+
+// CallParams is like Call but also returns the parameters starting
+// with "_" which the proxy returned so the caller can use its own.
+//
+// The params returned must not be modified.
+func (p *Proxy) CallParams(user, auth string, isPublicKey bool) (VFS *vfs.VFS, vfsKey string, params configmap.Simple, err error) {
 	// Look in the cache first
 	value, ok := p.vfsCache.GetMaybe(user)
 
@@ -263,14 +299,14 @@ func (p *Proxy) Call(user, auth string, isPublicKey bool) (VFS *vfs.VFS, vfsKey
 	if !ok {
 		value, err = p.call(user, auth, isPublicKey)
 		if err != nil {
-			return nil, "", err
+			return nil, "", nil, err
 		}
 	}
 
 	// check we got what we were expecting
 	entry, ok := value.(cacheEntry)
 	if !ok {
-		return nil, "", fmt.Errorf("proxy: value is not cache entry: %#v", value)
+		return nil, "", nil, fmt.Errorf("proxy: value is not cache entry: %#v", value)
 	}
 
 	// Check the password / public key is correct in the cached entry.  This
@@ -281,12 +317,12 @@ func (p *Proxy) Call(user, auth string, isPublicKey bool) (VFS *vfs.VFS, vfsKey
 	authHash := sha256.Sum256([]byte(auth))
 	if subtle.ConstantTimeCompare(authHash[:], entry.pwHash[:]) != 1 {
 		if isPublicKey {
-			return nil, "", errors.New("proxy: incorrect public key")
+			return nil, "", nil, errors.New("proxy: incorrect public key")
 		}
-		return nil, "", errors.New("proxy: incorrect password")
+		return nil, "", nil, errors.New("proxy: incorrect password")
 	}
 
-	return entry.vfs, user, nil
+	return entry.vfs, user, entry.params, nil
 }
 
 // Get VFS from the cache using key - returns nil if not found
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"strings"
 )
 
 func main() {
@@ -25,6 +26,11 @@ func main() {
 			v += "-test"
 		case "error":
 			log.Fatal(v)
+		case "pass":
+			// Let the tests set _read_only with the password
+			if readOnly, ok := strings.CutPrefix(v, "read_only="); ok {
+				out["_read_only"] = readOnly
+			}
 		}
 		out[k] = v
 	}
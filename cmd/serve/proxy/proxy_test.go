@@ -147,6 +147,36 @@ func TestRun(t *testing.T) {
 
 	})
 
+	t.Run("Call w/ReadOnly", func(t *testing.T) {
+		// check cache empty
+		assert.Equal(t, 0, p.vfsCache.Entries())
+		defer p.vfsCache.Clear()
+
+		vfs, _, params, err := p.CallParams(testUser, "read_only=true", false)
+		require.NoError(t, err)
+		require.NotNil(t, vfs)
+		assert.True(t, vfs.Opt.ReadOnly)
+		assert.Equal(t, "true", params["_read_only"])
+		assert.NotContains(t, params, "pass")
+		p.vfsCache.Clear()
+
+		vfs, _, err = p.Call(testUser, "read_only=false", false)
+		require.NoError(t, err)
+		assert.False(t, vfs.Opt.ReadOnly)
+		p.vfsCache.Clear()
+
+		vfs, _, err = p.Call(testUser, testPass, false)
+		require.NoError(t, err)
+		assert.False(t, vfs.Opt.ReadOnly)
+		p.vfsCache.Clear()
+
+		vfs, _, err = p.Call(testUser, "read_only=potato", false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid _read_only")
+		assert.Nil(t, vfs)
+		assert.Equal(t, 0, p.vfsCache.Entries())
+	})
+
 	privateKey, privateKeyErr := rsa.GenerateKey(rand.Reader, 2048)
 	if privateKeyErr != nil {
 		fs.Fatal(nil, "error generating test private key "+privateKeyErr.Error())
@@ -26,15 +26,24 @@ var (
 type s3Backend struct {
 	opt  *Options
 	s    *Server
-	meta *sync.Map
+	meta *sync.Map // metaKey to map[string]string
+	tags *sync.Map // metaKey to url.Values for remotes without metadata
+}
+
+// metaKey identifies the object metadata is stored for - objects
+// with the same path in different VFSes are different objects
+type metaKey struct {
+	vfs *vfs.VFS
+	fp  string
 }
 
 // newBackend creates a new SimpleBucketBackend.
-func newBackend(s *Server, opt *Options) gofakes3.Backend {
+func newBackend(s *Server, opt *Options) *s3Backend {
 	return &s3Backend{
 		opt:  opt,
 		s:    s,
 		meta: new(sync.Map),
+		tags: new(sync.Map),
 	}
 }
 
@@ -50,7 +59,7 @@ func (b *s3Backend) ListBuckets(ctx context.Context) ([]gofakes3.BucketInfo, err
 	}
 	var response []gofakes3.BucketInfo
 	for _, entry := range dirEntries {
-		if entry.IsDir() {
+		if entry.IsDir() && b.s.bucketAllowed(ctx, entry.Name()) {
 			response = append(response, gofakes3.BucketInfo{
 				Name:         gofakes3.URLEncode(entry.Name()),
 				CreationDate: gofakes3.NewContentTime(entry.ModTime()),
@@ -68,6 +77,9 @@ func (b *s3Backend) ListBucket(ctx context.Context, bucket string, prefix *gofak
 	if err != nil {
 		return nil, err
 	}
+	if !b.s.bucketAllowed(ctx, bucket) {
+		return nil, gofakes3.BucketNotFound(bucket)
+	}
 	_, err = _vfs.Stat(bucket)
 	if err != nil {
 		return nil, gofakes3.BucketNotFound(bucket)
@@ -106,6 +118,9 @@ func (b *s3Backend) HeadObject(ctx context.Context, bucketName, objectName strin
 	if err != nil {
 		return nil, err
 	}
+	if !b.s.bucketAllowed(ctx, bucketName) {
+		return nil, gofakes3.BucketNotFound(bucketName)
+	}
 	_, err = _vfs.Stat(bucketName)
 	if err != nil {
 		return nil, gofakes3.BucketNotFound(bucketName)
@@ -135,7 +150,7 @@ func (b *s3Backend) HeadObject(ctx context.Context, bucketName, objectName strin
 		"Content-Type":  fs.MimeType(context.Background(), fobj),
 	}
 
-	if val, ok := b.meta.Load(fp); ok {
+	if val, ok := b.meta.Load(metaKey{_vfs, fp}); ok {
 		metaMap := val.(map[string]string)
 		for k, v := range metaMap {
 			meta[k] = v
@@ -157,6 +172,9 @@ func (b *s3Backend) GetObject(ctx context.Context, bucketName, objectName string
 	if err != nil {
 		return nil, err
 	}
+	if !b.s.bucketAllowed(ctx, bucketName) {
+		return nil, gofakes3.BucketNotFound(bucketName)
+	}
 	_, err = _vfs.Stat(bucketName)
 	if err != nil {
 		return nil, gofakes3.BucketNotFound(bucketName)
@@ -212,7 +230,7 @@ func (b *s3Backend) GetObject(ctx context.Context, bucketName, objectName string
 		"Content-Type":  fs.MimeType(context.Background(), fobj),
 	}
 
-	if val, ok := b.meta.Load(fp); ok {
+	if val, ok := b.meta.Load(metaKey{_vfs, fp}); ok {
 		metaMap := val.(map[string]string)
 		for k, v := range metaMap {
 			meta[k] = v
@@ -231,10 +249,10 @@ func (b *s3Backend) GetObject(ctx context.Context, bucketName, objectName string
 
 // storeModtime sets both "mtime" and "X-Amz-Meta-Mtime" to val in b.meta.
 // Call this whenever modtime is updated.
-func (b *s3Backend) storeModtime(fp string, meta map[string]string, val string) {
+func (b *s3Backend) storeModtime(_vfs *vfs.VFS, fp string, meta map[string]string, val string) {
 	meta["X-Amz-Meta-Mtime"] = val
 	meta["mtime"] = val
-	b.meta.Store(fp, meta)
+	b.meta.Store(metaKey{_vfs, fp}, meta)
 }
 
 // TouchObject creates or updates meta on specified object.
@@ -260,12 +278,12 @@ func (b *s3Backend) TouchObject(ctx context.Context, fp string, meta map[string]
 		return result, err
 	}
 
-	b.meta.Store(fp, meta)
+	b.meta.Store(metaKey{_vfs, fp}, meta)
 
 	if val, ok := meta["X-Amz-Meta-Mtime"]; ok {
 		ti, err := swift.FloatStringToTime(val)
 		if err == nil {
-			b.storeModtime(fp, meta, val)
+			b.storeModtime(_vfs, fp, meta, val)
 			return result, _vfs.Chtimes(fp, ti, ti)
 		}
 		// ignore error since the file is successfully created
@@ -274,7 +292,7 @@ func (b *s3Backend) TouchObject(ctx context.Context, fp string, meta map[string]
 	if val, ok := meta["mtime"]; ok {
 		ti, err := swift.FloatStringToTime(val)
 		if err == nil {
-			b.storeModtime(fp, meta, val)
+			b.storeModtime(_vfs, fp, meta, val)
 			return result, _vfs.Chtimes(fp, ti, ti)
 		}
 		// ignore error since the file is successfully created
@@ -294,10 +312,17 @@ func (b *s3Backend) PutObject(
 	if err != nil {
 		return result, err
 	}
+	if !b.s.bucketAllowed(ctx, bucketName) {
+		return result, gofakes3.BucketNotFound(bucketName)
+	}
 	_, err = _vfs.Stat(bucketName)
 	if err != nil {
 		return result, gofakes3.BucketNotFound(bucketName)
 	}
+	tags, err := parseTaggingHeader(meta)
+	if err != nil {
+		return result, err
+	}
 
 	fp := path.Join(bucketName, objectName)
 	objectDir := path.Dir(fp)
@@ -336,18 +361,21 @@ func (b *s3Backend) PutObject(
 		return result, err
 	}
 
-	b.meta.Store(fp, meta)
+	b.meta.Store(metaKey{_vfs, fp}, meta)
+	if err := b.putObjectTags(ctx, _vfs, fp, tags); err != nil {
+		fs.Errorf(fp, "Failed to set tags: %v", err)
+	}
 
 	if val, ok := meta["X-Amz-Meta-Mtime"]; ok {
 		ti, err := swift.FloatStringToTime(val)
 		if err == nil {
-			b.storeModtime(fp, meta, val)
+			b.storeModtime(_vfs, fp, meta, val)
 			return result, _vfs.Chtimes(fp, ti, ti)
 		}
 		// ignore error since the file is successfully created
 
 		if val, ok := meta["mtime"]; ok {
-			b.storeModtime(fp, meta, val)
+			b.storeModtime(_vfs, fp, meta, val)
 			return result, _vfs.Chtimes(fp, ti, ti)
 		}
 		// ignore error since the file is successfully created
@@ -387,6 +415,9 @@ func (b *s3Backend) deleteObject(ctx context.Context, bucketName, objectName str
 	if err != nil {
 		return err
 	}
+	if !b.s.bucketAllowed(ctx, bucketName) {
+		return gofakes3.BucketNotFound(bucketName)
+	}
 	_, err = _vfs.Stat(bucketName)
 	if err != nil {
 		return gofakes3.BucketNotFound(bucketName)
@@ -399,6 +430,8 @@ func (b *s3Backend) deleteObject(ctx context.Context, bucketName, objectName str
 		return err
 	}
 
+	b.tags.Delete(metaKey{_vfs, fp})
+
 	// FIXME: unsafe operation
 	rmdirRecursive(fp, _vfs)
 	return nil
@@ -410,6 +443,9 @@ func (b *s3Backend) CreateBucket(ctx context.Context, name string) error {
 	if err != nil {
 		return err
 	}
+	if !b.s.bucketAllowed(ctx, name) {
+		return gofakes3.ErrorMessage(gofakes3.ErrInvalidBucketName, "bucket not allowed for this access key")
+	}
 	_, err = _vfs.Stat(name)
 	if err != nil && err != vfs.ENOENT {
 		return gofakes3.ErrInternal
@@ -431,6 +467,9 @@ func (b *s3Backend) DeleteBucket(ctx context.Context, name string) error {
 	if err != nil {
 		return err
 	}
+	if !b.s.bucketAllowed(ctx, name) {
+		return gofakes3.BucketNotFound(name)
+	}
 	_, err = _vfs.Stat(name)
 	if err != nil {
 		return gofakes3.BucketNotFound(name)
@@ -449,6 +488,9 @@ func (b *s3Backend) BucketExists(ctx context.Context, name string) (exists bool,
 	if err != nil {
 		return false, err
 	}
+	if !b.s.bucketAllowed(ctx, name) {
+		return false, nil
+	}
 	_, err = _vfs.Stat(name)
 	if err != nil {
 		return false, nil
@@ -463,9 +505,15 @@ func (b *s3Backend) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket
 	if err != nil {
 		return result, err
 	}
+	if !b.s.bucketAllowed(ctx, srcBucket) {
+		return result, gofakes3.BucketNotFound(srcBucket)
+	}
+	if !b.s.bucketAllowed(ctx, dstBucket) {
+		return result, gofakes3.BucketNotFound(dstBucket)
+	}
 	fp := path.Join(srcBucket, srcKey)
 	if srcBucket == dstBucket && srcKey == dstKey {
-		b.meta.Store(fp, meta)
+		b.meta.Store(metaKey{_vfs, fp}, meta)
 
 		val, ok := meta["X-Amz-Meta-Mtime"]
 		if !ok {
@@ -478,7 +526,7 @@ func (b *s3Backend) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket
 		if err != nil {
 			return result, nil
 		}
-		b.storeModtime(fp, meta, val)
+		b.storeModtime(_vfs, fp, meta, val)
 
 		return result, _vfs.Chtimes(fp, ti, ti)
 	}
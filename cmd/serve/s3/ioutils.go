@@ -1,6 +1,11 @@
 package s3
 
-import "io"
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+)
 
 type noOpReadCloser struct{}
 
@@ -32,3 +37,41 @@ func (rwc *readerWithCloser) Close() error {
 	}
 	return nil
 }
+
+// responseRecorder buffers a response so it can be inspected before
+// it is sent
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{
+		header: make(http.Header),
+		status: http.StatusOK,
+	}
+}
+
+func (rr *responseRecorder) Header() http.Header {
+	return rr.header
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.status = status
+}
+
+func (rr *responseRecorder) Write(p []byte) (int, error) {
+	return rr.body.Write(p)
+}
+
+// send the recorded response to w with body in place of the one
+// recorded
+func (rr *responseRecorder) send(w http.ResponseWriter, body []byte) {
+	for k, v := range rr.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(rr.status)
+	_, _ = w.Write(body)
+}
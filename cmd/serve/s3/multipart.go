@@ -0,0 +1,153 @@
+package s3
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/rclone/gofakes3"
+	"github.com/rclone/gofakes3/xml"
+	"github.com/rclone/rclone/vfs"
+)
+
+// multipartUploads tracks which VFS each in progress multipart upload
+// was started in.
+//
+// gofakes3 keeps the uploads, but only knows their bucket, so this is
+// used to stop access keys which serve different remotes seeing each
+// other's uploads.
+type multipartUploads struct {
+	mu      sync.Mutex
+	uploads map[gofakes3.UploadID]*vfs.VFS
+}
+
+// add records that upload id was started in VFS
+func (m *multipartUploads) add(id gofakes3.UploadID, VFS *vfs.VFS) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.uploads == nil {
+		m.uploads = make(map[gofakes3.UploadID]*vfs.VFS)
+	}
+	m.uploads[id] = VFS
+}
+
+// remove forgets upload id
+func (m *multipartUploads) remove(id gofakes3.UploadID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.uploads, id)
+}
+
+// visible returns true if upload id can be seen from VFS
+func (m *multipartUploads) visible(id gofakes3.UploadID, VFS *vfs.VFS) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	owner, found := m.uploads[id]
+	return !found || owner == VFS
+}
+
+// serveMultipart passes the multipart upload request r to next,
+// keeping track of the uploads and hiding those from other VFSes.
+func (w *Server) serveMultipart(next http.Handler, rw http.ResponseWriter, r *http.Request, bucket string) {
+	VFS, err := w.getVFS(r.Context())
+	if err != nil {
+		// let gofakes3 report the error
+		next.ServeHTTP(rw, r)
+		return
+	}
+	query := r.URL.Query()
+	if id := gofakes3.UploadID(query.Get("uploadId")); id != "" {
+		if !w.uploads.visible(id, VFS) {
+			writeError(rw, r, gofakes3.ErrNoSuchUpload)
+			return
+		}
+		rr := newResponseRecorder()
+		next.ServeHTTP(rr, r)
+		finished := (r.Method == http.MethodDelete && rr.status == http.StatusNoContent) ||
+			(r.Method == http.MethodPost && rr.status == http.StatusOK)
+		if finished {
+			w.uploads.remove(id)
+		}
+		rr.send(rw, rr.body.Bytes())
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		w.initiateMultipart(next, rw, r, VFS)
+	case http.MethodGet:
+		w.listMultipart(next, rw, r, bucket, VFS)
+	default:
+		next.ServeHTTP(rw, r)
+	}
+}
+
+// initiateMultipart starts a multipart upload and records which VFS
+// it belongs to
+func (w *Server) initiateMultipart(next http.Handler, rw http.ResponseWriter, r *http.Request, VFS *vfs.VFS) {
+	rr := newResponseRecorder()
+	next.ServeHTTP(rr, r)
+	if rr.status == http.StatusOK {
+		var result gofakes3.InitiateMultipartUpload
+		if err := xml.Unmarshal(rr.body.Bytes(), &result); err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		w.uploads.add(result.UploadID, VFS)
+	}
+	rr.send(rw, rr.body.Bytes())
+}
+
+// listMultipart lists the multipart uploads in bucket which belong to
+// VFS
+func (w *Server) listMultipart(next http.Handler, rw http.ResponseWriter, r *http.Request, bucket string, VFS *vfs.VFS) {
+	// gofakes3 treats an empty delimiter as a real one which
+	// matches nothing, so remove empty parameters. This is OK as
+	// the request has been authorized already.
+	query := r.URL.Query()
+	for _, key := range []string{"delimiter", "prefix", "key-marker", "upload-id-marker"} {
+		if query.Has(key) && query.Get(key) == "" {
+			query.Del(key)
+		}
+	}
+	r.URL.RawQuery = query.Encode()
+
+	rr := newResponseRecorder()
+	next.ServeHTTP(rr, r)
+	var result gofakes3.ListMultipartUploadsResult
+	switch rr.status {
+	case http.StatusOK:
+		if err := xml.Unmarshal(rr.body.Bytes(), &result); err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		uploads := result.Uploads[:0]
+		for _, upload := range result.Uploads {
+			if w.uploads.visible(upload.UploadID, VFS) {
+				uploads = append(uploads, upload)
+			}
+		}
+		result.Uploads = uploads
+	case http.StatusNotFound:
+		// gofakes3 returns NoSuchUpload if the bucket has never
+		// had any uploads, but S3 returns an empty list
+		var resp gofakes3.ErrorResponse
+		if err := xml.Unmarshal(rr.body.Bytes(), &resp); err != nil || resp.Code != gofakes3.ErrNoSuchUpload {
+			rr.send(rw, rr.body.Bytes())
+			return
+		}
+		rr.status = http.StatusOK
+		result = gofakes3.ListMultipartUploadsResult{
+			Bucket:    bucket,
+			Prefix:    query.Get("prefix"),
+			Delimiter: query.Get("delimiter"),
+		}
+	default:
+		rr.send(rw, rr.body.Bytes())
+		return
+	}
+	body, err := xml.Marshal(result)
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	rr.send(rw, append([]byte(xml.Header), body...))
+}
@@ -0,0 +1,44 @@
+//go:build ignore
+
+// A simple auth proxy for testing the serve s3 proxy parameters
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalf("Syntax: %s <root>", os.Args[0])
+	}
+	root := os.Args[1]
+
+	// Read the input
+	var in map[string]string
+	err := json.NewDecoder(os.Stdin).Decode(&in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Write the output
+	var out = map[string]string{
+		"type":  "local",
+		"_root": root,
+	}
+
+	// serve s3 passes the access key as the password
+	accessKey := in["pass"]
+	if strings.HasPrefix(accessKey, "readonly") {
+		out["_read_only"] = "true"
+	}
+	if buckets, ok := strings.CutPrefix(accessKey, "buckets-"); ok {
+		out["_buckets"] = strings.ReplaceAll(buckets, "-", ",")
+	}
+	err = json.NewEncoder(os.Stdout).Encode(&out)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -8,14 +8,18 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
+	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/tags"
 	"github.com/rclone/rclone/fs/object"
 
 	_ "github.com/rclone/rclone/backend/local"
@@ -302,3 +306,388 @@ func TestListBucketsAuthProxy(t *testing.T) {
 
 	testListBuckets(t, cases, true)
 }
+
+func TestParseAuthKey(t *testing.T) {
+	for _, test := range []struct {
+		in        string
+		accessKey string
+		want      *s3User
+		wantErr   string
+	}{
+		{in: "key,SECRET", accessKey: "key", want: &s3User{}},
+		{in: "key,SECRET,remote=remote:path/to/dir", accessKey: "key", want: &s3User{remote: "remote:path/to/dir"}},
+		{in: "key,SECRET, read_only", accessKey: "key", want: &s3User{readOnly: true}},
+		{in: "key,SECRET,read_only=false", accessKey: "key", want: &s3User{}},
+		{in: "key,SECRET,bucket=a,bucket=b", accessKey: "key", want: &s3User{buckets: map[string]struct{}{"a": {}, "b": {}}}},
+		{in: "key,SECRET,", accessKey: "key", want: &s3User{}},
+		{in: `key,SECRET,"remote=:s3,provider=AWS,region=eu-west-1:bucket/dir",read_only`, accessKey: "key", want: &s3User{remote: ":s3,provider=AWS,region=eu-west-1:bucket/dir", readOnly: true}},
+		{in: `key,SECRET,"remote=:local,case_insensitive=""true"":/tmp"`, accessKey: "key", want: &s3User{remote: `:local,case_insensitive="true":/tmp`}},
+		{in: "key,SECRET,remote=:s3,provider=AWS:bucket", wantErr: `unknown option "provider" - options containing commas must be quoted`},
+		{in: `key,SECRET,"remote=:s3`, wantErr: "invalid options"},
+		{in: "key", wantErr: "expecting access_key_id,secret_access_key"},
+		{in: "key,SECRET,read_only=potato", wantErr: "invalid read_only"},
+		{in: "key,SECRET,remote=", wantErr: "remote must not be empty"},
+		{in: "key,SECRET,bucket=", wantErr: "bucket must not be empty"},
+		{in: "key,SECRET,potato", wantErr: `unknown option "potato"`},
+	} {
+		accessKey, u, err := parseAuthKey(test.in)
+		if test.wantErr != "" {
+			require.Error(t, err, test.in)
+			assert.Contains(t, err.Error(), test.wantErr, test.in)
+			assert.NotContains(t, err.Error(), "SECRET", test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.accessKey, accessKey, test.in)
+		assert.Equal(t, test.want, u, test.in)
+	}
+}
+
+// makeTestRemote makes a local remote with a file.txt in each of the
+// buckets
+func makeTestRemote(t *testing.T, buckets ...string) (dir string, f fs.Fs) {
+	ctx := context.Background()
+	dir = t.TempDir()
+	f, err := fs.NewFs(ctx, dir)
+	require.NoError(t, err)
+	for _, bucket := range buckets {
+		obji := object.NewStaticObjectInfo(path.Join(bucket, "file.txt"), time.Now(), 8, true, nil, nil)
+		_, err = f.Put(ctx, bytes.NewBufferString("contents"), obji)
+		require.NoError(t, err)
+	}
+	return dir, f
+}
+
+// startTestServer serves f with the auth keys given returning the
+// host:port of the server
+func startTestServer(t *testing.T, f fs.Fs, authPair ...string) string {
+	opt := &Options{
+		HTTP:           httplib.DefaultCfg(),
+		pathBucketMode: true,
+		hashType:       hash.None,
+		authPair:       authPair,
+	}
+	opt.HTTP.ListenAddr = []string{endpoint}
+	s, err := newServer(context.Background(), f, opt)
+	require.NoError(t, err)
+	s.Bind(s.server.Router())
+	require.NoError(t, s.Serve())
+	t.Cleanup(func() {
+		assert.NoError(t, s.server.Shutdown())
+	})
+	testURL, err := url.Parse(s.server.URLs()[0])
+	require.NoError(t, err)
+	return testURL.Host
+}
+
+// newTestClient makes a minio client for the server at host
+func newTestClient(t *testing.T, host, keyID, keySecret string) *minio.Client {
+	c, err := minio.New(host, &minio.Options{
+		Creds:  credentials.NewStaticV4(keyID, keySecret, ""),
+		Secure: false,
+	})
+	require.NoError(t, err)
+	return c
+}
+
+// TestAuthKeyOptions checks access keys can be given their own
+// remote, made read only and restricted to some buckets
+func TestAuthKeyOptions(t *testing.T) {
+	ctx := context.Background()
+	fstest.Initialise()
+
+	_, f := makeTestRemote(t, "alpha", "beta")
+	teamDir, _ := makeTestRemote(t, "gamma")
+	host := startTestServer(t, f,
+		"fullkey,fullsecret",
+		"readonly,rosecret,read_only",
+		"teamkey,teamsecret,remote="+teamDir,
+		"alphakey,alphasecret,bucket=alpha",
+		`connkey,connsecret,"remote=:local,links=false:`+teamDir+`",read_only`,
+	)
+
+	client := func(t *testing.T, keyID, keySecret string) *minio.Client {
+		return newTestClient(t, host, keyID, keySecret)
+	}
+	bucketNames := func(t *testing.T, c *minio.Client) (names []string) {
+		buckets, err := c.ListBuckets(ctx)
+		require.NoError(t, err)
+		for _, bucket := range buckets {
+			names = append(names, bucket.Name)
+		}
+		return names
+	}
+	put := func(c *minio.Client, bucket string) error {
+		_, err := c.PutObject(ctx, bucket, "new.txt", bytes.NewBufferString("new"), 3, minio.PutObjectOptions{})
+		return err
+	}
+
+	t.Run("Full", func(t *testing.T) {
+		c := client(t, "fullkey", "fullsecret")
+		assert.Equal(t, []string{"alpha", "beta"}, bucketNames(t, c))
+		assert.NoError(t, put(c, "beta"))
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		c := client(t, "readonly", "rosecret")
+		assert.Equal(t, []string{"alpha", "beta"}, bucketNames(t, c))
+		_, err := c.StatObject(ctx, "alpha", "file.txt", minio.StatObjectOptions{})
+		assert.NoError(t, err)
+		err = put(c, "alpha")
+		require.Error(t, err)
+		assert.Equal(t, "AccessDenied", minio.ToErrorResponse(err).Code)
+		err = c.RemoveObject(ctx, "alpha", "file.txt", minio.RemoveObjectOptions{})
+		require.Error(t, err)
+		assert.Equal(t, "AccessDenied", minio.ToErrorResponse(err).Code)
+
+		// a badly signed write is rejected by the auth checks
+		err = put(client(t, "readonly", "wrongsecret"), "alpha")
+		require.Error(t, err)
+		assert.Equal(t, "SignatureDoesNotMatch", minio.ToErrorResponse(err).Code)
+	})
+
+	t.Run("Remote", func(t *testing.T) {
+		c := client(t, "teamkey", "teamsecret")
+		assert.Equal(t, []string{"gamma"}, bucketNames(t, c))
+		assert.NoError(t, put(c, "gamma"))
+		_, err := os.Stat(filepath.Join(teamDir, "gamma", "new.txt"))
+		assert.NoError(t, err)
+
+		// a connection string with commas can be used if quoted
+		c = client(t, "connkey", "connsecret")
+		assert.Equal(t, []string{"gamma"}, bucketNames(t, c))
+		err = put(c, "gamma")
+		require.Error(t, err)
+		assert.Equal(t, "AccessDenied", minio.ToErrorResponse(err).Code)
+	})
+
+	t.Run("Buckets", func(t *testing.T) {
+		c := client(t, "alphakey", "alphasecret")
+		assert.Equal(t, []string{"alpha"}, bucketNames(t, c))
+		assert.NoError(t, put(c, "alpha"))
+		_, err := c.StatObject(ctx, "beta", "file.txt", minio.StatObjectOptions{})
+		require.Error(t, err)
+		err = put(c, "beta")
+		require.Error(t, err)
+		assert.Equal(t, "NoSuchBucket", minio.ToErrorResponse(err).Code)
+	})
+}
+
+func TestPresignedURLs(t *testing.T) {
+	ctx := context.Background()
+	fstest.Initialise()
+	_, f := makeTestRemote(t, "bucket")
+	host := startTestServer(t, f, "presignkey,presignsecret", "readonly,rosecret,read_only")
+	c := newTestClient(t, host, "presignkey", "presignsecret")
+
+	do := func(method string, u *url.URL, body string) (int, string) {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+
+	putURL, err := c.PresignedPutObject(ctx, "bucket", "presigned.txt", time.Hour)
+	require.NoError(t, err)
+	status, _ := do(http.MethodPut, putURL, "presigned contents")
+	assert.Equal(t, http.StatusOK, status)
+
+	getURL, err := c.PresignedGetObject(ctx, "bucket", "presigned.txt", time.Hour, nil)
+	require.NoError(t, err)
+	status, body := do(http.MethodGet, getURL, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "presigned contents", body)
+
+	// tampering with the URL invalidates the signature
+	badURL := *getURL
+	query := badURL.Query()
+	query.Set("X-Amz-Expires", "7200")
+	badURL.RawQuery = query.Encode()
+	status, body = do(http.MethodGet, &badURL, "")
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Contains(t, body, "SignatureDoesNotMatch")
+
+	// presigned URLs get the permissions of their access key
+	roClient := newTestClient(t, host, "readonly", "rosecret")
+	putURL, err = roClient.PresignedPutObject(ctx, "bucket", "denied.txt", time.Hour)
+	require.NoError(t, err)
+	status, body = do(http.MethodPut, putURL, "denied")
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Contains(t, body, "AccessDenied")
+}
+
+func TestMultipartUploads(t *testing.T) {
+	ctx := context.Background()
+	fstest.Initialise()
+	_, f := makeTestRemote(t, "bucket", "empty")
+	otherDir, _ := makeTestRemote(t, "bucket")
+	host := startTestServer(t, f, "multikey,multisecret", "otherkey,othersecret,remote="+otherDir)
+	c := minio.Core{Client: newTestClient(t, host, "multikey", "multisecret")}
+	other := minio.Core{Client: newTestClient(t, host, "otherkey", "othersecret")}
+
+	listUploads := func(c minio.Core, bucket string) (keys []string) {
+		result, err := c.ListMultipartUploads(ctx, bucket, "", "", "", "", 100)
+		require.NoError(t, err)
+		for _, upload := range result.Uploads {
+			keys = append(keys, upload.Key)
+		}
+		return keys
+	}
+
+	// a bucket which has never had uploads lists as empty
+	assert.Empty(t, listUploads(c, "empty"))
+
+	id, err := c.NewMultipartUpload(ctx, "bucket", "multi.txt", minio.PutObjectOptions{})
+	require.NoError(t, err)
+	part, err := c.PutObjectPart(ctx, "bucket", "multi.txt", id, 1, bytes.NewReader([]byte("part1")), 5, minio.PutObjectPartOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"multi.txt"}, listUploads(c, "bucket"))
+	parts, err := c.ListObjectParts(ctx, "bucket", "multi.txt", id, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, parts.ObjectParts, 1)
+	assert.Equal(t, int64(5), parts.ObjectParts[0].Size)
+
+	// the upload can't be seen with an access key for another remote
+	assert.Empty(t, listUploads(other, "bucket"))
+	err = other.AbortMultipartUpload(ctx, "bucket", "multi.txt", id)
+	require.Error(t, err)
+	assert.Equal(t, "NoSuchUpload", minio.ToErrorResponse(err).Code)
+
+	err = c.AbortMultipartUpload(ctx, "bucket", "multi.txt", id)
+	require.NoError(t, err)
+	assert.Empty(t, listUploads(c, "bucket"))
+
+	// complete an upload
+	id, err = c.NewMultipartUpload(ctx, "bucket", "multi.txt", minio.PutObjectOptions{})
+	require.NoError(t, err)
+	part, err = c.PutObjectPart(ctx, "bucket", "multi.txt", id, 1, bytes.NewReader([]byte("part1")), 5, minio.PutObjectPartOptions{})
+	require.NoError(t, err)
+	_, err = c.CompleteMultipartUpload(ctx, "bucket", "multi.txt", id, []minio.CompletePart{{PartNumber: part.PartNumber, ETag: part.ETag}}, minio.PutObjectOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, listUploads(c, "bucket"))
+	obj, err := c.Client.GetObject(ctx, "bucket", "multi.txt", minio.GetObjectOptions{})
+	require.NoError(t, err)
+	data, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	assert.Equal(t, "part1", string(data))
+}
+
+func TestCheckTags(t *testing.T) {
+	tags := url.Values{}
+	for i := 0; i < maxTags; i++ {
+		tags.Set(fmt.Sprint("key", i), "value")
+	}
+	assert.NoError(t, checkTags(tags))
+	tags.Set("toomany", "value")
+	assert.Error(t, checkTags(tags))
+	assert.Error(t, checkTags(url.Values{"": {"value"}}))
+	assert.Error(t, checkTags(url.Values{strings.Repeat("k", maxTagKeyLength+1): {"value"}}))
+	assert.Error(t, checkTags(url.Values{"key": {strings.Repeat("v", maxTagValueLength+1)}}))
+	assert.Error(t, checkTags(url.Values{"key": {"value1", "value2"}}))
+}
+
+func TestObjectTagging(t *testing.T) {
+	ctx := context.Background()
+	fstest.Initialise()
+	_, f := makeTestRemote(t, "bucket")
+	host := startTestServer(t, f, "tagkey,tagsecret")
+	c := newTestClient(t, host, "tagkey", "tagsecret")
+
+	getTags := func(object string) map[string]string {
+		objectTags, err := c.GetObjectTagging(ctx, "bucket", object, minio.GetObjectTaggingOptions{})
+		require.NoError(t, err)
+		return objectTags.ToMap()
+	}
+	assert.Empty(t, getTags("file.txt"))
+
+	objectTags, err := tags.NewTags(map[string]string{"project": "potato", "team": "veg"}, true)
+	require.NoError(t, err)
+	require.NoError(t, c.PutObjectTagging(ctx, "bucket", "file.txt", objectTags, minio.PutObjectTaggingOptions{}))
+	assert.Equal(t, map[string]string{"project": "potato", "team": "veg"}, getTags("file.txt"))
+
+	// tagging mustn't change the object
+	obj, err := c.GetObject(ctx, "bucket", "file.txt", minio.GetObjectOptions{})
+	require.NoError(t, err)
+	data, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	assert.Equal(t, "contents", string(data))
+
+	require.NoError(t, c.RemoveObjectTagging(ctx, "bucket", "file.txt", minio.RemoveObjectTaggingOptions{}))
+	assert.Empty(t, getTags("file.txt"))
+
+	// tags can be set on upload and are replaced when the
+	// object is overwritten
+	_, err = c.PutObject(ctx, "bucket", "tagged.txt", bytes.NewBufferString("tagged"), 6, minio.PutObjectOptions{
+		UserTags: map[string]string{"colour": "blue"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"colour": "blue"}, getTags("tagged.txt"))
+	_, err = c.PutObject(ctx, "bucket", "tagged.txt", bytes.NewBufferString("untagged"), 8, minio.PutObjectOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, getTags("tagged.txt"))
+
+	_, err = c.GetObjectTagging(ctx, "bucket", "missing.txt", minio.GetObjectTaggingOptions{})
+	require.Error(t, err)
+	assert.Equal(t, "NoSuchKey", minio.ToErrorResponse(err).Code)
+}
+
+// TestAuthProxyOptions checks the auth proxy can make access keys read
+// only and restrict them to some buckets
+func TestAuthProxyOptions(t *testing.T) {
+	ctx := context.Background()
+	fstest.Initialise()
+
+	dir, _ := makeTestRemote(t, "alpha", "beta", "gamma")
+	prog, err := filepath.Abs("proxy_code.go")
+	require.NoError(t, err)
+	proxyflags.Opt.AuthProxy = "go run " + prog + " " + dir
+	defer func() {
+		proxyflags.Opt.AuthProxy = ""
+	}()
+	host := startTestServer(t, nil, "unused,proxysecret")
+
+	bucketNames := func(t *testing.T, c *minio.Client) (names []string) {
+		buckets, err := c.ListBuckets(ctx)
+		require.NoError(t, err)
+		for _, bucket := range buckets {
+			names = append(names, bucket.Name)
+		}
+		return names
+	}
+	put := func(c *minio.Client, bucket string) error {
+		_, err := c.PutObject(ctx, bucket, "new.txt", bytes.NewBufferString("new"), 3, minio.PutObjectOptions{})
+		return err
+	}
+
+	t.Run("Full", func(t *testing.T) {
+		c := newTestClient(t, host, "fullkey", "proxysecret")
+		assert.Equal(t, []string{"alpha", "beta", "gamma"}, bucketNames(t, c))
+		assert.NoError(t, put(c, "beta"))
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		c := newTestClient(t, host, "readonlykey", "proxysecret")
+		assert.Equal(t, []string{"alpha", "beta", "gamma"}, bucketNames(t, c))
+		err := put(c, "alpha")
+		require.Error(t, err)
+		assert.Equal(t, "AccessDenied", minio.ToErrorResponse(err).Code)
+	})
+
+	t.Run("Buckets", func(t *testing.T) {
+		c := newTestClient(t, host, "buckets-alpha-gamma", "proxysecret")
+		assert.Equal(t, []string{"alpha", "gamma"}, bucketNames(t, c))
+		assert.NoError(t, put(c, "gamma"))
+		_, err := c.StatObject(ctx, "beta", "file.txt", minio.StatObjectOptions{})
+		require.Error(t, err)
+		err = put(c, "beta")
+		require.Error(t, err)
+		assert.Equal(t, "NoSuchBucket", minio.ToErrorResponse(err).Code)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"strings"
@@ -14,6 +15,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/rclone/gofakes3"
 	"github.com/rclone/gofakes3/signature"
+	"github.com/rclone/gofakes3/xml"
 	"github.com/rclone/rclone/cmd/serve/proxy"
 	"github.com/rclone/rclone/cmd/serve/proxy/proxyflags"
 	"github.com/rclone/rclone/fs"
@@ -27,6 +29,7 @@ type ctxKey int
 
 const (
 	ctxKeyID ctxKey = iota
+	ctxKeyUser
 )
 
 // Options contains options for the http Server
@@ -43,14 +46,18 @@ type Options struct {
 
 // Server is a s3.FileSystem interface
 type Server struct {
-	server   *httplib.Server
-	f        fs.Fs
-	_vfs     *vfs.VFS // don't use directly, use getVFS
-	faker    *gofakes3.GoFakeS3
-	handler  http.Handler
-	proxy    *proxy.Proxy
-	ctx      context.Context // for global config
-	s3Secret string
+	server     *httplib.Server
+	f          fs.Fs
+	_vfs       *vfs.VFS // don't use directly, use getVFS
+	faker      *gofakes3.GoFakeS3
+	backend    *s3Backend
+	hostBucket bool // set if the bucket is in the host name
+	handler    http.Handler
+	proxy      *proxy.Proxy
+	users      map[string]*s3User // configuration for each access key
+	uploads    multipartUploads   // in progress multipart uploads
+	ctx        context.Context    // for global config
+	s3Secret   string
 }
 
 // Make a new S3 Server to serve the remote
@@ -67,29 +74,41 @@ func newServer(ctx context.Context, f fs.Fs, opt *Options) (s *Server, err error
 	}
 
 	var newLogger logger
+	w.backend = newBackend(w, opt)
+	w.hostBucket = !opt.pathBucketMode
 	w.faker = gofakes3.New(
-		newBackend(w, opt),
-		gofakes3.WithHostBucket(!opt.pathBucketMode),
+		w.backend,
+		gofakes3.WithHostBucket(w.hostBucket),
 		gofakes3.WithLogger(newLogger),
 		gofakes3.WithRequestID(rand.Uint64()),
 		gofakes3.WithoutVersioning(),
-		gofakes3.WithV4Auth(authlistResolver(opt.authPair)),
 		gofakes3.WithIntegrityCheck(true), // Check Content-MD5 if supplied
 	)
 
-	w.handler = http.NewServeMux()
-	w.handler = w.faker.Server()
+	// Auth is checked by authMiddleware below rather than by
+	// gofakes3 so routeMiddleware only sees authorized requests.
+	w.handler = routeMiddleware(w.faker.Server(), w)
 
 	if proxyflags.Opt.AuthProxy != "" {
+		if hasAuthKeyOptions(opt.authPair) {
+			return nil, errors.New("--auth-key options can't be used with --auth-proxy")
+		}
 		w.proxy = proxy.New(ctx, &proxyflags.Opt)
 		// proxy auth middleware
 		w.handler = proxyAuthMiddleware(w.handler, w)
+		w.handler = authMiddleware(w.handler)
 		w.handler = authPairMiddleware(w.handler, w)
 	} else {
 		w._vfs = vfs.New(f, &vfscommon.Opt)
 
 		if len(opt.authPair) > 0 {
-			w.faker.AddAuthKeys(authlistResolver(opt.authPair))
+			w.users, err = w.newUsers(ctx, opt.authPair)
+			if err != nil {
+				return nil, err
+			}
+			signature.StoreKeys(authlistResolver(opt.authPair))
+			w.handler = userMiddleware(w.handler, w)
+			w.handler = authMiddleware(w.handler)
 		}
 	}
 
@@ -105,6 +124,12 @@ func newServer(ctx context.Context, f fs.Fs, opt *Options) (s *Server, err error
 }
 
 func (w *Server) getVFS(ctx context.Context) (VFS *vfs.VFS, err error) {
+	if u := getUser(ctx); u != nil {
+		return u.vfs, nil
+	}
+	if len(w.users) > 0 {
+		return nil, errors.New("no access key configuration found in context")
+	}
 	if w._vfs != nil {
 		return w._vfs, nil
 	}
@@ -121,13 +146,14 @@ func (w *Server) getVFS(ctx context.Context) (VFS *vfs.VFS, err error) {
 	return VFS, nil
 }
 
-// auth does proxy authorization
-func (w *Server) auth(accessKeyID string) (value interface{}, err error) {
-	VFS, _, err := w.proxy.Call(stringToMd5Hash(accessKeyID), accessKeyID, false)
+// auth does proxy authorization returning the configuration for the
+// access key
+func (w *Server) auth(accessKeyID string) (u *s3User, err error) {
+	VFS, _, params, err := w.proxy.CallParams(stringToMd5Hash(accessKeyID), accessKeyID, false)
 	if err != nil {
 		return nil, err
 	}
-	return VFS, err
+	return newProxyUser(VFS, params), nil
 }
 
 // Bind register the handler to http.Router
@@ -149,7 +175,19 @@ func authPairMiddleware(next http.Handler, ws *Server) http.Handler {
 		authPair := map[string]string{
 			accessKey: ws.s3Secret,
 		}
-		ws.faker.AddAuthKeys(authPair)
+		signature.StoreKeys(authPair)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authMiddleware refuses requests which aren't signed with a known key
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if result := signature.V4SignVerify(r); result != signature.ErrNone {
+			fs.Infof(r.URL.Path, "%s: Access denied: %s", r.RemoteAddr, signature.GetAPIError(result).Description)
+			writeAPIError(w, signature.GetAPIError(result))
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -157,20 +195,92 @@ func authPairMiddleware(next http.Handler, ws *Server) http.Handler {
 func proxyAuthMiddleware(next http.Handler, ws *Server) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		accessKey, _ := parseAccessKeyID(r)
-		value, err := ws.auth(accessKey)
+		u, err := ws.auth(accessKey)
 		if err != nil {
 			fs.Infof(r.URL.Path, "%s: Auth failed: %v", r.RemoteAddr, err)
 		}
-		if value != nil {
-			r = r.WithContext(context.WithValue(r.Context(), ctxKeyID, value))
+		if u != nil {
+			if denyWrite(w, r, u.readOnly) {
+				return
+			}
+			ctx := context.WithValue(r.Context(), ctxKeyID, u.vfs)
+			r = r.WithContext(context.WithValue(ctx, ctxKeyUser, u))
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// routeMiddleware handles the requests which gofakes3 doesn't
+// support or needs help with
+func routeMiddleware(next http.Handler, ws *Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		bucket, object := ws.bucketObject(r)
+		switch {
+		case query.Has("tagging"):
+			ws.serveTagging(w, r, bucket, object)
+		case query.Has("uploads") || query.Has("uploadId"):
+			ws.serveMultipart(next, w, r, bucket)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// bucketObject returns the bucket and object the request is for in
+// the same way gofakes3 does
+func (w *Server) bucketObject(r *http.Request) (bucket, object string) {
+	p := r.URL.Path
+	if w.hostBucket {
+		bucket, _, _ = strings.Cut(r.Host, ".")
+		if p != "/" {
+			p = "/" + bucket + p
+		} else {
+			p = "/" + bucket
+		}
+	}
+	bucket, object, _ = strings.Cut(strings.Trim(p, "/"), "/")
+	return bucket, object
+}
+
+// writeError writes err as an S3 error response
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var resp gofakes3.Error
+	switch e := err.(type) {
+	case gofakes3.ErrorCode:
+		resp = &gofakes3.ErrorResponse{Code: e, Message: e.Message()}
+	case gofakes3.Error:
+		resp = e
+	default:
+		fs.Errorf("serve s3", "%s %s failed: %v", r.Method, r.URL.Path, err)
+		resp = &gofakes3.ErrorResponse{Code: gofakes3.ErrInternal, Message: "Internal Error"}
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(resp.ErrorCode().Status())
+	if r.Method != http.MethodHead {
+		_ = writeXML(w, resp)
+	}
+}
+
+// writeXML writes the XML header and v to w
+func writeXML(w io.Writer, v interface{}) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
 func parseAccessKeyID(r *http.Request) (accessKey string, error signature.ErrorCode) {
 	v4Auth := r.Header.Get("Authorization")
+	if v4Auth == "" {
+		// Presigned URLs have the credential in the query string
+		if credential := r.URL.Query().Get("X-Amz-Credential"); credential != "" {
+			accessKey, _, _ = strings.Cut(credential, "/")
+			return accessKey, signature.ErrNone
+		}
+	}
 	req, err := signature.ParseSignV4(v4Auth)
 	if err != signature.ErrNone {
 		return "", err
@@ -191,7 +301,7 @@ func getAuthSecret(authPair []string) string {
 	}
 
 	splited := strings.Split(authPair[0], ",")
-	if len(splited) != 2 {
+	if len(splited) < 2 {
 		return ""
 	}
 
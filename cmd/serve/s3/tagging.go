@@ -0,0 +1,241 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/rclone/gofakes3"
+	"github.com/rclone/gofakes3/xml"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+)
+
+// Object tags are stored in the metadata of the object under this key
+// if the remote supports it, otherwise they are kept in memory.
+const tagsMetadataKey = "s3-tagging"
+
+// Limits on tags from the S3 docs
+const (
+	maxTags           = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+	maxTaggingSize    = 64 * 1024 // limit on the size of a PutObjectTagging request
+)
+
+// tagging is the XML for the object tagging requests
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	TagSet  []tag    `xml:"TagSet>Tag"`
+}
+
+// tag is a single object tag
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// checkTags returns an error if tags aren't valid S3 tags
+func checkTags(tags url.Values) error {
+	if len(tags) > maxTags {
+		return gofakes3.ErrorMessage(gofakes3.ErrInvalidArgument, "Object tags cannot be greater than 10")
+	}
+	for key, values := range tags {
+		if len(values) != 1 {
+			return gofakes3.ErrorMessage(gofakes3.ErrInvalidArgument, "Cannot provide multiple Tags with the same key")
+		}
+		if key == "" || utf8.RuneCountInString(key) > maxTagKeyLength {
+			return gofakes3.ErrorMessage(gofakes3.ErrInvalidArgument, "The TagKey you have provided is invalid")
+		}
+		if utf8.RuneCountInString(values[0]) > maxTagValueLength {
+			return gofakes3.ErrorMessage(gofakes3.ErrInvalidArgument, "The TagValue you have provided is invalid")
+		}
+	}
+	return nil
+}
+
+// serveTagging serves the GetObjectTagging, PutObjectTagging and
+// DeleteObjectTagging requests
+func (w *Server) serveTagging(rw http.ResponseWriter, r *http.Request, bucket, object string) {
+	if object == "" {
+		// bucket tagging isn't supported
+		writeError(rw, r, gofakes3.ErrNotImplemented)
+		return
+	}
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		tags, err := w.backend.getTags(ctx, bucket, object)
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		out := tagging{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/", TagSet: []tag{}}
+		keys := make([]string, 0, len(tags))
+		for key := range tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			out.TagSet = append(out.TagSet, tag{Key: key, Value: tags.Get(key)})
+		}
+		rw.Header().Set("Content-Type", "application/xml")
+		_ = writeXML(rw, out)
+	case http.MethodPut:
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxTaggingSize+1))
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		if len(body) > maxTaggingSize {
+			writeError(rw, r, gofakes3.ErrorMessage(gofakes3.ErrInvalidArgument, "Tagging is too large"))
+			return
+		}
+		var in tagging
+		if err := xml.Unmarshal(body, &in); err != nil {
+			writeError(rw, r, gofakes3.ErrorMessage(gofakes3.ErrMalformedXML, err.Error()))
+			return
+		}
+		tags := url.Values{}
+		for _, t := range in.TagSet {
+			tags.Add(t.Key, t.Value)
+		}
+		if err := w.backend.setTags(ctx, bucket, object, tags); err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		if err := w.backend.setTags(ctx, bucket, object, nil); err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(rw, r, gofakes3.ErrMethodNotAllowed)
+	}
+}
+
+// tagObject finds the object in the VFS for the request
+func (b *s3Backend) tagObject(ctx context.Context, bucketName, objectName string) (_vfs *vfs.VFS, fp string, o fs.Object, err error) {
+	_vfs, err = b.s.getVFS(ctx)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if !b.s.bucketAllowed(ctx, bucketName) {
+		return nil, "", nil, gofakes3.BucketNotFound(bucketName)
+	}
+	_, err = _vfs.Stat(bucketName)
+	if err != nil {
+		return nil, "", nil, gofakes3.BucketNotFound(bucketName)
+	}
+	fp = path.Join(bucketName, objectName)
+	node, err := _vfs.Stat(fp)
+	if err != nil || !node.IsFile() {
+		return nil, "", nil, gofakes3.KeyNotFound(objectName)
+	}
+	o, ok := node.DirEntry().(fs.Object)
+	if !ok {
+		return nil, "", nil, gofakes3.KeyNotFound(objectName)
+	}
+	return _vfs, fp, o, nil
+}
+
+// getTags returns the tags of the object
+func (b *s3Backend) getTags(ctx context.Context, bucketName, objectName string) (url.Values, error) {
+	_vfs, fp, o, err := b.tagObject(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	if o.Fs().Features().ReadMetadata {
+		metadata, err := fs.GetMetadata(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		if value, found := metadata[tagsMetadataKey]; found {
+			return url.ParseQuery(value)
+		}
+	}
+	if value, ok := b.tags.Load(metaKey{_vfs, fp}); ok {
+		return value.(url.Values), nil
+	}
+	return url.Values{}, nil
+}
+
+// setTags replaces the tags of the object
+func (b *s3Backend) setTags(ctx context.Context, bucketName, objectName string, tags url.Values) error {
+	if err := checkTags(tags); err != nil {
+		return err
+	}
+	_vfs, fp, o, err := b.tagObject(ctx, bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	return b.storeTags(ctx, _vfs, fp, o, tags)
+}
+
+// storeTags stores the tags for the object o at fp in _vfs.
+//
+// They are kept in memory too in case the remote accepts the metadata
+// but doesn't store it, eg local without xattr support.
+func (b *s3Backend) storeTags(ctx context.Context, _vfs *vfs.VFS, fp string, o fs.Object, tags url.Values) error {
+	if do, ok := o.(fs.SetMetadataer); ok && o.Fs().Features().WriteMetadata {
+		err := storeTagsMetadata(ctx, do, o, tags)
+		if err != nil && !errors.Is(err, fs.ErrorNotImplemented) {
+			return err
+		}
+	}
+	key := metaKey{_vfs, fp}
+	if len(tags) == 0 {
+		b.tags.Delete(key)
+	} else {
+		b.tags.Store(key, tags)
+	}
+	return nil
+}
+
+// storeTagsMetadata stores the tags in the metadata of o
+func storeTagsMetadata(ctx context.Context, do fs.SetMetadataer, o fs.Object, tags url.Values) error {
+	if len(tags) == 0 {
+		// Metadata can't be removed so only blank the tags
+		// if there are some to save a write
+		metadata, err := fs.GetMetadata(ctx, o)
+		if err != nil {
+			return err
+		}
+		if metadata[tagsMetadataKey] == "" {
+			return nil
+		}
+	}
+	return do.SetMetadata(ctx, fs.Metadata{tagsMetadataKey: tags.Encode()})
+}
+
+// parseTaggingHeader parses the X-Amz-Tagging header in meta
+func parseTaggingHeader(meta map[string]string) (url.Values, error) {
+	tags, err := url.ParseQuery(meta["X-Amz-Tagging"])
+	if err != nil {
+		return nil, gofakes3.ErrorMessage(gofakes3.ErrInvalidArgument, "The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+	}
+	return tags, checkTags(tags)
+}
+
+// putObjectTags sets the tags of a newly uploaded object, removing
+// any old ones.
+func (b *s3Backend) putObjectTags(ctx context.Context, _vfs *vfs.VFS, fp string, tags url.Values) error {
+	node, err := _vfs.Stat(fp)
+	if err != nil {
+		return err
+	}
+	o, ok := node.DirEntry().(fs.Object)
+	if !ok {
+		b.tags.Delete(metaKey{_vfs, fp})
+		return nil
+	}
+	return b.storeTags(ctx, _vfs, fp, o, tags)
+}
@@ -0,0 +1,209 @@
+package s3
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rclone/gofakes3/signature"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// s3User is the configuration for an access key
+type s3User struct {
+	remote   string              // remote:path to serve - "" for the one on the command line
+	readOnly bool                // if set only allow reads
+	buckets  map[string]struct{} // buckets which can be accessed - nil for all
+	vfs      *vfs.VFS
+}
+
+// parseAuthKey parses an --auth-key value which looks like
+//
+//	access_key_id,secret_access_key[,remote=remote:path][,read_only][,bucket=name]...
+//
+// The options after the secret use the encoding/csv rules for quoting
+// so an option containing commas, such as a remote given as a
+// connection string, can be put in double quotes.
+//
+// It returns the access key and its configuration.
+func parseAuthKey(s string) (accessKey string, u *s3User, err error) {
+	parts := strings.SplitN(s, ",", 3)
+	if len(parts) < 2 {
+		return "", nil, fmt.Errorf("invalid auth key %q: expecting access_key_id,secret_access_key", parts[0])
+	}
+	accessKey = parts[0]
+	u = &s3User{}
+	if len(parts) < 3 {
+		return accessKey, u, nil
+	}
+	r := csv.NewReader(strings.NewReader(parts[2]))
+	r.TrimLeadingSpace = true
+	options, err := r.Read()
+	if err == io.EOF {
+		return accessKey, u, nil
+	} else if err != nil {
+		return "", nil, fmt.Errorf("auth key %q: invalid options: %w", accessKey, err)
+	}
+	for _, option := range options {
+		key, value, hasValue := strings.Cut(strings.TrimSpace(option), "=")
+		switch key {
+		case "remote":
+			if value == "" {
+				return "", nil, fmt.Errorf("auth key %q: remote must not be empty", accessKey)
+			}
+			u.remote = value
+		case "read_only":
+			u.readOnly = true
+			if hasValue {
+				u.readOnly, err = strconv.ParseBool(value)
+				if err != nil {
+					return "", nil, fmt.Errorf("auth key %q: invalid read_only: %w", accessKey, err)
+				}
+			}
+		case "bucket":
+			if value == "" {
+				return "", nil, fmt.Errorf("auth key %q: bucket must not be empty", accessKey)
+			}
+			if u.buckets == nil {
+				u.buckets = make(map[string]struct{})
+			}
+			u.buckets[value] = struct{}{}
+		default:
+			return "", nil, fmt.Errorf("auth key %q: unknown option %q - options containing commas must be quoted", accessKey, key)
+		}
+	}
+	return accessKey, u, nil
+}
+
+// hasAuthKeyOptions returns true if any of the auth keys have options
+// after the access key and secret
+func hasAuthKeyOptions(authPair []string) bool {
+	for _, pair := range authPair {
+		if strings.Count(pair, ",") > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// newUsers makes the configuration for each access key in authPair.
+//
+// Access keys without a remote use the VFS of the server and access
+// keys with the same remote share a VFS.
+func (w *Server) newUsers(ctx context.Context, authPair []string) (users map[string]*s3User, err error) {
+	users = make(map[string]*s3User, len(authPair))
+	vfses := make(map[string]*vfs.VFS)
+	for _, pair := range authPair {
+		accessKey, u, err := parseAuthKey(pair)
+		if err != nil {
+			return nil, err
+		}
+		if u.remote == "" {
+			u.vfs = w._vfs
+		} else if u.vfs = vfses[u.remote]; u.vfs == nil {
+			f, err := cache.Get(ctx, u.remote)
+			if err != nil {
+				return nil, fmt.Errorf("auth key %q: failed to make remote %q: %w", accessKey, u.remote, err)
+			}
+			u.vfs = vfs.New(f, &vfscommon.Opt)
+			vfses[u.remote] = u.vfs
+		}
+		users[accessKey] = u
+	}
+	return users, nil
+}
+
+// newProxyUser makes the configuration for an access key from the
+// VFS and parameters returned by the auth proxy.
+//
+// The proxy can return _buckets as a comma separated list of the
+// buckets the access key can use.
+func newProxyUser(VFS *vfs.VFS, params configmap.Simple) *s3User {
+	u := &s3User{
+		readOnly: VFS.Opt.ReadOnly,
+		vfs:      VFS,
+	}
+	if buckets, ok := params.Get("_buckets"); ok {
+		u.buckets = make(map[string]struct{})
+		for _, bucket := range strings.Split(buckets, ",") {
+			if bucket = strings.TrimSpace(bucket); bucket != "" {
+				u.buckets[bucket] = struct{}{}
+			}
+		}
+	}
+	return u
+}
+
+// bucketAllowed returns true if the user can access bucket
+func (u *s3User) bucketAllowed(bucket string) bool {
+	if u.buckets == nil {
+		return true
+	}
+	_, ok := u.buckets[bucket]
+	return ok
+}
+
+// getUser returns the configuration for the access key of the
+// request or nil if there isn't one
+func getUser(ctx context.Context) *s3User {
+	u, _ := ctx.Value(ctxKeyUser).(*s3User)
+	return u
+}
+
+// bucketAllowed returns true if the request can access bucket
+func (w *Server) bucketAllowed(ctx context.Context, bucket string) bool {
+	u := getUser(ctx)
+	return u == nil || u.bucketAllowed(bucket)
+}
+
+// isReadRequest returns true if the request doesn't modify anything
+func isReadRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// writeAPIError writes err as the response
+func writeAPIError(w http.ResponseWriter, err signature.APIError) {
+	w.Header().Add("content-type", "application/xml")
+	w.WriteHeader(err.HTTPStatusCode)
+	_, _ = w.Write(signature.EncodeAPIErrorToResponse(err))
+}
+
+// denyWrite returns true if it has refused the request because it
+// modifies something and the access key is read only.
+func denyWrite(w http.ResponseWriter, r *http.Request, readOnly bool) bool {
+	if !readOnly || isReadRequest(r) {
+		return false
+	}
+	writeAPIError(w, signature.APIError{
+		Code:           "AccessDenied",
+		Description:    "Access Denied",
+		HTTPStatusCode: http.StatusForbidden,
+	})
+	return true
+}
+
+// userMiddleware stores the configuration for the access key of the
+// request in the context and refuses writes for read only keys
+func userMiddleware(next http.Handler, ws *Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessKey, _ := parseAccessKeyID(r)
+		if u := ws.users[accessKey]; u != nil {
+			if denyWrite(w, r, u.readOnly) {
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), ctxKeyUser, u))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
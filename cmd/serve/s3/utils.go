@@ -125,7 +125,7 @@ func authlistResolver(list []string) map[string]string {
 	authList := make(map[string]string)
 	for _, v := range list {
 		parts := strings.Split(v, ",")
-		if len(parts) != 2 {
+		if len(parts) < 2 {
 			fs.Infof(nil, "Ignored: invalid auth pair %s", v)
 			continue
 		}
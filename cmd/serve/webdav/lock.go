@@ -0,0 +1,159 @@
+package webdav
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/vfs"
+	"golang.org/x/net/webdav"
+)
+
+// lockSystems holds a webdav.LockSystem for each remote being served.
+//
+// They are keyed on vfs.LockKey rather than the config string of the
+// remote since with --auth-proxy each user gets their own Fs called
+// proxy-<user>, and users of the same remote need to exclude each
+// other. Locks are mirrored into the VFS so that they stop writes to
+// the remote through the VFS of other users too.
+//
+// A lock system is evicted once it holds no locks, which happens when
+// they are unlocked, expire or the VFS which took them is released.
+type lockSystems struct {
+	mu      sync.Mutex
+	systems map[string]*lockSystem
+}
+
+// lockSystem is the lock system for a single remote
+type lockSystem struct {
+	ls     webdav.LockSystem
+	tokens map[string]*vfs.VFS // the VFS which took each lock
+	held   int                 // number of Confirms not yet released
+}
+
+// get returns the webdav.LockSystem to use for VFS
+func (l *lockSystems) get(VFS *vfs.VFS) webdav.LockSystem {
+	return &vfsLockSystem{
+		l:   l,
+		vfs: VFS,
+		key: vfs.LockKey(VFS.Fs()),
+	}
+}
+
+// system returns the lock system for key, making it if necessary,
+// with any locks no longer held in the VFS removed.
+//
+// Call with l.mu held
+func (l *lockSystems) system(key string, now time.Time) *lockSystem {
+	s := l.systems[key]
+	if s == nil {
+		s = &lockSystem{
+			ls:     webdav.NewMemLS(),
+			tokens: make(map[string]*vfs.VFS),
+		}
+		if l.systems == nil {
+			l.systems = make(map[string]*lockSystem)
+		}
+		l.systems[key] = s
+	}
+	for token, VFS := range s.tokens {
+		if !VFS.WriteLocked(token) {
+			_ = s.ls.Unlock(now, token)
+			delete(s.tokens, token)
+		}
+	}
+	return s
+}
+
+// evict removes the lock system for key if it isn't in use
+//
+// Call with l.mu held
+func (l *lockSystems) evict(key string, s *lockSystem) {
+	if len(s.tokens) == 0 && s.held == 0 && l.systems[key] == s {
+		delete(l.systems, key)
+	}
+}
+
+// expires returns when a lock of duration taken at now expires
+func expires(now time.Time, duration time.Duration) time.Time {
+	if duration < 0 {
+		return time.Time{}
+	}
+	return now.Add(duration)
+}
+
+// vfsLockSystem is the webdav.LockSystem used by requests for a VFS
+type vfsLockSystem struct {
+	l   *lockSystems
+	vfs *vfs.VFS
+	key string
+}
+
+// check interface
+var _ webdav.LockSystem = (*vfsLockSystem)(nil)
+
+// Confirm confirms that the caller can claim all of the locks
+// specified by the given conditions
+func (v *vfsLockSystem) Confirm(now time.Time, name0, name1 string, conditions ...webdav.Condition) (release func(), err error) {
+	v.l.mu.Lock()
+	defer v.l.mu.Unlock()
+	s := v.l.system(v.key, now)
+	lsRelease, err := s.ls.Confirm(now, name0, name1, conditions...)
+	if err != nil {
+		v.l.evict(v.key, s)
+		return nil, err
+	}
+	s.held++
+	return func() {
+		v.l.mu.Lock()
+		defer v.l.mu.Unlock()
+		lsRelease()
+		s.held--
+		v.l.evict(v.key, s)
+	}, nil
+}
+
+// Create creates a lock with the given depth, duration, owner and
+// root (name)
+func (v *vfsLockSystem) Create(now time.Time, details webdav.LockDetails) (token string, err error) {
+	v.l.mu.Lock()
+	defer v.l.mu.Unlock()
+	s := v.l.system(v.key, now)
+	token, err = s.ls.Create(now, details)
+	if err != nil {
+		v.l.evict(v.key, s)
+		return "", err
+	}
+	s.tokens[token] = v.vfs
+	v.vfs.LockWrite(details.Root, token, details.ZeroDepth, expires(now, details.Duration))
+	return token, nil
+}
+
+// Refresh refreshes the lock with the given token
+func (v *vfsLockSystem) Refresh(now time.Time, token string, duration time.Duration) (details webdav.LockDetails, err error) {
+	v.l.mu.Lock()
+	defer v.l.mu.Unlock()
+	s := v.l.system(v.key, now)
+	details, err = s.ls.Refresh(now, token, duration)
+	if err != nil {
+		v.l.evict(v.key, s)
+		return details, err
+	}
+	if VFS := s.tokens[token]; VFS != nil {
+		VFS.LockWrite(details.Root, token, details.ZeroDepth, expires(now, details.Duration))
+	}
+	return details, nil
+}
+
+// Unlock unlocks the lock with the given token
+func (v *vfsLockSystem) Unlock(now time.Time, token string) (err error) {
+	v.l.mu.Lock()
+	defer v.l.mu.Unlock()
+	s := v.l.system(v.key, now)
+	err = s.ls.Unlock(now, token)
+	if VFS := s.tokens[token]; VFS != nil && err == nil {
+		VFS.UnlockWrite(token)
+		delete(s.tokens, token)
+	}
+	v.l.evict(v.key, s)
+	return err
+}
@@ -2,10 +2,12 @@
 package webdav
 
 import (
+	"bytes"
 	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"mime"
 	"net/http"
 	"os"
@@ -89,6 +91,23 @@ supported hash on the backend or you can use a named hash such as
 "MD5" or "SHA-1". Use the [hashsum](/commands/rclone_hashsum/) command
 to see the full list.
 
+### Locking and quota
+
+The server supports WebDAV class 2 locking with LOCK and UNLOCK, which
+clients such as Microsoft Office and macOS Finder need to open files
+for editing. Locks are held in memory so are lost when the server is
+restarted. When using --auth-proxy, users serving the same remote
+share locks.
+
+Locks also apply to the VFS, so while a file is locked other users of
+the same remote can't write it, even through a different VFS, until
+the lock is released or expires.
+
+Directories have the quota-available-bytes and quota-used-bytes
+properties from RFC 4331 if the remote supports [about](/commands/rclone_about/).
+These are live properties so are only returned when asked for by name
+and can't be changed with PROPPATCH.
+
 ### Access WebDAV on Windows
 
 WebDAV shared folder can be mapped as a drive on Windows, however the default settings prevent it.
@@ -184,12 +203,12 @@ done by the permissions on the socket.
 // overwriting another existing file or directory is an error is OS-dependent.
 type WebDAV struct {
 	*libhttp.Server
-	opt           Options
-	f             fs.Fs
-	_vfs          *vfs.VFS // don't use directly, use getVFS
-	webdavhandler *webdav.Handler
-	proxy         *proxy.Proxy
-	ctx           context.Context // for global config
+	opt      Options
+	f        fs.Fs
+	_vfs     *vfs.VFS // don't use directly, use getVFS
+	locks    lockSystems
+	proxy    *proxy.Proxy
+	ctx      context.Context // for global config
 }
 
 // check interface
@@ -222,14 +241,6 @@ func newWebDAV(ctx context.Context, f fs.Fs, opt *Options) (w *WebDAV, err error
 	// Make sure BaseURL starts with a / and doesn't end with one
 	w.opt.HTTP.BaseURL = "/" + strings.Trim(w.opt.HTTP.BaseURL, "/")
 
-	webdavHandler := &webdav.Handler{
-		Prefix:     w.opt.HTTP.BaseURL,
-		FileSystem: w,
-		LockSystem: webdav.NewMemLS(),
-		Logger:     w.logRequest, // FIXME
-	}
-	w.webdavhandler = webdavHandler
-
 	router := w.Server.Router()
 	router.Use(
 		middleware.SetHeader("Accept-Ranges", "bytes"),
@@ -338,8 +349,23 @@ func (w *WebDAV) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	// Add URL Prefix back to path since webdavhandler needs to
 	// return absolute references.
 	r.URL.Path = w.opt.HTTP.BaseURL + r.URL.Path
+	VFS, err := w.getVFS(r.Context())
+	if err != nil {
+		http.Error(rw, "Root directory not found", http.StatusNotFound)
+		fs.Errorf(nil, "Failed to serve request: %v", err)
+		return
+	}
+	if r.Method == "PROPFIND" && quotaRequested(r) {
+		r = r.WithContext(context.WithValue(r.Context(), quotaKey{}, true))
+	}
+	handler := &webdav.Handler{
+		Prefix:     w.opt.HTTP.BaseURL,
+		FileSystem: w,
+		LockSystem: w.locks.get(VFS),
+		Logger:     w.logRequest, // FIXME
+	}
 	wrw := &webdavRW{ResponseWriter: rw}
-	w.webdavhandler.ServeHTTP(wrw, r)
+	handler.ServeHTTP(wrw, r)
 
 	if wrw.isSuccessfull() {
 		w.postprocess(r, remote)
@@ -540,16 +566,45 @@ func (h Handle) DeadProps() (map[xml.Name]webdav.Property, error) {
 	property.InnerXML = strconv.AppendInt(nil, h.Handle.Node().ModTime().Unix(), 10)
 	properties[xmlName] = property
 
+	// Report the quota of collections from About as in RFC 4331.
+	// These are live properties so are only reported when asked for
+	// by name, not for allprop.
+	if node := h.Handle.Node(); node.IsDir() && h.ctx.Value(quotaKey{}) != nil {
+		_, used, free := node.VFS().Statfs()
+		for _, quota := range []struct {
+			name  string
+			value int64
+		}{
+			{"quota-available-bytes", free},
+			{"quota-used-bytes", used},
+		} {
+			if quota.value < 0 {
+				continue
+			}
+			xmlName = xml.Name{Space: "DAV:", Local: quota.name}
+			properties[xmlName] = webdav.Property{
+				XMLName:  xmlName,
+				InnerXML: strconv.AppendInt(nil, quota.value, 10),
+			}
+		}
+	}
+
 	return properties, nil
 }
 
 // Patch changes modtime of the underlying resources, it returns ok for all properties, the error is from setModtime if any
+//
+// The quota properties are protected so if any are patched nothing
+// is changed, as PROPPATCH is atomic.
 // FIXME does not check for invalid property and SetModTime error
 func (h Handle) Patch(proppatches []webdav.Proppatch) ([]webdav.Propstat, error) {
 	var (
 		stat webdav.Propstat
 		err  error
 	)
+	if forbidden := patchQuota(proppatches); forbidden != nil {
+		return forbidden, nil
+	}
 	stat.Status = http.StatusOK
 	for _, patch := range proppatches {
 		for _, prop := range patch.Props {
@@ -566,6 +621,86 @@ func (h Handle) Patch(proppatches []webdav.Proppatch) ([]webdav.Propstat, error)
 	return []webdav.Propstat{stat}, err
 }
 
+// quotaProps are the quota properties from RFC 4331
+var quotaProps = map[xml.Name]bool{
+	{Space: "DAV:", Local: "quota-available-bytes"}: true,
+	{Space: "DAV:", Local: "quota-used-bytes"}:      true,
+}
+
+// quotaKey is the context key set when a PROPFIND asks for the quota
+// properties
+type quotaKey struct{}
+
+// maxPropfindSize is the most of a PROPFIND body read to look for the
+// quota properties
+const maxPropfindSize = 1 << 20
+
+// propNames are the properties named in a prop or include element
+type propNames struct {
+	Names []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+// quotaRequested returns true if the body of the PROPFIND request r
+// asks for any of the quota properties by name.
+//
+// The body is put back so the webdav handler can read it.
+func quotaRequested(r *http.Request) bool {
+	if r.Body == nil {
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPropfindSize))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil {
+		return false
+	}
+	var propfind struct {
+		Prop    propNames `xml:"DAV: prop"`
+		Include propNames `xml:"DAV: include"`
+	}
+	if xml.Unmarshal(body, &propfind) != nil {
+		return false
+	}
+	for _, prop := range append(propfind.Prop.Names, propfind.Include.Names...) {
+		if quotaProps[prop.XMLName] {
+			return true
+		}
+	}
+	return false
+}
+
+// patchQuota returns the Propstats refusing proppatches if they change
+// any of the quota properties, or nil if they don't.
+func patchQuota(proppatches []webdav.Proppatch) []webdav.Propstat {
+	forbidden := webdav.Propstat{
+		Status:   http.StatusForbidden,
+		XMLError: `<D:cannot-modify-protected-property xmlns:D="DAV:"/>`,
+	}
+	failedDependency := webdav.Propstat{
+		Status: http.StatusFailedDependency,
+	}
+	for _, patch := range proppatches {
+		for _, prop := range patch.Props {
+			if quotaProps[prop.XMLName] {
+				forbidden.Props = append(forbidden.Props, webdav.Property{XMLName: prop.XMLName})
+			} else {
+				failedDependency.Props = append(failedDependency.Props, webdav.Property{XMLName: prop.XMLName})
+			}
+		}
+	}
+	if len(forbidden.Props) == 0 {
+		return nil
+	}
+	if len(failedDependency.Props) == 0 {
+		return []webdav.Propstat{forbidden}
+	}
+	return []webdav.Propstat{forbidden, failedDependency}
+}
+
 // FileInfo represents info about a file satisfying os.FileInfo and
 // also some additional interfaces for webdav for ETag and ContentType
 type FileInfo struct {
@@ -24,6 +24,8 @@ import (
 	"github.com/rclone/rclone/fs/config/obscure"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/net/webdav"
@@ -260,3 +262,97 @@ func HelpTestGET(t *testing.T, testURL string) {
 		checkGolden(t, test.Golden, body)
 	}
 }
+
+func TestLockAndQuota(t *testing.T) {
+	ctx := context.Background()
+	f, err := fs.NewFs(ctx, t.TempDir())
+	require.NoError(t, err)
+
+	opt := DefaultOpt
+	opt.HTTP.ListenAddr = []string{testBindAddress}
+	w, err := newWebDAV(ctx, f, &opt)
+	require.NoError(t, err)
+	require.NoError(t, w.serve())
+	defer func() {
+		assert.NoError(t, w.Shutdown())
+		w.Wait()
+	}()
+	testURL := w.Server.URLs()[0]
+
+	do := func(t *testing.T, method, URL, body string, headers map[string]string) (*http.Response, string) {
+		req, err := http.NewRequest(method, testURL+URL, strings.NewReader(body))
+		require.NoError(t, err)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp, string(respBody)
+	}
+
+	resp, _ := do(t, "OPTIONS", "file.txt", "", nil)
+	assert.Equal(t, "1, 2", resp.Header.Get("DAV"))
+
+	// Locking a file which doesn't exist creates it
+	const lockInfo = `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>`
+	resp, body := do(t, "LOCK", "file.txt", lockInfo, map[string]string{"Depth": "0"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode, body)
+	token := resp.Header.Get("Lock-Token")
+	require.NotEqual(t, "", token)
+	assert.Contains(t, body, "lockdiscovery")
+
+	// Another client can't lock or write it
+	resp, _ = do(t, "LOCK", "file.txt", lockInfo, map[string]string{"Depth": "0"})
+	assert.Equal(t, http.StatusLocked, resp.StatusCode)
+	resp, _ = do(t, "PUT", "file.txt", "intruder", nil)
+	assert.Equal(t, http.StatusLocked, resp.StatusCode)
+
+	// Nor can another user of the remote through their own VFS
+	vfsOpt := vfscommon.Opt
+	vfsOpt.DirCacheTime = fs.Duration(time.Hour + time.Second)
+	other := vfs.New(f, &vfsOpt)
+	defer other.Shutdown()
+	assert.Equal(t, vfs.ELOCKED, other.Remove("file.txt"))
+
+	// The lock holder can
+	resp, _ = do(t, "PUT", "file.txt", "hello", map[string]string{"If": "(" + token + ")"})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp, _ = do(t, "UNLOCK", "file.txt", "", map[string]string{"Lock-Token": token})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Empty(t, w.locks.systems, "lock system not evicted")
+	resp, _ = do(t, "PUT", "file.txt", "hello again", nil)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	fd, err := other.OpenFile("file.txt", os.O_WRONLY|os.O_TRUNC, 0777)
+	require.NoError(t, err)
+	require.NoError(t, fd.Close())
+
+	// Collections report their quota
+	const propFind = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:quota-available-bytes/><D:quota-used-bytes/></D:prop></D:propfind>`
+	resp, body = do(t, "PROPFIND", "", propFind, map[string]string{"Depth": "0"})
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	if f.Features().About != nil {
+		assert.Regexp(t, `<D:quota-available-bytes>\d+</D:quota-available-bytes>`, body)
+		assert.Regexp(t, `<D:quota-used-bytes>\d+</D:quota-used-bytes>`, body)
+	}
+
+	// They are live properties so aren't in allprop
+	const allProp = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:allprop/></D:propfind>`
+	resp, body = do(t, "PROPFIND", "", allProp, map[string]string{"Depth": "0"})
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.NotContains(t, body, "quota-")
+
+	// and can't be changed
+	const propPatch = `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:"><D:set><D:prop><D:quota-used-bytes>0</D:quota-used-bytes><D:lastmodified>0</D:lastmodified></D:prop></D:set></D:propertyupdate>`
+	resp, body = do(t, "PROPPATCH", "file.txt", propPatch, nil)
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Regexp(t, `(?s)quota-used-bytes.*403 Forbidden.*cannot-modify-protected-property`, body)
+	assert.Regexp(t, `(?s)lastmodified.*424 Failed Dependency`, body)
+}
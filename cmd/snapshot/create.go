@@ -0,0 +1,113 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/sync"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/spf13/cobra"
+)
+
+var (
+	createEmptySrcDirs = false
+	timeNow            = time.Now // for testing
+)
+
+func init() {
+	cmdFlags := createCommand.Flags()
+	flags.BoolVarP(cmdFlags, &createEmptySrcDirs, "create-empty-src-dirs", "", createEmptySrcDirs, "Create empty source dirs in the snapshot", "")
+}
+
+var createCommand = &cobra.Command{
+	Use:   "create source:path dest:path",
+	Short: `Make a snapshot of source:path in the repository dest:path.`,
+	Long: `This copies the files in source:path which pass the filters into a
+new directory in dest:path named after the current time in UTC, then
+writes the manifest for the snapshot.
+
+The manifest is only written once the copy has succeeded, so failed
+snapshots don't appear in ` + "`rclone snapshot list`" + ` and are never used as
+the base of a later snapshot.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+	RunE: func(command *cobra.Command, args []string) error {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc := cmd.NewFsSrc(args)
+		cmd.Run(true, true, command, func() error {
+			m, err := Create(context.Background(), fsrc, args[1], createEmptySrcDirs)
+			if err != nil {
+				return err
+			}
+			fs.Logf(nil, "Created snapshot %s with %d files", m.Name, m.Files)
+			return nil
+		})
+		return nil
+	},
+}
+
+// Create makes a new snapshot of fsrc in the repository at dstRemote
+// returning its manifest.
+//
+// If the repository supports server-side copy then unchanged files
+// are copied from the newest snapshot.
+func Create(ctx context.Context, fsrc fs.Fs, dstRemote string, createEmptySrcDirs bool) (*Manifest, error) {
+	fdst, err := getRepo(ctx, dstRemote)
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := readManifests(ctx, fdst)
+	if err != nil {
+		return nil, err
+	}
+	now := timeNow().UTC()
+	m := &Manifest{
+		Name:   now.Format(nameFormat),
+		Time:   now,
+		Source: fs.ConfigString(fsrc),
+	}
+	if _, err := findSnapshot(manifests, m.Name); err == nil {
+		return nil, fmt.Errorf("snapshot %q already exists", m.Name)
+	}
+
+	fsnap, err := cache.Get(ctx, snapshotRemote(dstRemote, m.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to make snapshot directory: %w", err)
+	}
+	if len(manifests) > 0 && fdst.Features().Copy != nil {
+		parent := manifests[len(manifests)-1]
+		var ci *fs.ConfigInfo
+		ctx, ci = fs.AddConfig(ctx)
+		ci.CopyDest = []string{snapshotRemote(dstRemote, parent.Name)}
+		m.Parent = parent.Name
+		fs.Infof(fsnap, "Copying unchanged files from snapshot %s", parent.Name)
+	}
+	if err = sync.CopyDir(ctx, fsnap, fsrc, createEmptySrcDirs); err != nil {
+		return nil, fmt.Errorf("snapshot failed: %w", err)
+	}
+
+	// Count what was stored, ignoring the filters which have been
+	// applied already
+	err = walk.ListR(ctx, fsnap, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			m.Files++
+			m.Bytes += o.Size()
+		})
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrorDirNotFound) {
+		return nil, fmt.Errorf("failed to count snapshot: %w", err)
+	}
+	if err = writeManifest(ctx, fdst, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
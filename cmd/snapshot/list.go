@@ -0,0 +1,55 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+var listCommand = &cobra.Command{
+	Use:   "list dest:path",
+	Short: `List the snapshots in the repository dest:path.`,
+	Long: `This lists the snapshots in the repository oldest first, showing the
+name, the number of files, their total size and the source of each.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+	RunE: func(command *cobra.Command, args []string) error {
+		cmd.CheckArgs(1, 1, command, args)
+		cmd.Run(false, false, command, func() error {
+			return List(context.Background(), args[0], os.Stdout)
+		})
+		return nil
+	},
+}
+
+// List writes the snapshots in the repository at dstRemote to out
+func List(ctx context.Context, dstRemote string, out io.Writer) error {
+	fdst, err := getRepo(ctx, dstRemote)
+	if err != nil {
+		return err
+	}
+	manifests, err := readManifests(ctx, fdst)
+	if err != nil {
+		return err
+	}
+	ci := fs.GetConfig(ctx)
+	for _, m := range manifests {
+		_, err = fmt.Fprintf(out, "%s %s %s %s\n",
+			m.Name,
+			operations.CountStringField(m.Files, ci.HumanReadable, 9),
+			operations.SizeStringField(m.Bytes, ci.HumanReadable, 9),
+			m.Source)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
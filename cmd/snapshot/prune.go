@@ -0,0 +1,150 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+// Policy says which snapshots to keep when pruning
+type Policy struct {
+	Last    int // keep the newest Last snapshots
+	Daily   int // keep the newest snapshot of each of the last Daily days
+	Weekly  int // keep the newest snapshot of each of the last Weekly weeks
+	Monthly int // keep the newest snapshot of each of the last Monthly months
+	Yearly  int // keep the newest snapshot of each of the last Yearly years
+}
+
+var policy Policy
+
+func init() {
+	cmdFlags := pruneCommand.Flags()
+	flags.IntVarP(cmdFlags, &policy.Last, "keep-last", "", policy.Last, "Keep the newest N snapshots", "")
+	flags.IntVarP(cmdFlags, &policy.Daily, "keep-daily", "", policy.Daily, "Keep the newest snapshot of each of the last N days", "")
+	flags.IntVarP(cmdFlags, &policy.Weekly, "keep-weekly", "", policy.Weekly, "Keep the newest snapshot of each of the last N weeks", "")
+	flags.IntVarP(cmdFlags, &policy.Monthly, "keep-monthly", "", policy.Monthly, "Keep the newest snapshot of each of the last N months", "")
+	flags.IntVarP(cmdFlags, &policy.Yearly, "keep-yearly", "", policy.Yearly, "Keep the newest snapshot of each of the last N years", "")
+}
+
+var pruneCommand = &cobra.Command{
+	Use:   "prune dest:path",
+	Short: `Remove the snapshots in dest:path the retention policy doesn't keep.`,
+	Long: `This removes every snapshot which isn't kept by at least one of the
+` + "`--keep-*`" + ` flags. At least one of them must be given.
+
+For example, to keep the last 3 snapshots and one a day for a week,
+one a week for a month and one a month for a year
+
+    rclone snapshot prune remote:backup --keep-last 3 --keep-daily 7 --keep-weekly 4 --keep-monthly 12
+
+Days, weeks (starting on Monday), months and years are in local time.
+If there is more than one snapshot in a period the newest is kept.
+Periods with no snapshots don't count towards the limits.
+
+Use ` + "`--dry-run`" + ` to see which snapshots would be removed.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+	RunE: func(command *cobra.Command, args []string) error {
+		cmd.CheckArgs(1, 1, command, args)
+		cmd.Run(false, true, command, func() error {
+			_, err := Prune(context.Background(), args[0], policy)
+			return err
+		})
+		return nil
+	},
+}
+
+// bucket returns a function returning the period t is in
+func bucket(format func(t time.Time) string) func(t time.Time) string {
+	return func(t time.Time) string {
+		return format(t.Local())
+	}
+}
+
+// keep returns the names of the snapshots policy keeps out of
+// manifests, which should be sorted oldest first.
+func (policy Policy) keep(manifests []*Manifest) map[string]bool {
+	keep := map[string]bool{}
+	for _, rule := range []struct {
+		n      int
+		period func(t time.Time) string
+	}{
+		{policy.Last, func(t time.Time) string { return t.Format(time.RFC3339Nano) }},
+		{policy.Daily, bucket(func(t time.Time) string { return t.Format("2006-01-02") })},
+		{policy.Weekly, bucket(func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%04d-W%02d", year, week)
+		})},
+		{policy.Monthly, bucket(func(t time.Time) string { return t.Format("2006-01") })},
+		{policy.Yearly, bucket(func(t time.Time) string { return t.Format("2006") })},
+	} {
+		kept, last := 0, ""
+		for i := len(manifests) - 1; i >= 0 && kept < rule.n; i-- {
+			m := manifests[i]
+			if period := rule.period(m.Time); period != last {
+				keep[m.Name] = true
+				kept++
+				last = period
+			}
+		}
+	}
+	return keep
+}
+
+// Prune removes the snapshots in the repository at dstRemote which
+// policy doesn't keep, returning the ones removed.
+func Prune(ctx context.Context, dstRemote string, policy Policy) (removed []*Manifest, err error) {
+	if policy == (Policy{}) {
+		return nil, errors.New("need at least one --keep-* flag")
+	}
+	fdst, err := getRepo(ctx, dstRemote)
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := readManifests(ctx, fdst)
+	if err != nil {
+		return nil, err
+	}
+	keep := policy.keep(manifests)
+	var errs []error
+	for _, m := range manifests {
+		if keep[m.Name] {
+			fs.Debugf(m.Name, "Keeping snapshot")
+			continue
+		}
+		fs.Infof(m.Name, "Removing snapshot")
+		// Remove the manifest first so a partially removed
+		// snapshot is never listed or used as a parent.
+		if err := removeSnapshot(ctx, fdst, m); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove snapshot %q: %w", m.Name, err))
+			continue
+		}
+		removed = append(removed, m)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// removeSnapshot removes the manifest then the files of the snapshot m
+func removeSnapshot(ctx context.Context, fdst fs.Fs, m *Manifest) error {
+	o, err := fdst.NewObject(ctx, manifestRemote(m.Name))
+	if err != nil {
+		return err
+	}
+	if err = operations.DeleteFile(ctx, o); err != nil {
+		return err
+	}
+	err = operations.Purge(ctx, fdst, m.Name)
+	if errors.Is(err, fs.ErrorDirNotFound) {
+		err = nil
+	}
+	return err
+}
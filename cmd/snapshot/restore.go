@@ -0,0 +1,59 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/sync"
+	"github.com/spf13/cobra"
+)
+
+var restoreCommand = &cobra.Command{
+	Use:   "restore dest:path name target:path",
+	Short: `Restore a snapshot from the repository dest:path to target:path.`,
+	Long: `This copies the files in the snapshot called name which pass the
+filters to target:path. Use ` + "`latest`" + ` as the name to restore the
+newest snapshot.
+
+Files in target:path which aren't in the snapshot are left alone. Use
+` + "`rclone sync dest:path/name target:path`" + ` to make target:path match
+the snapshot exactly.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+	RunE: func(command *cobra.Command, args []string) error {
+		cmd.CheckArgs(3, 3, command, args)
+		ftarget := cmd.NewFsDir(args[2:])
+		cmd.Run(true, true, command, func() error {
+			return Restore(context.Background(), args[0], args[1], ftarget)
+		})
+		return nil
+	},
+}
+
+// Restore copies the snapshot called name in the repository at
+// dstRemote into ftarget
+func Restore(ctx context.Context, dstRemote string, name string, ftarget fs.Fs) error {
+	fdst, err := getRepo(ctx, dstRemote)
+	if err != nil {
+		return err
+	}
+	manifests, err := readManifests(ctx, fdst)
+	if err != nil {
+		return err
+	}
+	m, err := findSnapshot(manifests, name)
+	if err != nil {
+		return err
+	}
+	fsnap, err := cache.Get(ctx, snapshotRemote(dstRemote, m.Name))
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot %q: %w", m.Name, err)
+	}
+	fs.Infof(ftarget, "Restoring snapshot %s", m.Name)
+	return sync.CopyDir(ctx, ftarget, fsnap, false)
+}
@@ -0,0 +1,166 @@
+// Package snapshot provides the snapshot command.
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	commandDefinition.AddCommand(createCommand)
+	commandDefinition.AddCommand(listCommand)
+	commandDefinition.AddCommand(restoreCommand)
+	commandDefinition.AddCommand(pruneCommand)
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "snapshot <subcommand>",
+	Short: `Make, list, restore and prune point in time snapshots.`,
+	Long: `Rclone snapshot keeps timestamped copies of a source in a snapshot
+repository on a destination, together with a manifest for each one.
+
+    rclone snapshot create /home/user remote:backup
+    rclone snapshot list remote:backup
+    rclone snapshot restore remote:backup 2024-01-02T030405Z /tmp/restore
+    rclone snapshot prune remote:backup --keep-daily 7 --keep-weekly 4 --keep-monthly 12
+
+Each snapshot is a complete copy of the source in a directory named
+after the time it was taken, e.g. ` + "`remote:backup/2024-01-02T030405Z`" + `,
+so it can be read with any rclone command. The manifests are stored in
+` + "`remote:backup/" + manifestDir + "`" + `.
+
+If the destination supports server-side copy then files which haven't
+changed since the previous snapshot are copied server-side from it
+rather than transferred from the source (see ` + "`--copy-dest`" + `).
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.69",
+	},
+}
+
+const (
+	// manifestDir is the directory in the repository where the
+	// manifests are kept
+	manifestDir = ".snapshots"
+	// nameFormat is the time format used for snapshot names
+	nameFormat = "2006-01-02T150405Z"
+	// latest can be used instead of the name of the newest snapshot
+	latest = "latest"
+)
+
+// Manifest describes a snapshot
+type Manifest struct {
+	Name   string    `json:"name"`             // name of the snapshot directory
+	Time   time.Time `json:"time"`             // when the snapshot was started
+	Source string    `json:"source"`           // the source which was snapshotted
+	Parent string    `json:"parent,omitempty"` // name of the snapshot it was copied from if any
+	Files  int64     `json:"files"`            // number of files in the snapshot
+	Bytes  int64     `json:"bytes"`            // total size of the files
+}
+
+// manifestRemote returns the path of the manifest for name
+func manifestRemote(name string) string {
+	return path.Join(manifestDir, name+".json")
+}
+
+// snapshotRemote returns the remote string for the snapshot called
+// name in the repository at dstRemote
+func snapshotRemote(dstRemote, name string) string {
+	return fspath.JoinRootPath(dstRemote, name)
+}
+
+// readManifests reads the manifests in the repository fdst returning
+// them oldest first
+func readManifests(ctx context.Context, fdst fs.Fs) (manifests []*Manifest, err error) {
+	entries, err := fdst.List(ctx, manifestDir)
+	if errors.Is(err, fs.ErrorDirNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	for _, entry := range entries {
+		o, ok := entry.(fs.Object)
+		if !ok || !strings.HasSuffix(o.Remote(), ".json") {
+			continue
+		}
+		m, err := readManifest(ctx, o)
+		if err != nil {
+			fs.Errorf(o, "Ignoring snapshot manifest: %v", err)
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].Time.Before(manifests[j].Time)
+	})
+	return manifests, nil
+}
+
+// readManifest reads a single manifest
+func readManifest(ctx context.Context, o fs.Object) (m *Manifest, err error) {
+	in, err := operations.Open(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.CheckClose(in, &err)
+	m = new(Manifest)
+	if err = json.NewDecoder(in).Decode(m); err != nil {
+		return nil, err
+	}
+	if m.Name == "" || m.Name+".json" != path.Base(o.Remote()) {
+		return nil, errors.New("name doesn't match manifest")
+	}
+	return m, nil
+}
+
+// writeManifest writes m into the repository fdst
+func writeManifest(ctx context.Context, fdst fs.Fs, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = operations.Rcat(ctx, fdst, manifestRemote(m.Name), io.NopCloser(bytes.NewReader(data)), m.Time, nil)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot manifest: %w", err)
+	}
+	return nil
+}
+
+// findSnapshot finds the snapshot called name in manifests, which
+// may be "latest" for the newest one
+func findSnapshot(manifests []*Manifest, name string) (*Manifest, error) {
+	if len(manifests) > 0 && name == latest {
+		return manifests[len(manifests)-1], nil
+	}
+	for _, m := range manifests {
+		if m.Name == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("snapshot %q not found", name)
+}
+
+// getRepo returns the Fs for the repository at dstRemote
+func getRepo(ctx context.Context, dstRemote string) (fs.Fs, error) {
+	fdst, err := cache.Get(ctx, dstRemote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make snapshot repository %q: %w", dstRemote, err)
+	}
+	return fdst, nil
+}
@@ -0,0 +1,204 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	_ "github.com/rclone/rclone/backend/memory"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	t1 = fstest.Time("2001-02-03T04:05:06.499999999Z")
+	t2 = fstest.Time("2011-12-25T12:59:59.123456789Z")
+)
+
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+// setNow makes the snapshots think it is t until the test ends
+func setNow(t *testing.T, now time.Time) {
+	old := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = old })
+}
+
+func TestCreateListRestore(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	file1 := r.WriteFile("dir/file1", "file1 contents", t1)
+	file2 := r.WriteFile("file2", "file2 contents", t2)
+
+	setNow(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	m1, err := Create(ctx, r.Flocal, r.FremoteName, false)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-02T030405Z", m1.Name)
+	assert.Equal(t, int64(2), m1.Files)
+	assert.Equal(t, int64(28), m1.Bytes)
+
+	// Creating another with the same name fails
+	_, err = Create(ctx, r.Flocal, r.FremoteName, false)
+	assert.ErrorContains(t, err, "already exists")
+
+	// Change the source and make a second snapshot
+	file2b := r.WriteFile("file2", "file2 changed", t1)
+	file3 := r.WriteFile("file3", "file3", t1)
+	setNow(t, time.Date(2024, 1, 3, 3, 4, 5, 0, time.UTC))
+	m2, err := Create(ctx, r.Flocal, r.FremoteName, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), m2.Files)
+	if r.Fremote.Features().Copy != nil {
+		assert.Equal(t, m1.Name, m2.Parent)
+	}
+
+	// Check the snapshots are complete copies
+	checkSnapshot := func(name string, items ...fstest.Item) {
+		fsnap, err := cache.Get(ctx, snapshotRemote(r.FremoteName, name))
+		require.NoError(t, err)
+		fstest.CheckListingWithPrecision(t, fsnap, items, nil, fs.GetModifyWindow(ctx, fsnap))
+	}
+	checkSnapshot(m1.Name, file1, file2)
+	checkSnapshot(m2.Name, file1, file2b, file3)
+
+	var out bytes.Buffer
+	require.NoError(t, List(ctx, r.FremoteName, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], m1.Name), lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], m2.Name), lines[1])
+	assert.Contains(t, lines[1], " 3 ")
+
+	// Restore the first snapshot into a new directory
+	ftarget, err := cache.Get(ctx, r.FremoteName+"/restored")
+	require.NoError(t, err)
+	require.NoError(t, Restore(ctx, r.FremoteName, m1.Name, ftarget))
+	fstest.CheckListingWithPrecision(t, ftarget, []fstest.Item{file1, file2}, nil, fs.GetModifyWindow(ctx, ftarget))
+
+	// Restoring latest gets the second one
+	require.NoError(t, Restore(ctx, r.FremoteName, latest, ftarget))
+	fstest.CheckListingWithPrecision(t, ftarget, []fstest.Item{file1, file2b, file3}, nil, fs.GetModifyWindow(ctx, ftarget))
+
+	assert.Error(t, Restore(ctx, r.FremoteName, "potato", ftarget))
+}
+
+// Test unchanged files are server-side copied from the parent
+// snapshot when the repository supports it
+func TestCreateServerSideCopy(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	repo := ":memory:snapshot-server-side-copy"
+	fdst, err := cache.Get(ctx, repo)
+	require.NoError(t, err)
+	require.NotNil(t, fdst.Features().Copy)
+	t.Cleanup(func() {
+		require.NoError(t, operations.Purge(ctx, fdst, ""))
+	})
+
+	file1 := r.WriteFile("dir/file1", "file1 contents", t1)
+	r.WriteFile("file2", "file2 contents", t2)
+	setNow(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	m1, err := Create(ctx, r.Flocal, repo, false)
+	require.NoError(t, err)
+	assert.Equal(t, "", m1.Parent)
+
+	// Change one file and make a second snapshot
+	file2b := r.WriteFile("file2", "file2 changed", t1)
+	setNow(t, time.Date(2024, 1, 3, 3, 4, 5, 0, time.UTC))
+	const group = "snapshot-server-side-copy"
+	m2, err := Create(accounting.WithStatsGroup(ctx, group), r.Flocal, repo, false)
+	require.NoError(t, err)
+	assert.Equal(t, m1.Name, m2.Parent)
+
+	// Only the unchanged file should have been copied server-side
+	stats, err := accounting.StatsGroup(ctx, group).RemoteStats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats["serverSideCopies"])
+	assert.Equal(t, file1.Size, stats["serverSideCopyBytes"])
+
+	fsnap, err := cache.Get(ctx, snapshotRemote(repo, m2.Name))
+	require.NoError(t, err)
+	fstest.CheckListingWithPrecision(t, fsnap, []fstest.Item{file1, file2b}, nil, fs.GetModifyWindow(ctx, fsnap))
+}
+
+// manifestsAt makes manifests for snapshots at the times given
+func manifestsAt(times ...string) (manifests []*Manifest) {
+	for _, s := range times {
+		tm := fstest.Time(s)
+		manifests = append(manifests, &Manifest{Name: tm.UTC().Format(nameFormat), Time: tm})
+	}
+	return manifests
+}
+
+// kept returns the names of manifests which policy keeps in order
+func kept(policy Policy, manifests []*Manifest) (names []string) {
+	keep := policy.keep(manifests)
+	for _, m := range manifests {
+		if keep[m.Name] {
+			names = append(names, m.Name)
+		}
+	}
+	return names
+}
+
+func TestPolicyKeep(t *testing.T) {
+	manifests := manifestsAt(
+		"2023-11-15T12:00:00Z",
+		"2023-12-20T12:00:00Z",
+		"2024-01-01T10:00:00Z",
+		"2024-01-01T12:00:00Z",
+		"2024-01-08T12:00:00Z",
+		"2024-01-09T12:00:00Z",
+		"2024-01-10T11:00:00Z",
+		"2024-01-10T12:00:00Z",
+	)
+	assert.Nil(t, kept(Policy{}, manifests))
+	assert.Equal(t, []string{"2024-01-10T110000Z", "2024-01-10T120000Z"}, kept(Policy{Last: 2}, manifests))
+	assert.Equal(t, []string{"2024-01-09T120000Z", "2024-01-10T120000Z"}, kept(Policy{Daily: 2}, manifests))
+	assert.Equal(t, []string{"2024-01-01T120000Z", "2024-01-10T120000Z"}, kept(Policy{Weekly: 2}, manifests))
+	assert.Equal(t, []string{"2023-11-15T120000Z", "2023-12-20T120000Z", "2024-01-10T120000Z"}, kept(Policy{Monthly: 5}, manifests))
+	assert.Equal(t, []string{"2023-12-20T120000Z", "2024-01-10T120000Z"}, kept(Policy{Yearly: 2}, manifests))
+	assert.Equal(t, []string{"2024-01-01T120000Z", "2024-01-09T120000Z", "2024-01-10T110000Z", "2024-01-10T120000Z"}, kept(Policy{Last: 2, Daily: 2, Weekly: 2}, manifests))
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteFile("file1", "file1 contents", t1)
+
+	var names []string
+	for _, day := range []int{1, 2, 3} {
+		setNow(t, time.Date(2024, 1, day, 12, 0, 0, 0, time.UTC))
+		m, err := Create(ctx, r.Flocal, r.FremoteName, false)
+		require.NoError(t, err)
+		names = append(names, m.Name)
+	}
+
+	_, err := Prune(ctx, r.FremoteName, Policy{})
+	assert.Error(t, err)
+
+	removed, err := Prune(ctx, r.FremoteName, Policy{Daily: 2})
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	assert.Equal(t, names[0], removed[0].Name)
+
+	fdst, err := getRepo(ctx, r.FremoteName)
+	require.NoError(t, err)
+	manifests, err := readManifests(ctx, fdst)
+	require.NoError(t, err)
+	require.Len(t, manifests, 2)
+	assert.Equal(t, names[1], manifests[0].Name)
+	assert.Equal(t, names[2], manifests[1].Name)
+	_, err = fdst.NewObject(ctx, names[0]+"/file1")
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+}
@@ -2,8 +2,10 @@ package accounting
 
 import (
 	"context"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rclone/rclone/fs"
 )
 
 var namespace = "rclone_"
@@ -110,3 +112,69 @@ func bool2Float(e bool) float64 {
 	}
 	return 0
 }
+
+// Metrics provide per remote metrics for the operations rclone does
+// on backends.
+type Metrics struct {
+	Calls    *prometheus.CounterVec
+	Duration *prometheus.HistogramVec
+}
+
+// NewMetrics creates a new metrics instance, the instance shall be
+// assigned to DefaultMetrics before any processing takes place.
+func NewMetrics(namespace string) *Metrics {
+	return &Metrics{
+		Calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "backend",
+			Name:      "calls_total",
+			Help:      "Number of backend operations by remote, method and result",
+		}, []string{"remote", "method", "result"}),
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "backend",
+			Name:      "call_duration_seconds",
+			Help:      "Time taken by backend operations by remote and method",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 4, 9),
+		}, []string{"remote", "method"}),
+	}
+}
+
+// DefaultMetrics specifies the metrics used by Observe.
+var DefaultMetrics = (*Metrics)(nil)
+
+// Collectors returns all prometheus metrics as collectors for registration.
+func (m *Metrics) Collectors() []prometheus.Collector {
+	if m == nil {
+		return nil
+	}
+	return []prometheus.Collector{
+		m.Calls,
+		m.Duration,
+	}
+}
+
+// Observe starts timing a call of method on the backend f. It
+// returns a function which should be called with the error the call
+// returned when it has finished.
+func (m *Metrics) Observe(f fs.Info, method string) func(err error) {
+	if m == nil {
+		return func(error) {}
+	}
+	start := time.Now()
+	return func(err error) {
+		remote := f.Name()
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+		m.Calls.WithLabelValues(remote, method, result).Inc()
+		m.Duration.WithLabelValues(remote, method).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Observe starts timing a call of method on the backend f using
+// DefaultMetrics. See Metrics.Observe.
+func Observe(f fs.Info, method string) func(err error) {
+	return DefaultMetrics.Observe(f, method)
+}
@@ -0,0 +1,32 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsObserve(t *testing.T) {
+	f, err := mockfs.NewFs(context.Background(), "mock", "root", nil)
+	require.NoError(t, err)
+
+	// Check nil metrics are OK
+	var nilMetrics *Metrics
+	nilMetrics.Observe(f, "List")(nil)
+	assert.Nil(t, nilMetrics.Collectors())
+
+	m := NewMetrics("test")
+	m.Observe(f, "List")(nil)
+	m.Observe(f, "List")(nil)
+	m.Observe(f, "Put")(errors.New("boom"))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.Calls.WithLabelValues("mock", "List", "ok")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.Calls.WithLabelValues("mock", "List", "error")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Calls.WithLabelValues("mock", "Put", "error")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.Duration))
+}
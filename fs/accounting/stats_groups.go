@@ -286,6 +286,12 @@ func StatsGroup(ctx context.Context, group string) *StatsInfo {
 	return stats
 }
 
+// StatsGroupIfExists gets the stats by group name or returns nil if
+// the group doesn't exist.
+func StatsGroupIfExists(group string) *StatsInfo {
+	return groups.get(group)
+}
+
 // GlobalStats returns special stats used for global accounting.
 func GlobalStats() *StatsInfo {
 	return StatsGroup(context.Background(), globalStats)
@@ -9,6 +9,9 @@ import (
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // TransferSnapshot represents state of an account at point in time.
@@ -65,6 +68,7 @@ type Transfer struct {
 	acc         *Account
 	err         error
 	completedAt time.Time
+	span        trace.Span // set if StartSpan was called
 }
 
 // newCheckingTransfer instantiates new checking of the object.
@@ -107,6 +111,9 @@ func (tr *Transfer) Done(ctx context.Context, err error) {
 	acc := tr.acc
 	tr.mu.RUnlock()
 
+	bytes, _ := acc.progress()
+	tr.endSpan(ctx, bytes, err)
+
 	ci := fs.GetConfig(ctx)
 	if acc != nil {
 		// Close the file if it is still open
@@ -131,6 +138,66 @@ func (tr *Transfer) Done(ctx context.Context, err error) {
 	tr.stats.PruneTransfers()
 }
 
+// spanName returns the name of the trace span for the transfer
+func (tr *Transfer) spanName() string {
+	if tr.checking {
+		return tr.what
+	}
+	return "transfer"
+}
+
+// spanAttributes returns the attributes for the trace span
+func (tr *Transfer) spanAttributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("rclone.transfer.name", tr.remote),
+		attribute.Int64("rclone.transfer.size", tr.size),
+		attribute.String("rclone.transfer.group", tr.stats.group),
+	}
+	if tr.srcFs != nil {
+		attrs = append(attrs, attribute.String("rclone.transfer.src_fs", fs.ConfigString(tr.srcFs)))
+	}
+	if tr.dstFs != nil {
+		attrs = append(attrs, attribute.String("rclone.transfer.dst_fs", fs.ConfigString(tr.dstFs)))
+	}
+	return attrs
+}
+
+// StartSpan starts tracing the transfer as a child of any span in
+// ctx. It returns a context which should be used for the calls the
+// transfer makes so that they are traced as part of it.
+//
+// Transfers which aren't started like this are traced when they are
+// Done, but their calls won't be part of their span.
+func (tr *Transfer) StartSpan(ctx context.Context) context.Context {
+	if !tracing.Enabled() {
+		return ctx
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.span != nil {
+		return trace.ContextWithSpan(ctx, tr.span)
+	}
+	ctx, tr.span = tracing.StartSpan(ctx, tr.spanName(), trace.WithTimestamp(tr.startedAt), trace.WithAttributes(tr.spanAttributes()...))
+	return ctx
+}
+
+// endSpan finishes the trace span of the transfer, making it first
+// if StartSpan wasn't called.
+func (tr *Transfer) endSpan(ctx context.Context, bytes int64, err error) {
+	tr.mu.Lock()
+	span := tr.span
+	tr.span = nil
+	tr.mu.Unlock()
+	if span == nil {
+		if !tracing.Enabled() {
+			return
+		}
+		_, span = tracing.StartSpan(ctx, tr.spanName(), trace.WithTimestamp(tr.startedAt), trace.WithAttributes(tr.spanAttributes()...))
+	}
+	span.SetAttributes(attribute.Int64("rclone.transfer.bytes", bytes))
+	tracing.EndSpan(span, err)
+}
+
 // Reset allows to switch the Account to another transfer method.
 func (tr *Transfer) Reset(ctx context.Context) {
 	tr.mu.RLock()
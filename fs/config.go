@@ -241,6 +241,11 @@ var ConfigOptionsInfo = Options{{
 	Default: false,
 	Help:    "Don't update directory modification times",
 	Groups:  "Copy",
+}, {
+	Name:    "name_transform",
+	Default: []string{},
+	Help:    "Transform paths during the copy process",
+	Groups:  "Copy",
 }, {
 	Name:    "compare_dest",
 	Default: []string{},
@@ -271,6 +276,21 @@ var ConfigOptionsInfo = Options{{
 	Default: false,
 	Help:    "Use recursive list if available; uses more memory but fewer transactions",
 	Groups:  "Listing",
+}, {
+	Name:    "list_index",
+	Default: false,
+	Help:    "Use the persistent listing index to avoid re-listing unchanged directories",
+	Groups:  "Listing",
+}, {
+	Name:    "list_index_max_age",
+	Default: time.Duration(0),
+	Help:    "Trust listings in the index younger than this without checking (0 to disable)",
+	Groups:  "Listing",
+}, {
+	Name:    "list_index_dir_fingerprint",
+	Default: false,
+	Help:    "Trust directory modtime/size/ID to detect unchanged directories in the index",
+	Groups:  "Listing",
 }, {
 	Name:    "tpslimit",
 	Default: 0.0,
@@ -442,6 +462,11 @@ var ConfigOptionsInfo = Options{{
 	Default: SizeSuffix(64 * 1024 * 1024),
 	Help:    "Chunk size for multi-thread downloads / uploads, if not set by filesystem",
 	Groups:  "Copy",
+}, {
+	Name:    "resume_uploads",
+	Default: false,
+	Help:    "Resume interrupted multipart uploads if the backend supports it",
+	Groups:  "Copy",
 }, {
 	Name:    "use_json_log",
 	Default: false,
@@ -572,12 +597,16 @@ type ConfigInfo struct {
 	NoUpdateModTime            bool              `config:"no_update_modtime"`
 	NoUpdateDirModTime         bool              `config:"no_update_dir_modtime"`
 	DataRateUnit               string            `config:"stats_unit"`
+	NameTransform              []string          `config:"name_transform"`
 	CompareDest                []string          `config:"compare_dest"`
 	CopyDest                   []string          `config:"copy_dest"`
 	BackupDir                  string            `config:"backup_dir"`
 	Suffix                     string            `config:"suffix"`
 	SuffixKeepExtension        bool              `config:"suffix_keep_extension"`
 	UseListR                   bool              `config:"fast_list"`
+	ListIndex                  bool              `config:"list_index"`
+	ListIndexMaxAge            time.Duration     `config:"list_index_max_age"`
+	ListIndexDirFingerprint    bool              `config:"list_index_dir_fingerprint"`
 	BufferSize                 SizeSuffix        `config:"buffer_size"`
 	BwLimit                    BwTimetable       `config:"bwlimit"`
 	BwLimitFile                BwTimetable       `config:"bwlimit_file"`
@@ -614,7 +643,8 @@ type ConfigInfo struct {
 	MultiThreadSet             bool              `config:"multi_thread_set"`        // whether MultiThreadStreams was set (set in fs/config/configflags)
 	MultiThreadChunkSize       SizeSuffix        `config:"multi_thread_chunk_size"` // Chunk size for multi-thread downloads / uploads, if not set by filesystem
 	MultiThreadWriteBufferSize SizeSuffix        `config:"multi_thread_write_buffer_size"`
-	OrderBy                    string            `config:"order_by"` // instructions on how to order the transfer
+	ResumeUploads              bool              `config:"resume_uploads"` // keep the state of multipart uploads so they can be resumed
+	OrderBy                    string            `config:"order_by"`       // instructions on how to order the transfer
 	UploadHeaders              []*HTTPOption     `config:"upload_headers"`
 	DownloadHeaders            []*HTTPOption     `config:"download_headers"`
 	Headers                    []*HTTPOption     `config:"headers"`
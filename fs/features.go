@@ -39,6 +39,7 @@ type Features struct {
 	NoMultiThreading         bool // set if can't have multiplethreads on one download open
 	Overlay                  bool // this wraps one or more backends to add functionality
 	ChunkWriterDoesntSeek    bool // set if the chunk writer doesn't need to read the data more than once
+	ChunkWriterCopies        bool // set if the chunk writer can copy chunks server-side (implements ChunkWriterCopier)
 
 	// Purge all files in the directory specified
 	//
@@ -382,6 +383,7 @@ func (ft *Features) Mask(ctx context.Context, f Fs) *Features {
 	ft.FilterAware = ft.FilterAware && mask.FilterAware
 	ft.PartialUploads = ft.PartialUploads && mask.PartialUploads
 	ft.NoMultiThreading = ft.NoMultiThreading && mask.NoMultiThreading
+	ft.ChunkWriterCopies = ft.ChunkWriterCopies && mask.ChunkWriterCopies
 	// ft.Overlay = ft.Overlay && mask.Overlay don't propagate Overlay
 
 	if mask.Purge == nil {
@@ -725,6 +727,38 @@ type ChunkWriter interface {
 	Abort(ctx context.Context) error
 }
 
+// ChunkWriterResumer is an optional interface for ChunkWriter
+//
+// ChunkWriters which implement it can carry on an upload started by an
+// earlier run of rclone if OpenChunkWriter is passed a ResumeOption.
+type ChunkWriterResumer interface {
+	// ResumeID returns the ID of the upload to pass in
+	// ResumeOption.ID to carry it on
+	ResumeID() string
+
+	// ChunkState returns what the backend needs to know about
+	// chunkNumber, which has been written successfully, to finish
+	// the upload when it is resumed. It is passed back in
+	// ResumeOption.Chunks. It should return "" if chunkNumber isn't
+	// part of the upload, for example if it was missing when the
+	// upload was resumed.
+	ChunkState(chunkNumber int) string
+}
+
+// ChunkWriterCopier is an optional interface for ChunkWriter
+//
+// ChunkWriters which implement it can write a chunk by copying part
+// of an existing object on the same remote server-side rather than
+// uploading the data. Backends which implement it should set the
+// ChunkWriterCopies feature so callers can tell before opening the
+// chunk writer.
+type ChunkWriterCopier interface {
+	// CopyChunk writes chunk number chunkNumber by copying length
+	// bytes starting at offset from src. It returns ErrorCantCopy
+	// if src can't be copied from.
+	CopyChunk(ctx context.Context, chunkNumber int, src Object, offset, length int64) (bytesWritten int64, err error)
+}
+
 // UserInfoer is an optional interface for Fs
 type UserInfoer interface {
 	// UserInfo returns info about the connected user
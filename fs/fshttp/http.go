@@ -16,7 +16,10 @@ import (
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/tracing"
 	"github.com/rclone/rclone/lib/structs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/publicsuffix"
 )
 
@@ -128,13 +131,14 @@ func NewTransportCustom(ctx context.Context, customize func(*http.Transport)) ht
 	}
 
 	// Wrap that http.Transport in our own transport
-	return newTransport(ci, t)
+	return newTransport(ctx, ci, t)
 }
 
 // NewTransport returns an http.RoundTripper with the correct timeouts
 func NewTransport(ctx context.Context) http.RoundTripper {
 	(*noTransport).Do(func() {
-		transport = NewTransportCustom(ctx, nil)
+		// This is shared between remotes so don't label it with one
+		transport = NewTransportCustom(fs.WithRemoteName(ctx, ""), nil)
 	})
 	return transport
 }
@@ -179,6 +183,7 @@ type Transport struct {
 	userAgent     string
 	headers       []*fs.HTTPOption
 	metrics       *Metrics
+	remote        string // name of the remote for the metrics
 	// Filename of the client cert in case we need to reload it
 	clientCert string
 	clientKey  string
@@ -188,13 +193,14 @@ type Transport struct {
 
 // newTransport wraps the http.Transport passed in and logs all
 // roundtrips including the body if logBody is set.
-func newTransport(ci *fs.ConfigInfo, transport *http.Transport) *Transport {
+func newTransport(ctx context.Context, ci *fs.ConfigInfo, transport *http.Transport) *Transport {
 	return &Transport{
 		Transport:  transport,
 		dump:       ci.Dump,
 		userAgent:  ci.UserAgent,
 		headers:    ci.Headers,
 		metrics:    DefaultMetrics,
+		remote:     fs.RemoteName(ctx),
 		clientCert: ci.ClientCert,
 		clientKey:  ci.ClientKey,
 	}
@@ -349,7 +355,19 @@ func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error
 		logMutex.Unlock()
 	}
 	// Do round trip
+	_, span := tracing.StartSpan(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+		))
+	start := time.Now()
 	resp, err = t.Transport.RoundTrip(req)
+	duration := time.Since(start)
+	if resp != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	tracing.EndSpan(span, err)
 	// Logf response
 	if t.dump&(fs.DumpHeaders|fs.DumpBodies|fs.DumpAuth|fs.DumpRequests|fs.DumpResponses) != 0 {
 		logMutex.Lock()
@@ -365,7 +383,7 @@ func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error
 		logMutex.Unlock()
 	}
 	// Update metrics
-	t.metrics.onResponse(req, resp)
+	t.metrics.onResponse(t.remote, req, resp, duration)
 
 	if err == nil {
 		checkServerTime(req, resp)
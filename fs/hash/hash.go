@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"hash"
 	"hash/crc32"
+	"hash/crc64"
 	"io"
 	"strings"
 
@@ -87,6 +88,21 @@ var (
 
 	// SHA256 indicates SHA-256 support
 	SHA256 Type
+
+	// CRC32C indicates CRC-32C (Castagnoli) support
+	CRC32C Type
+
+	// CRC64NVME indicates CRC-64/NVME support
+	CRC64NVME Type
+)
+
+var (
+	// crc32CTable is the table for the Castagnoli polynomial
+	crc32CTable = crc32.MakeTable(crc32.Castagnoli)
+
+	// crc64NVMETable is the table for the CRC-64/NVME polynomial
+	// as used by NVMe and the S3 x-amz-checksum-crc64nvme header
+	crc64NVMETable = crc64.MakeTable(0x9a6c9329ac4bc9b5)
 )
 
 func init() {
@@ -95,6 +111,8 @@ func init() {
 	Whirlpool = RegisterHash("whirlpool", "Whirlpool", 128, whirlpool.New)
 	CRC32 = RegisterHash("crc32", "CRC-32", 8, func() hash.Hash { return crc32.NewIEEE() })
 	SHA256 = RegisterHash("sha256", "SHA-256", 64, sha256.New)
+	CRC32C = RegisterHash("crc32c", "CRC-32C", 8, func() hash.Hash { return crc32.New(crc32CTable) })
+	CRC64NVME = RegisterHash("crc64nvme", "CRC-64/NVME", 16, func() hash.Hash { return crc64.New(crc64NVMETable) })
 }
 
 // Supported returns a set of all the supported hashes by
@@ -77,6 +77,8 @@ var hashTestSet = []hashTest{
 			hash.Whirlpool: "eddf52133d4566d763f716e853d6e4efbabd29e2c2e63f56747b1596172851d34c2df9944beb6640dbdbe3d9b4eb61180720a79e3d15baff31c91e43d63869a4",
 			hash.CRC32:     "a6041d7e",
 			hash.SHA256:    "c839e57675862af5c21bd0a15413c3ec579e0d5522dab600bc6c3489b05b8f54",
+			hash.CRC32C:    "4d8ae017",
+			hash.CRC64NVME: "6870c3fff5245563",
 		},
 	},
 	// Empty data set
@@ -88,6 +90,8 @@ var hashTestSet = []hashTest{
 			hash.Whirlpool: "19fa61d75522a4669b44e39c1d2e1726c530232130d407f89afee0964997f7a73e83be698b288febcf88e3e03c4f0757ea8964e59b63d93708b138cc42a66eb3",
 			hash.CRC32:     "00000000",
 			hash.SHA256:    "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			hash.CRC32C:    "00000000",
+			hash.CRC64NVME: "0000000000000000",
 		},
 	},
 }
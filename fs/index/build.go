@@ -0,0 +1,174 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/kv"
+	"golang.org/x/sync/errgroup"
+)
+
+// dirJob is a directory waiting to be listed
+type dirJob struct {
+	dir   string
+	entry fs.DirEntry // directory entry in the parent listing if known
+}
+
+// traverse lists dir and all the directories below it from the
+// remote, calling fn with each listing.
+//
+// The directories are listed --checkers at a time, one level at once.
+func (idx *Index) traverse(ctx context.Context, dir string, fn func(job dirJob, entries fs.DirEntries) error) error {
+	ci := fs.GetConfig(ctx)
+	level := []dirJob{{dir: dir}}
+	for len(level) > 0 {
+		var (
+			mu   sync.Mutex
+			next []dirJob
+		)
+		g, gCtx := errgroup.WithContext(ctx)
+		g.SetLimit(ci.Checkers)
+		for _, job := range level {
+			job := job
+			g.Go(func() error {
+				entries, err := idx.f.List(gCtx, job.dir)
+				if err != nil {
+					return fmt.Errorf("failed to list %q: %w", job.dir, err)
+				}
+				if err := fn(job, entries); err != nil {
+					return err
+				}
+				mu.Lock()
+				for _, entry := range entries {
+					if _, ok := entry.(fs.Directory); ok {
+						next = append(next, dirJob{dir: entry.Remote(), entry: entry})
+					}
+				}
+				mu.Unlock()
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+		level = next
+	}
+	return nil
+}
+
+// Build lists dir and everything below it from the remote and records
+// all the listings in the index.
+//
+// It returns the number of directories recorded.
+func (idx *Index) Build(ctx context.Context, dir string) (dirs int64, err error) {
+	var mu sync.Mutex
+	err = idx.traverse(ctx, dir, func(job dirJob, entries fs.DirEntries) error {
+		if err := idx.Put(ctx, job.dir, job.entry, entries); err != nil {
+			return fmt.Errorf("failed to record listing of %q: %w", job.dir, err)
+		}
+		mu.Lock()
+		dirs++
+		mu.Unlock()
+		return nil
+	})
+	return dirs, err
+}
+
+// Verify compares the listings in the index for dir and everything
+// below it with fresh listings from the remote.
+//
+// Listings which are out of date are logged and replaced and listings
+// of directories which no longer exist are removed.
+//
+// It returns the number of directories checked and the number which
+// were out of date.
+func (idx *Index) Verify(ctx context.Context, dir string) (dirs, changed int64, err error) {
+	var (
+		mu   sync.Mutex
+		seen = map[string]struct{}{}
+	)
+	err = idx.traverse(ctx, dir, func(job dirJob, entries fs.DirEntries) error {
+		ok, reason := idx.verifyDir(ctx, job.dir, entries)
+		mu.Lock()
+		dirs++
+		seen[job.dir] = struct{}{}
+		if !ok {
+			changed++
+		}
+		mu.Unlock()
+		if ok {
+			return nil
+		}
+		fs.Logf(job.dir, "Listing index out of date: %s", reason)
+		if err := idx.Put(ctx, job.dir, job.entry, entries); err != nil {
+			return fmt.Errorf("failed to record listing of %q: %w", job.dir, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return dirs, changed, err
+	}
+
+	// Remove the listings of directories which have gone away
+	indexed, err := idx.Dirs(ctx, dir)
+	if err != nil {
+		return dirs, changed, err
+	}
+	for _, indexedDir := range indexed {
+		if _, found := seen[indexedDir]; found {
+			continue
+		}
+		fs.Logf(indexedDir, "Listing index out of date: directory not found")
+		changed++
+		if err := idx.Invalidate(ctx, indexedDir, false); err != nil {
+			return dirs, changed, err
+		}
+	}
+	return dirs, changed, nil
+}
+
+// verifyDir checks the indexed listing of dir against entries,
+// returning a reason if they differ.
+func (idx *Index) verifyDir(ctx context.Context, dir string, entries fs.DirEntries) (ok bool, reason string) {
+	op := &kvGet{key: idx.key(dir)}
+	err := idx.db.Do(false, op)
+	if errors.Is(err, errNoRecord) || errors.Is(err, kv.ErrEmpty) {
+		return false, "not indexed"
+	} else if err != nil {
+		return false, err.Error()
+	}
+	records := make(map[string]*entryRecord, len(op.dir.Entries))
+	for i := range op.dir.Entries {
+		r := &op.dir.Entries[i]
+		records[r.Leaf] = r
+	}
+	for _, entry := range entries {
+		leaf := path.Base(entry.Remote())
+		r, found := records[leaf]
+		if !found {
+			return false, fmt.Sprintf("%q is missing", leaf)
+		}
+		delete(records, leaf)
+		switch x := entry.(type) {
+		case fs.Object:
+			if r.Dir {
+				return false, fmt.Sprintf("%q is now a file", leaf)
+			}
+			if fp := fs.Fingerprint(ctx, x, true); fp != r.Fp {
+				return false, fmt.Sprintf("%q has changed", leaf)
+			}
+		case fs.Directory:
+			if !r.Dir {
+				return false, fmt.Sprintf("%q is now a directory", leaf)
+			}
+		}
+	}
+	for leaf := range records {
+		return false, fmt.Sprintf("%q has been removed", leaf)
+	}
+	return true, ""
+}
@@ -0,0 +1,254 @@
+// Package index implements a persistent directory listing index
+//
+// The index records the unfiltered listing of each directory along
+// with fingerprints of its entries in a key-value database so that
+// directories which are proven unchanged don't need to be listed
+// again on the next run.
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/kv"
+)
+
+// Facility is the name of the key-value database facility used
+const Facility = "index"
+
+// ListFn lists a single directory of the remote unfiltered
+type ListFn func(ctx context.Context, dir string) (fs.DirEntries, error)
+
+// Index is a persistent directory listing index for an Fs
+type Index struct {
+	f              fs.Fs
+	db             *kv.DB
+	root           string        // key of the root of f
+	maxAge         time.Duration // trust records younger than this
+	dirFingerprint bool          // trust directory fingerprints
+}
+
+// Open the index for f
+//
+// The settings for trusting records are read from the config in ctx.
+// Call Close when finished with it.
+func Open(ctx context.Context, f fs.Fs) (*Index, error) {
+	if !kv.Supported() {
+		return nil, kv.ErrUnsupported
+	}
+	db, err := kv.Start(ctx, Facility, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open listing index: %w", err)
+	}
+	ci := fs.GetConfig(ctx)
+	return &Index{
+		f:              f,
+		db:             db,
+		root:           path.Join("/", f.Root()),
+		maxAge:         ci.ListIndexMaxAge,
+		dirFingerprint: ci.ListIndexDirFingerprint,
+	}, nil
+}
+
+// Close the index
+func (idx *Index) Close() error {
+	return idx.db.Stop(false)
+}
+
+// Path returns the path of the database file
+func (idx *Index) Path() string {
+	return idx.db.Path()
+}
+
+// key returns the database key for dir
+func (idx *Index) key(dir string) string {
+	return path.Join(idx.root, dir)
+}
+
+// DirFingerprint returns a fingerprint for a directory entry or "" if
+// there isn't enough information to say whether it changed.
+//
+// Directories without a modification time or an ID can't be
+// fingerprinted.
+func DirFingerprint(ctx context.Context, entry fs.DirEntry) string {
+	d, ok := entry.(fs.Directory)
+	if !ok {
+		return ""
+	}
+	if _, cached := entry.(*Dir); cached {
+		return ""
+	}
+	var modTime string
+	if t := d.ModTime(ctx); !t.Equal(time.Time(fs.GetConfig(ctx).DefaultTime)) {
+		modTime = t.UTC().String()
+	}
+	if d.ID() == "" && modTime == "" {
+		return ""
+	}
+	return fmt.Sprintf("%d,%d,%s,%s", d.Size(), d.Items(), modTime, d.ID())
+}
+
+// Get returns the indexed listing of dir if it can be trusted.
+//
+// entry is the directory entry for dir as seen in its parent listing
+// or nil if unknown. A listing is trusted if any of these hold
+//
+//   - it is younger than --list-index-max-age
+//   - a change watcher has been running since it was recorded
+//   - --list-index-dir-fingerprint is set and the fingerprint of
+//     entry matches the one recorded with the listing
+func (idx *Index) Get(ctx context.Context, dir string, entry fs.DirEntry) (entries fs.DirEntries, ok bool) {
+	key := idx.key(dir)
+	op := &kvGet{key: key}
+	err := idx.db.Do(false, op)
+	if err != nil {
+		if !errors.Is(err, errNoRecord) && !errors.Is(err, kv.ErrEmpty) {
+			fs.Debugf(dir, "index: couldn't read listing: %v", err)
+		}
+		return nil, false
+	}
+	switch {
+	case idx.maxAge > 0 && time.Since(op.dir.Created) < idx.maxAge:
+		fs.Debugf(dir, "index: using listing as younger than %v", idx.maxAge)
+	case op.watched:
+		fs.Debugf(dir, "index: using listing as watched for changes")
+	case idx.dirFingerprint && op.dir.Fp != "" && op.dir.Fp == DirFingerprint(ctx, entry):
+		fs.Debugf(dir, "index: using listing as directory fingerprint unchanged")
+	default:
+		return nil, false
+	}
+	return idx.entries(dir, op.dir.Entries), true
+}
+
+// entries converts the records into directory entries
+func (idx *Index) entries(dir string, records []entryRecord) fs.DirEntries {
+	entries := make(fs.DirEntries, 0, len(records))
+	for _, r := range records {
+		remote := path.Join(dir, r.Leaf)
+		if r.Dir {
+			d := fs.NewDir(remote, r.ModTime).SetSize(r.Size).SetItems(r.Items).SetID(r.ID)
+			entries = append(entries, &Dir{Dir: d})
+			continue
+		}
+		o := &Object{
+			f:       idx.f,
+			remote:  remote,
+			size:    r.Size,
+			modTime: r.ModTime,
+		}
+		if len(r.Hashes) > 0 {
+			o.hashes = make(map[hash.Type]string, len(r.Hashes))
+			for name, sum := range r.Hashes {
+				var ht hash.Type
+				if ht.Set(name) == nil {
+					o.hashes[ht] = sum
+				}
+			}
+		}
+		entries = append(entries, o)
+	}
+	return entries
+}
+
+// Put records the listing of dir in the index
+//
+// entry is the directory entry for dir as seen in its parent listing
+// or nil if unknown.
+func (idx *Index) Put(ctx context.Context, dir string, entry fs.DirEntry, entries fs.DirEntries) error {
+	features := idx.f.Features()
+	hashType := idx.f.Hashes().GetOne()
+	r := &dirRecord{
+		Fp:      DirFingerprint(ctx, entry),
+		Created: time.Now(),
+		Entries: make([]entryRecord, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		er := entryRecord{
+			Leaf:    path.Base(entry.Remote()),
+			Size:    entry.Size(),
+			ModTime: entry.ModTime(ctx),
+		}
+		switch x := entry.(type) {
+		case fs.Object:
+			er.Fp = fs.Fingerprint(ctx, x, true)
+			if hashType != hash.None && !features.SlowHash {
+				if sum, err := x.Hash(ctx, hashType); err == nil && sum != "" {
+					er.Hashes = map[string]string{hashType.String(): sum}
+				}
+			}
+		case fs.Directory:
+			er.Dir = true
+			er.Items = x.Items()
+			er.ID = x.ID()
+			er.Fp = DirFingerprint(ctx, x)
+		}
+		r.Entries = append(r.Entries, er)
+	}
+	return idx.db.Do(true, &kvPut{key: idx.key(dir), dir: r})
+}
+
+// ListDir lists dir using the index if the recorded listing can be
+// trusted, otherwise it lists with listFn and records the result.
+//
+// entry is the directory entry for dir as seen in its parent listing
+// or nil if unknown.
+func (idx *Index) ListDir(ctx context.Context, dir string, entry fs.DirEntry, listFn ListFn) (fs.DirEntries, error) {
+	if entries, ok := idx.Get(ctx, dir, entry); ok {
+		return entries, nil
+	}
+	entries, err := listFn(ctx, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrorDirNotFound) {
+			_ = idx.Invalidate(ctx, dir, true)
+		}
+		return nil, err
+	}
+	if err := idx.Put(ctx, dir, entry, entries); err != nil {
+		fs.Debugf(dir, "index: failed to record listing: %v", err)
+	}
+	return entries, nil
+}
+
+// Invalidate removes the listing of dir from the index and all the
+// listings below it if subtree is set.
+func (idx *Index) Invalidate(ctx context.Context, dir string, subtree bool) error {
+	err := idx.db.Do(true, &kvPurge{key: idx.key(dir), subtree: subtree})
+	if errors.Is(err, kv.ErrEmpty) {
+		err = nil
+	}
+	return err
+}
+
+// Dirs returns the directories below dir which have a listing in the
+// index, including dir itself. They are returned relative to the root
+// of the Fs.
+func (idx *Index) Dirs(ctx context.Context, dir string) ([]string, error) {
+	op := &kvKeys{key: idx.key(dir)}
+	err := idx.db.Do(false, op)
+	if errors.Is(err, kv.ErrEmpty) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dirs := make([]string, 0, len(op.keys))
+	for _, key := range op.keys {
+		rel := strings.TrimPrefix(strings.TrimPrefix(key, idx.root), "/")
+		dirs = append(dirs, rel)
+	}
+	return dirs, nil
+}
+
+// Drop removes the whole index database for the remote
+//
+// Note that this covers every path on the remote, not just the root
+// of the Fs. The index can't be used after this.
+func (idx *Index) Drop() error {
+	return idx.db.Stop(true)
+}
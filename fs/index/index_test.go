@@ -0,0 +1,208 @@
+package index
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/list"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	t1 = fstest.Time("2001-02-03T04:05:06.499999999Z")
+	t2 = fstest.Time("2011-12-25T12:59:59.123456789Z")
+)
+
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func openIndex(ctx context.Context, t *testing.T, f fs.Fs) *Index {
+	if !kv.Supported() {
+		t.Skip("index is not supported on this OS")
+	}
+	idx, err := Open(ctx, f)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, idx.Drop())
+	})
+	return idx
+}
+
+func TestListDirMaxAge(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	r.WriteFile("dir/file1", "hello", t1)
+	r.WriteFile("dir/file2", "hello world", t2)
+	idx := openIndex(ctx, t, r.Flocal)
+
+	// Nothing recorded yet
+	_, ok := idx.Get(ctx, "dir", nil)
+	assert.False(t, ok)
+
+	listed := 0
+	listFn := func(ctx context.Context, dir string) (fs.DirEntries, error) {
+		listed++
+		return r.Flocal.List(ctx, dir)
+	}
+	entries, err := idx.ListDir(ctx, "dir", nil, listFn)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, 1, listed)
+
+	// Not trusted without a max age
+	_, err = idx.ListDir(ctx, "dir", nil, listFn)
+	require.NoError(t, err)
+	assert.Equal(t, 2, listed)
+
+	// Trusted with a max age
+	ci.ListIndexMaxAge = time.Hour
+	idx.maxAge = ci.ListIndexMaxAge
+	entries, err = idx.ListDir(ctx, "dir", nil, listFn)
+	require.NoError(t, err)
+	assert.Equal(t, 2, listed)
+	entries, err = list.DirSortedFn(ctx, r.Flocal, false, "dir", func(ctx context.Context, dir string) (fs.DirEntries, error) {
+		return entries, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+	o, ok := entries[1].(*Object)
+	require.True(t, ok)
+	assert.Equal(t, "dir/file2", o.Remote())
+	assert.Equal(t, int64(11), o.Size())
+	assert.True(t, t2.Equal(o.ModTime(ctx)))
+
+	// The object can be opened
+	in, err := o.Open(ctx)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.NotNil(t, o.UnWrap())
+
+	// Invalidating removes the listing
+	require.NoError(t, idx.Invalidate(ctx, "", true))
+	_, ok = idx.Get(ctx, "dir", nil)
+	assert.False(t, ok)
+}
+
+func TestListDirFingerprint(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteFile("dir/file1", "hello", t1)
+	idx := openIndex(ctx, t, r.Flocal)
+	idx.dirFingerprint = true
+
+	entry := fs.NewDir("dir", t1).SetID("id1")
+	require.NoError(t, idx.Put(ctx, "dir", entry, fs.DirEntries{}))
+
+	// Same fingerprint is trusted
+	_, ok := idx.Get(ctx, "dir", fs.NewDir("dir", t1).SetID("id1"))
+	assert.True(t, ok)
+
+	// Changed fingerprint is not
+	_, ok = idx.Get(ctx, "dir", fs.NewDir("dir", t2).SetID("id1"))
+	assert.False(t, ok)
+
+	// Nor is an entry from the index
+	_, ok = idx.Get(ctx, "dir", &Dir{Dir: fs.NewDir("dir", t1).SetID("id1")})
+	assert.False(t, ok)
+
+	// Nor an unknown entry
+	_, ok = idx.Get(ctx, "dir", nil)
+	assert.False(t, ok)
+}
+
+func TestDirFingerprint(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "", DirFingerprint(ctx, nil))
+	assert.Equal(t, "", DirFingerprint(ctx, fs.NewDir("dir", time.Time{})))
+	assert.NotEqual(t, "", DirFingerprint(ctx, fs.NewDir("dir", t1)))
+	assert.NotEqual(t, "", DirFingerprint(ctx, fs.NewDir("dir", time.Time{}).SetID("id")))
+	assert.NotEqual(t, DirFingerprint(ctx, fs.NewDir("dir", t1)), DirFingerprint(ctx, fs.NewDir("dir", t2)))
+}
+
+func TestBuildVerify(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteFile("file0", "root", t1)
+	r.WriteFile("a/file1", "hello", t1)
+	r.WriteFile("a/b/file2", "hello world", t2)
+	idx := openIndex(ctx, t, r.Flocal)
+
+	dirs, err := idx.Build(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), dirs)
+
+	indexed, err := idx.Dirs(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "a", "a/b"}, indexed)
+
+	dirs, changed, err := idx.Verify(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), dirs)
+	assert.Equal(t, int64(0), changed)
+
+	// Change a file and verify again
+	r.WriteFile("a/b/file2", "hello world!", t2)
+	dirs, changed, err = idx.Verify(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), dirs)
+	assert.Equal(t, int64(1), changed)
+
+	// Verify fixed it
+	_, changed, err = idx.Verify(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), changed)
+
+	// Subtree invalidation
+	require.NoError(t, idx.Invalidate(ctx, "a", true))
+	indexed, err = idx.Dirs(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{""}, indexed)
+}
+
+func TestChangeNotify(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteFile("a/b/file", "hello", t1)
+	idx := openIndex(ctx, t, r.Flocal)
+
+	_, err := idx.Build(ctx, "")
+	require.NoError(t, err)
+
+	// A file change invalidates its parent only
+	idx.changeNotify(ctx, "a/b/file", fs.EntryObject)
+	indexed, err := idx.Dirs(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "a"}, indexed)
+
+	// A directory change invalidates its parent and subtree
+	_, err = idx.Build(ctx, "")
+	require.NoError(t, err)
+	idx.changeNotify(ctx, "a", fs.EntryDirectory)
+	indexed, err = idx.Dirs(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(indexed))
+}
+
+func TestWatchRecordCovers(t *testing.T) {
+	now := time.Now()
+	w := &watchRecord{
+		Root:     "/root",
+		Started:  now.Add(-time.Minute),
+		Seen:     now,
+		Interval: time.Minute,
+	}
+	assert.True(t, w.covers("/root", now))
+	assert.True(t, w.covers("/root/dir", now))
+	assert.False(t, w.covers("/rootdir", now))
+	assert.False(t, w.covers("/root/dir", now.Add(-time.Hour)))
+	w.Seen = now.Add(-time.Hour)
+	assert.False(t, w.covers("/root/dir", now))
+}
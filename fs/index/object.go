@@ -0,0 +1,153 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// Dir is a directory read from the index rather than from the remote
+type Dir struct {
+	*fs.Dir
+}
+
+// Object is an object read from the index rather than from the remote
+//
+// The attributes which were recorded in the index are returned
+// directly. Any operation which needs the real object looks it up
+// on the remote with NewObject the first time it is needed.
+type Object struct {
+	f       fs.Fs
+	remote  string
+	size    int64
+	modTime time.Time
+	hashes  map[hash.Type]string
+
+	mu  sync.Mutex
+	obj fs.Object // the real object once it has been looked up
+}
+
+// Fs returns the Fs this object is part of
+func (o *Object) Fs() fs.Info {
+	return o.f
+}
+
+// String returns a description of the Object
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.remote
+}
+
+// ModTime returns the modification date of the file as recorded in the index
+func (o *Object) ModTime(ctx context.Context) time.Time {
+	return o.modTime
+}
+
+// Size returns the size of the file as recorded in the index
+func (o *Object) Size() int64 {
+	return o.size
+}
+
+// Storable returns whether the object is storable
+func (o *Object) Storable() bool {
+	return true
+}
+
+// Hash returns the requested hash, from the index if possible
+func (o *Object) Hash(ctx context.Context, ht hash.Type) (string, error) {
+	if sum, ok := o.hashes[ht]; ok {
+		return sum, nil
+	}
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+	return obj.Hash(ctx, ht)
+}
+
+// resolve looks up the real object on the remote
+func (o *Object) resolve(ctx context.Context) (fs.Object, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.obj != nil {
+		return o.obj, nil
+	}
+	obj, err := o.f.NewObject(ctx, o.remote)
+	if err != nil {
+		return nil, fmt.Errorf("index: failed to find indexed object: %w", err)
+	}
+	o.obj = obj
+	return obj, nil
+}
+
+// SetModTime sets the modification time of the object on the remote
+func (o *Object) SetModTime(ctx context.Context, t time.Time) error {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	err = obj.SetModTime(ctx, t)
+	if err == nil {
+		o.modTime = t
+	}
+	return err
+}
+
+// Open opens the object on the remote for read
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Open(ctx, options...)
+}
+
+// Update the object on the remote with the contents of the io.Reader
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	err = obj.Update(ctx, in, src, options...)
+	if err == nil {
+		o.size = obj.Size()
+		o.modTime = obj.ModTime(ctx)
+		o.hashes = nil
+	}
+	return err
+}
+
+// Remove the object from the remote
+func (o *Object) Remove(ctx context.Context) error {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return obj.Remove(ctx)
+}
+
+// UnWrap returns the real object if it has been looked up already
+func (o *Object) UnWrap() fs.Object {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.obj
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Object          = (*Object)(nil)
+	_ fs.ObjectUnWrapper = (*Object)(nil)
+	_ fs.Directory       = (*Dir)(nil)
+)
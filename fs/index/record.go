@@ -0,0 +1,199 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/kv"
+)
+
+// watchPrefix starts the keys of the records describing change
+// watchers. It is followed by the directory key of the watched root.
+//
+// Directory keys always start with "/" so these can't clash.
+const watchPrefix = "watch:"
+
+// errNoRecord is returned when there is no usable record
+var errNoRecord = errors.New("no record")
+
+// entryRecord describes a single directory entry
+type entryRecord struct {
+	Leaf    string            // leaf name of the entry
+	Dir     bool              // set if this is a directory
+	Size    int64             // size of the entry, -1 if unknown
+	ModTime time.Time         // modification time of the entry
+	Items   int64             // number of items in a directory, -1 if unknown
+	ID      string            // optional ID of the entry
+	Fp      string            // fingerprint of the entry
+	Hashes  map[string]string // hashes which were cheap to read while listing
+}
+
+// dirRecord is a directory listing as stored in the database
+type dirRecord struct {
+	Fp      string        // fingerprint of the directory entry in its parent, "" if unknown
+	Created time.Time     // when the listing was taken
+	Entries []entryRecord // the unfiltered listing
+}
+
+// watchRecord describes a running change watcher
+type watchRecord struct {
+	Root     string        // directory key of the watched root
+	Started  time.Time     // when the watcher started
+	Seen     time.Time     // when the watcher was last known to be alive
+	Interval time.Duration // how often the watcher checks in
+}
+
+// covers returns true if the watcher was running at time t, is still
+// running and watches the directory with the key passed in
+func (w *watchRecord) covers(key string, t time.Time) bool {
+	if w.Interval <= 0 || t.Before(w.Started) || time.Since(w.Seen) >= 3*w.Interval {
+		return false
+	}
+	return w.Root == "/" || key == w.Root || strings.HasPrefix(key, w.Root+"/")
+}
+
+func encode(key string, v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		fs.Debugf(key, "index encoding %v: %v", v, err)
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decode(key string, data []byte, v interface{}) error {
+	if err := gob.NewDecoder(bytes.NewBuffer(data)).Decode(v); err != nil {
+		fs.Debugf(key, "index decoding %q failed: %v", data, err)
+		return err
+	}
+	return nil
+}
+
+// kvGet: read a directory record and the watcher records
+type kvGet struct {
+	key     string
+	dir     dirRecord
+	watched bool // set if a live watcher covers the record
+}
+
+func (op *kvGet) Do(ctx context.Context, b kv.Bucket) error {
+	data := b.Get([]byte(op.key))
+	if len(data) == 0 {
+		return errNoRecord
+	}
+	if err := decode(op.key, data, &op.dir); err != nil {
+		return errors.New("invalid record")
+	}
+	cur := b.Cursor()
+	bkey, data := cur.Seek([]byte(watchPrefix))
+	for bkey != nil && strings.HasPrefix(string(bkey), watchPrefix) {
+		var w watchRecord
+		if decode(string(bkey), data, &w) == nil && w.covers(op.key, op.dir.Created) {
+			op.watched = true
+			break
+		}
+		bkey, data = cur.Next()
+	}
+	return nil
+}
+
+// kvPut: store a directory record
+type kvPut struct {
+	key string
+	dir *dirRecord
+}
+
+func (op *kvPut) Do(ctx context.Context, b kv.Bucket) error {
+	data, err := encode(op.key, op.dir)
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+	if err = b.Put([]byte(op.key), data); err != nil {
+		return fmt.Errorf("put failed: %w", err)
+	}
+	return nil
+}
+
+// kvPurge: delete a directory record, optionally with its subtree
+type kvPurge struct {
+	key     string
+	subtree bool
+	num     int
+}
+
+func (op *kvPurge) Do(ctx context.Context, b kv.Bucket) error {
+	if err := b.Delete([]byte(op.key)); err != nil {
+		return err
+	}
+	op.num = 1
+	if !op.subtree {
+		return nil
+	}
+	prefix := op.key
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	var items [][]byte
+	cur := b.Cursor()
+	bkey, _ := cur.Seek([]byte(prefix))
+	for bkey != nil && strings.HasPrefix(string(bkey), prefix) {
+		items = append(items, append([]byte(nil), bkey...))
+		bkey, _ = cur.Next()
+	}
+	nerr := 0
+	for _, item := range items {
+		if err := b.Delete(item); err != nil {
+			nerr++
+		}
+	}
+	op.num += len(items) - nerr
+	fs.Debugf(op.key, "%d index records purged, %d failed", len(items)-nerr, nerr)
+	return nil
+}
+
+// kvWatch: update or remove a watcher record
+type kvWatch struct {
+	watch  *watchRecord
+	remove bool
+}
+
+func (op *kvWatch) Do(ctx context.Context, b kv.Bucket) error {
+	key := watchPrefix + op.watch.Root
+	if op.remove {
+		return b.Delete([]byte(key))
+	}
+	data, err := encode(key, op.watch)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), data)
+}
+
+// kvKeys: read the directory keys of a directory and its subtree
+type kvKeys struct {
+	key  string
+	keys []string
+}
+
+func (op *kvKeys) Do(ctx context.Context, b kv.Bucket) error {
+	prefix := op.key
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+		if b.Get([]byte(op.key)) != nil {
+			op.keys = append(op.keys, op.key)
+		}
+	}
+	cur := b.Cursor()
+	bkey, _ := cur.Seek([]byte(prefix))
+	for bkey != nil && strings.HasPrefix(string(bkey), prefix) {
+		op.keys = append(op.keys, string(bkey))
+		bkey, _ = cur.Next()
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"path"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// Watch keeps the index up to date using the ChangeNotify feature of
+// the remote until ctx is cancelled.
+//
+// While the watcher is running, listings recorded after it started
+// are trusted by other users of the index as any change to them will
+// have invalidated them.
+func (idx *Index) Watch(ctx context.Context, pollInterval time.Duration) error {
+	doChangeNotify := idx.f.Features().ChangeNotify
+	if doChangeNotify == nil {
+		return errors.New("remote doesn't support change notification so can't be watched")
+	}
+	if pollInterval <= 0 {
+		return errors.New("poll interval must be positive")
+	}
+	w := &watchRecord{
+		Root:     idx.root,
+		Started:  time.Now(),
+		Seen:     time.Now(),
+		Interval: pollInterval,
+	}
+	if err := idx.db.Do(true, &kvWatch{watch: w}); err != nil {
+		return err
+	}
+	defer func() {
+		_ = idx.db.Do(true, &kvWatch{watch: w, remove: true})
+	}()
+
+	pollChan := make(chan time.Duration)
+	doChangeNotify(ctx, func(relativePath string, entryType fs.EntryType) {
+		idx.changeNotify(ctx, relativePath, entryType)
+	}, pollChan)
+	pollChan <- pollInterval
+	fs.Logf(idx.f, "Watching for changes to keep the listing index up to date, polling every %v", pollInterval)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			close(pollChan)
+			return nil
+		case <-ticker.C:
+			w.Seen = time.Now()
+			if err := idx.db.Do(true, &kvWatch{watch: w}); err != nil {
+				fs.Errorf(idx.f, "index: failed to update watcher: %v", err)
+			}
+		}
+	}
+}
+
+// changeNotify invalidates the listings affected by a change to
+// relativePath.
+//
+// The parent directory is always invalidated and if the change is to a
+// directory then its whole subtree is too as it may have been renamed.
+func (idx *Index) changeNotify(ctx context.Context, relativePath string, entryType fs.EntryType) {
+	fs.Debugf(relativePath, "index: invalidating %v", entryType)
+	parent := path.Dir(relativePath)
+	if parent == "." {
+		parent = ""
+	}
+	if err := idx.Invalidate(ctx, parent, false); err != nil {
+		fs.Errorf(parent, "index: failed to invalidate listing: %v", err)
+	}
+	if entryType == fs.EntryDirectory {
+		if err := idx.Invalidate(ctx, relativePath, true); err != nil {
+			fs.Errorf(relativePath, "index: failed to invalidate listing: %v", err)
+		}
+	}
+}
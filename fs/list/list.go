@@ -8,7 +8,11 @@ import (
 	"strings"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // DirSorted reads Object and *Dir into entries for the given Fs.
@@ -20,8 +24,18 @@ import (
 //
 // Files will be returned in sorted order
 func DirSorted(ctx context.Context, f fs.Fs, includeAll bool, dir string) (entries fs.DirEntries, err error) {
+	return DirSortedFn(ctx, f, includeAll, dir, f.List)
+}
+
+// DirSortedFn is like DirSorted but reads the unfiltered entries with
+// listFn rather than f.List.
+func DirSortedFn(ctx context.Context, f fs.Fs, includeAll bool, dir string, listFn func(ctx context.Context, dir string) (fs.DirEntries, error)) (entries fs.DirEntries, err error) {
 	// Get unfiltered entries from the fs
-	entries, err = f.List(ctx, dir)
+	listCtx, span := tracing.StartSpan(ctx, "list", trace.WithAttributes(attribute.String("rclone.list.dir", dir)))
+	done := accounting.Observe(f, "List")
+	entries, err = listFn(listCtx, dir)
+	done(err)
+	tracing.EndSpan(span, err)
 	if err != nil {
 		return nil, err
 	}
@@ -5,8 +5,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fstest/mockdir"
+	"github.com/rclone/rclone/fstest/mockfs"
 	"github.com/rclone/rclone/fstest/mockobject"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -104,3 +107,21 @@ func TestFilterAndSortUnknown(t *testing.T) {
 	assert.Error(t, err, "error")
 	assert.Nil(t, newEntries)
 }
+
+func TestDirSortedFnMetrics(t *testing.T) {
+	ctx := context.Background()
+	f, err := mockfs.NewFs(ctx, "mock", "root", nil)
+	require.NoError(t, err)
+	oldMetrics := accounting.DefaultMetrics
+	accounting.DefaultMetrics = accounting.NewMetrics("test")
+	defer func() { accounting.DefaultMetrics = oldMetrics }()
+
+	// Listings made with a custom list function are counted too
+	listFn := func(ctx context.Context, dir string) (fs.DirEntries, error) {
+		return fs.DirEntries{mockobject.Object("A")}, nil
+	}
+	entries, err := DirSortedFn(ctx, f, true, "", listFn)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(accounting.DefaultMetrics.Calls.WithLabelValues("mock", "List", "ok")))
+}
@@ -12,8 +12,11 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/dirtree"
 	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/index"
 	"github.com/rclone/rclone/fs/list"
 	"github.com/rclone/rclone/fs/walk"
+	"github.com/rclone/rclone/lib/transform"
 	"golang.org/x/text/unicode/norm"
 )
 
@@ -31,10 +34,14 @@ type March struct {
 	Callback               Marcher         // object to call with results
 	NoCheckDest            bool            // transfer all objects regardless without checking dst
 	NoUnicodeNormalization bool            // don't normalize unicode characters in filenames
+	SrcMayChange           bool            // the callbacks may move or delete source entries
 	// internal state
-	srcListDir listDirFn // function to call to list a directory in the src
-	dstListDir listDirFn // function to call to list a directory in the dst
+	srcListDir listDirFn    // function to call to list a directory in the src
+	dstListDir listDirFn    // function to call to list a directory in the dst
+	srcIndex   *index.Index // persistent listing index for the src if in use
+	dstIndex   *index.Index // persistent listing index for the dst if in use
 	transforms []matchTransformFn
+	srcRename  renameFn      // set if --name-transform is in use
 	limiter    chan struct{} // make sure we don't do too many operations at once
 }
 
@@ -52,9 +59,15 @@ type Marcher interface {
 // Note: this will flag filter-aware backends on the source side
 func (m *March) init(ctx context.Context) {
 	ci := fs.GetConfig(ctx)
-	m.srcListDir = m.makeListDir(ctx, m.Fsrc, m.SrcIncludeAll)
+	if ci.ListIndex {
+		m.srcIndex = openIndex(ctx, m.Fsrc)
+		if !m.NoTraverse {
+			m.dstIndex = openIndex(ctx, m.Fdst)
+		}
+	}
+	m.srcListDir = m.makeListDir(ctx, m.Fsrc, m.SrcIncludeAll, m.srcIndex)
 	if !m.NoTraverse {
-		m.dstListDir = m.makeListDir(ctx, m.Fdst, m.DstIncludeAll)
+		m.dstListDir = m.makeListDir(ctx, m.Fdst, m.DstIncludeAll, m.dstIndex)
 	}
 	// Now create the matching transform
 	// ..normalise the UTF8 first
@@ -70,22 +83,61 @@ func (m *March) init(ctx context.Context) {
 	if m.Fdst.Features().CaseInsensitive || ci.IgnoreCaseSync {
 		m.transforms = append(m.transforms, strings.ToLower)
 	}
+	// ..if --name-transform is in use then compare the source names
+	// with the names they will have in the destination
+	if transform.Transforming(ctx) {
+		m.srcRename = func(entry fs.DirEntry) (string, error) {
+			_, isDir := entry.(fs.Directory)
+			return transform.Name(ctx, path.Base(entry.Remote()), isDir)
+		}
+	}
 	// Limit parallelism for operations
 	m.limiter = make(chan struct{}, ci.Checkers)
 }
 
+// openIndex opens the persistent listing index for f returning nil if
+// it couldn't be opened.
+func openIndex(ctx context.Context, f fs.Fs) *index.Index {
+	idx, err := index.Open(ctx, f)
+	if err != nil {
+		fs.Errorf(f, "Not using listing index: %v", err)
+		return nil
+	}
+	return idx
+}
+
+// closeIndexes closes any listing indexes in use
+func (m *March) closeIndexes() {
+	for _, idx := range []*index.Index{m.srcIndex, m.dstIndex} {
+		if idx != nil {
+			_ = idx.Close()
+		}
+	}
+}
+
 // list a directory into entries, err
-type listDirFn func(dir string) (entries fs.DirEntries, err error)
+//
+// entry is the directory entry for dir in its parent listing if known
+type listDirFn func(dir string, entry fs.DirEntry) (entries fs.DirEntries, err error)
 
 // makeListDir makes constructs a listing function for the given fs
 // and includeAll flags for marching through the file system.
 // Note: this will optionally flag filter-aware backends!
-func (m *March) makeListDir(ctx context.Context, f fs.Fs, includeAll bool) listDirFn {
+func (m *March) makeListDir(ctx context.Context, f fs.Fs, includeAll bool, idx *index.Index) listDirFn {
 	ci := fs.GetConfig(ctx)
 	fi := filter.GetConfig(ctx)
 	if !(ci.UseListR && f.Features().ListR != nil) && // !--fast-list active and
 		!(ci.NoTraverse && fi.HaveFilesFrom()) { // !(--files-from and --no-traverse)
-		return func(dir string) (entries fs.DirEntries, err error) {
+		if idx != nil {
+			// The index stores unfiltered listings so don't let
+			// filter-aware backends constrain List
+			return func(dir string, entry fs.DirEntry) (entries fs.DirEntries, err error) {
+				return list.DirSortedFn(m.Ctx, f, includeAll, dir, func(ctx context.Context, dir string) (fs.DirEntries, error) {
+					return idx.ListDir(ctx, dir, entry, f.List)
+				})
+			}
+		}
+		return func(dir string, entry fs.DirEntry) (entries fs.DirEntries, err error) {
 			dirCtx := filter.SetUseFilter(m.Ctx, f.Features().FilterAware && !includeAll) // make filter-aware backends constrain List
 			return list.DirSorted(dirCtx, f, includeAll, dir)
 		}
@@ -99,7 +151,10 @@ func (m *March) makeListDir(ctx context.Context, f fs.Fs, includeAll bool) listD
 		dirs    dirtree.DirTree
 		dirsErr error
 	)
-	return func(dir string) (entries fs.DirEntries, err error) {
+	if idx != nil {
+		fs.Debugf(f, "Not using listing index as the whole tree is listed at once")
+	}
+	return func(dir string, entry fs.DirEntry) (entries fs.DirEntries, err error) {
 		mu.Lock()
 		defer mu.Unlock()
 		if !started {
@@ -124,6 +179,8 @@ func (m *March) makeListDir(ctx context.Context, f fs.Fs, includeAll bool) listD
 type listDirJob struct {
 	srcRemote string
 	dstRemote string
+	srcEntry  fs.DirEntry // directory entry for srcRemote if known
+	dstEntry  fs.DirEntry // directory entry for dstRemote if known
 	srcDepth  int
 	dstDepth  int
 	noSrc     bool
@@ -135,6 +192,7 @@ func (m *March) Run(ctx context.Context) error {
 	ci := fs.GetConfig(ctx)
 	fi := filter.GetConfig(ctx)
 	m.init(ctx)
+	defer m.closeIndexes()
 
 	srcDepth := ci.MaxDepth
 	if srcDepth < 0 {
@@ -275,19 +333,35 @@ func (es matchEntries) sort() {
 }
 
 // make a matchEntries from a newMatch entries
-func newMatchEntries(entries fs.DirEntries, transforms []matchTransformFn) matchEntries {
-	es := make(matchEntries, len(entries))
-	for i := range es {
-		es[i].entry = entries[i]
-		name := path.Base(entries[i].Remote())
-		es[i].leaf = name
+//
+// If rename is set it is used to find the name of each entry before
+// the transforms are applied. Entries it can't rename are reported
+// and left out, returning the last error.
+func newMatchEntries(entries fs.DirEntries, rename renameFn, transforms []matchTransformFn) (es matchEntries, err error) {
+	es = make(matchEntries, 0, len(entries))
+	for _, entry := range entries {
+		leaf := path.Base(entry.Remote())
+		name := leaf
+		if rename != nil {
+			newName, renameErr := rename(entry)
+			if renameErr != nil {
+				err = fs.CountError(renameErr)
+				fs.Errorf(entry, "Skipping: %v", err)
+				continue
+			}
+			name = newName
+		}
 		for _, transform := range transforms {
 			name = transform(name)
 		}
-		es[i].name = name
+		es = append(es, matchEntry{
+			entry: entry,
+			leaf:  leaf,
+			name:  name,
+		})
 	}
 	es.sort()
-	return es
+	return es, err
 }
 
 // matchPair is a matched pair of direntries returned by matchListings
@@ -299,17 +373,25 @@ type matchPair struct {
 // comparison in matchListings.
 type matchTransformFn func(name string) string
 
+// renameFn returns the name the entry will have in the destination
+type renameFn func(entry fs.DirEntry) (string, error)
+
 // Process the two listings, matching up the items in the two slices
 // using the transform function on each name first.
 //
+// If srcRename is set it is applied to the names of the srcList only.
+//
 // Into srcOnly go Entries which only exist in the srcList
 // Into dstOnly go Entries which only exist in the dstList
 // Into matches go matchPair's of src and dst which have the same name
 //
 // This checks for duplicates and checks the list is sorted.
-func matchListings(srcListEntries, dstListEntries fs.DirEntries, transforms []matchTransformFn) (srcOnly fs.DirEntries, dstOnly fs.DirEntries, matches []matchPair) {
-	srcList := newMatchEntries(srcListEntries, transforms)
-	dstList := newMatchEntries(dstListEntries, transforms)
+//
+// If any of the srcList can't be renamed they are left out and an
+// error is returned along with the other results.
+func matchListings(srcListEntries, dstListEntries fs.DirEntries, srcRename renameFn, transforms []matchTransformFn) (srcOnly fs.DirEntries, dstOnly fs.DirEntries, matches []matchPair, err error) {
+	srcList, err := newMatchEntries(srcListEntries, srcRename, transforms)
+	dstList, _ := newMatchEntries(dstListEntries, nil, transforms)
 
 	for iSrc, iDst := 0, 0; ; iSrc, iDst = iSrc+1, iDst+1 {
 		var src, dst fs.DirEntry
@@ -377,6 +459,46 @@ func matchListings(srcListEntries, dstListEntries fs.DirEntries, transforms []ma
 	return
 }
 
+// dstMayChange returns true if the callbacks might alter the
+// destination directory, either because the listings differ or
+// because matching objects will be transferred anyway.
+func (m *March) dstMayChange(srcOnly, dstOnly fs.DirEntries, matches []matchPair) bool {
+	if len(srcOnly) > 0 || len(dstOnly) > 0 {
+		return true
+	}
+	ci := fs.GetConfig(m.Ctx)
+	window := fs.GetModifyWindow(m.Ctx, m.Fsrc, m.Fdst)
+	hashType := m.Fsrc.Hashes().Overlap(m.Fdst.Hashes()).GetOne()
+	for _, match := range matches {
+		srcObj, srcOK := match.src.(fs.Object)
+		dstObj, dstOK := match.dst.(fs.Object)
+		if !srcOK && !dstOK {
+			continue
+		}
+		if srcOK != dstOK || srcObj.Size() != dstObj.Size() {
+			return true
+		}
+		if ci.IgnoreTimes {
+			// --ignore-times transfers matching objects regardless
+			return true
+		}
+		if window != fs.ModTimeNotSupported {
+			dt := srcObj.ModTime(m.Ctx).Sub(dstObj.ModTime(m.Ctx))
+			if dt < -window || dt > window {
+				return true
+			}
+		}
+		if ci.CheckSum && hashType != hash.None {
+			srcSum, srcErr := srcObj.Hash(m.Ctx, hashType)
+			dstSum, dstErr := dstObj.Hash(m.Ctx, hashType)
+			if srcErr != nil || dstErr != nil || srcSum != dstSum {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // processJob processes a listDirJob listing the source and
 // destination directories, comparing them and returning a slice of
 // more jobs
@@ -396,14 +518,14 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			srcList, srcListErr = m.srcListDir(job.srcRemote)
+			srcList, srcListErr = m.srcListDir(job.srcRemote, job.srcEntry)
 		}()
 	}
 	if !m.NoTraverse && !job.noDst {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			dstList, dstListErr = m.dstListDir(job.dstRemote)
+			dstList, dstListErr = m.dstListDir(job.dstRemote, job.dstEntry)
 		}()
 	}
 
@@ -440,7 +562,15 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 				defer wg.Done()
 				if srcObj, ok := src.(fs.Object); ok {
 					leaf := path.Base(srcObj.Remote())
-					dstObj, err := m.Fdst.NewObject(m.Ctx, path.Join(job.dstRemote, leaf))
+					var err error
+					if m.srcRename != nil {
+						// errors are reported by matchListings
+						leaf, err = m.srcRename(srcObj)
+					}
+					var dstObj fs.Object
+					if err == nil {
+						dstObj, err = m.Fdst.NewObject(m.Ctx, path.Join(job.dstRemote, leaf))
+					}
 					if err == nil {
 						mu.Lock()
 						dstList = append(dstList, dstObj)
@@ -454,16 +584,37 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 	}
 
 	// Work out what to do and do it
-	srcOnly, dstOnly, matches := matchListings(srcList, dstList, m.transforms)
+	srcOnly, dstOnly, matches, renameErr := matchListings(srcList, dstList, m.srcRename, m.transforms)
+	if m.dstIndex != nil && !job.noDst && m.dstMayChange(srcOnly, dstOnly, matches) {
+		// The callbacks may alter the destination directory so
+		// don't trust the indexed listing next time
+		if err := m.dstIndex.Invalidate(m.Ctx, job.dstRemote, false); err != nil {
+			fs.Debugf(job.dstRemote, "Failed to invalidate listing index: %v", err)
+		}
+	}
+	if m.srcIndex != nil && !job.noSrc && m.SrcMayChange && len(srcList) > 0 {
+		// The callbacks may move or delete the source entries so
+		// don't trust the indexed listing next time
+		if err := m.srcIndex.Invalidate(m.Ctx, job.srcRemote, false); err != nil {
+			fs.Debugf(job.srcRemote, "Failed to invalidate listing index: %v", err)
+		}
+	}
 	for _, src := range srcOnly {
 		if m.aborting() {
 			return nil, m.Ctx.Err()
 		}
 		recurse := m.Callback.SrcOnly(src)
 		if recurse && job.srcDepth > 0 {
+			dstRemote, err := transform.Path(m.Ctx, src.Remote(), true)
+			if err != nil {
+				err = fs.CountError(err)
+				fs.Errorf(src, "Not recursing into directory: %v", err)
+				continue
+			}
 			jobs = append(jobs, listDirJob{
 				srcRemote: src.Remote(),
-				dstRemote: src.Remote(),
+				dstRemote: dstRemote,
+				srcEntry:  src,
 				srcDepth:  job.srcDepth - 1,
 				noDst:     true,
 			})
@@ -479,6 +630,7 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 			jobs = append(jobs, listDirJob{
 				srcRemote: dst.Remote(),
 				dstRemote: dst.Remote(),
+				dstEntry:  dst,
 				dstDepth:  job.dstDepth - 1,
 				noSrc:     true,
 			})
@@ -493,10 +645,12 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 			jobs = append(jobs, listDirJob{
 				srcRemote: match.src.Remote(),
 				dstRemote: match.dst.Remote(),
+				srcEntry:  match.src,
+				dstEntry:  match.dst,
 				srcDepth:  job.srcDepth - 1,
 				dstDepth:  job.dstDepth - 1,
 			})
 		}
 	}
-	return jobs, nil
+	return jobs, renameErr
 }
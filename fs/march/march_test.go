@@ -6,17 +6,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	_ "github.com/rclone/rclone/backend/local"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/index"
 	"github.com/rclone/rclone/fstest"
 	"github.com/rclone/rclone/fstest/mockdir"
 	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/rclone/rclone/lib/kv"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/text/unicode/norm"
@@ -216,6 +222,129 @@ func TestMarch(t *testing.T) {
 	}
 }
 
+func TestMarchListIndex(t *testing.T) {
+	if !kv.Supported() {
+		t.Skip("listing index is not supported on this OS")
+	}
+	r := fstest.NewRun(t)
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	ci.ListIndex = true
+	ci.ListIndexMaxAge = time.Hour
+
+	// Keep the index open between runs
+	idx, err := index.Open(ctx, r.Flocal)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, idx.Drop())
+	})
+
+	match := []fstest.Item{
+		r.WriteBoth(ctx, "match", "hello world", t1),
+		r.WriteBoth(ctx, "sub dir/match", "hello world", t1),
+	}
+
+	runMarch := func() *marchTester {
+		ctx, cancel := context.WithCancel(ctx)
+		mt := &marchTester{
+			ctx:    ctx,
+			cancel: cancel,
+		}
+		m := &March{
+			Ctx:      ctx,
+			Fdst:     r.Fremote,
+			Fsrc:     r.Flocal,
+			Callback: mt,
+		}
+		mt.processError(m.Run(ctx))
+		mt.cancel()
+		require.NoError(t, mt.currentError())
+		return mt
+	}
+	precision := fs.GetModifyWindow(ctx, r.Fremote, r.Flocal)
+
+	// First run records the listings
+	mt := runMarch()
+	fstest.CompareItems(t, mt.match, match, []string{"sub dir"}, precision, "match")
+	assert.Equal(t, 0, len(mt.srcOnly))
+
+	// Second run trusts the recorded listings so doesn't see the new file
+	newFile := r.WriteFile("sub dir/new", "hello world", t1)
+	mt = runMarch()
+	fstest.CompareItems(t, mt.match, match, []string{"sub dir"}, precision, "match")
+	assert.Equal(t, 0, len(mt.srcOnly))
+
+	// Without a max age the listings are not trusted
+	ci.ListIndexMaxAge = 0
+	mt = runMarch()
+	fstest.CompareItems(t, mt.srcOnly, []fstest.Item{newFile}, nil, precision, "srcOnly")
+}
+
+func TestMarchListIndexInvalidate(t *testing.T) {
+	if !kv.Supported() {
+		t.Skip("listing index is not supported on this OS")
+	}
+	r := fstest.NewRun(t)
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	ci.ListIndex = true
+	ci.ListIndexMaxAge = time.Hour
+
+	// Keep the indexes open between runs
+	for _, f := range []fs.Fs{r.Flocal, r.Fremote} {
+		idx, err := index.Open(ctx, f)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, idx.Drop())
+		})
+	}
+
+	match := r.WriteBoth(ctx, "sub dir/match", "hello world", t1)
+
+	runMarch := func(srcMayChange bool) *marchTester {
+		ctx, cancel := context.WithCancel(ctx)
+		mt := &marchTester{
+			ctx:    ctx,
+			cancel: cancel,
+		}
+		m := &March{
+			Ctx:          ctx,
+			Fdst:         r.Fremote,
+			Fsrc:         r.Flocal,
+			Callback:     mt,
+			SrcMayChange: srcMayChange,
+		}
+		mt.processError(m.Run(ctx))
+		mt.cancel()
+		require.NoError(t, mt.currentError())
+		return mt
+	}
+	precision := fs.GetModifyWindow(ctx, r.Fremote, r.Flocal)
+
+	t.Run("IgnoreTimes", func(t *testing.T) {
+		// With --ignore-times matching objects are transferred
+		// anyway so the destination listings must not be trusted
+		ci.IgnoreTimes = true
+		defer func() { ci.IgnoreTimes = false }()
+		mt := runMarch(false)
+		fstest.CompareItems(t, mt.match, []fstest.Item{match}, []string{"sub dir"}, precision, "match")
+		r.WriteObject(ctx, "sub dir/extra", "hello world", t1)
+		mt = runMarch(false)
+		assert.Equal(t, 1, len(mt.dstOnly))
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		// A move deletes the source objects so the source
+		// listings must not be trusted next time
+		mt := runMarch(true)
+		fstest.CompareItems(t, mt.match, []fstest.Item{match}, []string{"sub dir"}, precision, "match")
+		require.NoError(t, os.Remove(filepath.Join(r.LocalName, "sub dir", "match")))
+		mt = runMarch(true)
+		assert.Equal(t, 1, len(mt.match)) // just "sub dir"
+		assert.Equal(t, 2, len(mt.dstOnly))
+	})
+}
+
 func TestMarchNoTraverse(t *testing.T) {
 	for _, test := range []struct {
 		what        string
@@ -290,7 +419,8 @@ func TestNewMatchEntries(t *testing.T) {
 		c = mockobject.Object("path/c")
 	)
 
-	es := newMatchEntries(fs.DirEntries{a, A, B, c}, nil)
+	es, err := newMatchEntries(fs.DirEntries{a, A, B, c}, nil, nil)
+	require.NoError(t, err)
 	assert.Equal(t, es, matchEntries{
 		{name: "A", leaf: "A", entry: A},
 		{name: "B", leaf: "B", entry: B},
@@ -298,13 +428,28 @@ func TestNewMatchEntries(t *testing.T) {
 		{name: "c", leaf: "c", entry: c},
 	})
 
-	es = newMatchEntries(fs.DirEntries{a, A, B, c}, []matchTransformFn{strings.ToLower})
+	es, err = newMatchEntries(fs.DirEntries{a, A, B, c}, nil, []matchTransformFn{strings.ToLower})
+	require.NoError(t, err)
 	assert.Equal(t, es, matchEntries{
 		{name: "a", leaf: "A", entry: A},
 		{name: "a", leaf: "a", entry: a},
 		{name: "b", leaf: "B", entry: B},
 		{name: "c", leaf: "c", entry: c},
 	})
+
+	// Entries which can't be renamed are left out
+	rename := func(entry fs.DirEntry) (string, error) {
+		if entry == B {
+			return "", errors.New("bad name")
+		}
+		return "x" + path.Base(entry.Remote()), nil
+	}
+	es, err = newMatchEntries(fs.DirEntries{a, B, c}, rename, nil)
+	require.Error(t, err)
+	assert.Equal(t, es, matchEntries{
+		{name: "xa", leaf: "a", entry: a},
+		{name: "xc", leaf: "c", entry: c},
+	})
 }
 
 func TestMatchListings(t *testing.T) {
@@ -517,12 +662,14 @@ func TestMatchListings(t *testing.T) {
 					dstList = append(dstList, dst)
 				}
 			}
-			srcOnly, dstOnly, matches := matchListings(srcList, dstList, test.transforms)
+			srcOnly, dstOnly, matches, err := matchListings(srcList, dstList, nil, test.transforms)
+			require.NoError(t, err)
 			assert.Equal(t, test.srcOnly, srcOnly, test.what, "srcOnly differ")
 			assert.Equal(t, test.dstOnly, dstOnly, test.what, "dstOnly differ")
 			assert.Equal(t, test.matches, matches, test.what, "matches differ")
 			// now swap src and dst
-			dstOnly, srcOnly, matches = matchListings(dstList, srcList, test.transforms)
+			dstOnly, srcOnly, matches, err = matchListings(dstList, srcList, nil, test.transforms)
+			require.NoError(t, err)
 			assert.Equal(t, test.srcOnly, srcOnly, test.what, "srcOnly differ")
 			assert.Equal(t, test.dstOnly, dstOnly, test.what, "dstOnly differ")
 			assert.Equal(t, test.matches, matches, test.what, "matches differ")
@@ -64,13 +64,32 @@ func NewFs(ctx context.Context, path string) (Fs, error) {
 		overriddenConfig[suffix] = extraConfig
 		overriddenConfigMu.Unlock()
 	}
-	f, err := fsInfo.NewFs(ctx, configName, fsPath, config)
+	f, err := fsInfo.NewFs(WithRemoteName(ctx, configName), configName, fsPath, config)
 	if f != nil && (err == nil || err == ErrorIsFile) {
 		addReverse(f, fsInfo)
 	}
 	return f, err
 }
 
+type remoteNameKey struct{}
+
+// WithRemoteName returns a copy of ctx which notes that the backend
+// being made with it is the remote called name.
+//
+// NewFs calls this so that things the backend makes in its
+// constructor, such as HTTP clients and pacers, can label their
+// metrics with the remote name.
+func WithRemoteName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, remoteNameKey{}, name)
+}
+
+// RemoteName returns the name of the remote set with WithRemoteName
+// or "" if not set.
+func RemoteName(ctx context.Context) string {
+	name, _ := ctx.Value(remoteNameKey{}).(string)
+	return name
+}
+
 // ConfigFs makes the config for calling NewFs with.
 //
 // It parses the path which is of the form remote:path
@@ -43,3 +43,11 @@ func TestNewFs(t *testing.T) {
 	assert.Equal(t, ":mockfs{S_NHG}:/tmp", fs.ConfigString(f3))
 	assert.Equal(t, ":mockfs,potato='true':/tmp", fs.ConfigStringFull(f3))
 }
+
+func TestRemoteName(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "", fs.RemoteName(ctx))
+	ctx = fs.WithRemoteName(ctx, "potato")
+	assert.Equal(t, "potato", fs.RemoteName(ctx))
+	assert.Equal(t, "", fs.RemoteName(fs.WithRemoteName(ctx, "")))
+}
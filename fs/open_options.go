@@ -306,6 +306,43 @@ func (o *ChunkOption) String() string {
 	return fmt.Sprintf("ChunkOption(%v)", o.ChunkSize)
 }
 
+// ResumeOption asks OpenChunkWriter to carry on the upload with ID
+// rather than starting a new one. Chunks contains the ChunkState of
+// each chunk which has been written already, indexed by chunk number.
+//
+// If the upload can't be resumed, for example because it no longer
+// exists or the chunk size would be different from ChunkSize, then a
+// new one should be started and the ChunkWriter should return its ID
+// from ResumeID.
+//
+// If Abort is set then the source has changed since the upload with ID
+// was started, so it should be aborted, if it still exists, and a new
+// one started.
+type ResumeOption struct {
+	ID        string
+	ChunkSize int64
+	Chunks    map[int]string
+	Abort     bool
+}
+
+// Header formats the option as an http header
+func (o *ResumeOption) Header() (key string, value string) {
+	return "", ""
+}
+
+// Mandatory returns whether the option must be parsed or can be ignored
+func (o *ResumeOption) Mandatory() bool {
+	return false
+}
+
+// String formats the option into human-readable form
+func (o *ResumeOption) String() string {
+	if o.Abort {
+		return fmt.Sprintf("ResumeOption(%q, abort)", o.ID)
+	}
+	return fmt.Sprintf("ResumeOption(%q, %d chunks)", o.ID, len(o.Chunks))
+}
+
 // OpenOptionAddHeaders adds each header found in options to the
 // headers map provided the key was non empty.
 func OpenOptionAddHeaders(options []OpenOption, headers map[string]string) {
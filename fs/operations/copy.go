@@ -147,7 +147,9 @@ func (c *copy) serverSideCopy(ctx context.Context) (actionTaken string, newDst f
 	}
 	in := c.tr.Account(ctx, nil) // account the transfer
 	in.ServerSideTransferStart()
+	done := accounting.Observe(c.f, "Copy")
 	newDst, err = doCopy(ctx, c.src, c.remoteForCopy)
+	done(err)
 	if err == nil {
 		in.ServerSideCopyEnd(newDst.Size()) // account the bytes for the server-side transfer
 	}
@@ -208,13 +210,17 @@ func (c *copy) updateOrPut(ctx context.Context, in io.ReadCloser, uploadOptions
 		wrappedSrc = fs.NewOverrideRemote(c.src, c.remoteForCopy)
 	}
 	if c.doUpdate && c.inplace {
+		done := accounting.Observe(c.f, "Update")
 		err = c.dst.Update(ctx, inAcc, wrappedSrc, uploadOptions...)
+		done(err)
 		// Make sure newDst is c.dst since we updated it
 		if err == nil {
 			newDst = c.dst
 		}
 	} else {
+		done := accounting.Observe(c.f, "Put")
 		newDst, err = c.f.Put(ctx, inAcc, wrappedSrc, uploadOptions...)
+		done(err)
 	}
 	closeErr := inAcc.Close()
 	if err == nil {
@@ -381,6 +387,7 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 	defer func() {
 		tr.Done(ctx, err)
 	}()
+	ctx = tr.StartSpan(ctx)
 	if SkipDestructive(ctx, src, "copy") {
 		in := tr.Account(ctx, nil)
 		in.DryRun(src.Size())
@@ -125,6 +125,26 @@ func TestCopyFile(t *testing.T) {
 	r.CheckRemoteItems(t, file2)
 }
 
+func TestCopyFileNameTransform(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	ci.NameTransform = []string{"uppercase"}
+
+	err := operations.CopyFile(ctx, r.Fremote, r.Flocal, file1.Path, file1.Path)
+	require.NoError(t, err)
+	file1dst := file1
+	file1dst.Path = "FILE1"
+	r.CheckRemoteItems(t, file1dst)
+
+	ci.NameTransform = []string{"replace=1:/"}
+	err = operations.CopyFile(ctx, r.Fremote, r.Flocal, file1.Path, file1.Path)
+	require.Error(t, err)
+	r.CheckRemoteItems(t, file1dst)
+}
+
 // Find the longest file name for writing to local
 func maxLengthFileName(t *testing.T, r *fstest.Run) string {
 	require.NoError(t, r.Flocal.Mkdir(context.Background(), "")) // create the root
@@ -225,6 +225,12 @@ func TestListJSON(t *testing.T) {
 					if hashes["crc32"] != "" {
 						assert.Equal(t, "9ee760e5", hashes["crc32"])
 					}
+					if hashes["crc32c"] != "" {
+						assert.Equal(t, "31fcbd1b", hashes["crc32c"])
+					}
+					if hashes["crc64nvme"] != "" {
+						assert.Equal(t, "ff6ce158609a79b1", hashes["crc64nvme"])
+					}
 					if hashes["dropbox"] != "" {
 						assert.Equal(t, "f4d62afeaee6f35d3efdd8c66623360395165473bcc958f835343eb3f542f983", hashes["dropbox"])
 					}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	gohash "hash"
 	"io"
 	"time"
 
@@ -59,7 +60,8 @@ type multiThreadCopyState struct {
 	src         fs.Object
 	acc         *accounting.Account
 	numChunks   int
-	noBuffering bool // set to read the input without buffering
+	noBuffering bool               // set to read the input without buffering
+	resumer     *multipart.Resumer // saves the chunks written if resuming uploads
 }
 
 // Copy a single chunk into place
@@ -79,6 +81,12 @@ func (mc *multiThreadCopyState) copyChunk(ctx context.Context, chunk int, writer
 	}
 	size := end - start
 
+	// The source hasn't changed so trust chunks already written
+	if mc.resumer.Skip(chunk, size, "") {
+		fs.Debugf(mc.src, "multi-thread copy: chunk %d/%d (%d-%d) size %v skipped as already written", chunk+1, mc.numChunks, start, end, fs.SizeSuffix(size))
+		return mc.acc.AccountRead(int(size))
+	}
+
 	fs.Debugf(mc.src, "multi-thread copy: chunk %d/%d (%d-%d) size %v starting", chunk+1, mc.numChunks, start, end, fs.SizeSuffix(size))
 
 	rc, err := Open(ctx, mc.src, &fs.RangeOption{Start: start, End: end - 1})
@@ -87,7 +95,10 @@ func (mc *multiThreadCopyState) copyChunk(ctx context.Context, chunk int, writer
 	}
 	defer fs.CheckClose(rc, &err)
 
-	var rs io.ReadSeeker
+	var (
+		rs io.ReadSeeker
+		h  gohash.Hash
+	)
 	if mc.noBuffering {
 		// Read directly if we are sure we aren't going to seek
 		// and account with accounting
@@ -97,7 +108,11 @@ func (mc *multiThreadCopyState) copyChunk(ctx context.Context, chunk int, writer
 		// Read the chunk into buffered reader
 		rw := multipart.NewRW()
 		defer fs.CheckClose(rw, &err)
-		_, err = io.CopyN(rw, rc, size)
+		var w io.Writer = rw
+		if h = mc.resumer.Hasher(); h != nil {
+			w = io.MultiWriter(rw, h)
+		}
+		_, err = io.CopyN(w, rc, size)
 		if err != nil {
 			return fmt.Errorf("multi-thread copy: failed to read chunk: %w", err)
 		}
@@ -111,6 +126,7 @@ func (mc *multiThreadCopyState) copyChunk(ctx context.Context, chunk int, writer
 	if err != nil {
 		return fmt.Errorf("multi-thread copy: failed to write chunk: %w", err)
 	}
+	mc.resumer.Done(chunk, size, multipart.Sum(h))
 
 	fs.Debugf(mc.src, "multi-thread copy: chunk %d/%d (%d-%d) size %v finished", chunk+1, mc.numChunks, start, end, fs.SizeSuffix(bytesWritten))
 	return nil
@@ -161,10 +177,20 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 		return nil, fmt.Errorf("multi-thread copy: can't copy zero sized file")
 	}
 
-	info, chunkWriter, err := openChunkWriter(ctx, remote, src, options...)
+	var resumer *multipart.Resumer
+	if !usingOpenWriterAt {
+		resumer = multipart.NewResumer(ctx, f, remote, src)
+	}
+	uploadOver := false
+	defer func() {
+		resumer.Finish(uploadOver)
+	}()
+
+	info, chunkWriter, err := openChunkWriter(ctx, remote, src, resumer.OpenOptions(options)...)
 	if err != nil {
 		return nil, fmt.Errorf("multi-thread copy: failed to open chunk writer: %w", err)
 	}
+	resumable := resumer.Start(info, chunkWriter)
 
 	uploadCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -174,10 +200,16 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 		if info.LeavePartsOnError || uploadedOK {
 			return
 		}
+		if resumable {
+			fs.Debugf(src, "multi-thread copy: leaving transfer on exit so it can be resumed")
+			return
+		}
 		fs.Debugf(src, "multi-thread copy: cancelling transfer on exit")
 		abortErr := chunkWriter.Abort(ctx)
 		if abortErr != nil {
 			fs.Debugf(src, "multi-thread copy: abort failed: %v", abortErr)
+		} else {
+			uploadOver = true
 		}
 	})()
 
@@ -212,6 +244,7 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 		partSize:    info.ChunkSize,
 		numChunks:   numChunks,
 		noBuffering: noBuffering,
+		resumer:     resumer,
 	}
 
 	// Make accounting
@@ -238,6 +271,7 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 		return nil, fmt.Errorf("multi-thread copy: failed to close object after copy: %w", err)
 	}
 	uploadedOK = true // file is definitely uploaded OK so no need to abort
+	uploadOver = true
 
 	obj, err := f.NewObject(ctx, remote)
 	if err != nil {
@@ -39,6 +39,7 @@ import (
 	"github.com/rclone/rclone/lib/pacer"
 	"github.com/rclone/rclone/lib/random"
 	"github.com/rclone/rclone/lib/readers"
+	"github.com/rclone/rclone/lib/transform"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/text/unicode/norm"
 )
@@ -437,6 +438,7 @@ func move(ctx context.Context, fdst fs.Fs, dst fs.Object, remote string, src fs.
 		}
 		tr.Done(ctx, err)
 	}()
+	ctx = tr.StartSpan(ctx)
 	newDst = dst
 	if SkipDestructive(ctx, src, "move") {
 		in := tr.Account(ctx, nil)
@@ -466,7 +468,9 @@ func move(ctx context.Context, fdst fs.Fs, dst fs.Object, remote string, src fs.
 		// Move dst <- src
 		in := tr.Account(ctx, nil) // account the transfer
 		in.ServerSideTransferStart()
+		done := accounting.Observe(fdst, "Move")
 		newDst, err = doMove(ctx, src, remote)
+		done(err)
 		switch err {
 		case nil:
 			if newDst != nil && src.String() != newDst.String() {
@@ -562,7 +566,9 @@ func DeleteFileWithBackupDir(ctx context.Context, dst fs.Object, backupDir fs.Fs
 	} else if backupDir != nil {
 		err = MoveBackupDir(ctx, backupDir, dst)
 	} else {
+		done := accounting.Observe(dst.Fs(), "Remove")
 		err = dst.Remove(ctx)
+		done(err)
 	}
 	if err != nil {
 		fs.Errorf(dst, "Couldn't %s: %v", action, err)
@@ -1051,7 +1057,9 @@ func Mkdir(ctx context.Context, f fs.Fs, dir string) error {
 		return nil
 	}
 	fs.Debugf(fs.LogDirName(f, dir), "Making directory")
+	done := accounting.Observe(f, "Mkdir")
 	err := f.Mkdir(ctx, dir)
+	done(err)
 	if err != nil {
 		err = fs.CountError(err)
 		return err
@@ -1126,7 +1134,10 @@ func TryRmdir(ctx context.Context, f fs.Fs, dir string) error {
 		return nil
 	}
 	fs.Infof(fs.LogDirName(f, dir), "Removing directory")
-	return f.Rmdir(ctx, dir)
+	done := accounting.Observe(f, "Rmdir")
+	err := f.Rmdir(ctx, dir)
+	done(err)
+	return err
 }
 
 // Rmdir removes a container but not if not empty
@@ -1401,13 +1412,17 @@ func rcatSrc(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadClos
 			if err != nil {
 				return fmt.Errorf("failed to rewind temporary spool file: %v", err)
 			}
+			done := accounting.Observe(fdst, "Put")
 			dst, err = fdst.Put(ctx, rs, objInfo, options...)
+			done(err)
 			return err
 		})
 	} else {
 		// Upload with PutStream with no retries
 		objInfo := object.NewStaticObjectInfo(dstFileName, modTime, -1, false, nil, fsrc).WithMetadata(meta)
+		done := accounting.Observe(fdst, "PutStream")
 		dst, err = doPutStream(ctx, streamIn, objInfo, options...)
+		done(err)
 	}
 	if err != nil {
 		return dst, err
@@ -1558,7 +1573,11 @@ func GetCompareDest(ctx context.Context) (CompareDest []fs.Fs, err error) {
 func compareDest(ctx context.Context, dst, src fs.Object, CompareDest fs.Fs) (NoNeedTransfer bool, err error) {
 	var remote string
 	if dst == nil {
-		remote = src.Remote()
+		// Look for src under the name it will have in the destination
+		remote, err = transform.Path(ctx, src.Remote(), false)
+		if err != nil {
+			return false, err
+		}
 	} else {
 		remote = dst.Remote()
 	}
@@ -1606,7 +1625,11 @@ func GetCopyDest(ctx context.Context, fdst fs.Fs) (CopyDest []fs.Fs, err error)
 func copyDest(ctx context.Context, fdst fs.Fs, dst, src fs.Object, CopyDest, backupDir fs.Fs) (NoNeedTransfer bool, err error) {
 	var remote string
 	if dst == nil {
-		remote = src.Remote()
+		// Look for src under the name it will have in the destination
+		remote, err = transform.Path(ctx, src.Remote(), false)
+		if err != nil {
+			return false, err
+		}
 	} else {
 		remote = dst.Remote()
 	}
@@ -1946,6 +1969,10 @@ func MoveCaseInsensitive(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, dstFileNam
 func moveOrCopyFile(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, dstFileName string, srcFileName string, cp bool) (err error) {
 	ci := fs.GetConfig(ctx)
 	logger, usingLogger := GetLogger(ctx)
+	dstFileName, err = transform.Path(ctx, dstFileName, false)
+	if err != nil {
+		return err
+	}
 	dstFilePath := path.Join(fdst.Root(), dstFileName)
 	srcFilePath := path.Join(fsrc.Root(), srcFileName)
 	if fdst.Name() == fsrc.Name() && dstFilePath == srcFilePath {
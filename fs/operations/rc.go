@@ -490,6 +490,8 @@ This returns info about the remote passed in;
                 "whirlpool",
                 "crc32",
                 "sha256",
+                "crc32c",
+                "crc64nvme",
                 "dropbox",
                 "mailru",
                 "quickxor"
@@ -32,6 +32,7 @@ func NewPacer(ctx context.Context, c pacer.Calculator) *Pacer {
 			// pacer.MaxConnectionsOption(ci.Checkers+ci.Transfers),
 			pacer.RetriesOption(retries),
 			pacer.CalculatorOption(c),
+			pacer.NameOption(RemoteName(ctx)),
 		),
 	}
 	p.SetCalculator(c)
@@ -0,0 +1,453 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/kv"
+)
+
+// historyFacility is the name of the key-value database facility used
+// to store the job history
+const historyFacility = "jobhistory"
+
+// errNoHistory is returned when a job isn't in the history
+var errNoHistory = errors.New("job not found in history")
+
+// historyTransfer is a summary of a file transferred by a job
+type historyTransfer struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	Bytes       int64     `json:"bytes"`
+	Checked     bool      `json:"checked"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at,omitempty"`
+	Error       string    `json:"error"`
+	SrcFs       string    `json:"srcFs,omitempty"`
+	DstFs       string    `json:"dstFs,omitempty"`
+}
+
+// historyRecord is a finished job as stored in the history
+type historyRecord struct {
+	ID          string            `json:"id"`        // key in the history
+	JobID       int64             `json:"jobid"`     // ID of the job in the rclone which ran it
+	ExecuteID   string            `json:"executeId"` // ID of the rclone which ran it
+	Group       string            `json:"group"`
+	Params      rc.Params         `json:"params"`
+	StartTime   time.Time         `json:"startTime"`
+	EndTime     time.Time         `json:"endTime"`
+	Duration    float64           `json:"duration"`
+	Success     bool              `json:"success"`
+	Error       string            `json:"error"`
+	Errors      []string          `json:"errors"`
+	Stats       rc.Params         `json:"stats"`
+	Transferred []historyTransfer `json:"transferred"`
+}
+
+// summary returns the parts of the record shown by job/history
+func (rec *historyRecord) summary() rc.Params {
+	return rc.Params{
+		"id":        rec.ID,
+		"jobid":     rec.JobID,
+		"executeId": rec.ExecuteID,
+		"group":     rec.Group,
+		"startTime": rec.StartTime,
+		"endTime":   rec.EndTime,
+		"duration":  rec.Duration,
+		"success":   rec.Success,
+		"error":     rec.Error,
+	}
+}
+
+// historyKey makes the key for the job so that the most recently
+// finished jobs sort first
+func historyKey(job *Job) string {
+	return fmt.Sprintf("%019d-%s-%d", math.MaxInt64-job.EndTime.UnixNano(), executeID, job.ID)
+}
+
+// the database the history is saved in
+var (
+	historyMu sync.Mutex
+	historyDB *kv.DB
+)
+
+// the records waiting to be saved to the history
+var (
+	pendingMu      sync.Mutex
+	pending        []*historyRecord
+	pendingKeep    int            // number of records to keep in the history
+	pendingWriting bool           // set if writeHistory is running
+	pendingWG      sync.WaitGroup // counts the records not saved yet
+)
+
+// getHistory opens the history database if necessary
+func getHistory(ctx context.Context) (*kv.DB, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if historyDB != nil {
+		return historyDB, nil
+	}
+	if !kv.Supported() {
+		return nil, kv.ErrUnsupported
+	}
+	db, err := kv.Start(ctx, historyFacility, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job history: %w", err)
+	}
+	historyDB = db
+	return db, nil
+}
+
+// saveHistory queues the finished job to be saved to the history if
+// it is enabled.
+//
+// The record is made now but written in the background so finishing
+// a job doesn't wait for the disk.
+func (jobs *Jobs) saveHistory(job *Job) {
+	keep := jobs.opt.JobHistory
+	if keep <= 0 {
+		return
+	}
+
+	// Read the stats without making the group if it doesn't exist
+	stats := accounting.StatsGroupIfExists(job.Group)
+
+	job.mu.Lock()
+	rec := &historyRecord{
+		ID:        historyKey(job),
+		JobID:     job.ID,
+		ExecuteID: executeID,
+		Group:     job.Group,
+		Params:    job.params,
+		StartTime: job.StartTime,
+		EndTime:   job.EndTime,
+		Duration:  job.Duration,
+		Success:   job.Success,
+		Error:     job.Error,
+		Errors:    []string{},
+	}
+	job.mu.Unlock()
+	if rec.Error != "" {
+		rec.Errors = append(rec.Errors, rec.Error)
+	}
+	if stats != nil {
+		var err error
+		rec.Stats, err = stats.RemoteStats()
+		if err != nil {
+			fs.Debugf(nil, "Failed to read stats for job %d: %v", job.ID, err)
+		}
+		for _, tr := range stats.Transferred() {
+			transfer := historyTransfer{
+				Name:        tr.Name,
+				Size:        tr.Size,
+				Bytes:       tr.Bytes,
+				Checked:     tr.Checked,
+				StartedAt:   tr.StartedAt,
+				CompletedAt: tr.CompletedAt,
+				SrcFs:       rc.RedactRemote(tr.SrcFs),
+				DstFs:       rc.RedactRemote(tr.DstFs),
+			}
+			if tr.Error != nil {
+				transfer.Error = tr.Error.Error()
+				rec.Errors = append(rec.Errors, fmt.Sprintf("%s: %v", tr.Name, tr.Error))
+			}
+			rec.Transferred = append(rec.Transferred, transfer)
+		}
+	}
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	pendingWG.Add(1)
+	pending = append(pending, rec)
+	pendingKeep = keep
+	if !pendingWriting {
+		pendingWriting = true
+		go writeHistory()
+	}
+}
+
+// writeHistory writes the pending records to the history until there
+// are none left.
+//
+// The records which are queued while a write is in progress are
+// written together in the next one.
+func writeHistory() {
+	for {
+		pendingMu.Lock()
+		recs, keep := pending, pendingKeep
+		pending = nil
+		if len(recs) == 0 {
+			pendingWriting = false
+			pendingMu.Unlock()
+			return
+		}
+		pendingMu.Unlock()
+
+		db, err := getHistory(context.Background())
+		if err == nil {
+			err = db.Do(true, &opPutHistory{recs: recs, keep: keep})
+		}
+		if err != nil {
+			fs.Errorf(nil, "Failed to save %d job(s) to history: %v", len(recs), err)
+		}
+		pendingWG.Add(-len(recs))
+	}
+}
+
+// flushHistory waits for the pending records to be written
+func flushHistory() {
+	pendingWG.Wait()
+}
+
+// opPutHistory: save jobs removing the oldest if there are more than keep
+type opPutHistory struct {
+	recs []*historyRecord
+	keep int
+}
+
+func (op *opPutHistory) Do(ctx context.Context, b kv.Bucket) error {
+	for _, rec := range op.recs {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("marshal failed: %w", err)
+		}
+		if err := b.Put([]byte(rec.ID), data); err != nil {
+			return err
+		}
+	}
+	var old [][]byte
+	n := 0
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		n++
+		if n > op.keep {
+			old = append(old, append([]byte(nil), k...))
+		}
+	}
+	for _, k := range old {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// opGetHistory: read a job from the history by ID or by jobid in this
+// rclone if ID is empty
+type opGetHistory struct {
+	id    string
+	jobID int64
+	rec   historyRecord
+}
+
+func (op *opGetHistory) Do(ctx context.Context, b kv.Bucket) error {
+	if op.id != "" {
+		data := b.Get([]byte(op.id))
+		if data == nil {
+			return errNoHistory
+		}
+		return json.Unmarshal(data, &op.rec)
+	}
+	c := b.Cursor()
+	for k, data := c.First(); k != nil; k, data = c.Next() {
+		var rec historyRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		if rec.ExecuteID == executeID && rec.JobID == op.jobID {
+			op.rec = rec
+			return nil
+		}
+	}
+	return errNoHistory
+}
+
+// opListHistory: list a page of the history, most recent first
+type opListHistory struct {
+	before string // start after this ID if set
+	group  string // only return jobs in this group if set
+	limit  int
+	recs   []*historyRecord
+	more   bool // set if there are more records
+}
+
+func (op *opListHistory) Do(ctx context.Context, b kv.Bucket) error {
+	c := b.Cursor()
+	k, data := c.First()
+	if op.before != "" {
+		k, data = c.Seek([]byte(op.before))
+		if k != nil && string(k) == op.before {
+			k, data = c.Next()
+		}
+	}
+	for ; k != nil; k, data = c.Next() {
+		var rec historyRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		if op.group != "" && rec.Group != op.group {
+			continue
+		}
+		if len(op.recs) >= op.limit {
+			op.more = true
+			break
+		}
+		op.recs = append(op.recs, &rec)
+	}
+	return nil
+}
+
+// check the history is enabled and return the database once the
+// pending records have been written
+func historyEnabled(ctx context.Context) (*kv.DB, error) {
+	if running.opt.JobHistory <= 0 {
+		return nil, errors.New("job history is disabled - enable it with --rc-job-history")
+	}
+	flushHistory()
+	return getHistory(ctx)
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/history",
+		Fn:    rcJobHistory,
+		Title: "Lists finished jobs from the job history",
+		Help: `This lists the jobs saved in the job history, most recently
+finished first. The history is kept on disk so includes jobs run
+before rclone was restarted. It must be enabled with --rc-job-history.
+
+Parameters:
+
+- limit - max number of jobs to return (integer, default 100)
+- before - return jobs finished before the job with this id (string, optional)
+- group - only return jobs in this stats group (string, optional)
+
+Results:
+
+- jobs - array of jobs each with
+    - id - string id of the job in the history
+    - jobid - id of the job when it ran
+    - executeId - id of the rclone which ran the job
+    - group - stats group of the job
+    - startTime - time the job started
+    - endTime - time the job finished
+    - duration - time in seconds that the job ran for
+    - success - boolean - true for success false otherwise
+    - error - error from the job or empty string for no error
+- next - pass this as before to read the next page, only set if there are more jobs
+
+Use job/get to read the details of a job.
+`,
+	})
+}
+
+// Returns a page of the job history
+func rcJobHistory(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	op := &opListHistory{limit: 100}
+	limit, err := in.GetInt64("limit")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	} else if err == nil {
+		if limit <= 0 {
+			return nil, errors.New("limit must be positive")
+		}
+		op.limit = int(limit)
+	}
+	op.before, err = in.GetString("before")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	op.group, err = in.GetString("group")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	db, err := historyEnabled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Do(false, op)
+	if err != nil && !errors.Is(err, kv.ErrEmpty) {
+		return nil, err
+	}
+	jobs := make([]rc.Params, 0, len(op.recs))
+	for _, rec := range op.recs {
+		jobs = append(jobs, rec.summary())
+	}
+	out = rc.Params{"jobs": jobs}
+	if op.more {
+		out["next"] = op.recs[len(op.recs)-1].ID
+	}
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/get",
+		Fn:    rcJobGet,
+		Title: "Reads a finished job from the job history",
+		Help: `This reads all the details of a job saved in the job history.
+It must be enabled with --rc-job-history.
+
+Parameters:
+
+- id - string id of the job in the history as returned by job/history
+- jobid - or the id of a job run by this rclone (integer)
+
+Results:
+
+- id - string id of the job in the history
+- jobid - id of the job when it ran
+- executeId - id of the rclone which ran the job
+- group - stats group of the job
+- params - the parameters the job was called with with passwords and keys removed
+- startTime - time the job started
+- endTime - time the job finished
+- duration - time in seconds that the job ran for
+- success - boolean - true for success false otherwise
+- error - error from the job or empty string for no error
+- errors - array of the error from the job and the errors from files transferred
+- stats - the stats of the job when it finished as returned by core/stats
+- transferred - array of files transferred as returned by core/transferred
+`,
+	})
+}
+
+// Returns a job from the history
+func rcJobGet(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	op := &opGetHistory{}
+	op.id, err = in.GetString("id")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if op.id == "" {
+		op.jobID, err = in.GetInt64("jobid")
+		if err != nil {
+			return nil, err
+		}
+	}
+	db, err := historyEnabled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Do(false, op)
+	if errors.Is(err, kv.ErrEmpty) {
+		err = errNoHistory
+	}
+	if err != nil {
+		return nil, err
+	}
+	out = make(rc.Params)
+	err = rc.Reshape(&out, &op.rec)
+	if err != nil {
+		return nil, fmt.Errorf("reshape failed in job get: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobHistory(t *testing.T) {
+	ctx := context.Background()
+	oldOpt := running.opt
+	opt := *oldOpt
+	opt.JobHistory = 3
+	running.opt = &opt
+	defer func() {
+		running.opt = oldOpt
+	}()
+
+	call := func(path string, in rc.Params) (rc.Params, error) {
+		return rc.Calls.Get(path).Fn(ctx, in)
+	}
+
+	// Run some jobs - one fails
+	var jobIDs []int64
+	defer func() {
+		running.mu.Lock()
+		for _, id := range jobIDs {
+			delete(running.jobs, id)
+		}
+		running.mu.Unlock()
+	}()
+	for i := 0; i < 4; i++ {
+		job, _, err := NewJob(ctx, func(ctx context.Context, in rc.Params) (rc.Params, error) {
+			if i == 2 {
+				return nil, errors.New("job failed")
+			}
+			return rc.Params{"i": i}, nil
+		}, rc.Params{"i": i, "pass": "potato", "_group": fmt.Sprintf("history/%d", i%2)})
+		if i == 2 {
+			require.Error(t, err)
+		} else {
+			require.NoError(t, err)
+		}
+		jobIDs = append(jobIDs, job.ID)
+	}
+
+	// Only the most recent 3 are kept, newest first
+	out, err := call("job/history", rc.Params{})
+	require.NoError(t, err)
+	jobs := out["jobs"].([]rc.Params)
+	require.Equal(t, 3, len(jobs))
+	for i, job := range jobs {
+		assert.Equal(t, jobIDs[3-i], job["jobid"])
+		assert.Equal(t, executeID, job["executeId"])
+	}
+	assert.Equal(t, false, jobs[1]["success"])
+	assert.Equal(t, "job failed", jobs[1]["error"])
+	assert.Nil(t, out["next"])
+
+	// Paging
+	out, err = call("job/history", rc.Params{"limit": 2})
+	require.NoError(t, err)
+	jobs = out["jobs"].([]rc.Params)
+	require.Equal(t, 2, len(jobs))
+	assert.Equal(t, jobIDs[3], jobs[0]["jobid"])
+	assert.Equal(t, jobIDs[2], jobs[1]["jobid"])
+	next := out["next"]
+	require.NotNil(t, next)
+	out, err = call("job/history", rc.Params{"limit": 2, "before": next})
+	require.NoError(t, err)
+	jobs = out["jobs"].([]rc.Params)
+	require.Equal(t, 1, len(jobs))
+	assert.Equal(t, jobIDs[1], jobs[0]["jobid"])
+	assert.Nil(t, out["next"])
+
+	// Filter by group
+	out, err = call("job/history", rc.Params{"group": "history/1"})
+	require.NoError(t, err)
+	jobs = out["jobs"].([]rc.Params)
+	require.Equal(t, 2, len(jobs))
+	assert.Equal(t, jobIDs[3], jobs[0]["jobid"])
+	assert.Equal(t, jobIDs[1], jobs[1]["jobid"])
+
+	// Get by history id
+	id := jobs[0]["id"]
+	out, err = call("job/get", rc.Params{"id": id})
+	require.NoError(t, err)
+	assert.Equal(t, id, out["id"])
+	assert.Equal(t, float64(jobIDs[3]), out["jobid"])
+	assert.Equal(t, "history/1", out["group"])
+	assert.Equal(t, map[string]any{"i": float64(3), "pass": "XXX", "_group": "history/1"}, out["params"])
+	assert.Equal(t, true, out["success"])
+
+	// Get by job id
+	out, err = call("job/get", rc.Params{"jobid": jobIDs[2]})
+	require.NoError(t, err)
+	assert.Equal(t, false, out["success"])
+	assert.Equal(t, []any{"job failed"}, out["errors"])
+
+	// Expired from the history
+	_, err = call("job/get", rc.Params{"jobid": jobIDs[0]})
+	assert.ErrorIs(t, err, errNoHistory)
+	_, err = call("job/get", rc.Params{"id": "potato"})
+	assert.ErrorIs(t, err, errNoHistory)
+
+	// Disabled
+	opt.JobHistory = 0
+	_, err = call("job/history", rc.Params{})
+	assert.ErrorContains(t, err, "disabled")
+}
@@ -16,6 +16,9 @@ import (
 	"github.com/rclone/rclone/fs/cache"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Fill in these to avoid circular dependencies
@@ -38,6 +41,8 @@ type Job struct {
 	Output    rc.Params `json:"output"`
 	Stop      func()    `json:"-"`
 	listeners []*func()
+	span      trace.Span
+	params    rc.Params // parameters for the history
 
 	// realErr is the Error before printing it as a string, it's used to return
 	// the real error to the upper application layers while still printing the
@@ -64,6 +69,9 @@ func (job *Job) finish(out rc.Params, err error) {
 		job.Success = true
 	}
 	job.Finished = true
+	if job.span != nil {
+		tracing.EndSpan(job.span, err)
+	}
 
 	// Notify listeners that the job is finished
 	for i := range job.listeners {
@@ -71,6 +79,7 @@ func (job *Job) finish(out rc.Params, err error) {
 	}
 
 	job.mu.Unlock()
+	running.saveHistory(job)
 	running.kickExpire() // make sure this job gets expired
 }
 
@@ -91,6 +100,14 @@ func (job *Job) removeListener(fn *func()) {
 	}
 }
 
+// Err returns the error the job finished with, or nil if it succeeded
+// or hasn't finished yet.
+func (job *Job) Err() error {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.realErr
+}
+
 // OnFinish adds listener to job that will be triggered when job is finished.
 // It returns a function to cancel listening.
 func (job *Job) OnFinish(fn func()) func() {
@@ -266,6 +283,8 @@ var jobKey = jobKeyType{}
 func (jobs *Jobs) NewJob(ctx context.Context, fn rc.Func, in rc.Params) (job *Job, out rc.Params, err error) {
 	id := jobID.Add(1)
 	in = in.Copy() // copy input so we can change it
+	caller := trace.SpanContextFromContext(ctx)
+	params := rc.RedactParams(in)
 
 	ctx, isAsync, err := getAsync(ctx, in)
 	if err != nil {
@@ -287,6 +306,18 @@ func (jobs *Jobs) NewJob(ctx context.Context, fn rc.Func, in rc.Params) (job *Jo
 		return nil, nil, err
 	}
 
+	// Trace the job - async jobs are linked to the caller rather
+	// than being part of its span
+	spanOpts := []trace.SpanStartOption{trace.WithAttributes(
+		attribute.Int64("rclone.job.id", id),
+		attribute.String("rclone.job.group", group),
+		attribute.Bool("rclone.job.async", isAsync),
+	)}
+	if isAsync && caller.IsValid() {
+		spanOpts = append(spanOpts, trace.WithLinks(trace.Link{SpanContext: caller}))
+	}
+	ctx, span := tracing.StartSpan(ctx, "rc job", spanOpts...)
+
 	ctx, cancel := context.WithCancel(ctx)
 	stop := func() {
 		cancel()
@@ -298,6 +329,8 @@ func (jobs *Jobs) NewJob(ctx context.Context, fn rc.Func, in rc.Params) (job *Jo
 		Group:     group,
 		StartTime: time.Now(),
 		Stop:      stop,
+		span:      span,
+		params:    params,
 	}
 
 	jobs.mu.Lock()
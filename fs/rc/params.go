@@ -8,10 +8,13 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fspath"
 )
 
 // Params is the input and output type for the Func
@@ -91,6 +94,67 @@ func (p Params) Copy() (out Params) {
 	return out
 }
 
+// sensitiveParam matches the names of parameters whose values
+// shouldn't be shown or saved
+var sensitiveParam = regexp.MustCompile(`(?i)pass|secret|token|key|auth|credential`)
+
+// RedactParams returns a copy of in with the values of sensitive
+// parameters replaced with "XXX" so it can be shown or saved.
+//
+// Nested parameters and the sensitive parameters of connection
+// strings, such as ":s3,secret_access_key=XXX:bucket", are redacted
+// too.
+func RedactParams(in Params) Params {
+	out := make(Params, len(in))
+	for k, v := range in {
+		switch {
+		case sensitiveParam.MatchString(k):
+			out[k] = "XXX"
+		default:
+			switch x := v.(type) {
+			case map[string]any:
+				v = map[string]any(RedactParams(x))
+			case Params:
+				v = RedactParams(x)
+			case string:
+				v = RedactRemote(x)
+			}
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// RedactRemote returns remote with the values of the sensitive
+// parameters in its connection string replaced with "XXX".
+//
+// Parameters are sensitive if their name looks like it is, or if the
+// backend marks them as passwords or sensitive.
+func RedactRemote(remote string) string {
+	if !strings.Contains(remote, ",") {
+		return remote
+	}
+	parsed, err := fspath.Parse(remote)
+	if err != nil || len(parsed.Config) == 0 {
+		return remote
+	}
+	var options fs.Options
+	if strings.HasPrefix(parsed.Name, ":") {
+		if ri, err := fs.Find(parsed.Name[1:]); err == nil {
+			options = ri.Options
+		}
+	}
+	for k, v := range parsed.Config {
+		if opt := options.Get(k); sensitiveParam.MatchString(k) || (opt != nil && (opt.IsPassword || opt.Sensitive)) {
+			parsed.Config[k] = "XXX"
+		} else {
+			// Values may be connection strings themselves
+			parsed.Config[k] = RedactRemote(v)
+		}
+	}
+	return parsed.Name + "," + parsed.Config.String() + ":" + parsed.Path
+}
+
 // Get gets a parameter from the input
 //
 // If the parameter isn't found then error will be of type
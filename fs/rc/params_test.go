@@ -38,6 +38,47 @@ func TestIsErrParamInvalid(t *testing.T) {
 	assert.Equal(t, false, IsErrParamInvalid(errors.New("potato")))
 }
 
+func TestRedactParams(t *testing.T) {
+	in := Params{
+		"srcFs":    "remote:",
+		"password": "potato",
+		"_config": map[string]any{
+			"Transfers":  4,
+			"SessionKey": "secret",
+		},
+		"opt": Params{"token": "abc"},
+	}
+	assert.Equal(t, Params{
+		"srcFs":    "remote:",
+		"password": "XXX",
+		"_config": map[string]any{
+			"Transfers":  4,
+			"SessionKey": "XXX",
+		},
+		"opt": Params{"token": "XXX"},
+	}, RedactParams(in))
+	// check the input wasn't changed
+	assert.Equal(t, "potato", in["password"])
+	assert.Equal(t, "abc", in["opt"].(Params)["token"])
+}
+
+func TestRedactParamsConnectionString(t *testing.T) {
+	in := Params{
+		"srcFs": ":s3,region=eu,secret_access_key='abc,def':bucket/path",
+		"dstFs": ":crypt,remote=':sftp,pass=potato:dir',password2=xyz:",
+		"fs":    "remote,token=abc:",
+		"other": "/local/path",
+		"list":  "a,b,c",
+	}
+	assert.Equal(t, Params{
+		"srcFs": ":s3,region='eu',secret_access_key='XXX':bucket/path",
+		"dstFs": ":crypt,password2='XXX',remote=':sftp,pass=''XXX'':dir':",
+		"fs":    "remote,token='XXX':",
+		"other": "/local/path",
+		"list":  "a,b,c",
+	}, RedactParams(in))
+}
+
 func TestReshape(t *testing.T) {
 	in := Params{
 		"String": "hello",
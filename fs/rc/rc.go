@@ -83,6 +83,16 @@ var OptionsInfo = fs.Options{{
 	Default: 10 * time.Second,
 	Help:    "Interval to check for expired async jobs",
 	Groups:  "RC",
+}, {
+	Name:    "rc_job_history",
+	Default: 0,
+	Help:    "Number of finished jobs to keep in the job history on disk (0 to disable)",
+	Groups:  "RC",
+}, {
+	Name:    "rc_schedule_file",
+	Default: "",
+	Help:    "File to save the rc schedules in (default is in the cache directory)",
+	Groups:  "RC",
 }, {
 	Name:    "metrics_addr",
 	Default: []string{},
@@ -122,6 +132,8 @@ type Options struct {
 	MetricsTemplate     libhttp.TemplateConfig `config:"metrics"`
 	JobExpireDuration   time.Duration          `config:"rc_job_expire_duration"`
 	JobExpireInterval   time.Duration          `config:"rc_job_expire_interval"`
+	JobHistory          int                    `config:"rc_job_history"`   // number of finished jobs to keep on disk
+	ScheduleFile        string                 `config:"rc_schedule_file"` // file to save schedules in
 }
 
 // Opt is the default values used for Options
@@ -13,6 +13,7 @@ import (
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/rclone/rclone/fs/rc/jobs"
 	libhttp "github.com/rclone/rclone/lib/http"
+	"github.com/rclone/rclone/lib/pacer"
 )
 
 const path = "/metrics"
@@ -29,6 +30,18 @@ func init() {
 	}
 	fshttp.DefaultMetrics = m
 
+	am := accounting.NewMetrics("rclone")
+	for _, c := range am.Collectors() {
+		prometheus.MustRegister(c)
+	}
+	accounting.DefaultMetrics = am
+
+	pm := pacer.NewMetrics("rclone")
+	for _, c := range pm.Collectors() {
+		prometheus.MustRegister(c)
+	}
+	pacer.DefaultMetrics = pm
+
 	promHandlerFunc = promhttp.Handler().ServeHTTP
 }
 
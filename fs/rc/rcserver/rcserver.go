@@ -23,11 +23,14 @@ import (
 	"github.com/rclone/rclone/fs/list"
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/rclone/rclone/fs/rc/jobs"
+	"github.com/rclone/rclone/fs/rc/schedule"
 	"github.com/rclone/rclone/fs/rc/webgui"
+	"github.com/rclone/rclone/fs/tracing"
 	libhttp "github.com/rclone/rclone/lib/http"
 	"github.com/rclone/rclone/lib/http/serve"
 	"github.com/rclone/rclone/lib/random"
 	"github.com/skratchdot/open-golang/open"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Start the remote control server if configured
@@ -41,6 +44,9 @@ func Start(ctx context.Context, opt *rc.Options) (*Server, error) {
 		if err != nil {
 			return nil, err
 		}
+		if err := schedule.Start(ctx, opt); err != nil {
+			return nil, err
+		}
 		return s, s.Serve()
 	}
 	return nil, nil
@@ -261,7 +267,9 @@ func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, path string)
 	}
 
 	fs.Debugf(nil, "rc: %q: with parameters %+v", path, in)
+	ctx, span := tracing.StartSpan(ctx, "rc/"+path, trace.WithSpanKind(trace.SpanKindServer))
 	job, out, err := jobs.NewJob(ctx, call.Fn, in)
+	tracing.EndSpan(span, err)
 	if job != nil {
 		w.Header().Add("x-rclone-jobid", fmt.Sprintf("%d", job.ID))
 	}
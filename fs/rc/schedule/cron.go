@@ -0,0 +1,200 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is a bit set of the values allowed in a field of a cron
+// expression
+type cronField uint64
+
+// has returns true if v is in the field
+func (c cronField) has(v int) bool {
+	return c&(1<<uint(v)) != 0
+}
+
+// cronRange describes the values a field of a cron expression can take
+type cronRange struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes  = cronRange{name: "minute", min: 0, max: 59}
+	hours    = cronRange{name: "hour", min: 0, max: 23}
+	days     = cronRange{name: "day of month", min: 1, max: 31}
+	months   = cronRange{name: "month", min: 1, max: 12, names: map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}}
+	weekdays = cronRange{name: "day of week", min: 0, max: 7, names: map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}}
+)
+
+// descriptors are the shortcuts which can be used instead of the 5
+// fields of a cron expression
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSpec is a parsed cron expression
+type cronSpec struct {
+	every                         time.Duration // set for @every
+	minute, hour, dom, month, dow cronField
+	domStar, dowStar              bool // set if the day fields were "*"
+}
+
+// parseCron parses a cron expression.
+//
+// It understands the standard 5 fields "minute hour day-of-month
+// month day-of-week" with "*", lists, ranges, steps and month and day
+// names, the descriptors such as "@daily" and "@every <duration>".
+func parseCron(spec string) (*cronSpec, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("bad @every duration: %w", err)
+		}
+		if every < time.Second {
+			return nil, errors.New("@every duration must be at least 1s")
+		}
+		return &cronSpec{every: every}, nil
+	}
+	if expanded, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields: minute hour day-of-month month day-of-week", spec)
+	}
+	c := &cronSpec{
+		domStar: fields[2] == "*" || fields[2] == "?",
+		dowStar: fields[4] == "*" || fields[4] == "?",
+	}
+	var err error
+	for i, r := range []struct {
+		field *cronField
+		rng   cronRange
+	}{
+		{&c.minute, minutes},
+		{&c.hour, hours},
+		{&c.dom, days},
+		{&c.month, months},
+		{&c.dow, weekdays},
+	} {
+		*r.field, err = parseCronField(fields[i], r.rng)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Sunday can be 0 or 7
+	if c.dow.has(7) {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// parseCronValue parses a single value of a field
+func parseCronValue(s string, rng cronRange) (int, error) {
+	if v, ok := rng.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < rng.min || v > rng.max {
+		return 0, fmt.Errorf("bad %s %q: must be in the range %d-%d", rng.name, s, rng.min, rng.max)
+	}
+	return v, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and
+// steps
+func parseCronField(s string, rng cronRange) (field cronField, err error) {
+	for _, part := range strings.Split(s, ",") {
+		var (
+			step     = 1
+			low      = rng.min
+			high     = rng.max
+			valueStr = part
+		)
+		if before, after, ok := strings.Cut(part, "/"); ok {
+			valueStr = before
+			step, err = strconv.Atoi(after)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("bad step %q in %s", after, rng.name)
+			}
+		}
+		switch {
+		case valueStr == "*" || valueStr == "?":
+		case strings.Contains(valueStr, "-"):
+			lowStr, highStr, _ := strings.Cut(valueStr, "-")
+			if low, err = parseCronValue(lowStr, rng); err != nil {
+				return 0, err
+			}
+			if high, err = parseCronValue(highStr, rng); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("bad %s range %q", rng.name, valueStr)
+			}
+		default:
+			if low, err = parseCronValue(valueStr, rng); err != nil {
+				return 0, err
+			}
+			// a single value with a step, e.g. "5/15", runs to the end
+			if valueStr == part {
+				high = low
+			}
+		}
+		for v := low; v <= high; v += step {
+			field |= 1 << uint(v)
+		}
+	}
+	return field, nil
+}
+
+// matchDay returns true if t is on a day the spec runs.
+//
+// As in cron, if both day of month and day of week are restricted
+// then either may match.
+func (c *cronSpec) matchDay(t time.Time) bool {
+	domMatch := c.dom.has(t.Day())
+	dowMatch := c.dow.has(int(t.Weekday()))
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// next returns the first time after t the spec runs or the zero time
+// if it never does.
+func (c *cronSpec) next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Add(c.every).Truncate(time.Second)
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Give up after 5 years, e.g. for 30th of February
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.hour.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
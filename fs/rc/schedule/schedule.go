@@ -0,0 +1,539 @@
+// Package schedule runs rc commands on a schedule given as a cron
+// expression.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/jobs"
+)
+
+// Schedule is an rc command to run on a schedule
+type Schedule struct {
+	Name      string    `json:"name"`      // unique name of the schedule
+	Spec      string    `json:"schedule"`  // cron expression
+	Command   string    `json:"command"`   // rc command to run, e.g. "sync/sync"
+	Params    rc.Params `json:"params"`    // parameters for the command
+	Created   time.Time `json:"created"`   // when the schedule was added
+	LastRun   time.Time `json:"lastRun"`   // when the command was last started
+	LastJobID int64     `json:"lastJobId"` // the job ID of the last run
+	LastError string    `json:"lastError"` // error from the last run if any
+	Runs      int64     `json:"runs"`      // number of times the command was started
+	Skipped   int64     `json:"skipped"`   // number of runs skipped as the last was still running
+
+	cron    *cronSpec
+	next    time.Time
+	running bool
+}
+
+// Scheduler runs the Schedules
+type Scheduler struct {
+	mu        sync.Mutex
+	path      string               // file to save the schedules in
+	loaded    bool                 // set if the schedules have been read
+	schedules map[string]*Schedule // schedules by name
+	kick      chan struct{}        // poke the scheduler loop when the schedules change
+	started   bool                 // set if the loop is running
+	now       func() time.Time     // for testing
+}
+
+// the global scheduler used by the rc
+var scheduler = newScheduler("")
+
+// newScheduler makes a scheduler which saves its schedules in path,
+// or the default place if empty.
+func newScheduler(path string) *Scheduler {
+	return &Scheduler{
+		path:      path,
+		schedules: map[string]*Schedule{},
+		kick:      make(chan struct{}, 1),
+		now:       time.Now,
+	}
+}
+
+// Start reads the saved schedules and starts running them in the
+// background.
+//
+// It is called when the rc server starts.
+func Start(ctx context.Context, opt *rc.Options) error {
+	scheduler.mu.Lock()
+	if opt.ScheduleFile != "" && !scheduler.loaded {
+		scheduler.path = opt.ScheduleFile
+	}
+	scheduler.mu.Unlock()
+	return scheduler.start(ctx)
+}
+
+// start the scheduler loop if it isn't running
+func (s *Scheduler) start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	if s.started {
+		return nil
+	}
+	s.started = true
+	if len(s.schedules) > 0 {
+		fs.Infof(nil, "Loaded %d rc schedules from %q", len(s.schedules), s.path)
+	}
+	go s.loop(ctx)
+	return nil
+}
+
+// load the schedules from disk if not done already - call with lock held
+func (s *Scheduler) load() error {
+	if s.loaded {
+		return nil
+	}
+	if s.path == "" {
+		s.path = filepath.Join(config.GetCacheDir(), "rc", "schedules.json")
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.loaded = true
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read rc schedules: %w", err)
+	}
+	var schedules []*Schedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return fmt.Errorf("failed to parse rc schedules from %q: %w", s.path, err)
+	}
+	now := s.now()
+	for _, sch := range schedules {
+		sch.cron, err = parseCron(sch.Spec)
+		if err != nil {
+			fs.Errorf(nil, "Ignoring rc schedule %q: %v", sch.Name, err)
+			continue
+		}
+		sch.next = sch.cron.next(now)
+		s.schedules[sch.Name] = sch
+	}
+	s.loaded = true
+	return nil
+}
+
+// save the schedules to disk - call with lock held
+func (s *Scheduler) save() error {
+	data, err := json.MarshalIndent(s.list(), "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to marshal rc schedules: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to make directory for rc schedules: %w", err)
+	}
+	// Write to a temporary file then rename so the file is never half written
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write rc schedules: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write rc schedules: %w", err)
+	}
+	return nil
+}
+
+// list the schedules sorted by name - call with lock held
+func (s *Scheduler) list() []*Schedule {
+	schedules := make([]*Schedule, 0, len(s.schedules))
+	for _, sch := range s.schedules {
+		schedules = append(schedules, sch)
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].Name < schedules[j].Name
+	})
+	return schedules
+}
+
+// poke the loop to recalculate when it should next wake up
+func (s *Scheduler) poke() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// loop runs the schedules when they are due
+func (s *Scheduler) loop(ctx context.Context) {
+	for {
+		s.mu.Lock()
+		now := s.now()
+		var wake time.Time
+		for _, sch := range s.list() {
+			if !sch.next.IsZero() && !sch.next.After(now) {
+				_, _ = s.run(ctx, sch)
+				sch.next = sch.cron.next(now)
+			}
+			if !sch.next.IsZero() && (wake.IsZero() || sch.next.Before(wake)) {
+				wake = sch.next
+			}
+		}
+		s.mu.Unlock()
+
+		sleep := time.Hour
+		if !wake.IsZero() {
+			sleep = wake.Sub(now)
+		}
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.kick:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// run starts the command of sch as a job unless the last one is still
+// running - call with lock held.
+//
+// It returns the job ID or 0 if the run was skipped.
+func (s *Scheduler) run(ctx context.Context, sch *Schedule) (jobID int64, err error) {
+	if sch.running {
+		sch.Skipped++
+		fs.Logf(nil, "rc schedule %q: skipping run as job %d is still running", sch.Name, sch.LastJobID)
+		return 0, nil
+	}
+	call := rc.Calls.Get(sch.Command)
+	if call == nil {
+		err = fmt.Errorf("couldn't find rc command %q", sch.Command)
+		sch.LastError = err.Error()
+		fs.Errorf(nil, "rc schedule %q: %v", sch.Name, err)
+		return 0, err
+	}
+	in := sch.Params.Copy()
+	in["_async"] = true
+	in["_group"] = "schedule/" + sch.Name
+	job, out, err := jobs.NewJob(ctx, call.Fn, in)
+	if err != nil {
+		sch.LastError = err.Error()
+		fs.Errorf(nil, "rc schedule %q: failed to start job: %v", sch.Name, err)
+		return 0, err
+	}
+	sch.running = true
+	sch.LastRun = s.now()
+	sch.LastJobID = job.ID
+	sch.LastError = ""
+	sch.Runs++
+	fs.Infof(nil, "rc schedule %q: started %q as job %d", sch.Name, sch.Command, job.ID)
+	job.OnFinish(func() {
+		// This may be called straight away so don't take the lock here
+		go s.finished(sch, job)
+	})
+	if err := s.save(); err != nil {
+		fs.Errorf(nil, "rc schedule %q: %v", sch.Name, err)
+	}
+	return out.GetInt64("jobid")
+}
+
+// finished is called when the job started by sch has finished
+func (s *Scheduler) finished(sch *Schedule, job *jobs.Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sch.LastJobID != job.ID {
+		return
+	}
+	sch.running = false
+	if err := job.Err(); err != nil {
+		sch.LastError = err.Error()
+	}
+	if s.schedules[sch.Name] != sch {
+		return // schedule was removed
+	}
+	if err := s.save(); err != nil {
+		fs.Errorf(nil, "rc schedule %q: %v", sch.Name, err)
+	}
+}
+
+// add a schedule
+func (s *Scheduler) add(sch *Schedule) error {
+	var err error
+	sch.cron, err = parseCron(sch.Spec)
+	if err != nil {
+		return err
+	}
+	call := rc.Calls.Get(sch.Command)
+	if call == nil {
+		return fmt.Errorf("couldn't find rc command %q", sch.Command)
+	}
+	if call.NeedsRequest || call.NeedsResponse {
+		return fmt.Errorf("rc command %q can't be scheduled", sch.Command)
+	}
+	if sch.Params == nil {
+		sch.Params = rc.Params{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	if sch.Name == "" {
+		for i := len(s.schedules) + 1; ; i++ {
+			sch.Name = strconv.Itoa(i)
+			if s.schedules[sch.Name] == nil {
+				break
+			}
+		}
+	}
+	if s.schedules[sch.Name] != nil {
+		return fmt.Errorf("rc schedule %q already exists", sch.Name)
+	}
+	sch.Created = s.now()
+	sch.next = sch.cron.next(sch.Created)
+	if sch.next.IsZero() {
+		return fmt.Errorf("cron expression %q never runs", sch.Spec)
+	}
+	s.schedules[sch.Name] = sch
+	if err := s.save(); err != nil {
+		delete(s.schedules, sch.Name)
+		return err
+	}
+	s.poke()
+	return nil
+}
+
+// get the schedule called name - call with lock held
+func (s *Scheduler) get(name string) (*Schedule, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	sch := s.schedules[name]
+	if sch == nil {
+		return nil, fmt.Errorf("rc schedule %q not found", name)
+	}
+	return sch, nil
+}
+
+// remove the schedule called name
+func (s *Scheduler) remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.get(name); err != nil {
+		return err
+	}
+	delete(s.schedules, name)
+	if err := s.save(); err != nil {
+		return err
+	}
+	s.poke()
+	return nil
+}
+
+// toParams returns the state of the schedule as rc.Params - call with
+// lock held
+func (sch *Schedule) toParams() (out rc.Params, err error) {
+	out = make(rc.Params)
+	err = rc.Reshape(&out, sch)
+	if err != nil {
+		return nil, err
+	}
+	out["params"] = rc.RedactParams(sch.Params)
+	out["running"] = sch.running
+	if !sch.next.IsZero() {
+		out["next"] = sch.next
+	}
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "schedule/add",
+		AuthRequired: true,
+		Fn:           rcAdd,
+		Title:        "Add a schedule to run an rc command regularly",
+		Help: `This adds a schedule which runs an rc command as an async job
+at the times given by a cron expression. The schedules are saved to
+disk and are run while the rc server is running.
+
+If the job started by the last run of the schedule is still running
+when the schedule is next due then that run is skipped.
+
+The jobs are put in the stats group "schedule/NAME" so their progress
+can be seen with core/stats and job/status.
+
+Parameters:
+
+- name - name of the schedule (optional - a number is used if not set)
+- schedule - cron expression, e.g. "30 2 * * *" to run at 02:30 every day
+- command - rc command to run, e.g. "sync/sync"
+- params - object with the parameters for the command (optional)
+
+The cron expression has 5 fields: minute, hour, day of month, month
+and day of week. Each may be "*", a number, a list, a range or a step,
+e.g. "*/15", "1-5" or "mon,wed,fri". The shortcuts "@yearly",
+"@monthly", "@weekly", "@daily" and "@hourly" may be used, as may
+"@every DURATION", e.g. "@every 1h30m". Times are in the local time
+zone.
+
+Eg
+
+    rclone rc schedule/add name=nightly schedule="0 3 * * *" command=sync/sync \
+        params='{"srcFs": "/home/user/files", "dstFs": "remote:backup"}'
+
+Results:
+
+- name - the name of the schedule
+- next - time the schedule will next run
+`,
+	})
+}
+
+// Add a schedule
+func rcAdd(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	sch := &Schedule{}
+	sch.Name, err = in.GetString("name")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	sch.Spec, err = in.GetString("schedule")
+	if err != nil {
+		return nil, err
+	}
+	sch.Command, err = in.GetString("command")
+	if err != nil {
+		return nil, err
+	}
+	err = in.GetStruct("params", &sch.Params)
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	err = scheduler.add(sch)
+	if err != nil {
+		return nil, err
+	}
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	return rc.Params{
+		"name": sch.Name,
+		"next": sch.next,
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "schedule/list",
+		AuthRequired: true,
+		Fn:           rcList,
+		Title:        "List the rc schedules",
+		Help: `Parameters: None.
+
+Results:
+
+- schedules - array of schedules, each with
+    - name - name of the schedule
+    - schedule - the cron expression
+    - command - the rc command run
+    - params - the parameters for the command with passwords and keys removed
+    - created - when the schedule was added
+    - next - when the schedule will next run
+    - running - true if the job from the last run is running
+    - lastRun - when the schedule last ran
+    - lastJobId - the job ID of the last run
+    - lastError - the error from the last run or empty for none
+    - runs - number of times the schedule has run
+    - skipped - number of runs skipped as the last was still running
+`,
+	})
+}
+
+// List the schedules
+func rcList(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if err := scheduler.load(); err != nil {
+		return nil, err
+	}
+	schedules := []rc.Params{}
+	for _, sch := range scheduler.list() {
+		params, err := sch.toParams()
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, params)
+	}
+	return rc.Params{"schedules": schedules}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "schedule/remove",
+		AuthRequired: true,
+		Fn:           rcRemove,
+		Title:        "Remove an rc schedule",
+		Help: `This removes the schedule so it won't run again. Any job it
+started will carry on running - use job/stop to stop it.
+
+Parameters:
+
+- name - name of the schedule
+`,
+	})
+}
+
+// Remove a schedule
+func rcRemove(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	name, err := in.GetString("name")
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{}, scheduler.remove(name)
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "schedule/run",
+		AuthRequired: true,
+		Fn:           rcRun,
+		Title:        "Run an rc schedule now",
+		Help: `This runs the command of the schedule straight away as an async
+job. It doesn't change when the schedule next runs.
+
+If the job from the last run of the schedule is still running then
+the schedule isn't run and jobid is returned as 0.
+
+Parameters:
+
+- name - name of the schedule
+
+Results:
+
+- jobid - ID of the job started, or 0 if not started
+`,
+	})
+}
+
+// Run a schedule now
+func rcRun(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	name, err := in.GetString("name")
+	if err != nil {
+		return nil, err
+	}
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	sch, err := scheduler.get(name)
+	if err != nil {
+		return nil, err
+	}
+	// Run the job in the background so it isn't stopped with this call
+	jobID, err := scheduler.run(context.Background(), sch)
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{"jobid": jobID}, nil
+}
@@ -0,0 +1,191 @@
+package schedule
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	_ "github.com/rclone/rclone/fs/rc/jobs" // for job/status
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// channels the test/schedule call blocks on
+var (
+	testCalled  = make(chan rc.Params, 10)
+	testRelease = make(chan struct{})
+)
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "test/schedule",
+		Title: "Blocks until released for testing the scheduler",
+		Fn: func(ctx context.Context, in rc.Params) (rc.Params, error) {
+			testCalled <- in
+			<-testRelease
+			return rc.Params{}, nil
+		},
+	})
+}
+
+// use a fresh scheduler saving to a temporary file for the test
+func newTestScheduler(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	oldScheduler := scheduler
+	scheduler = newScheduler(path)
+	t.Cleanup(func() {
+		scheduler = oldScheduler
+	})
+	return path
+}
+
+func callRc(t *testing.T, path string, in rc.Params) (rc.Params, error) {
+	call := rc.Calls.Get(path)
+	require.NotNil(t, call, path)
+	return call.Fn(context.Background(), in)
+}
+
+func TestScheduleAddListRemove(t *testing.T) {
+	path := newTestScheduler(t)
+
+	_, err := callRc(t, "schedule/add", rc.Params{"schedule": "bad", "command": "rc/noop"})
+	assert.ErrorContains(t, err, "5 fields")
+	_, err = callRc(t, "schedule/add", rc.Params{"schedule": "@daily", "command": "potato/potato"})
+	assert.ErrorContains(t, err, "couldn't find rc command")
+
+	out, err := callRc(t, "schedule/add", rc.Params{
+		"name":     "nightly",
+		"schedule": "0 3 * * *",
+		"command":  "rc/noop",
+		"params":   rc.Params{"potato": 1, "password": "secret"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "nightly", out["name"])
+	next := out["next"].(time.Time)
+	assert.Equal(t, 3, next.Hour())
+
+	_, err = callRc(t, "schedule/add", rc.Params{"name": "nightly", "schedule": "@daily", "command": "rc/noop"})
+	assert.ErrorContains(t, err, "already exists")
+
+	out, err = callRc(t, "schedule/add", rc.Params{"schedule": "@hourly", "command": "rc/noop"})
+	require.NoError(t, err)
+	assert.Equal(t, "2", out["name"])
+
+	out, err = callRc(t, "schedule/list", rc.Params{})
+	require.NoError(t, err)
+	schedules := out["schedules"].([]rc.Params)
+	require.Equal(t, 2, len(schedules))
+	assert.Equal(t, "2", schedules[0]["name"])
+	assert.Equal(t, "nightly", schedules[1]["name"])
+	assert.Equal(t, "0 3 * * *", schedules[1]["schedule"])
+	assert.Equal(t, "rc/noop", schedules[1]["command"])
+	assert.Equal(t, rc.Params{"potato": float64(1), "password": "XXX"}, schedules[1]["params"])
+	assert.Equal(t, false, schedules[1]["running"])
+
+	// Check the schedules are read back from disk
+	scheduler = newScheduler(path)
+	out, err = callRc(t, "schedule/list", rc.Params{})
+	require.NoError(t, err)
+	schedules = out["schedules"].([]rc.Params)
+	require.Equal(t, 2, len(schedules))
+	assert.Equal(t, "nightly", schedules[1]["name"])
+	assert.Equal(t, next, schedules[1]["next"])
+
+	_, err = callRc(t, "schedule/remove", rc.Params{"name": "nightly"})
+	require.NoError(t, err)
+	_, err = callRc(t, "schedule/remove", rc.Params{"name": "nightly"})
+	assert.ErrorContains(t, err, "not found")
+
+	scheduler = newScheduler(path)
+	out, err = callRc(t, "schedule/list", rc.Params{})
+	require.NoError(t, err)
+	schedules = out["schedules"].([]rc.Params)
+	require.Equal(t, 1, len(schedules))
+	assert.Equal(t, "2", schedules[0]["name"])
+}
+
+func TestScheduleRunOverlap(t *testing.T) {
+	newTestScheduler(t)
+
+	_, err := callRc(t, "schedule/add", rc.Params{
+		"name":     "test",
+		"schedule": "@yearly",
+		"command":  "test/schedule",
+		"params":   rc.Params{"potato": "jersey"},
+	})
+	require.NoError(t, err)
+
+	out, err := callRc(t, "schedule/run", rc.Params{"name": "test"})
+	require.NoError(t, err)
+	jobID := out["jobid"].(int64)
+	assert.NotEqual(t, int64(0), jobID)
+	in := <-testCalled
+	assert.Equal(t, rc.Params{"potato": "jersey"}, in)
+
+	// Stats are in the schedule's group
+	out, err = callRc(t, "job/status", rc.Params{"jobid": jobID})
+	require.NoError(t, err)
+	assert.Equal(t, "schedule/test", out["group"])
+
+	// Running again while the job is running is skipped
+	out, err = callRc(t, "schedule/run", rc.Params{"name": "test"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), out["jobid"])
+
+	out, err = callRc(t, "schedule/list", rc.Params{})
+	require.NoError(t, err)
+	sch := out["schedules"].([]rc.Params)[0]
+	assert.Equal(t, true, sch["running"])
+	assert.Equal(t, float64(1), sch["runs"])
+	assert.Equal(t, float64(1), sch["skipped"])
+	assert.Equal(t, float64(jobID), sch["lastJobId"])
+
+	// Once finished it can run again
+	testRelease <- struct{}{}
+	assert.Eventually(t, func() bool {
+		scheduler.mu.Lock()
+		defer scheduler.mu.Unlock()
+		return !scheduler.schedules["test"].running
+	}, 5*time.Second, 10*time.Millisecond)
+
+	out, err = callRc(t, "schedule/run", rc.Params{"name": "test"})
+	require.NoError(t, err)
+	assert.NotEqual(t, int64(0), out["jobid"])
+	<-testCalled
+	testRelease <- struct{}{}
+
+	// Wait for the schedule to be saved before the directory is removed
+	assert.Eventually(t, func() bool {
+		scheduler.mu.Lock()
+		defer scheduler.mu.Unlock()
+		return !scheduler.schedules["test"].running
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = callRc(t, "schedule/run", rc.Params{"name": "potato"})
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestScheduleLoop(t *testing.T) {
+	newTestScheduler(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, scheduler.start(ctx))
+
+	_, err := callRc(t, "schedule/add", rc.Params{
+		"name":     "often",
+		"schedule": "@every 1s",
+		"command":  "test/schedule",
+	})
+	require.NoError(t, err)
+
+	select {
+	case <-testCalled:
+	case <-time.After(10 * time.Second):
+		t.Fatal("schedule didn't run")
+	}
+	_, err = callRc(t, "schedule/remove", rc.Params{"name": "often"})
+	require.NoError(t, err)
+	testRelease <- struct{}{}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/rclone/rclone/fs/march"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/lib/errcount"
+	"github.com/rclone/rclone/lib/transform"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -129,6 +130,9 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 	if (deleteMode != fs.DeleteModeOff || DoMove) && operations.OverlappingFilterCheck(ctx, fdst, fsrc) {
 		return nil, fserrors.FatalError(fs.ErrorOverlapping)
 	}
+	if err := transform.Check(ctx); err != nil {
+		return nil, fserrors.FatalError(err)
+	}
 	ci := fs.GetConfig(ctx)
 	fi := filter.GetConfig(ctx)
 	s := &syncCopyMove{
@@ -388,12 +392,12 @@ func (s *syncCopyMove) pairChecker(in *pipe, out *pipe, fraction int, wg *sync.W
 				}
 			}
 			// Fix case for case insensitive filesystems
-			if s.ci.FixCase && !s.ci.Immutable && src.Remote() != pair.Dst.Remote() {
-				if newDst, err := operations.Move(s.ctx, s.fdst, nil, src.Remote(), pair.Dst); err != nil {
-					fs.Errorf(pair.Dst, "Error while attempting to rename to %s: %v", src.Remote(), err)
+			if dstRemote, err := s.dstRemote(src.Remote(), false); err == nil && s.ci.FixCase && !s.ci.Immutable && dstRemote != pair.Dst.Remote() {
+				if newDst, err := operations.Move(s.ctx, s.fdst, nil, dstRemote, pair.Dst); err != nil {
+					fs.Errorf(pair.Dst, "Error while attempting to rename to %s: %v", dstRemote, err)
 					s.processError(err)
 				} else {
-					fs.Infof(pair.Dst, "Fixed case by renaming to: %s", src.Remote())
+					fs.Infof(pair.Dst, "Fixed case by renaming to: %s", dstRemote)
 					pair.Dst = newDst
 				}
 			}
@@ -407,7 +411,7 @@ func (s *syncCopyMove) pairChecker(in *pipe, out *pipe, fraction int, wg *sync.W
 					if pair.Dst != nil {
 						s.markDirModifiedObject(pair.Dst)
 					} else {
-						s.markDirModifiedObject(src)
+						s.markDirModifiedRemote(src.Remote())
 					}
 					// If destination already exists, then we must move it into --backup-dir if required
 					if pair.Dst != nil && s.backupDir != nil {
@@ -490,15 +494,19 @@ func (s *syncCopyMove) pairCopyOrMove(ctx context.Context, in *pipe, fdst fs.Fs,
 		}
 		src := pair.Src
 		dst := pair.Dst
-		if s.DoMove {
-			if src != dst {
-				_, err = operations.MoveTransfer(ctx, fdst, dst, src.Remote(), src)
-			} else {
-				// src == dst signals delete the src
-				err = operations.DeleteFile(ctx, src)
-			}
-		} else {
-			_, err = operations.Copy(ctx, fdst, dst, src.Remote(), src)
+		var dstRemote string
+		dstRemote, err = s.dstRemote(src.Remote(), false)
+		switch {
+		case err != nil:
+			err = fs.CountError(err)
+			fs.Errorf(src, "Can't transfer: %v", err)
+		case s.DoMove && src != dst:
+			_, err = operations.MoveTransfer(ctx, fdst, dst, dstRemote, src)
+		case s.DoMove:
+			// src == dst signals delete the src
+			err = operations.DeleteFile(ctx, src)
+		default:
+			_, err = operations.Copy(ctx, fdst, dst, dstRemote, src)
 		}
 		s.processError(err)
 		if err != nil {
@@ -702,8 +710,14 @@ func (s *syncCopyMove) markParentNotEmpty(entry fs.DirEntry) {
 	defer s.srcEmptyDirsMu.Unlock()
 	// Mark entry as potentially empty if it is a directory
 	_, isDir := entry.(fs.Directory)
+	// Empty directories are keyed by their name in the destination
+	dstRemote, err := s.dstRemote(entry.Remote(), isDir)
+	if err != nil {
+		// march has reported this already
+		dstRemote = entry.Remote()
+	}
 	if isDir {
-		s.srcEmptyDirs[entry.Remote()] = entry
+		s.srcEmptyDirs[dstRemote] = entry
 		// if DoMove and --delete-empty-src-dirs flag is set then record the parent but
 		// don't remove any as we are about to move files out of them them making the
 		// directory empty.
@@ -711,7 +725,7 @@ func (s *syncCopyMove) markParentNotEmpty(entry fs.DirEntry) {
 			s.srcMoveEmptyDirs[entry.Remote()] = entry
 		}
 	}
-	parentDir := path.Dir(entry.Remote())
+	parentDir := path.Dir(dstRemote)
 	if isDir && s.copyEmptySrcDirs {
 		// Mark its parent as not empty
 		if parentDir == "." {
@@ -895,10 +909,15 @@ func (s *syncCopyMove) tryRename(src fs.Object) bool {
 	}
 
 	// Find dst object we are about to overwrite if it exists
-	dstOverwritten, _ := s.fdst.NewObject(s.ctx, src.Remote())
+	dstRemote, err := s.dstRemote(src.Remote(), false)
+	if err != nil {
+		fs.Debugf(src, "Failed to rename: %v", err)
+		return false
+	}
+	dstOverwritten, _ := s.fdst.NewObject(s.ctx, dstRemote)
 
-	// Rename dst to have name src.Remote()
-	_, err := operations.Move(s.ctx, s.fdst, dstOverwritten, src.Remote(), dst)
+	// Rename dst to have name dstRemote
+	_, err = operations.Move(s.ctx, s.fdst, dstOverwritten, dstRemote, dst)
 	if err != nil {
 		fs.Debugf(src, "Failed to rename to %q: %v", dst.Remote(), err)
 		return false
@@ -948,6 +967,7 @@ func (s *syncCopyMove) run() error {
 		DstIncludeAll:          s.fi.Opt.DeleteExcluded,
 		NoCheckDest:            s.noCheckDest,
 		NoUnicodeNormalization: s.noUnicodeNormalization,
+		SrcMayChange:           s.DoMove,
 	}
 	s.processError(m.Run(s.ctx))
 
@@ -1097,6 +1117,27 @@ func (s *syncCopyMove) markDirModifiedObject(o fs.Object) {
 	s.markDirModified(dir)
 }
 
+// like markDirModifiedObject, but accepts the remote of a source
+// object which may be renamed by --name-transform.
+func (s *syncCopyMove) markDirModifiedRemote(remote string) {
+	dstRemote, err := s.dstRemote(remote, false)
+	if err != nil {
+		// march has reported this already
+		dstRemote = remote
+	}
+	dir := path.Dir(dstRemote)
+	if dir == "." {
+		dir = ""
+	}
+	s.markDirModified(dir)
+}
+
+// dstRemote returns the name remote in the source will have in the
+// destination once --name-transform has been applied.
+func (s *syncCopyMove) dstRemote(remote string, isDir bool) (string, error) {
+	return transform.Path(s.ctx, remote, isDir)
+}
+
 // copyDirMetadata copies the src directory modTime or Metadata to dst
 // or f if nil. If dst is nil then it uses dir as the name of the new
 // directory.
@@ -1246,7 +1287,7 @@ func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 			if !NoNeedTransfer {
 				// No need to check since doesn't exist
 				fs.Debugf(src, "Need to transfer - File not found at Destination")
-				s.markDirModifiedObject(x)
+				s.markDirModifiedRemote(x.Remote())
 				ok := s.toBeUploaded.Put(s.inCtx, fs.ObjectPair{Src: x, Dst: nil})
 				if !ok {
 					return
@@ -1259,8 +1300,13 @@ func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 		s.logger(s.ctx, operations.MissingOnDst, src, nil, fs.ErrorIsDir)
 
 		// Create the directory and make sure the Metadata/ModTime is correct
-		s.copyDirMetadata(s.ctx, s.fdst, nil, x.Remote(), x)
-		s.markDirModified(x.Remote())
+		dstRemote, err := s.dstRemote(x.Remote(), true)
+		if err != nil {
+			// march has reported this already
+			return false
+		}
+		s.copyDirMetadata(s.ctx, s.fdst, nil, dstRemote, x)
+		s.markDirModified(dstRemote)
 		return true
 	default:
 		panic("Bad object in DirEntries")
@@ -1300,15 +1346,15 @@ func (s *syncCopyMove) Match(ctx context.Context, dst, src fs.DirEntry) (recurse
 			// Create the directory and make sure the Metadata/ModTime is correct
 			s.copyDirMetadata(s.ctx, s.fdst, dstX, "", srcX)
 
-			if s.ci.FixCase && !s.ci.Immutable && src.Remote() != dst.Remote() {
+			if dstRemote, err := s.dstRemote(src.Remote(), true); err == nil && s.ci.FixCase && !s.ci.Immutable && dstRemote != dst.Remote() {
 				// Fix case for case insensitive filesystems
 				// Fix each dir before recursing into subdirs and files
-				err := operations.DirMoveCaseInsensitive(s.ctx, s.fdst, dst.Remote(), src.Remote())
+				err := operations.DirMoveCaseInsensitive(s.ctx, s.fdst, dst.Remote(), dstRemote)
 				if err != nil {
-					fs.Errorf(dst, "Error while attempting to rename to %s: %v", src.Remote(), err)
+					fs.Errorf(dst, "Error while attempting to rename to %s: %v", dstRemote, err)
 					s.processError(err)
 				} else {
-					fs.Infof(dst, "Fixed case by renaming to: %s", src.Remote())
+					fs.Infof(dst, "Fixed case by renaming to: %s", dstRemote)
 				}
 			}
 
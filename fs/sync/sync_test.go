@@ -274,6 +274,121 @@ func TestCopyWithDepth(t *testing.T) {
 	r.CheckRemoteItems(t, file2)
 }
 
+// Test copy and sync with --name-transform
+func TestSyncNameTransform(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	file1 := r.WriteFile("sub dir/hello world.txt", "hello world", t1)
+	file2 := r.WriteFile("potato", "hello world2", t2)
+	r.Mkdir(ctx, r.Fremote)
+
+	ci.NameTransform = []string{"suffix_keep_extension=-v1", "dir,uppercase"}
+
+	err := CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+
+	file1dst := file1
+	file1dst.Path = "SUB DIR/hello world-v1.txt"
+	file2dst := file2
+	file2dst.Path = "potato-v1"
+	r.CheckLocalItems(t, file1, file2)
+	r.CheckRemoteItems(t, file1dst, file2dst)
+
+	// A second sync should match up the renamed files and only
+	// delete the extra one
+	r.WriteObject(ctx, "extra", "delete me", t1)
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), accounting.GlobalStats().GetTransfers())
+	r.CheckRemoteListing(t, []fstest.Item{file1dst, file2dst}, []string{"SUB DIR"})
+
+	// Check an invalid transform is rejected
+	ci.NameTransform = []string{"potato"}
+	err = CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+}
+
+// Test copy with --name-transform and --no-traverse
+func TestSyncNameTransformNoTraverse(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	file1 := r.WriteFile("sub dir/hello world.txt", "hello world", t1)
+	r.Mkdir(ctx, r.Fremote)
+
+	ci.NameTransform = []string{"all,uppercase"}
+	ci.NoTraverse = true
+
+	err := CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+
+	file1dst := file1
+	file1dst.Path = "SUB DIR/HELLO WORLD.TXT"
+	r.CheckRemoteItems(t, file1dst)
+
+	// The second copy should find the renamed file without
+	// listing the destination
+	accounting.GlobalStats().ResetCounters()
+	err = CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), accounting.GlobalStats().GetTransfers())
+	r.CheckRemoteItems(t, file1dst)
+}
+
+// Test sync with --name-transform and --track-renames
+func TestSyncNameTransformTrackRenames(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+
+	haveHash := r.Fremote.Hashes().Overlap(r.Flocal.Hashes()).GetOne() != hash.None
+	if !haveHash || !operations.CanServerSideMove(r.Fremote) {
+		t.Skip("can't track renames")
+	}
+
+	ci.NameTransform = []string{"prefix=x-"}
+	ci.TrackRenames = true
+
+	file1 := r.WriteFile("potato", "Potato Content", t1)
+	require.NoError(t, Sync(ctx, r.Fremote, r.Flocal, false))
+	file1dst := file1
+	file1dst.Path = "x-potato"
+	r.CheckRemoteItems(t, file1dst)
+
+	// Now rename locally and check the remote is renamed to the
+	// transformed name rather than uploaded again
+	file1 = r.RenameFile(file1, "yam")
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, Sync(ctx, r.Fremote, r.Flocal, false))
+	file1dst.Path = "x-yam"
+	r.CheckRemoteItems(t, file1dst)
+	assert.NotEqual(t, int64(0), accounting.GlobalStats().Renames(0))
+	assert.Equal(t, int64(0), accounting.GlobalStats().GetTransfers())
+}
+
+// Test --name-transform making invalid names is an error
+func TestSyncNameTransformInvalid(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	file1 := r.WriteFile("potato", "Potato Content", t1)
+	file2 := r.WriteFile("yam", "Yam Content", t1)
+	r.Mkdir(ctx, r.Fremote)
+
+	// This makes "potato" into "p/tat/" which isn't allowed
+	ci.NameTransform = []string{"replace=o:/"}
+	accounting.GlobalStats().ResetCounters()
+	err := CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+	r.CheckLocalItems(t, file1, file2)
+	r.CheckRemoteItems(t, file2)
+
+	// Don't leave the error in the stats for the following tests
+	accounting.GlobalStats().ResetCounters()
+}
+
 // Test copy with files from
 func testCopyWithFilesFrom(t *testing.T, noTraverse bool) {
 	ctx := context.Background()
@@ -0,0 +1,131 @@
+// Package tracing exports OpenTelemetry traces of what rclone is doing
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/rclone/rclone/fs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OptionsInfo describes the Options in use
+var OptionsInfo = fs.Options{{
+	Name:    "trace_file",
+	Default: "",
+	Help:    "Write OpenTelemetry trace spans to this file as JSON",
+	Groups:  "Tracing",
+}, {
+	Name:    "trace_endpoint",
+	Default: "",
+	Help:    "Send OpenTelemetry trace spans to this OTLP/HTTP endpoint, e.g. http://localhost:4318",
+	Groups:  "Tracing",
+}, {
+	Name:    "trace_service_name",
+	Default: "rclone",
+	Help:    "Service name to put in the trace spans",
+	Groups:  "Tracing",
+}}
+
+// Options contains options for controlling tracing
+type Options struct {
+	File        string `config:"trace_file"`         // Write spans to this file
+	Endpoint    string `config:"trace_endpoint"`     // Send spans to this OTLP/HTTP endpoint
+	ServiceName string `config:"trace_service_name"` // Service name for the spans
+}
+
+func init() {
+	fs.RegisterGlobalOptions(fs.OptionsInfo{Name: "tracing", Opt: &Opt, Options: OptionsInfo})
+}
+
+// Opt is the options for tracing
+var Opt Options
+
+// name of the tracer which makes rclone's spans
+const tracerName = "github.com/rclone/rclone"
+
+// set if Start has set up exporting
+var enabled atomic.Bool
+
+// Enabled returns true if traces are being exported. It can be used
+// to skip work which is only needed for tracing.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Start starts exporting traces if any of the tracing options are
+// set.
+//
+// It returns a function which should be called to flush any spans
+// not yet exported when rclone exits.
+func Start(ctx context.Context, opt *Options) (shutdown func(), err error) {
+	if opt.File == "" && opt.Endpoint == "" {
+		return func() {}, nil
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(opt.ServiceName),
+		semconv.ServiceVersion(fs.Version),
+	)
+	tpOpts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	var closers []func() error
+	if opt.File != "" {
+		out, err := os.Create(opt.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		closers = append(closers, out.Close)
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(out))
+		if err != nil {
+			_ = out.Close()
+			return nil, fmt.Errorf("failed to make trace file exporter: %w", err)
+		}
+		tpOpts = append(tpOpts, sdktrace.WithBatcher(exporter))
+	}
+	if opt.Endpoint != "" {
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(opt.Endpoint))
+		if err != nil {
+			return nil, fmt.Errorf("failed to make OTLP trace exporter: %w", err)
+		}
+		tpOpts = append(tpOpts, sdktrace.WithBatcher(exporter))
+	}
+	tp := sdktrace.NewTracerProvider(tpOpts...)
+	otel.SetTracerProvider(tp)
+	enabled.Store(true)
+	fs.Debugf(nil, "Tracing started")
+	return func() {
+		enabled.Store(false)
+		err := tp.Shutdown(context.Background())
+		for _, closer := range closers {
+			err = errors.Join(err, closer())
+		}
+		if err != nil {
+			fs.Errorf(nil, "Failed to flush traces: %v", err)
+		}
+	}, nil
+}
+
+// StartSpan starts a span called name as a child of any span in ctx.
+//
+// The span must be finished with EndSpan.
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// EndSpan ends span recording err if it is set
+func EndSpan(span trace.Span, err error, opts ...trace.SpanEndOption) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(opts...)
+}
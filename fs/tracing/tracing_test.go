@@ -0,0 +1,90 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/tracing"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// span is the part of the spans written to the trace file we check
+type span struct {
+	Name        string
+	SpanContext struct {
+		SpanID string
+	}
+	Parent struct {
+		SpanID string
+	}
+	Status struct {
+		Code string
+	}
+}
+
+// readSpans reads the spans from the trace file
+func readSpans(t *testing.T, traceFile string) map[string]span {
+	in, err := os.Open(traceFile)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, in.Close()) }()
+	spans := map[string]span{}
+	dec := json.NewDecoder(in)
+	for {
+		var s span
+		err := dec.Decode(&s)
+		if err == io.EOF {
+			return spans
+		}
+		require.NoError(t, err)
+		spans[s.Name] = s
+	}
+}
+
+func TestStart(t *testing.T) {
+	ctx := context.Background()
+
+	// Check nothing happens with no options
+	stop, err := tracing.Start(ctx, &tracing.Options{})
+	require.NoError(t, err)
+	stop()
+	assert.False(t, tracing.Enabled())
+
+	traceFile := filepath.Join(t.TempDir(), "trace.json")
+	stop, err = tracing.Start(ctx, &tracing.Options{File: traceFile, ServiceName: "rclone"})
+	require.NoError(t, err)
+	assert.True(t, tracing.Enabled())
+
+	jobCtx, job := tracing.StartSpan(ctx, "job")
+
+	// A transfer started with StartSpan is a parent of its calls
+	tr := accounting.Stats(jobCtx).NewTransfer(mockobject.Object("file1"), nil)
+	trCtx := tr.StartSpan(jobCtx)
+	_, call := tracing.StartSpan(trCtx, "call")
+	tracing.EndSpan(call, errors.New("boom"))
+	tr.Done(trCtx, nil)
+
+	// A transfer which isn't is traced when it is done
+	tr = accounting.Stats(jobCtx).NewCheckingTransfer(mockobject.Object("file2"), "hashing")
+	tr.Done(jobCtx, nil)
+
+	tracing.EndSpan(job, nil)
+	stop()
+	assert.False(t, tracing.Enabled())
+
+	spans := readSpans(t, traceFile)
+	require.Len(t, spans, 4)
+	jobID := spans["job"].SpanContext.SpanID
+	assert.Equal(t, jobID, spans["transfer"].Parent.SpanID)
+	assert.Equal(t, jobID, spans["hashing"].Parent.SpanID)
+	assert.Equal(t, spans["transfer"].SpanContext.SpanID, spans["call"].Parent.SpanID)
+	assert.Equal(t, "Error", spans["call"].Status.Code)
+	assert.Equal(t, "Unset", spans["job"].Status.Code)
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fs/dirtree"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/list"
@@ -356,7 +357,12 @@ func walkListR(ctx context.Context, f fs.Fs, path string, includeAll bool, maxLe
 	if listR == nil {
 		return ErrorCantListR
 	}
-	return walkR(ctx, f, path, includeAll, maxLevel, fn, listR)
+	return walkR(ctx, f, path, includeAll, maxLevel, fn, func(ctx context.Context, dir string, callback fs.ListRCallback) error {
+		done := accounting.Observe(f, "ListR")
+		err := listR(ctx, dir, callback)
+		done(err)
+		return err
+	})
 }
 
 type listDirFunc func(ctx context.Context, fs fs.Fs, includeAll bool, dir string) (entries fs.DirEntries, err error)
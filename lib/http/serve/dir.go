@@ -37,6 +37,8 @@ type Directory struct {
 	Breadcrumb   []Crumb
 	Sort         string
 	Order        string
+	AllowZip     bool // set if the directory can be downloaded with ?download=zip
+	AllowWrite   bool // set if files can be uploaded, directories made and entries deleted
 }
 
 // Crumb is a breadcrumb entry
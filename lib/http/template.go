@@ -41,6 +41,8 @@ to be used within the template to server pages:
 |-- .IsDir    | Boolean for if an entry is a directory or not. |
 |-- .Size     | Size in Bytes of the entry. |
 |-- .ModTime  | The UTC timestamp of an entry. |
+| .AllowZip   | Boolean for if the directory can be downloaded as a zip with ?download=zip |
+| .AllowWrite | Boolean for if files can be uploaded, directories made and entries deleted with a POST |
 
 The server also makes the following functions available so that they can be used within the
 template. These functions help extend the options for dynamic rendering of HTML. They can
@@ -55,10 +55,20 @@ type UploadMultipartOptions struct {
 //
 // It returns the chunkWriter used in case the caller needs to extract any private info from it.
 func UploadMultipart(ctx context.Context, src fs.ObjectInfo, in io.Reader, opt UploadMultipartOptions) (chunkWriterOut fs.ChunkWriter, err error) {
-	info, chunkWriter, err := opt.Open.OpenChunkWriter(ctx, src.Remote(), src, opt.OpenOptions...)
+	var resumer *Resumer
+	if f, ok := opt.Open.(fs.Fs); ok {
+		resumer = NewResumer(ctx, f, src.Remote(), src)
+	}
+	over := false
+	defer func() {
+		resumer.Finish(over)
+	}()
+
+	info, chunkWriter, err := opt.Open.OpenChunkWriter(ctx, src.Remote(), src, resumer.OpenOptions(opt.OpenOptions)...)
 	if err != nil {
 		return nil, fmt.Errorf("multipart upload failed to initialise: %w", err)
 	}
+	resumable := resumer.Start(info, chunkWriter)
 
 	// make concurrency machinery
 	concurrency := info.Concurrency
@@ -74,10 +84,16 @@ func UploadMultipart(ctx context.Context, src fs.ObjectInfo, in io.Reader, opt U
 		if info.LeavePartsOnError {
 			return
 		}
+		if resumable {
+			fs.Debugf(src, "Leaving multipart upload so it can be resumed")
+			return
+		}
 		fs.Debugf(src, "Cancelling multipart upload")
 		errCancel := chunkWriter.Abort(ctx)
 		if errCancel != nil {
 			fs.Debugf(src, "Failed to cancel multipart upload: %v", errCancel)
+		} else {
+			over = true // nothing left to resume
 		}
 	})()
 
@@ -114,8 +130,15 @@ func UploadMultipart(ctx context.Context, src fs.ObjectInfo, in io.Reader, opt U
 		}
 
 		// Read the chunk
-		var n int64
-		n, err = io.CopyN(rw, in, chunkSize)
+		var (
+			n int64
+			w io.Writer = rw
+			h           = resumer.Hasher()
+		)
+		if h != nil {
+			w = io.MultiWriter(rw, h)
+		}
+		n, err = io.CopyN(w, in, chunkSize)
 		if err == io.EOF {
 			if n == 0 && partNum != 0 { // end if no data and if not first chunk
 				free()
@@ -130,10 +153,22 @@ func UploadMultipart(ctx context.Context, src fs.ObjectInfo, in io.Reader, opt U
 		partNum := partNum
 		partOff := off
 		off += n
+		hash := Sum(h)
+		if resumer.Skip(int(partNum), n, hash) {
+			fs.Debugf(src, "multipart upload: skipping chunk %d size %v offset %v/%v as already written", partNum, fs.SizeSuffix(n), fs.SizeSuffix(partOff), fs.SizeSuffix(size))
+			if acc != nil {
+				_ = acc.AccountRead(int(n))
+			}
+			free()
+			continue
+		}
 		g.Go(func() (err error) {
 			defer free()
 			fs.Debugf(src, "multipart upload: starting chunk %d size %v offset %v/%v", partNum, fs.SizeSuffix(n), fs.SizeSuffix(partOff), fs.SizeSuffix(size))
 			_, err = chunkWriter.WriteChunk(gCtx, int(partNum), rw)
+			if err == nil {
+				resumer.Done(int(partNum), n, hash)
+			}
 			return err
 		})
 	}
@@ -147,6 +182,7 @@ func UploadMultipart(ctx context.Context, src fs.ObjectInfo, in io.Reader, opt U
 	if err != nil {
 		return nil, fmt.Errorf("multipart upload: failed to finalise: %w", err)
 	}
+	over = true
 
 	return chunkWriter, nil
 }
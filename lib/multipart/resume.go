@@ -0,0 +1,275 @@
+package multipart
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	gohash "hash"
+	"path"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/kv"
+)
+
+// resumeFacility is the name of the key-value database facility used
+// to store the state of uploads
+const resumeFacility = "resume"
+
+// errNoRecord is returned when there is no record of an upload
+var errNoRecord = errors.New("no record")
+
+// chunkRecord describes a chunk which has been written
+type chunkRecord struct {
+	Size  int64  // size of the chunk
+	Hash  string // MD5 of the chunk, "" if not known
+	State string // what the backend needs to finish the upload
+}
+
+// resumeRecord is the state of an upload as stored in the database
+type resumeRecord struct {
+	Fingerprint string              // fingerprint of the source
+	ChunkSize   int64               // size of the chunks
+	ID          string              // the backend's ID for the upload
+	Chunks      map[int]chunkRecord // chunks written so far by chunk number
+}
+
+// Resumer saves the progress of a multipart upload so that a later
+// run of rclone can carry it on if it is interrupted.
+//
+// A nil *Resumer is valid and does nothing.
+type Resumer struct {
+	db     *kv.DB
+	key    string
+	src    fs.ObjectInfo
+	old    *resumeRecord // record left by an earlier upload if any
+	stale  *resumeRecord // record of an earlier upload of a different source
+	mu     sync.Mutex
+	rec    resumeRecord          // record of this upload
+	writer fs.ChunkWriterResumer // set if the upload can be resumed
+}
+
+// NewResumer returns a Resumer for uploading src to remote on f.
+//
+// It returns nil if --resume-uploads isn't in use or the upload can't
+// be resumed. Call Finish on it when the upload is over.
+func NewResumer(ctx context.Context, f fs.Fs, remote string, src fs.ObjectInfo) *Resumer {
+	if !fs.GetConfig(ctx).ResumeUploads || src.Size() < 0 || !kv.Supported() {
+		return nil
+	}
+	db, err := kv.Start(ctx, resumeFacility, f)
+	if err != nil {
+		fs.Debugf(src, "Can't resume uploads: %v", err)
+		return nil
+	}
+	r := &Resumer{
+		db:  db,
+		key: path.Join("/", f.Root(), remote),
+		src: src,
+		rec: resumeRecord{
+			Fingerprint: fs.Fingerprint(ctx, src, true),
+			Chunks:      map[int]chunkRecord{},
+		},
+	}
+	op := &kvGetResume{key: r.key}
+	err = db.Do(false, op)
+	switch {
+	case err == nil && op.rec.Fingerprint == r.rec.Fingerprint:
+		r.old = &op.rec
+	case err == nil:
+		fs.Debugf(src, "Not resuming upload %q as the source has changed", op.rec.ID)
+		r.stale = &op.rec
+	case !errors.Is(err, errNoRecord) && !errors.Is(err, kv.ErrEmpty):
+		fs.Debugf(src, "Failed to read upload state: %v", err)
+	}
+	return r
+}
+
+// OpenOptions returns options with a fs.ResumeOption added if there
+// is an upload to carry on, or one to abort as the source has changed.
+func (r *Resumer) OpenOptions(options []fs.OpenOption) []fs.OpenOption {
+	if r == nil {
+		return options
+	}
+	if r.stale != nil && r.stale.ID != "" {
+		option := &fs.ResumeOption{
+			ID:    r.stale.ID,
+			Abort: true,
+		}
+		return append(append([]fs.OpenOption(nil), options...), option)
+	}
+	if r.old == nil {
+		return options
+	}
+	chunks := make(map[int]string, len(r.old.Chunks))
+	for chunkNumber, chunk := range r.old.Chunks {
+		chunks[chunkNumber] = chunk.State
+	}
+	option := &fs.ResumeOption{
+		ID:        r.old.ID,
+		ChunkSize: r.old.ChunkSize,
+		Chunks:    chunks,
+	}
+	return append(append([]fs.OpenOption(nil), options...), option)
+}
+
+// Start is called with the ChunkWriter opened for the upload.
+//
+// It returns true if the upload can be resumed, in which case the
+// chunks written shouldn't be removed if the upload fails.
+func (r *Resumer) Start(info fs.ChunkWriterInfo, writer fs.ChunkWriter) bool {
+	if r == nil {
+		return false
+	}
+	resumer, ok := writer.(fs.ChunkWriterResumer)
+	if !ok {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writer = resumer
+	r.rec.ID = resumer.ResumeID()
+	r.rec.ChunkSize = info.ChunkSize
+	if r.old != nil && r.old.ID == r.rec.ID && r.old.ChunkSize == r.rec.ChunkSize {
+		// Only trust the chunks the backend still knows about
+		for chunkNumber, chunk := range r.old.Chunks {
+			if state := resumer.ChunkState(chunkNumber); state != "" && state == chunk.State {
+				r.rec.Chunks[chunkNumber] = chunk
+			}
+		}
+		fs.Infof(r.src, "Resuming upload with %d chunks already written", len(r.rec.Chunks))
+	}
+	r.save()
+	return true
+}
+
+// active returns true if the upload is being saved
+func (r *Resumer) active() bool {
+	return r != nil && r.writer != nil
+}
+
+// Hasher returns a hash to calculate the hash passed to Skip and Done
+// or nil if it isn't needed.
+func (r *Resumer) Hasher() gohash.Hash {
+	if !r.active() {
+		return nil
+	}
+	return md5.New()
+}
+
+// Sum returns the hash of the chunk calculated with h, which may be
+// nil if the hash isn't known.
+func Sum(h gohash.Hash) string {
+	if h == nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Skip returns true if chunkNumber was written by the upload being
+// resumed so doesn't need writing again.
+//
+// hash is the hash of the chunk, or "" to trust the chunk is the same
+// as the source hasn't changed.
+func (r *Resumer) Skip(chunkNumber int, size int64, hash string) bool {
+	if !r.active() {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chunk, ok := r.rec.Chunks[chunkNumber]
+	if !ok || chunk.Size != size {
+		return false
+	}
+	if hash != "" && chunk.Hash != "" && hash != chunk.Hash {
+		fs.Debugf(r.src, "Chunk %d has changed since it was written so writing it again", chunkNumber)
+		return false
+	}
+	return true
+}
+
+// Done records that chunkNumber has been written
+func (r *Resumer) Done(chunkNumber int, size int64, hash string) {
+	if !r.active() {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rec.Chunks[chunkNumber] = chunkRecord{
+		Size:  size,
+		Hash:  hash,
+		State: r.writer.ChunkState(chunkNumber),
+	}
+	r.save()
+}
+
+// save the record - call with the lock held
+func (r *Resumer) save() {
+	err := r.db.Do(true, &kvPutResume{key: r.key, rec: &r.rec})
+	if err != nil {
+		fs.Errorf(r.src, "Failed to save upload state: %v", err)
+	}
+}
+
+// Finish should be called when the upload is over.
+//
+// If the upload was completed or aborted then its record is removed,
+// otherwise it is kept so the upload can be resumed.
+func (r *Resumer) Finish(over bool) {
+	if r == nil {
+		return
+	}
+	if over && (r.active() || r.old != nil) {
+		err := r.db.Do(true, &kvDeleteResume{key: r.key})
+		if err != nil {
+			fs.Debugf(r.src, "Failed to remove upload state: %v", err)
+		}
+	}
+	if err := r.db.Stop(false); err != nil {
+		fs.Debugf(r.src, "Failed to close upload state: %v", err)
+	}
+}
+
+// kvGetResume: read the record of an upload
+type kvGetResume struct {
+	key string
+	rec resumeRecord
+}
+
+func (op *kvGetResume) Do(ctx context.Context, b kv.Bucket) error {
+	data := b.Get([]byte(op.key))
+	if len(data) == 0 {
+		return errNoRecord
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&op.rec); err != nil {
+		return fmt.Errorf("invalid record: %w", err)
+	}
+	return nil
+}
+
+// kvPutResume: store the record of an upload
+type kvPutResume struct {
+	key string
+	rec *resumeRecord
+}
+
+func (op *kvPutResume) Do(ctx context.Context, b kv.Bucket) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(op.rec); err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+	return b.Put([]byte(op.key), buf.Bytes())
+}
+
+// kvDeleteResume: remove the record of an upload
+type kvDeleteResume struct {
+	key string
+}
+
+func (op *kvDeleteResume) Do(ctx context.Context, b kv.Bucket) error {
+	return b.Delete([]byte(op.key))
+}
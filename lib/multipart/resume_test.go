@@ -0,0 +1,174 @@
+package multipart
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/rclone/rclone/lib/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testChunkSize = 1024
+
+var errTestWrite = errors.New("write failed")
+
+// testResumeFs makes testChunkWriters
+type testResumeFs struct {
+	fs.Fs
+	mu       sync.Mutex
+	resumed  *fs.ResumeOption // the ResumeOption passed in if any
+	failAt   int              // fail writing this chunk if >= 0
+	written  []int            // chunks written
+	uploadID int              // ID of the last upload started
+	aborted  []string         // IDs of stale uploads aborted
+}
+
+// OpenChunkWriter makes a testChunkWriter
+func (f *testResumeFs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	w := &testChunkWriter{f: f, states: map[int]string{}}
+	f.resumed = nil
+	for _, option := range options {
+		if resume, ok := option.(*fs.ResumeOption); ok {
+			f.resumed = resume
+			if resume.Abort {
+				f.aborted = append(f.aborted, resume.ID)
+				continue
+			}
+			w.id = resume.ID
+			for chunkNumber, state := range resume.Chunks {
+				w.states[chunkNumber] = state
+			}
+		}
+	}
+	if w.id == "" {
+		f.uploadID++
+		w.id = fmt.Sprintf("upload-%d", f.uploadID)
+	}
+	info = fs.ChunkWriterInfo{
+		ChunkSize:   testChunkSize,
+		Concurrency: 1,
+	}
+	return info, w, nil
+}
+
+// testChunkWriter records the chunks written
+type testChunkWriter struct {
+	f      *testResumeFs
+	id     string
+	states map[int]string
+}
+
+func (w *testChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	w.f.mu.Lock()
+	defer w.f.mu.Unlock()
+	if chunkNumber == w.f.failAt {
+		return -1, errTestWrite
+	}
+	n, err := io.Copy(io.Discard, reader)
+	if err != nil {
+		return -1, err
+	}
+	w.f.written = append(w.f.written, chunkNumber)
+	w.states[chunkNumber] = fmt.Sprintf("%s/%d", w.id, chunkNumber)
+	return n, nil
+}
+
+func (w *testChunkWriter) Close(ctx context.Context) error {
+	return nil
+}
+
+func (w *testChunkWriter) Abort(ctx context.Context) error {
+	return nil
+}
+
+func (w *testChunkWriter) ResumeID() string {
+	return w.id
+}
+
+func (w *testChunkWriter) ChunkState(chunkNumber int) string {
+	w.f.mu.Lock()
+	defer w.f.mu.Unlock()
+	return w.states[chunkNumber]
+}
+
+func TestUploadMultipartResume(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	ci.ResumeUploads = true
+
+	mf, err := mockfs.NewFs(ctx, "resume", "root", nil)
+	require.NoError(t, err)
+	f := &testResumeFs{Fs: mf, failAt: 2}
+
+	// Keep the database open so the state isn't dropped between uploads
+	db, err := kv.Start(ctx, resumeFacility, f)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Stop(false))
+	}()
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 4*testChunkSize/16)
+	src := object.NewStaticObjectInfo("file.txt", time.Unix(1700000000, 0), int64(len(data)), true, nil, nil)
+	upload := func() error {
+		f.written = nil
+		_, err := UploadMultipart(ctx, src, bytes.NewReader(data), UploadMultipartOptions{Open: f})
+		return err
+	}
+
+	// The first upload fails part way through
+	err = upload()
+	require.ErrorIs(t, err, errTestWrite)
+	assert.Equal(t, []int{0, 1}, f.written)
+	assert.Nil(t, f.resumed)
+
+	// The second carries on where the first left off
+	f.failAt = -1
+	require.NoError(t, upload())
+	require.NotNil(t, f.resumed)
+	assert.Equal(t, "upload-1", f.resumed.ID)
+	assert.Equal(t, int64(testChunkSize), f.resumed.ChunkSize)
+	assert.Equal(t, map[int]string{0: "upload-1/0", 1: "upload-1/1"}, f.resumed.Chunks)
+	assert.Equal(t, []int{2, 3}, f.written)
+
+	// The upload finished so the next one starts from scratch
+	require.NoError(t, upload())
+	assert.Nil(t, f.resumed)
+	assert.Equal(t, []int{0, 1, 2, 3}, f.written)
+	assert.Equal(t, 2, f.uploadID)
+
+	// A changed source isn't resumed
+	f.failAt = 1
+	require.Error(t, upload())
+	f.failAt = -1
+	src = object.NewStaticObjectInfo("file.txt", time.Unix(1700000001, 0), int64(len(data)), true, nil, nil)
+	require.NoError(t, upload())
+	require.NotNil(t, f.resumed, "the stale upload should be aborted")
+	assert.Equal(t, "upload-3", f.resumed.ID)
+	assert.True(t, f.resumed.Abort)
+	assert.Equal(t, []string{"upload-3"}, f.aborted)
+	assert.Equal(t, []int{0, 1, 2, 3}, f.written)
+}
+
+func TestResumerNil(t *testing.T) {
+	ctx := context.Background()
+	src := object.NewStaticObjectInfo("file.txt", time.Now(), 10, true, nil, nil)
+	r := NewResumer(ctx, nil, "file.txt", src)
+	assert.Nil(t, r)
+	options := []fs.OpenOption{&fs.HTTPOption{Key: "a", Value: "b"}}
+	assert.Equal(t, options, r.OpenOptions(options))
+	assert.False(t, r.Start(fs.ChunkWriterInfo{}, nil))
+	assert.Nil(t, r.Hasher())
+	assert.False(t, r.Skip(0, 10, ""))
+	r.Done(0, 10, "")
+	r.Finish(true)
+}
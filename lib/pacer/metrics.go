@@ -0,0 +1,72 @@
+package pacer
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics provide Pacer level metrics labelled with the name of the
+// Pacer.
+type Metrics struct {
+	Calls   *prometheus.CounterVec
+	Retries *prometheus.CounterVec
+	Sleep   *prometheus.CounterVec
+}
+
+// NewMetrics creates a new metrics instance, the instance shall be
+// assigned to DefaultMetrics before any Pacers are made.
+func NewMetrics(namespace string) *Metrics {
+	return &Metrics{
+		Calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "pacer",
+			Name:      "calls_total",
+			Help:      "Number of calls made through the pacer including retries",
+		}, []string{"remote"}),
+		Retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "pacer",
+			Name:      "retries_total",
+			Help:      "Number of calls which asked to be retried",
+		}, []string{"remote"}),
+		Sleep: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "pacer",
+			Name:      "sleep_seconds_total",
+			Help:      "Total time the pacer has made calls wait",
+		}, []string{"remote"}),
+	}
+}
+
+// DefaultMetrics specifies metrics used for new Pacers.
+var DefaultMetrics = (*Metrics)(nil)
+
+// Collectors returns all prometheus metrics as collectors for registration.
+func (m *Metrics) Collectors() []prometheus.Collector {
+	if m == nil {
+		return nil
+	}
+	return []prometheus.Collector{
+		m.Calls,
+		m.Retries,
+		m.Sleep,
+	}
+}
+
+// onCall records a call which had to wait for waited before it could
+// start
+func (m *Metrics) onCall(name string, waited time.Duration) {
+	if m == nil {
+		return
+	}
+	m.Calls.WithLabelValues(name).Inc()
+	m.Sleep.WithLabelValues(name).Add(waited.Seconds())
+}
+
+func (m *Metrics) onRetry(name string) {
+	if m == nil {
+		return
+	}
+	m.Retries.WithLabelValues(name).Inc()
+}
@@ -31,12 +31,14 @@ type Pacer struct {
 	pacer      chan struct{} // To pace the operations
 	connTokens chan struct{} // Connection tokens
 	state      State
+	metrics    *Metrics
 }
 type pacerOptions struct {
 	maxConnections int         // Maximum number of concurrent connections
 	retries        int         // Max number of retries
 	calculator     Calculator  // switchable pacing algorithm - call with mu held
 	invoker        InvokerFunc // wrapper function used to invoke the target function
+	name           string      // name used to label the metrics
 }
 
 // InvokerFunc is the signature of the wrapper function used to invoke the
@@ -66,6 +68,11 @@ func InvokerOption(invoker InvokerFunc) Option {
 	return func(p *pacerOptions) { p.invoker = invoker }
 }
 
+// NameOption sets the name used to label the metrics of the new Pacer.
+func NameOption(name string) Option {
+	return func(p *pacerOptions) { p.name = name }
+}
+
 // Paced is a function which is called by the Call and CallNoRetry
 // methods.  It should return a boolean, true if it would like to be
 // retried, and an error.  This error may be returned or returned
@@ -84,6 +91,7 @@ func New(options ...Option) *Pacer {
 	p := &Pacer{
 		pacerOptions: opts,
 		pacer:        make(chan struct{}, 1),
+		metrics:      DefaultMetrics,
 	}
 	if p.calculator == nil {
 		p.SetCalculator(nil)
@@ -156,18 +164,22 @@ func (p *Pacer) beginCall() {
 	// XXX ms later we put another in.  We could do this with a
 	// Ticker more accurately, but then we'd have to work out how
 	// not to run it when it wasn't needed
+	start := time.Now()
 	<-p.pacer
 	if p.maxConnections > 0 {
 		<-p.connTokens
 	}
+	waited := time.Since(start)
 
 	p.mu.Lock()
+	sleepTime := p.state.SleepTime
 	// Restart the timer
 	go func(t time.Duration) {
 		time.Sleep(t)
 		p.pacer <- struct{}{}
-	}(p.state.SleepTime)
+	}(sleepTime)
 	p.mu.Unlock()
+	p.metrics.onCall(p.name, waited)
 }
 
 // endCall implements the pacing algorithm
@@ -199,6 +211,7 @@ func (p *Pacer) call(fn Paced, retries int) (err error) {
 		if !retry {
 			break
 		}
+		p.metrics.onRetry(p.name)
 	}
 	return err
 }
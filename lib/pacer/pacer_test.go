@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -350,3 +351,39 @@ func TestCallParallel(t *testing.T) {
 	assert.Equal(t, 5, called)
 	wait.Broadcast()
 }
+
+func TestCallMetrics(t *testing.T) {
+	oldMetrics := DefaultMetrics
+	DefaultMetrics = NewMetrics("test")
+	defer func() { DefaultMetrics = oldMetrics }()
+
+	p := New(NameOption("remote"), CalculatorOption(NewDefault(MinSleep(1*time.Millisecond), MaxSleep(2*time.Millisecond))))
+
+	dp := &dummyPaced{retry: true}
+	err := p.call(dp.fn, 3)
+	assert.Equal(t, errFoo, err)
+	assert.Equal(t, 3.0, testutil.ToFloat64(DefaultMetrics.Calls.WithLabelValues("remote")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(DefaultMetrics.Retries.WithLabelValues("remote")))
+	assert.Greater(t, testutil.ToFloat64(DefaultMetrics.Sleep.WithLabelValues("remote")), 0.0)
+}
+
+func TestCallMetricsSleep(t *testing.T) {
+	oldMetrics := DefaultMetrics
+	DefaultMetrics = NewMetrics("test")
+	defer func() { DefaultMetrics = oldMetrics }()
+
+	const minSleep = 50 * time.Millisecond
+	p := New(NameOption("remote"), CalculatorOption(NewDefault(MinSleep(minSleep), MaxSleep(2*minSleep))))
+	sleep := func() time.Duration {
+		return time.Duration(testutil.ToFloat64(DefaultMetrics.Sleep.WithLabelValues("remote")) * float64(time.Second))
+	}
+
+	// An unthrottled call doesn't count as sleeping
+	dp := &dummyPaced{retry: false}
+	assert.Equal(t, errFoo, p.call(dp.fn, 1))
+	assert.Less(t, sleep(), minSleep/2)
+
+	// A call straight after has to wait for the pacer
+	assert.Equal(t, errFoo, p.call(dp.fn, 1))
+	assert.Greater(t, sleep(), minSleep/2)
+}
@@ -17,7 +17,9 @@ import (
 	"sync"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/tracing"
 	"github.com/rclone/rclone/lib/readers"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Client contains the info to sustain the API
@@ -237,6 +239,11 @@ func (api *Client) Call(ctx context.Context, opts *Opts) (resp *http.Response, e
 	if url == "" {
 		return nil, errors.New("RootURL not set")
 	}
+	ctx, span := tracing.StartSpan(ctx, "rest "+opts.Method)
+	span.SetAttributes(attribute.String("rclone.rest.path", opts.Path))
+	defer func() {
+		tracing.EndSpan(span, err)
+	}()
 	url += opts.Path
 	if len(opts.Parameters) > 0 {
 		url += "?" + opts.Parameters.Encode()
@@ -0,0 +1,223 @@
+// Package transform implements the --name-transform pipeline which
+// rewrites file and directory names on their way to the destination.
+package transform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/encoder"
+	"golang.org/x/text/unicode/norm"
+)
+
+// scope says which names a transform applies to
+type scope int
+
+const (
+	scopeFile scope = 1 << iota
+	scopeDir
+	scopeAll = scopeFile | scopeDir
+)
+
+// transform is a single parsed transform
+type transform struct {
+	scope scope
+	fn    func(name string) string
+}
+
+// transforms is a parsed --name-transform pipeline
+type transforms []transform
+
+var (
+	parsedMu sync.Mutex
+	parsed   = map[string]transforms{} // cache of parsed pipelines by option
+)
+
+// parse the transforms passed in
+func parse(opts []string) (transforms, error) {
+	ts := make(transforms, 0, len(opts))
+	for _, opt := range opts {
+		t, err := parseOne(opt)
+		if err != nil {
+			return nil, fmt.Errorf("bad --name-transform %q: %w", opt, err)
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+// parseOne parses a single transform
+func parseOne(opt string) (t transform, err error) {
+	t.scope = scopeFile
+	for _, prefix := range []struct {
+		name  string
+		scope scope
+	}{{"file,", scopeFile}, {"dir,", scopeDir}, {"all,", scopeAll}} {
+		if rest, found := strings.CutPrefix(opt, prefix.name); found {
+			t.scope = prefix.scope
+			opt = rest
+			break
+		}
+	}
+	name, value, hasValue := strings.Cut(opt, "=")
+	needValue := func() error {
+		if !hasValue {
+			return fmt.Errorf("%q needs a value", name)
+		}
+		return nil
+	}
+	switch name {
+	case "nfc":
+		t.fn = norm.NFC.String
+	case "nfd":
+		t.fn = norm.NFD.String
+	case "nfkc":
+		t.fn = norm.NFKC.String
+	case "nfkd":
+		t.fn = norm.NFKD.String
+	case "lowercase":
+		t.fn = strings.ToLower
+	case "uppercase":
+		t.fn = strings.ToUpper
+	case "prefix":
+		t.fn = func(name string) string { return value + name }
+	case "suffix":
+		t.fn = func(name string) string { return name + value }
+	case "suffix_keep_extension":
+		t.fn = func(name string) string {
+			ext := path.Ext(name)
+			return name[:len(name)-len(ext)] + value + ext
+		}
+	case "trimprefix":
+		t.fn = func(name string) string { return strings.TrimPrefix(name, value) }
+	case "trimsuffix":
+		t.fn = func(name string) string { return strings.TrimSuffix(name, value) }
+	case "replace":
+		old, replacement, found := strings.Cut(value, ":")
+		if !found || old == "" {
+			return t, errors.New("replace needs a value of the form old:new")
+		}
+		t.fn = func(name string) string { return strings.ReplaceAll(name, old, replacement) }
+	case "regex":
+		i := strings.LastIndex(value, "/")
+		if i < 0 {
+			return t, errors.New("regex needs a value of the form pattern/replacement")
+		}
+		re, err := regexp.Compile(value[:i])
+		if err != nil {
+			return t, err
+		}
+		replacement := value[i+1:]
+		t.fn = func(name string) string { return re.ReplaceAllString(name, replacement) }
+	case "encoder", "decoder":
+		if err = needValue(); err != nil {
+			return t, err
+		}
+		var enc encoder.MultiEncoder
+		if err = enc.Set(value); err != nil {
+			return t, err
+		}
+		if name == "encoder" {
+			t.fn = enc.Encode
+		} else {
+			t.fn = enc.Decode
+		}
+	default:
+		return t, fmt.Errorf("unknown transform %q", name)
+	}
+	switch name {
+	case "prefix", "suffix", "suffix_keep_extension", "trimprefix", "trimsuffix", "replace", "regex":
+		if err = needValue(); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// get returns the parsed transforms in use in ctx
+func get(ctx context.Context) (transforms, error) {
+	opts := fs.GetConfig(ctx).NameTransform
+	if len(opts) == 0 {
+		return nil, nil
+	}
+	key := strings.Join(opts, "\x00")
+	parsedMu.Lock()
+	defer parsedMu.Unlock()
+	if ts, ok := parsed[key]; ok {
+		return ts, nil
+	}
+	ts, err := parse(opts)
+	if err != nil {
+		return nil, err
+	}
+	parsed[key] = ts
+	return ts, nil
+}
+
+// Check returns an error if the --name-transform in ctx is invalid
+func Check(ctx context.Context) error {
+	_, err := get(ctx)
+	return err
+}
+
+// Transforming returns true if any --name-transform is in use in ctx
+func Transforming(ctx context.Context) bool {
+	return len(fs.GetConfig(ctx).NameTransform) > 0
+}
+
+// Name applies the transforms in ctx to a single path segment which
+// is a directory if isDir is set.
+//
+// It returns an error if the transformed name isn't a valid path
+// segment, for example if it is empty or contains a "/".
+func Name(ctx context.Context, name string, isDir bool) (string, error) {
+	ts, err := get(ctx)
+	if err != nil {
+		return "", err
+	}
+	s := scopeFile
+	if isDir {
+		s = scopeDir
+	}
+	newName := name
+	for _, t := range ts {
+		if t.scope&s != 0 {
+			newName = t.fn(newName)
+		}
+	}
+	switch {
+	case newName == "", newName == ".", newName == "..":
+		return "", fmt.Errorf("--name-transform turned %q into invalid name %q", name, newName)
+	case strings.Contains(newName, "/"):
+		return "", fmt.Errorf("--name-transform turned %q into %q which contains a \"/\"", name, newName)
+	}
+	return newName, nil
+}
+
+// Path applies the transforms in ctx to every segment of remote.
+//
+// All the segments but the last are directories. The last one is a
+// directory if isDir is set.
+func Path(ctx context.Context, remote string, isDir bool) (string, error) {
+	if !Transforming(ctx) || remote == "" {
+		return remote, nil
+	}
+	segments := strings.Split(remote, "/")
+	for i, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		newSegment, err := Name(ctx, segment, isDir || i < len(segments)-1)
+		if err != nil {
+			return "", err
+		}
+		segments[i] = newSegment
+	}
+	return strings.Join(segments, "/"), nil
+}
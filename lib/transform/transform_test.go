@@ -0,0 +1,110 @@
+package transform
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestName(t *testing.T) {
+	for _, test := range []struct {
+		opts  []string
+		in    string
+		isDir bool
+		want  string
+	}{
+		{nil, "Hello.txt", false, "Hello.txt"},
+		{[]string{"lowercase"}, "Hello.txt", false, "hello.txt"},
+		{[]string{"lowercase"}, "Hello", true, "Hello"},
+		{[]string{"dir,lowercase"}, "Hello", true, "hello"},
+		{[]string{"dir,lowercase"}, "Hello", false, "Hello"},
+		{[]string{"all,uppercase"}, "Hello", true, "HELLO"},
+		{[]string{"all,uppercase"}, "Hello", false, "HELLO"},
+		{[]string{"prefix=pre-"}, "file", false, "pre-file"},
+		{[]string{"suffix=-post"}, "file.txt", false, "file.txt-post"},
+		{[]string{"suffix_keep_extension=-v2"}, "file.txt", false, "file-v2.txt"},
+		{[]string{"suffix_keep_extension=-v2"}, "file", false, "file-v2"},
+		{[]string{"trimprefix=IMG_"}, "IMG_001.jpg", false, "001.jpg"},
+		{[]string{"trimsuffix=.bak"}, "file.bak", false, "file"},
+		{[]string{"replace=a:b"}, "banana", false, "bbnbnb"},
+		{[]string{"replace=a:"}, "banana", false, "bnn"},
+		{[]string{"regex=([0-9]+)/<$1>"}, "a12b3", false, "a<12>b<3>"},
+		{[]string{"nfc"}, "é", false, "é"},
+		{[]string{"nfd"}, "é", false, "é"},
+		{[]string{"nfkc"}, "ﬁ", false, "fi"},
+		{[]string{"encoder=Colon"}, "a:b", false, "a：b"},
+		{[]string{"decoder=Colon"}, "a：b", false, "a:b"},
+		{[]string{"trimprefix=x", "uppercase", "prefix=y"}, "xfile", false, "yFILE"},
+	} {
+		ctx, ci := fs.AddConfig(context.Background())
+		ci.NameTransform = test.opts
+		got, err := Name(ctx, test.in, test.isDir)
+		require.NoError(t, err)
+		assert.Equal(t, test.want, got, "opts=%q in=%q isDir=%v", test.opts, test.in, test.isDir)
+	}
+}
+
+func TestNameInvalid(t *testing.T) {
+	ctx, ci := fs.AddConfig(context.Background())
+	for _, test := range []struct {
+		opts []string
+		in   string
+	}{
+		{[]string{"replace=a:/"}, "banana"},
+		{[]string{"regex=^.*$/"}, "file"},
+		{[]string{"trimprefix=file"}, "file"},
+		{[]string{"regex=.*/.."}, "file"},
+		{[]string{"prefix=dir/"}, "file"},
+	} {
+		ci.NameTransform = test.opts
+		_, err := Name(ctx, test.in, false)
+		assert.Error(t, err, "opts=%q in=%q", test.opts, test.in)
+	}
+	ci.NameTransform = []string{"potato"}
+	_, err := Name(ctx, "file", false)
+	assert.Error(t, err)
+}
+
+func TestPath(t *testing.T) {
+	ctx, ci := fs.AddConfig(context.Background())
+	assert.False(t, Transforming(ctx))
+	check := func(want, remote string, isDir bool) {
+		got, err := Path(ctx, remote, isDir)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, remote)
+	}
+	check("a/b/c", "a/b/c", false)
+
+	ci.NameTransform = []string{"prefix=f-", "dir,prefix=d-"}
+	assert.True(t, Transforming(ctx))
+	check("d-a/d-b/f-c", "a/b/c", false)
+	check("d-a/d-b/d-c", "a/b/c", true)
+	check("f-c", "c", false)
+	check("", "", true)
+
+	ci.NameTransform = []string{"dir,replace=b:"}
+	_, err := Path(ctx, "a/b/c", false)
+	assert.Error(t, err)
+}
+
+func TestCheck(t *testing.T) {
+	ctx, ci := fs.AddConfig(context.Background())
+	require.NoError(t, Check(ctx))
+	for _, opt := range []string{
+		"potato",
+		"prefix",
+		"file,replace=nocolon",
+		"regex=noslash",
+		"regex=([/x",
+		"encoder=Potato",
+		"dir,decoder",
+	} {
+		ci.NameTransform = []string{opt}
+		assert.Error(t, Check(ctx), opt)
+	}
+	ci.NameTransform = []string{"all,nfc", "file,lowercase", "dir,replace=a:b"}
+	require.NoError(t, Check(ctx))
+}
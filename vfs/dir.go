@@ -17,6 +17,7 @@ import (
 	"github.com/rclone/rclone/fs/log"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/walk"
+	"github.com/rclone/rclone/vfs/vfscache"
 	"github.com/rclone/rclone/vfs/vfscommon"
 	"golang.org/x/text/unicode/norm"
 )
@@ -83,6 +84,12 @@ func (d *Dir) cacheCleanup() {
 	_, stale := d._age(when)
 	d.mu.Unlock()
 
+	if stale && d.vfs.offline.Load() {
+		// keep the stale entries while the remote is unreachable
+		d.cleanupTimer.Reset(time.Duration(d.vfs.Opt.DirCacheTime * 2))
+		return
+	}
+
 	if stale {
 		d.ForgetAll()
 	}
@@ -267,6 +274,7 @@ func (d *Dir) forgetDirPath(relativePath string) {
 
 // invalidateDir invalidates the directory cache for absPath relative to the root
 func (d *Dir) invalidateDir(absPath string) {
+	d.vfs.dirStore.forget(absPath, false)
 	node := d.vfs.root.cachedNode(absPath)
 	if dir, ok := node.(*Dir); ok {
 		dir.mu.Lock()
@@ -308,6 +316,7 @@ func (d *Dir) ForgetPath(relativePath string, entryType fs.EntryType) {
 		d.invalidateDir(vfscommon.FindParent(absPath))
 	}
 	if entryType == fs.EntryDirectory {
+		d.vfs.dirStore.forget(absPath, true)
 		d.forgetDirPath(relativePath)
 	}
 }
@@ -451,6 +460,7 @@ func (d *Dir) addObject(node Node) {
 	d.virtual[leaf] = vAdd
 	d.setHasVirtual(true)
 	fs.Debugf(d.path, "Added virtual directory entry %v: %q", vAdd, leaf)
+	d.vfs.dirStore.forget(d.path, false)
 	d.mu.Unlock()
 }
 
@@ -495,6 +505,7 @@ func (d *Dir) delObject(leaf string) {
 	d.virtual[leaf] = vDel
 	d.setHasVirtual(true)
 	fs.Debugf(d.path, "Added virtual directory entry %v: %q", vDel, leaf)
+	d.vfs.dirStore.forget(d.path, false)
 	d.mu.Unlock()
 }
 
@@ -519,10 +530,31 @@ func (d *Dir) _readDir() error {
 	} else {
 		return nil
 	}
+	if d.vfs.journalling() && d.vfs.cache.JournalPending(d.path) {
+		// Keep the local entries until the queued changes
+		// have been replayed otherwise they will disappear
+		return nil
+	}
+	if d.read.IsZero() {
+		// Use the listing saved by the previous run if available
+		if entries, read := d.vfs.dirStore.get(d.path); entries != nil {
+			err := d._readDirFromEntries(entries, nil, time.Time{})
+			if err != nil {
+				return err
+			}
+			d.read = read
+			d.cleanupTimer.Reset(time.Duration(d.vfs.Opt.DirCacheTime * 2))
+			return nil
+		}
+	}
 	entries, err := list.DirSorted(context.TODO(), d.f, false, d.path)
 	if err == fs.ErrorDirNotFound {
 		// We treat directory not found as empty because we
 		// create directories on the fly
+	} else if d.vfs.setOffline(err) && !d.read.IsZero() {
+		fs.Debugf(d.path, "Using stale directory listing as remote is unreachable: %v", err)
+		d.read = when
+		return nil
 	} else if err != nil {
 		return err
 	}
@@ -560,6 +592,7 @@ func (d *Dir) _readDir() error {
 	if err != nil {
 		return err
 	}
+	d.vfs.dirStore.put(d.path, entries)
 
 	d.read = when
 	d.cleanupTimer.Reset(time.Duration(d.vfs.Opt.DirCacheTime * 2))
@@ -781,6 +814,9 @@ func (d *Dir) readDirTree() error {
 	if err != nil {
 		return err
 	}
+	for dir, entries := range dt {
+		d.vfs.dirStore.put(dir, entries)
+	}
 	fs.Debugf(d.path, "Reading directory tree done in %s", time.Since(when))
 	d.read = when
 	d.cleanupTimer.Reset(time.Duration(d.vfs.Opt.DirCacheTime * 2))
@@ -969,6 +1005,9 @@ func (d *Dir) Create(name string, flags int) (*File, error) {
 	if d.vfs.Opt.ReadOnly {
 		return nil, EROFS
 	}
+	if err = d.vfs.checkWriteLock(path.Join(d.path, name), true); err != nil {
+		return nil, err
+	}
 	if err = d.SetModTime(time.Now()); err != nil {
 		fs.Errorf(d, "Dir.Create failed to set modtime on parent dir: %v", err)
 		return nil, err
@@ -983,6 +1022,9 @@ func (d *Dir) Mkdir(name string) (*Dir, error) {
 		return nil, EROFS
 	}
 	path := path.Join(d.path, name)
+	if err := d.vfs.checkWriteLock(path, true); err != nil {
+		return nil, err
+	}
 	node, err := d.stat(name)
 	switch err {
 	case ENOENT:
@@ -999,7 +1041,9 @@ func (d *Dir) Mkdir(name string) (*Dir, error) {
 		return nil, err
 	}
 	// fs.Debugf(path, "Dir.Mkdir")
-	err = d.f.Mkdir(context.TODO(), path)
+	err = d.vfs.runOrJournal(vfscache.JournalEntry{Op: vfscache.JournalMkdir, Name: path, IsDir: true}, func() error {
+		return d.f.Mkdir(context.TODO(), path)
+	})
 	if err != nil {
 		fs.Errorf(d, "Dir.Mkdir failed to create directory: %v", err)
 		return nil, err
@@ -1020,6 +1064,9 @@ func (d *Dir) Remove() error {
 	if d.vfs.Opt.ReadOnly {
 		return EROFS
 	}
+	if err := d.vfs.checkWriteLock(d.path, true); err != nil {
+		return err
+	}
 	// Check directory is empty first
 	empty, err := d.isEmpty()
 	if err != nil {
@@ -1031,7 +1078,9 @@ func (d *Dir) Remove() error {
 		return ENOTEMPTY
 	}
 	// remove directory
-	err = d.f.Rmdir(context.TODO(), d.path)
+	err = d.vfs.runOrJournal(vfscache.JournalEntry{Op: vfscache.JournalRmdir, Name: d.path, IsDir: true}, func() error {
+		return d.f.Rmdir(context.TODO(), d.path)
+	})
 	if err != nil {
 		fs.Errorf(d, "Dir.Remove failed to remove directory: %v", err)
 		return err
@@ -1048,6 +1097,9 @@ func (d *Dir) RemoveAll() error {
 	if d.vfs.Opt.ReadOnly {
 		return EROFS
 	}
+	if err := d.vfs.checkWriteLock(d.path, true); err != nil {
+		return err
+	}
 	// Remove contents of the directory
 	nodes, err := d.ReadDirAll()
 	if err != nil {
@@ -1097,6 +1149,11 @@ func (d *Dir) Rename(oldName, newName string, destDir *Dir) error {
 	}
 	oldPath := path.Join(d.path, oldName)
 	newPath := path.Join(destDir.path, newName)
+	for _, name := range []string{oldPath, newPath} {
+		if err := d.vfs.checkWriteLock(name, true); err != nil {
+			return err
+		}
+	}
 	// fs.Debugf(oldPath, "Dir.Rename to %q", newPath)
 	oldNode, err := d.stat(oldName)
 	if err != nil {
@@ -1134,7 +1191,9 @@ func (d *Dir) Rename(oldName, newName string, destDir *Dir) error {
 		}
 		srcRemote := x.Remote()
 		dstRemote := newPath
-		err = operations.DirMove(context.TODO(), d.f, srcRemote, dstRemote)
+		err = d.vfs.runOrJournal(vfscache.JournalEntry{Op: vfscache.JournalRename, Name: srcRemote, NewName: dstRemote, IsDir: true}, func() error {
+			return operations.DirMove(context.TODO(), d.f, srcRemote, dstRemote)
+		})
 		if err != nil {
 			fs.Errorf(oldPath, "Dir.Rename error: %v", err)
 			return err
@@ -0,0 +1,451 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/kv"
+)
+
+// dirStoreFlushInterval is how often changed directory listings are
+// written to disk
+var dirStoreFlushInterval = 10 * time.Second
+
+// dirStoreMaxTrees is the most directory trees not to load from disk
+// which are remembered. Beyond this none of the saved listings are
+// used.
+const dirStoreMaxTrees = 64
+
+// dirStore saves directory listings to disk so they can be used
+// instead of listing the remote when the VFS is restarted.
+//
+// Listings saved by a previous run are used at most once, and only if
+// they are younger than --dir-cache-time, as they are refreshed from
+// the remote after --dir-cache-time as normal.
+type dirStore struct {
+	db     *kv.DB
+	f      fs.Fs
+	prefix string        // prefix for keys so different roots don't clash
+	start  time.Time     // listings read before this are from a previous run
+	maxAge time.Duration // listings older than this aren't used
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.Mutex
+	dirty map[string]*dirRecord // listings to write - nil to delete
+	purge []string              // directory trees to delete before writing
+	used  map[string]struct{}   // directories not to load from disk
+	trees []string              // directory trees not to load from disk
+}
+
+// dirRecord is a directory listing saved on disk
+type dirRecord struct {
+	Read    time.Time
+	Entries []dirRecordEntry
+}
+
+// dirRecordEntry is an entry in a saved directory listing
+type dirRecordEntry struct {
+	Name    string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+}
+
+// newDirStore opens the directory store for f using saved listings
+// younger than maxAge
+func newDirStore(f fs.Fs, maxAge time.Duration) (*dirStore, error) {
+	db, err := kv.Start(context.Background(), "vfsdir", f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open directory cache store: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &dirStore{
+		db:     db,
+		f:      f,
+		prefix: f.Root() + "/",
+		start:  time.Now(),
+		maxAge: maxAge,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		dirty:  make(map[string]*dirRecord),
+		used:   make(map[string]struct{}),
+	}
+	go s.flusher(ctx)
+	return s, nil
+}
+
+// put records the directory listing of dir read from the remote
+func (s *dirStore) put(dir string, entries fs.DirEntries) {
+	if s == nil {
+		return
+	}
+	rec := &dirRecord{
+		Read:    time.Now(),
+		Entries: make([]dirRecordEntry, 0, len(entries)),
+	}
+	ctx := context.Background()
+	for _, entry := range entries {
+		_, isDir := entry.(fs.Directory)
+		rec.Entries = append(rec.Entries, dirRecordEntry{
+			Name:    path.Base(entry.Remote()),
+			IsDir:   isDir,
+			Size:    entry.Size(),
+			ModTime: entry.ModTime(ctx),
+		})
+	}
+	s.mu.Lock()
+	s.dirty[dir] = rec
+	s.used[dir] = struct{}{}
+	s.mu.Unlock()
+}
+
+// forget removes the saved listing of dir and any directories below
+// it if recurse is set so they are read from the remote next time
+func (s *dirStore) forget(dir string, recurse bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty[dir] = nil
+	s.used[dir] = struct{}{}
+	if !recurse {
+		return
+	}
+	below := dir + "/"
+	if dir == "" {
+		below = ""
+	}
+	for name := range s.dirty {
+		if strings.HasPrefix(name, below) {
+			delete(s.dirty, name)
+		}
+	}
+	s.purge = append(s.purge, below)
+	s._addTree(below)
+}
+
+// _addTree adds the directory tree below to the trees not to load from
+// disk - call with mu held
+func (s *dirStore) _addTree(below string) {
+	trees := s.trees[:0]
+	for _, tree := range s.trees {
+		if strings.HasPrefix(below, tree) {
+			return // already covered
+		}
+		if !strings.HasPrefix(tree, below) {
+			trees = append(trees, tree)
+		}
+	}
+	s.trees = append(trees, below)
+	if len(s.trees) > dirStoreMaxTrees {
+		// Stop using any saved listings rather than keep them all
+		s.trees = []string{""}
+	}
+}
+
+// get returns the listing of dir saved by a previous run and when it
+// was read, or nil if there isn't one, it is too old or it has been
+// used already.
+func (s *dirStore) get(dir string) (entries fs.DirEntries, read time.Time) {
+	if s == nil {
+		return nil, read
+	}
+	s.mu.Lock()
+	_, used := s.used[dir]
+	s.used[dir] = struct{}{}
+	for _, below := range s.trees {
+		if strings.HasPrefix(dir, below) {
+			used = true
+		}
+	}
+	s.mu.Unlock()
+	if used {
+		return nil, read
+	}
+	op := &dirStoreGet{key: s.prefix + dir}
+	err := s.db.Do(false, op)
+	if err != nil {
+		if !errors.Is(err, kv.ErrEmpty) {
+			fs.Debugf(dir, "vfs: failed to read directory cache store: %v", err)
+		}
+		return nil, read
+	}
+	if op.rec == nil || !op.rec.Read.Before(s.start) {
+		return nil, read
+	}
+	if time.Since(op.rec.Read) > s.maxAge {
+		fs.Debugf(dir, "vfs: not using directory listing from %v saved on disk as it is too old", op.rec.Read)
+		return nil, read
+	}
+	entries = make(fs.DirEntries, 0, len(op.rec.Entries))
+	for _, entry := range op.rec.Entries {
+		remote := path.Join(dir, entry.Name)
+		if entry.IsDir {
+			entries = append(entries, fs.NewDir(remote, entry.ModTime))
+		} else {
+			entries = append(entries, &storedObject{
+				f:       s.f,
+				remote:  remote,
+				size:    entry.Size,
+				modTime: entry.ModTime,
+			})
+		}
+	}
+	fs.Debugf(dir, "vfs: using directory listing from %v saved on disk", op.rec.Read)
+	return entries, op.rec.Read
+}
+
+// flush writes the changed listings to disk
+func (s *dirStore) flush() {
+	s.mu.Lock()
+	op := &dirStorePut{prefix: s.prefix, recs: s.dirty, purge: s.purge}
+	s.dirty = make(map[string]*dirRecord)
+	s.purge = nil
+	s.mu.Unlock()
+	if len(op.recs) == 0 && len(op.purge) == 0 {
+		return
+	}
+	err := s.db.Do(true, op)
+	if err != nil {
+		fs.Errorf(s.f, "vfs: failed to save directory cache: %v", err)
+	}
+}
+
+// flusher writes the changed listings to disk periodically
+func (s *dirStore) flusher(ctx context.Context) {
+	defer close(s.done)
+	ticker := time.NewTicker(dirStoreFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// close writes any outstanding changes and closes the store
+func (s *dirStore) close() {
+	if s == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+	s.flush()
+	if err := s.db.Stop(false); err != nil {
+		fs.Errorf(s.f, "vfs: failed to close directory cache store: %v", err)
+	}
+}
+
+// dirStoreGet reads a single directory listing
+type dirStoreGet struct {
+	key string
+	rec *dirRecord
+}
+
+func (op *dirStoreGet) Do(ctx context.Context, b kv.Bucket) error {
+	data := b.Get([]byte(op.key))
+	if data == nil {
+		return nil
+	}
+	var rec dirRecord
+	if err := gob.NewDecoder(bytes.NewBuffer(data)).Decode(&rec); err != nil {
+		return fmt.Errorf("failed to decode %q: %w", op.key, err)
+	}
+	op.rec = &rec
+	return nil
+}
+
+// dirStorePut writes and deletes directory listings
+type dirStorePut struct {
+	prefix string
+	recs   map[string]*dirRecord
+	purge  []string
+}
+
+func (op *dirStorePut) Do(ctx context.Context, b kv.Bucket) error {
+	// Purge directory trees first as recs were added after
+	for _, below := range op.purge {
+		prefix := op.prefix + below
+		var keys [][]byte
+		cur := b.Cursor()
+		for key, _ := cur.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, _ = cur.Next() {
+			keys = append(keys, append([]byte(nil), key...))
+		}
+		for _, key := range keys {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	for dir, rec := range op.recs {
+		key := []byte(op.prefix + dir)
+		if rec == nil {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+			continue
+		}
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(rec); err != nil {
+			return fmt.Errorf("failed to encode %q: %w", dir, err)
+		}
+		if err := b.Put(key, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storedObject is an object from a directory listing saved on disk, or
+// one whose rename has been journalled.
+//
+// It finds the object on the remote when it is needed for anything
+// other than its size and modification time.
+type storedObject struct {
+	f       fs.Fs
+	remote  string
+	size    int64
+	modTime time.Time
+
+	mu sync.Mutex
+	o  fs.Object
+}
+
+// resolve finds the object on the remote
+func (o *storedObject) resolve(ctx context.Context) (fs.Object, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.o == nil {
+		obj, err := o.f.NewObject(ctx, o.remote)
+		if err != nil {
+			return nil, err
+		}
+		o.o = obj
+	}
+	return o.o, nil
+}
+
+// resolveObject returns the remote object for o if it came from a
+// directory listing saved on disk
+func resolveObject(ctx context.Context, o fs.Object) (fs.Object, error) {
+	if so, ok := o.(*storedObject); ok {
+		return so.resolve(ctx)
+	}
+	return o, nil
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *storedObject) Fs() fs.Info { return o.f }
+
+// String returns the remote path
+func (o *storedObject) String() string { return o.remote }
+
+// Remote returns the remote path
+func (o *storedObject) Remote() string { return o.remote }
+
+// ModTime returns the saved modification time
+func (o *storedObject) ModTime(ctx context.Context) time.Time { return o.modTime }
+
+// Size returns the saved size
+func (o *storedObject) Size() int64 { return o.size }
+
+// Storable says whether this object can be stored
+func (o *storedObject) Storable() bool { return true }
+
+// Hash returns the requested hash of the object on the remote
+func (o *storedObject) Hash(ctx context.Context, ty hash.Type) (string, error) {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+	return obj.Hash(ctx, ty)
+}
+
+// SetModTime sets the modification time of the object on the remote
+func (o *storedObject) SetModTime(ctx context.Context, t time.Time) error {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return obj.SetModTime(ctx, t)
+}
+
+// Open opens the object on the remote for read
+func (o *storedObject) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Open(ctx, options...)
+}
+
+// Update the object on the remote with the contents of in
+func (o *storedObject) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return obj.Update(ctx, in, src, options...)
+}
+
+// Remove the object from the remote
+func (o *storedObject) Remove(ctx context.Context) error {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return obj.Remove(ctx)
+}
+
+// MimeType returns the content type of the object on the remote
+func (o *storedObject) MimeType(ctx context.Context) string {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return fs.MimeTypeFromName(o.remote)
+	}
+	return fs.MimeType(ctx, obj)
+}
+
+// ID returns the ID of the object on the remote if known, or "" if not
+func (o *storedObject) ID() string {
+	obj, err := o.resolve(context.Background())
+	if err != nil {
+		return ""
+	}
+	if do, ok := obj.(fs.IDer); ok {
+		return do.ID()
+	}
+	return ""
+}
+
+// Metadata returns the metadata of the object on the remote
+func (o *storedObject) Metadata(ctx context.Context) (fs.Metadata, error) {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return fs.GetMetadata(ctx, obj)
+}
+
+// Check interfaces
+var (
+	_ fs.Object     = (*storedObject)(nil)
+	_ fs.MimeTyper  = (*storedObject)(nil)
+	_ fs.IDer       = (*storedObject)(nil)
+	_ fs.Metadataer = (*storedObject)(nil)
+)
@@ -0,0 +1,152 @@
+package vfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/kv"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVFSPersistDirCache(t *testing.T) {
+	if !kv.Supported() {
+		t.Skip("kv not supported on this OS")
+	}
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	r.WriteObject(ctx, "dir/file2", "file2 contents", t1)
+	r.WriteObject(ctx, "file3", "file3 contents", t1)
+
+	// Hold the store open so the test binary doesn't drop it
+	// when the second VFS is started
+	db, err := kv.Start(ctx, "vfsdir", r.Fremote)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Stop(true))
+	}()
+
+	opt := vfscommon.Opt
+	opt.PersistDirCache = true
+
+	names := func(vfs *VFS, dir string) (names []string) {
+		t.Helper()
+		node, err := vfs.Stat(dir)
+		require.NoError(t, err)
+		nodes, err := node.(*Dir).ReadDirAll()
+		require.NoError(t, err)
+		for _, node := range nodes {
+			names = append(names, node.Name())
+		}
+		return names
+	}
+
+	vfs := New(r.Fremote, &opt)
+	assert.Equal(t, []string{"dir", "file3"}, names(vfs, ""))
+	assert.Equal(t, []string{"file1", "file2"}, names(vfs, "dir"))
+	vfs.Shutdown()
+
+	// Change the remote behind the VFS's back
+	r.WriteObject(ctx, "file4", "file4 contents", t1)
+	o, err := r.Fremote.NewObject(ctx, "dir/file2")
+	require.NoError(t, err)
+	require.NoError(t, o.Remove(ctx))
+
+	// The new VFS uses the saved listings
+	vfs = New(r.Fremote, &opt)
+	defer vfs.Shutdown()
+	assert.Equal(t, []string{"dir", "file3"}, names(vfs, ""))
+	assert.Equal(t, []string{"file1", "file2"}, names(vfs, "dir"))
+
+	// Files from the saved listings can be read
+	node, err := vfs.Stat("dir/file1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(14), node.Size())
+	assert.Equal(t, t1, node.ModTime().UTC())
+	fd, err := vfs.OpenFile("dir/file1", 0, 0)
+	require.NoError(t, err)
+	data, err := io.ReadAll(fd)
+	require.NoError(t, err)
+	require.NoError(t, fd.Close())
+	assert.Equal(t, "file1 contents", string(data))
+
+	// The saved object finds the metadata on the remote
+	o1 := node.(*File).getObject()
+	require.IsType(t, &storedObject{}, o1)
+	metadata, err := fs.GetMetadata(ctx, o1)
+	require.NoError(t, err)
+	assert.NotEmpty(t, metadata["mtime"])
+
+	// And renamed on the remote
+	require.NoError(t, vfs.Rename("file3", "file5"))
+	_, err = r.Fremote.NewObject(ctx, "file5")
+	require.NoError(t, err)
+
+	// Change notifications refresh the listings
+	vfs.root.ForgetPath("", fs.EntryDirectory)
+	assert.Equal(t, []string{"dir", "file4", "file5"}, names(vfs, ""))
+	assert.Equal(t, []string{"file1"}, names(vfs, "dir"))
+}
+
+func TestVFSPersistDirCacheMaxAge(t *testing.T) {
+	if !kv.Supported() {
+		t.Skip("kv not supported on this OS")
+	}
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteObject(ctx, "file1", "file1 contents", t1)
+
+	db, err := kv.Start(ctx, "vfsdir", r.Fremote)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Stop(true))
+	}()
+
+	opt := vfscommon.Opt
+	opt.PersistDirCache = true
+	vfs := New(r.Fremote, &opt)
+	_, err = vfs.ReadDir("")
+	require.NoError(t, err)
+	vfs.Shutdown()
+
+	r.WriteObject(ctx, "file2", "file2 contents", t1)
+
+	// A listing older than --dir-cache-time isn't used
+	opt.DirCacheTime = fs.Duration(time.Nanosecond)
+	vfs = New(r.Fremote, &opt)
+	defer vfs.Shutdown()
+	nodes, err := vfs.ReadDir("")
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(nodes))
+}
+
+func TestDirStoreTrees(t *testing.T) {
+	s := &dirStore{
+		dirty: make(map[string]*dirRecord),
+		used:  make(map[string]struct{}),
+	}
+	s.forget("a/b", true)
+	s.forget("a/b/c", true)
+	assert.Equal(t, []string{"a/b/"}, s.trees)
+	s.forget("a", true)
+	assert.Equal(t, []string{"a/"}, s.trees)
+	s.forget("d", true)
+	assert.Equal(t, []string{"a/", "d/"}, s.trees)
+
+	// Too many trees stops all the saved listings being used
+	for i := 0; i < dirStoreMaxTrees; i++ {
+		s.forget(fmt.Sprintf("dir%d", i), true)
+	}
+	assert.Equal(t, []string{""}, s.trees)
+	s.forget("e", true)
+	assert.Equal(t, []string{""}, s.trees)
+	entries, _ := s.get("potato")
+	assert.Nil(t, entries)
+}
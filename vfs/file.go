@@ -13,6 +13,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/log"
 	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/vfs/vfscache"
 	"github.com/rclone/rclone/vfs/vfscommon"
 )
 
@@ -50,6 +51,7 @@ type File struct {
 	virtualModTime   *time.Time                      // modtime for backends with Precision == fs.ModTimeNotSupported
 	pendingModTime   time.Time                       // will be applied once o becomes available, i.e. after file was written
 	pendingRenameFun func(ctx context.Context) error // will be run/renamed after all writers close
+	pendingMetadata  fs.Metadata                     // will be applied once o becomes available
 	sys              atomic.Value                    // user defined info to be attached here
 	nwriters         atomic.Int32                    // len(writers)
 	appendMode       bool                            // file was opened with O_APPEND
@@ -200,24 +202,31 @@ func (f *File) rename(ctx context.Context, destDir *Dir, newName string) error {
 		var newObject fs.Object
 		// if o is nil then are writing the file so no need to rename the object
 		if o != nil {
-			if o.Remote() == newPath {
+			if o.Remote() == newPath && !d.vfs.journalQueued() {
 				return nil // no need to rename
 			}
 
 			// do the move of the remote object
-			dstOverwritten, _ := d.Fs().NewObject(ctx, newPath)
-			newObject, err = operations.Move(ctx, d.Fs(), dstOverwritten, newPath, o)
+			err = d.vfs.runOrJournal(vfscache.JournalEntry{Op: vfscache.JournalRename, Name: oldPath, NewName: newPath}, func() error {
+				src, err := resolveObject(ctx, o)
+				if err != nil {
+					return err
+				}
+				dstOverwritten, _ := d.Fs().NewObject(ctx, newPath)
+				newObject, err = operations.Move(ctx, d.Fs(), dstOverwritten, newPath, src)
+				if err != nil {
+					return err
+				}
+				// newObject can be nil here for example if --dry-run
+				if newObject == nil {
+					return errors.New("rename failed: nil object returned")
+				}
+				return nil
+			})
 			if err != nil {
 				fs.Errorf(f.Path(), "File.Rename error: %v", err)
 				return err
 			}
-
-			// newObject can be nil here for example if --dry-run
-			if newObject == nil {
-				err = errors.New("rename failed: nil object returned")
-				fs.Errorf(f.Path(), "File.Rename %v", err)
-				return err
-			}
 		}
 		// Rename in the cache
 		if d.vfs.cache != nil && d.vfs.cache.Exists(oldPath) {
@@ -231,6 +240,15 @@ func (f *File) rename(ctx context.Context, destDir *Dir, newName string) error {
 		f.mu.Lock()
 		if newObject != nil {
 			f.o = newObject
+		} else if o != nil {
+			// The rename was journalled so the object isn't at
+			// newPath on the remote yet - find it there when needed
+			f.o = &storedObject{
+				f:       d.Fs(),
+				remote:  newPath,
+				size:    o.Size(),
+				modTime: o.ModTime(ctx),
+			}
 		}
 		f.pendingRenameFun = nil
 		f.mu.Unlock()
@@ -400,6 +418,9 @@ func (f *File) SetModTime(modTime time.Time) error {
 	if f.d.vfs.Opt.ReadOnly {
 		return EROFS
 	}
+	if err := f.d.vfs.checkWriteLock(f._path(), false); err != nil {
+		return err
+	}
 
 	f.pendingModTime = modTime
 
@@ -437,7 +458,9 @@ func (f *File) _applyPendingModTime() error {
 	}
 
 	// set the time of the object
-	err := f.o.SetModTime(context.TODO(), f.pendingModTime)
+	err := f.d.vfs.runOrJournal(vfscache.JournalEntry{Op: vfscache.JournalSetModTime, Name: f._path(), ModTime: f.pendingModTime}, func() error {
+		return f.o.SetModTime(context.TODO(), f.pendingModTime)
+	})
 	switch err {
 	case nil:
 		fs.Debugf(f.o, "Applied pending mod time %v OK", f.pendingModTime)
@@ -481,6 +504,7 @@ func (f *File) setObject(o fs.Object) {
 	f.mu.Lock()
 	f.o = o
 	_ = f._applyPendingModTime()
+	_ = f._applyPendingMetadata()
 	d := f.d
 	f.mu.Unlock()
 
@@ -608,6 +632,9 @@ func (f *File) Remove() (err error) {
 	if d.vfs.Opt.ReadOnly {
 		return EROFS
 	}
+	if err = d.vfs.checkWriteLock(f.Path(), true); err != nil {
+		return err
+	}
 
 	// Remove the object from the cache
 	wasWriting := false
@@ -618,7 +645,9 @@ func (f *File) Remove() (err error) {
 	f.muRW.Lock() // muRW must be locked before mu to avoid
 	f.mu.Lock()   // deadlock in RWFileHandle.openPending and .close
 	if f.o != nil {
-		err = f.o.Remove(context.TODO())
+		err = d.vfs.runOrJournal(vfscache.JournalEntry{Op: vfscache.JournalRemove, Name: f._path()}, func() error {
+			return f.o.Remove(context.TODO())
+		})
 	}
 	f.mu.Unlock()
 	f.muRW.Unlock()
@@ -737,6 +766,11 @@ func (f *File) Open(flags int) (fd Handle, err error) {
 	f.mu.RLock()
 	d := f.d
 	f.mu.RUnlock()
+	if write {
+		if err = d.vfs.checkWriteLock(f.Path(), false); err != nil {
+			return nil, err
+		}
+	}
 	CacheMode := d.vfs.Opt.CacheMode
 	if CacheMode >= vfscommon.CacheModeMinimal && (d.vfs.cache.InUse(f.Path()) || d.vfs.cache.Exists(f.Path())) {
 		fd, err = f.openRW(flags)
@@ -775,6 +809,9 @@ func (f *File) Open(flags int) (fd Handle, err error) {
 
 // Truncate changes the size of the named file.
 func (f *File) Truncate(size int64) (err error) {
+	if err = f.VFS().checkWriteLock(f.Path(), false); err != nil {
+		return err
+	}
 	// make a copy of fh.writers with the lock held then unlock so
 	// we can call other file methods.
 	f.mu.Lock()
@@ -0,0 +1,160 @@
+// Write locks taken by clients of the VFS
+
+package vfs
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// writeLock is a write lock on a path taken by a VFS
+type writeLock struct {
+	owner     *VFS      // VFS which took the lock - writes through it are allowed
+	name      string    // path of the locked node
+	zeroDepth bool      // if set only name is locked, otherwise everything under it too
+	expires   time.Time // when the lock expires - zero for never
+}
+
+// expired returns true if the lock has expired at now
+func (l *writeLock) expired(now time.Time) bool {
+	return !l.expires.IsZero() && !now.Before(l.expires)
+}
+
+// covers returns true if the lock stops writes to name
+func (l *writeLock) covers(name string) bool {
+	return l.name == name || (!l.zeroDepth && isAncestor(l.name, name))
+}
+
+// Write locks are kept globally so that all the VFS serving the same
+// remote see them.
+var (
+	writeLocksMu sync.Mutex
+	writeLocks   = map[string]map[string]*writeLock{} // keyed on LockKey then token
+)
+
+// LockKey returns the key for the write locks of f.
+//
+// This is made from the type and description of the Fs rather than
+// its config name, so that the Fs made by --auth-proxy for each user,
+// which are called proxy-<user>, share locks when they point to the
+// same place. Remotes whose description doesn't identify the account
+// may share locks with other accounts which only causes extra
+// conflicts, never missed ones.
+func LockKey(f fs.Fs) string {
+	return fmt.Sprintf("%T:%s", f, f.String())
+}
+
+// cleanLockPath makes name into a VFS path
+func cleanLockPath(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+// isAncestor returns true if dir is a parent directory of name
+func isAncestor(dir, name string) bool {
+	if dir == "" {
+		return name != ""
+	}
+	return strings.HasPrefix(name, dir+"/")
+}
+
+// LockWrite takes the write lock identified by token on name, or
+// updates it if it exists already.
+//
+// If zeroDepth is set only name is locked, otherwise everything under
+// it is too. The lock lasts until UnlockWrite is called, the VFS is
+// shut down or expires if it isn't zero.
+//
+// While the lock is held, writes to the locked paths through any
+// other VFS on the same remote return ELOCKED.
+func (vfs *VFS) LockWrite(name, token string, zeroDepth bool, expires time.Time) {
+	writeLocksMu.Lock()
+	defer writeLocksMu.Unlock()
+	locks := writeLocks[vfs.lockKey]
+	if locks == nil {
+		locks = make(map[string]*writeLock)
+		writeLocks[vfs.lockKey] = locks
+	}
+	locks[token] = &writeLock{
+		owner:     vfs,
+		name:      cleanLockPath(name),
+		zeroDepth: zeroDepth,
+		expires:   expires,
+	}
+}
+
+// UnlockWrite releases the write lock identified by token
+func (vfs *VFS) UnlockWrite(token string) {
+	writeLocksMu.Lock()
+	defer writeLocksMu.Unlock()
+	locks := writeLocks[vfs.lockKey]
+	delete(locks, token)
+	if len(locks) == 0 {
+		delete(writeLocks, vfs.lockKey)
+	}
+}
+
+// WriteLocked returns true if the write lock identified by token is
+// still held.
+//
+// It returns false once the lock has expired or the VFS which took
+// it has been shut down.
+func (vfs *VFS) WriteLocked(token string) bool {
+	writeLocksMu.Lock()
+	defer writeLocksMu.Unlock()
+	l := writeLocks[vfs.lockKey][token]
+	return l != nil && !l.expired(time.Now())
+}
+
+// releaseWriteLocks releases all the write locks taken by this VFS
+func (vfs *VFS) releaseWriteLocks() {
+	writeLocksMu.Lock()
+	defer writeLocksMu.Unlock()
+	locks := writeLocks[vfs.lockKey]
+	for token, l := range locks {
+		if l.owner == vfs {
+			delete(locks, token)
+		}
+	}
+	if len(locks) == 0 {
+		delete(writeLocks, vfs.lockKey)
+	}
+}
+
+// checkWriteLock returns ELOCKED if name is locked by a different VFS.
+//
+// If membership is set then the write adds or removes name so it is
+// also locked by a lock on its parent directory or on anything under
+// name.
+func (vfs *VFS) checkWriteLock(name string, membership bool) error {
+	name = cleanLockPath(name)
+	parent := path.Dir(name)
+	if parent == "." {
+		parent = ""
+	}
+	now := time.Now()
+	writeLocksMu.Lock()
+	defer writeLocksMu.Unlock()
+	locks := writeLocks[vfs.lockKey]
+	for token, l := range locks {
+		if l.expired(now) {
+			delete(locks, token)
+			continue
+		}
+		if l.owner == vfs {
+			continue
+		}
+		if l.covers(name) || (membership && name != "" && (l.covers(parent) || isAncestor(name, l.name))) {
+			fs.Debugf(name, "Write refused: locked at %q", l.name)
+			return ELOCKED
+		}
+	}
+	if len(locks) == 0 {
+		delete(writeLocks, vfs.lockKey)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package vfs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteLocks(t *testing.T) {
+	r, vfs := newTestVFS(t)
+	ctx := context.Background()
+	r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+
+	// A second VFS on the same remote as another user would have
+	opt := vfscommon.Opt
+	opt.DirCacheTime = fs.Duration(time.Hour + time.Second)
+	other := New(r.Fremote, &opt)
+	require.NotEqual(t, vfs, other)
+	defer cleanupVFS(t, other)
+	assert.Equal(t, vfs.lockKey, other.lockKey)
+
+	write := func(v *VFS, name string) error {
+		fd, err := v.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+		if err != nil {
+			return err
+		}
+		_, err = fd.Write([]byte("hello"))
+		require.NoError(t, err)
+		return fd.Close()
+	}
+
+	// Lock just the file
+	vfs.LockWrite("/dir/file1", "token1", true, time.Time{})
+	assert.True(t, vfs.WriteLocked("token1"))
+	assert.Equal(t, ELOCKED, write(other, "dir/file1"))
+	assert.Equal(t, ELOCKED, other.Remove("dir/file1"))
+	assert.Equal(t, ELOCKED, other.Rename("dir/file1", "dir/file3"))
+	assert.NoError(t, write(other, "dir/file2"))
+	assert.NoError(t, write(vfs, "dir/file1"))
+
+	// Lock the directory and everything in it
+	vfs.LockWrite("/dir", "token2", false, time.Time{})
+	assert.Equal(t, ELOCKED, write(other, "dir/file2"))
+	assert.Equal(t, ELOCKED, write(other, "dir/new"))
+	assert.Equal(t, ELOCKED, other.Mkdir("dir/sub", 0777))
+	assert.Equal(t, ELOCKED, other.Rename("dir", "dir2"))
+	assert.NoError(t, write(vfs, "dir/file2"))
+
+	// Locking the directory with zero depth only stops its
+	// membership changing
+	vfs.LockWrite("/dir", "token2", true, time.Time{})
+	assert.NoError(t, write(other, "dir/file2"))
+	assert.Equal(t, ELOCKED, write(other, "dir/new"))
+	vfs.UnlockWrite("token2")
+	assert.False(t, vfs.WriteLocked("token2"))
+	assert.NoError(t, write(other, "dir/new"))
+
+	// Expired locks are ignored
+	vfs.LockWrite("/dir/file1", "token1", true, time.Now().Add(-time.Second))
+	assert.False(t, vfs.WriteLocked("token1"))
+	assert.NoError(t, write(other, "dir/file1"))
+
+	// Shutting down a VFS releases its locks
+	opt.DirCacheTime = fs.Duration(time.Hour + 2*time.Second)
+	third := New(r.Fremote, &opt)
+	third.LockWrite("/dir/file1", "token3", true, time.Time{})
+	assert.Equal(t, ELOCKED, write(other, "dir/file1"))
+	third.Shutdown()
+	assert.False(t, other.WriteLocked("token3"))
+	assert.NoError(t, write(other, "dir/file1"))
+}
@@ -0,0 +1,57 @@
+package vfs
+
+import (
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscache"
+)
+
+// journalling returns true if changes should be journalled when the
+// remote is unreachable
+func (vfs *VFS) journalling() bool {
+	return vfs.Opt.Offline && vfs.cache != nil
+}
+
+// journalQueued returns true if there are journalled changes waiting
+// to be replayed on the remote
+func (vfs *VFS) journalQueued() bool {
+	return vfs.journalling() && vfs.cache.JournalLen() > 0
+}
+
+// setOffline records whether the remote is reachable based on the
+// error returned from an operation on it.
+//
+// It returns true if the remote is unreachable and the VFS is
+// journalling changes.
+func (vfs *VFS) setOffline(err error) bool {
+	if !vfs.journalling() {
+		return false
+	}
+	offline := vfscache.IsOfflineError(err)
+	if vfs.offline.Swap(offline) != offline {
+		if offline {
+			fs.Logf(vfs.f, "vfs: remote is unreachable - queueing changes: %v", err)
+		} else {
+			fs.Logf(vfs.f, "vfs: remote is reachable again")
+			vfs.cache.JournalReplay()
+		}
+	}
+	return offline
+}
+
+// runOrJournal runs fn to make a change on the remote.
+//
+// If the VFS is journalling and fn fails because the remote is
+// unreachable, or there are changes queued already which must be
+// done first, then entry is queued to be replayed later instead.
+func (vfs *VFS) runOrJournal(entry vfscache.JournalEntry, fn func() error) error {
+	if !vfs.journalling() {
+		return fn()
+	}
+	if !vfs.journalQueued() {
+		err := fn()
+		if !vfs.setOffline(err) {
+			return err
+		}
+	}
+	return vfs.cache.Journal(entry)
+}
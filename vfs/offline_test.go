@@ -0,0 +1,231 @@
+package vfs
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/vfs/vfscache"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// offlineFs wraps an Fs so it can be made unreachable
+type offlineFs struct {
+	fs.Fs
+	offline atomic.Bool
+}
+
+// offlineObject wraps an Object so it can be made unreachable
+type offlineObject struct {
+	fs.Object
+	f *offlineFs
+}
+
+var errUnreachable = &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+func (f *offlineFs) check() error {
+	if f.offline.Load() {
+		return errUnreachable
+	}
+	return nil
+}
+
+func (f *offlineFs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	entries, err = f.Fs.List(ctx, dir)
+	for i, entry := range entries {
+		if o, ok := entry.(fs.Object); ok {
+			entries[i] = &offlineObject{Object: o, f: f}
+		}
+	}
+	return entries, err
+}
+
+func (f *offlineFs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	o, err := f.Fs.NewObject(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	return &offlineObject{Object: o, f: f}, nil
+}
+
+func (f *offlineFs) Mkdir(ctx context.Context, dir string) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	return f.Fs.Mkdir(ctx, dir)
+}
+
+func (f *offlineFs) Rmdir(ctx context.Context, dir string) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	return f.Fs.Rmdir(ctx, dir)
+}
+
+func (f *offlineFs) Features() *fs.Features {
+	features := *f.Fs.Features()
+	features.Move = f.move
+	return &features
+}
+
+func (f *offlineFs) move(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	if o, ok := src.(*offlineObject); ok {
+		src = o.Object
+	}
+	o, err := f.Fs.Features().Move(ctx, src, remote)
+	if err != nil {
+		return nil, err
+	}
+	return &offlineObject{Object: o, f: f}, nil
+}
+
+func (o *offlineObject) Remove(ctx context.Context) error {
+	if err := o.f.check(); err != nil {
+		return err
+	}
+	return o.Object.Remove(ctx)
+}
+
+func TestVFSOffline(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	file1 := r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	file2 := r.WriteObject(ctx, "file2", "file2 contents", t1)
+	require.NoError(t, r.Fremote.Mkdir(ctx, "emptydir"))
+	r.CheckRemoteListing(t, []fstest.Item{file1, file2}, []string{"dir", "emptydir"})
+
+	opt := vfscommon.Opt
+	opt.CacheMode = vfscommon.CacheModeWrites
+	opt.CachePollInterval = 0
+	opt.Offline = true
+	f := &offlineFs{Fs: r.Fremote}
+	vfs := New(f, &opt)
+	t.Cleanup(func() {
+		cleanupVFS(t, vfs)
+	})
+
+	checkNames := func(want ...string) {
+		t.Helper()
+		nodes, err := vfs.root.ReadDirAll()
+		require.NoError(t, err)
+		var names []string
+		for _, node := range nodes {
+			names = append(names, node.Name())
+		}
+		assert.Equal(t, want, names)
+	}
+	checkNames("dir", "emptydir", "file2")
+	_, err := vfs.ReadDir("emptydir")
+	require.NoError(t, err)
+	_, err = vfs.ReadDir("dir")
+	require.NoError(t, err)
+
+	// Go offline and make the listing stale
+	f.offline.Store(true)
+	vfs.root.mu.Lock()
+	vfs.root.read = time.Now().Add(-time.Hour)
+	vfs.root.mu.Unlock()
+
+	// The stale listing is used
+	checkNames("dir", "emptydir", "file2")
+	assert.True(t, vfs.offline.Load())
+
+	// Changes are queued
+	require.NoError(t, vfs.Mkdir("newdir", 0777))
+	require.NoError(t, vfs.Remove("file2"))
+	require.NoError(t, vfs.Remove("emptydir"))
+	require.NoError(t, vfs.Rename("dir/file1", "dir/file3"))
+	checkNames("dir", "newdir")
+	assert.Equal(t, 4, vfs.cache.JournalLen())
+	r.CheckRemoteListing(t, []fstest.Item{file1, file2}, []string{"dir", "emptydir"})
+
+	// The renamed file refers to its new name on the remote
+	node, err := vfs.Stat("dir/file3")
+	require.NoError(t, err)
+	o := node.(*File).getObject()
+	require.NotNil(t, o)
+	assert.Equal(t, "dir/file3", o.Remote())
+
+	// The local view isn't lost when the listing is re-read
+	vfs.root.mu.Lock()
+	vfs.root.read = time.Time{}
+	vfs.root.mu.Unlock()
+	checkNames("dir", "newdir")
+
+	out, err := rc.Calls.Get("vfs/journal").Fn(ctx, rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, true, out["offline"])
+	pending := out["pending"].([]vfscache.JournalEntry)
+	require.Equal(t, 4, len(pending))
+	assert.Equal(t, vfscache.JournalMkdir, pending[0].Op)
+	assert.Equal(t, "newdir", pending[0].Name)
+	assert.Equal(t, vfscache.JournalRemove, pending[1].Op)
+	assert.Equal(t, "file2", pending[1].Name)
+	assert.Equal(t, vfscache.JournalRmdir, pending[2].Op)
+	assert.Equal(t, "emptydir", pending[2].Name)
+	assert.Equal(t, vfscache.JournalRename, pending[3].Op)
+	assert.Equal(t, "dir/file3", pending[3].NewName)
+
+	// Come back online and replay
+	f.offline.Store(false)
+	_, err = rc.Calls.Get("vfs/journal").Fn(ctx, rc.Params{"replay": true})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return vfs.cache.JournalLen() == 0
+	}, 10*time.Second, 10*time.Millisecond)
+	file1.Path = "dir/file3"
+	r.CheckRemoteListing(t, []fstest.Item{file1}, []string{"dir", "newdir"})
+
+	out, err = rc.Calls.Get("vfs/journal").Fn(ctx, rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(out["conflicts"].([]vfscache.JournalEntry)))
+
+	// The listing is read from the remote again
+	vfs.root.mu.Lock()
+	vfs.root.read = time.Time{}
+	vfs.root.mu.Unlock()
+	checkNames("dir", "newdir")
+	assert.False(t, vfs.offline.Load())
+}
+
+func TestVFSOfflineNotEnabled(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteObject(ctx, "file1", "file1 contents", t1)
+
+	opt := vfscommon.Opt
+	opt.CacheMode = vfscommon.CacheModeWrites
+	opt.CachePollInterval = 0
+	f := &offlineFs{Fs: r.Fremote}
+	vfs := New(f, &opt)
+	t.Cleanup(func() {
+		cleanupVFS(t, vfs)
+	})
+
+	_, err := vfs.Stat("file1")
+	require.NoError(t, err)
+	f.offline.Store(true)
+	err = vfs.Mkdir("newdir", 0777)
+	assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+	_, err = vfs.Stat("newdir")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Equal(t, 0, vfs.cache.JournalLen())
+}
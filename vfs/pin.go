@@ -0,0 +1,120 @@
+package vfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// errNeedFullCache is returned by the pin and prefetch operations if
+// the cache mode doesn't read files through the cache
+var errNeedFullCache = errors.New("needs --vfs-cache-mode full")
+
+// checkFullCache returns an error if files aren't read through the cache
+func (vfs *VFS) checkFullCache() error {
+	if vfs.cache == nil || vfs.Opt.CacheMode < vfscommon.CacheModeFull {
+		return errNeedFullCache
+	}
+	return nil
+}
+
+// PrefetchStats describes what Prefetch did
+type PrefetchStats struct {
+	Files      int64 // number of files checked
+	Downloaded int64 // number of files which needed downloading
+	Bytes      int64 // size of the files downloaded
+	Errors     int64 // number of files which failed to download
+}
+
+// Prefetch downloads the file or directory tree at path into the
+// cache so it can be read while the remote is unavailable.
+//
+// Files which are already in the cache are left alone.
+func (vfs *VFS) Prefetch(ctx context.Context, path string) (stats PrefetchStats, err error) {
+	if err := vfs.checkFullCache(); err != nil {
+		return stats, err
+	}
+	node, err := vfs.Stat(path)
+	if err != nil {
+		return stats, err
+	}
+	err = vfs.prefetchNode(ctx, node, &stats)
+	if err == nil && stats.Errors > 0 {
+		err = fmt.Errorf("failed to prefetch %d files", stats.Errors)
+	}
+	return stats, err
+}
+
+// prefetchNode downloads node into the cache recursing into directories
+func (vfs *VFS) prefetchNode(ctx context.Context, node Node, stats *PrefetchStats) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	switch x := node.(type) {
+	case *Dir:
+		nodes, err := x.ReadDirAll()
+		if err != nil {
+			return fmt.Errorf("failed to read directory %q: %w", x.Path(), err)
+		}
+		for _, node := range nodes {
+			if err := vfs.prefetchNode(ctx, node, stats); err != nil {
+				return err
+			}
+		}
+	case *File:
+		stats.Files++
+		o := x.getObject()
+		if o == nil {
+			// being written so is in the cache already
+			return nil
+		}
+		downloaded, err := vfs.cache.Item(x.Path()).Prefetch(o)
+		if err != nil {
+			fs.Errorf(x.Path(), "vfs cache: failed to prefetch: %v", err)
+			stats.Errors++
+			return nil
+		}
+		if downloaded {
+			fs.Debugf(x.Path(), "vfs cache: prefetched")
+			stats.Downloaded++
+			stats.Bytes += o.Size()
+		}
+	}
+	return nil
+}
+
+// Pin marks the file or directory tree at path so it won't be removed
+// from the cache to make space.
+//
+// The pin is saved in the cache so lasts until Unpin is called. Call
+// Prefetch to download the files.
+func (vfs *VFS) Pin(path string) error {
+	if err := vfs.checkFullCache(); err != nil {
+		return err
+	}
+	node, err := vfs.Stat(path)
+	if err != nil {
+		return err
+	}
+	return vfs.cache.Pin(node.Path())
+}
+
+// Unpin removes a pin set with Pin so the files at path can be
+// removed from the cache as normal.
+func (vfs *VFS) Unpin(path string) error {
+	if err := vfs.checkFullCache(); err != nil {
+		return err
+	}
+	return vfs.cache.Unpin(path)
+}
+
+// Pins returns the pinned paths
+func (vfs *VFS) Pins() ([]string, error) {
+	if err := vfs.checkFullCache(); err != nil {
+		return nil, err
+	}
+	return vfs.cache.Pins(), nil
+}
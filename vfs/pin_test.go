@@ -0,0 +1,71 @@
+package vfs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/ranges"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRcPinPrefetch(t *testing.T) {
+	opt := vfscommon.Opt
+	opt.CacheMode = vfscommon.CacheModeFull
+	opt.CachePollInterval = 0
+	r, vfs := newTestVFSOpt(t, &opt)
+	ctx := context.Background()
+
+	file1 := r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	file2 := r.WriteObject(ctx, "dir/sub/file2", "file2 contents!", t1)
+	file3 := r.WriteObject(ctx, "file3", "file3", t1)
+	r.CheckRemoteItems(t, file1, file2, file3)
+
+	call := func(method string, in rc.Params) (rc.Params, error) {
+		c := rc.Calls.Get(method)
+		require.NotNil(t, c)
+		return c.Fn(ctx, in)
+	}
+
+	_, err := call("vfs/pin", rc.Params{"path": "notfound"})
+	assert.Error(t, err)
+
+	out, err := call("vfs/pin", rc.Params{"path": "dir"})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"pins":       []string{"dir"},
+		"files":      int64(2),
+		"downloaded": int64(2),
+		"bytes":      int64(29),
+		"errors":     int64(0),
+	}, out)
+	assert.True(t, vfs.cache.Item("dir/file1").HasRange(ranges.Range{Pos: 0, Size: 14}))
+	assert.True(t, vfs.cache.Item("dir/sub/file2").HasRange(ranges.Range{Pos: 0, Size: 15}))
+	assert.True(t, vfs.cache.IsPinned("dir/sub/file2"))
+
+	// Files already in the cache aren't downloaded again
+	out, err = call("vfs/prefetch", rc.Params{"path": "/"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), out["files"])
+	assert.Equal(t, int64(1), out["downloaded"])
+	assert.Equal(t, int64(5), out["bytes"])
+	assert.False(t, vfs.cache.IsPinned("file3"))
+
+	out, err = call("vfs/pin", rc.Params{"path": "file3", "prefetch": false})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir", "file3"}, out["pins"])
+	assert.Equal(t, int64(0), out["files"])
+
+	out, err = call("vfs/unpin", rc.Params{"path": "dir"})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"pins": []string{"file3"}}, out)
+	assert.False(t, vfs.cache.IsPinned("dir/file1"))
+}
+
+func TestRcPinNeedsFullCache(t *testing.T) {
+	_, _, call := rcNewRun(t, "vfs/pin")
+	_, err := call.Fn(context.Background(), rc.Params{"path": "dir"})
+	assert.ErrorIs(t, err, errNeedFullCache)
+}
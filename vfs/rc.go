@@ -546,3 +546,242 @@ func rcQueueSetExpiry(ctx context.Context, in rc.Params) (out rc.Params, err err
 	err = vfs.cache.QueueSetExpiry(writeback.Handle(id), expiryTime)
 	return nil, err
 }
+
+// prefetchResult returns the results of vfs/pin and vfs/prefetch
+func prefetchResult(vfs *VFS, stats PrefetchStats) (out rc.Params, err error) {
+	pins, err := vfs.Pins()
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{
+		"pins":       pins,
+		"files":      stats.Files,
+		"downloaded": stats.Downloaded,
+		"bytes":      stats.Bytes,
+		"errors":     stats.Errors,
+	}, nil
+}
+
+const prefetchResultHelp = `
+This returns
+
+- |pins| - a list of the pinned paths
+- |files| - the number of files checked
+- |downloaded| - the number of files which were downloaded
+- |bytes| - the size of the files downloaded
+- |errors| - the number of files which couldn't be downloaded
+`
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/pin",
+		Title: "Pin a file or directory so it stays in the VFS cache.",
+		Help: strings.ReplaceAll(`
+
+This pins the file or directory tree at |path| so that its files are
+never removed from the VFS cache to make space, and downloads them
+into the cache so they can be read while the remote is unavailable.
+
+The pins are saved in the vfsState directory in the cache directory so
+they last until |vfs/unpin| is called. Files added to a pinned
+directory later aren't downloaded until |vfs/prefetch| or |vfs/pin| is
+called again but they won't be removed from the cache once read.
+
+This needs |--vfs-cache-mode full|.
+
+This takes the following parameters
+
+- |fs| - select the VFS in use (optional)
+- |path| - the file or directory to pin
+- |prefetch| - set to false to pin without downloading (optional, default true)
+`+prefetchResultHelp, "|", "`") + getVFSHelp,
+		Fn: rcPin,
+	})
+}
+
+func rcPin(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	path, err := in.GetString("path")
+	if err != nil {
+		return nil, err
+	}
+	prefetch, err := in.GetBool("prefetch")
+	if rc.IsErrParamNotFound(err) {
+		prefetch = true
+	} else if err != nil {
+		return nil, err
+	}
+	err = vfs.Pin(path)
+	if err != nil {
+		return nil, err
+	}
+	var stats PrefetchStats
+	if prefetch {
+		stats, err = vfs.Prefetch(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return prefetchResult(vfs, stats)
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/unpin",
+		Title: "Unpin a file or directory pinned with vfs/pin.",
+		Help: strings.ReplaceAll(`
+
+This removes a pin made with |vfs/pin| so the files can be removed
+from the VFS cache as normal. |path| must be exactly as pinned.
+
+This takes the following parameters
+
+- |fs| - select the VFS in use (optional)
+- |path| - the file or directory to unpin
+
+This returns
+
+- |pins| - a list of the pinned paths
+`, "|", "`") + getVFSHelp,
+		Fn: rcUnpin,
+	})
+}
+
+func rcUnpin(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	path, err := in.GetString("path")
+	if err != nil {
+		return nil, err
+	}
+	err = vfs.Unpin(path)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := vfs.Pins()
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{"pins": pins}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/prefetch",
+		Title: "Download a file or directory into the VFS cache.",
+		Help: strings.ReplaceAll(`
+
+This downloads the file or directory tree at |path| into the VFS
+cache without pinning it. Files which are already in the cache are
+left alone.
+
+This needs |--vfs-cache-mode full|.
+
+This takes the following parameters
+
+- |fs| - select the VFS in use (optional)
+- |path| - the file or directory to download
+`+prefetchResultHelp, "|", "`") + getVFSHelp,
+		Fn: rcPrefetch,
+	})
+}
+
+func rcPrefetch(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	path, err := in.GetString("path")
+	if err != nil {
+		return nil, err
+	}
+	stats, err := vfs.Prefetch(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return prefetchResult(vfs, stats)
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/journal",
+		Title: "Show the changes queued while the remote was unreachable.",
+		Help: strings.ReplaceAll(`
+This returns info about the directory changes which were queued with
+|--vfs-offline| while the remote was unreachable and the changes which
+conflicted when they were replayed.
+
+This is only useful if |--vfs-cache-mode| > off. If you call it when
+the |--vfs-cache-mode| is off, it will return an empty result.
+
+    {
+        "offline": false,  // boolean: true if the remote is unreachable
+        "pending": // an array of changes waiting to be replayed in order
+        [
+            {
+                "id":      12,                     // integer: id of this change
+                "op":      "rename",               // string: mkdir, rmdir, remove, rename or setmodtime
+                "name":    "dir/file",             // string: full path of the file or directory
+                "newName": "dir/file2",            // string: full path to rename to
+                "isDir":   false,                  // boolean: true if name is a directory
+                "modTime": "2024-01-02T15:04:05Z", // time: modtime to set for setmodtime
+                "queued":  "2024-01-02T15:04:05Z", // time: when the change was queued
+                "tries":   3,                      // integer: number of times we have tried to replay it
+                "error":   "dial tcp: ...",        // string: the last error replaying it
+            },
+        ],
+        "conflicts": // an array of changes which couldn't be replayed
+        [
+        ],
+    }
+
+Changes which fail to replay for any reason other than the remote
+being unreachable, for example because the file was modified on the
+remote after it was removed locally, are moved to |conflicts| with the
+|error| explaining why.
+
+This takes the following parameters
+
+- |fs| - select the VFS in use (optional)
+- |replay| - set to |true| to try replaying the changes now (optional)
+- |clearConflicts| - set to |true| to forget the conflicts after returning them (optional)
+
+`, "|", "`") + getVFSHelp,
+		Fn: rcJournal,
+	})
+}
+
+func rcJournal(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	if vfs.cache == nil {
+		return nil, nil
+	}
+	replay, err := in.GetBool("replay")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	clearConflicts, err := in.GetBool("clearConflicts")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	out = vfs.cache.JournalQueue()
+	out["offline"] = vfs.offline.Load()
+	if clearConflicts {
+		err = vfs.cache.JournalClearConflicts()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if replay {
+		vfs.cache.JournalReplay()
+	}
+	return out, nil
+}
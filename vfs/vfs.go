@@ -66,6 +66,10 @@ type Node interface {
 	Truncate(size int64) error
 	Path() string
 	SetSys(interface{})
+	ListXattr() (names []string, err error)
+	GetXattr(name string) (value []byte, err error)
+	SetXattr(name string, value []byte) error
+	RemoveXattr(name string) error
 }
 
 // Check interfaces
@@ -183,6 +187,9 @@ type VFS struct {
 	usage       *fs.Usage
 	pollChan    chan time.Duration
 	inUse       atomic.Int32 // count of number of opens
+	offline     atomic.Bool  // set if the remote was last found to be unreachable
+	dirStore    *dirStore    // if set, directory listings are saved here
+	lockKey     string       // key for the write locks, see LockKey
 }
 
 // Keep track of active VFS keyed on fs.ConfigString(f)
@@ -196,7 +203,8 @@ var (
 func New(f fs.Fs, opt *vfscommon.Options) *VFS {
 	fsDir := fs.NewDir("", time.Now())
 	vfs := &VFS{
-		f: f,
+		f:       f,
+		lockKey: LockKey(f),
 	}
 	vfs.inUse.Store(1)
 
@@ -224,6 +232,15 @@ func New(f fs.Fs, opt *vfscommon.Options) *VFS {
 	// Put the VFS into the active cache
 	active[configName] = append(active[configName], vfs)
 
+	// Open the store for the directory cache
+	if vfs.Opt.PersistDirCache {
+		var err error
+		vfs.dirStore, err = newDirStore(f, time.Duration(vfs.Opt.DirCacheTime))
+		if err != nil {
+			fs.Errorf(f, "Failed to open directory cache store - not saving directory cache: %v", err)
+		}
+	}
+
 	// Create root directory
 	vfs.root = newDir(vfs, f, nil, fsDir)
 
@@ -360,6 +377,8 @@ func (vfs *VFS) Shutdown() {
 	activeMu.Unlock()
 
 	vfs.shutdownCache()
+	vfs.dirStore.close()
+	vfs.releaseWriteLocks()
 }
 
 // CleanUp deletes the contents of the on disk cache
@@ -3,6 +3,8 @@ package vfscache
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
@@ -47,21 +49,27 @@ type Cache struct {
 	opt        *vfscommon.Options   // vfs Options
 	root       string               // root of the cache directory
 	metaRoot   string               // root of the cache metadata directory
+	stateRoot  string               // directory the state files are kept in
+	stateName  string               // prefix of the state file names of this cache
 	hashType   hash.Type            // hash to use locally and remotely
 	hashOption *fs.HashesOption     // corresponding OpenOption
 	writeback  *writeback.WriteBack // holds Items for writeback
 	avFn       AddVirtualFn         // if set, can be called to add dir entries
 
-	mu            sync.Mutex       // protects the following variables
-	cond          sync.Cond        // cond lock for synchronous cache cleaning
-	item          map[string]*Item // files/directories in the cache
-	errItems      map[string]error // items in error state
-	used          int64            // total size of files in the cache
-	outOfSpace    bool             // out of space
-	cleanerKicked bool             // some thread kicked the cleaner upon out of space
-	kickerMu      sync.Mutex       // mutex for cleanerKicked
-	kick          chan struct{}    // channel for kicking clear to start
+	mu            sync.Mutex          // protects the following variables
+	cond          sync.Cond           // cond lock for synchronous cache cleaning
+	item          map[string]*Item    // files/directories in the cache
+	errItems      map[string]error    // items in error state
+	pins          map[string]struct{} // pinned files and directories which aren't purged
+	used          int64               // total size of files in the cache
+	outOfSpace    bool                // out of space
+	cleanerKicked bool                // some thread kicked the cleaner upon out of space
+	kickerMu      sync.Mutex          // mutex for cleanerKicked
+	kick          chan struct{}       // channel for kicking clear to start
 
+	journalMu   sync.Mutex    // protects journal
+	journal     journal       // metadata operations waiting to be replayed
+	journalKick chan struct{} // channel for kicking the journaller to replay
 }
 
 // AddVirtualFn if registered by the WithAddVirtual method, can be
@@ -113,6 +121,9 @@ func New(ctx context.Context, fremote fs.Fs, opt *vfscommon.Options, avFn AddVir
 	}
 	hashType, hashOption := operations.CommonHash(ctx, fdata, fremote)
 
+	// The state files are named after the remote so they are unique
+	stateName := md5.Sum([]byte(relativeDirPath))
+
 	// Create the cache object
 	c := &Cache{
 		fremote:    fremote,
@@ -121,6 +132,8 @@ func New(ctx context.Context, fremote fs.Fs, opt *vfscommon.Options, avFn AddVir
 		opt:        opt,
 		root:       dataOSPath,
 		metaRoot:   metaOSPath,
+		stateRoot:  file.UNCPath(filepath.Join(parentOSPath, "vfsState")),
+		stateName:  hex.EncodeToString(stateName[:]),
 		item:       make(map[string]*Item),
 		errItems:   make(map[string]error),
 		hashType:   hashType,
@@ -134,6 +147,14 @@ func New(ctx context.Context, fremote fs.Fs, opt *vfscommon.Options, avFn AddVir
 	if err != nil {
 		return nil, fmt.Errorf("failed to load cache: %w", err)
 	}
+	err = c.loadPins()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cache: %w", err)
+	}
+	err = c.loadJournal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cache: %w", err)
+	}
 
 	// Remove any empty directories
 	c.purgeEmptyDirs("", true)
@@ -144,6 +165,11 @@ func New(ctx context.Context, fremote fs.Fs, opt *vfscommon.Options, avFn AddVir
 
 	go c.cleaner(ctx)
 
+	// Replay any queued metadata operations
+	if opt.Offline || len(c.journal.Pending) > 0 {
+		go c.journaller(ctx)
+	}
+
 	return c, nil
 }
 
@@ -166,6 +192,8 @@ func (c *Cache) Stats() (out rc.Params) {
 	out["erroredFiles"] = len(c.errItems)
 	out["bytesUsed"] = c.used
 	out["outOfSpace"] = c.outOfSpace
+	out["pins"] = len(c.pins)
+	out["journalQueued"] = c.JournalLen()
 
 	return out
 }
@@ -187,6 +215,15 @@ func createDir(dir string) error {
 	return file.MkdirAll(dir, 0700)
 }
 
+// statePath returns the OS path of the state file called kind, e.g.
+// the pins.
+//
+// These are kept outside the data and metadata trees so they can't
+// clash with the cached files.
+func (c *Cache) statePath(kind string) string {
+	return filepath.Join(c.stateRoot, c.stateName+"."+kind+".json")
+}
+
 // createRootDir creates a single cache root directory
 func createRootDir(parentOSPath string, name string, relativeDirOSPath string) (path string, err error) {
 	path = file.UNCPath(filepath.Join(parentOSPath, name, relativeDirOSPath))
@@ -422,6 +459,7 @@ func (c *Cache) Rename(name string, newName string, newObj fs.Object) (err error
 		c.item[newName] = item
 		delete(c.item, name)
 	}
+	c._renamePins(clean(name), clean(newName))
 	c.mu.Unlock()
 
 	fs.Infof(name, "vfs cache: renamed in cache to %q", newName)
@@ -448,6 +486,7 @@ func (c *Cache) DirRename(oldDirName string, newDirName string) (err error) {
 	// Find all items to rename
 	var renames []string
 	c.mu.Lock()
+	c._renamePins(clean(oldDirName), clean(newDirName))
 	for itemName := range c.item {
 		if strings.HasPrefix(itemName, oldDirName) {
 			renames = append(renames, itemName)
@@ -502,7 +541,15 @@ func (c *Cache) CleanUp() error {
 	if err1 != nil {
 		return err1
 	}
-	return err2
+	if err2 != nil {
+		return err2
+	}
+	for _, statePath := range []string{c.pinsPath(), c.journalPath()} {
+		if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
 }
 
 // walk walks the cache calling the function
@@ -631,9 +678,9 @@ func (c *Cache) purgeClean() {
 
 	var items Items
 
-	// Make a slice of clean cache files
+	// Make a slice of clean cache files which aren't pinned
 	for _, item := range c.item {
-		if !item.IsDirty() {
+		if !item.IsDirty() && !c._isPinned(item.name) {
 			items = append(items, item)
 		}
 	}
@@ -673,6 +720,9 @@ func (c *Cache) purgeOld(maxAge time.Duration) {
 	defer c.mu.Unlock()
 	// cutoff := time.Now().Add(-maxAge)
 	for _, item := range c.item {
+		if c._isPinned(item.name) {
+			continue
+		}
 		c.removeNotInUse(item, maxAge, false)
 	}
 	if c.quotasOK() {
@@ -759,9 +809,9 @@ func (c *Cache) purgeOverQuota() {
 
 	var items Items
 
-	// Make a slice of unused files
+	// Make a slice of unused files which aren't pinned
 	for _, item := range c.item {
-		if !item.inUse() {
+		if !item.inUse() && !c._isPinned(item.name) {
 			items = append(items, item)
 		}
 	}
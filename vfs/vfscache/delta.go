@@ -0,0 +1,178 @@
+package vfscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/ranges"
+	"golang.org/x/sync/errgroup"
+)
+
+// errDeltaUnsupported is returned if a delta upload can't be done
+var errDeltaUnsupported = errors.New("delta upload not supported")
+
+// deltaChunk is a chunk of a delta upload
+type deltaChunk struct {
+	r    ranges.Range // part of the file the chunk covers
+	copy bool         // set to copy the chunk from the remote object
+}
+
+// deltaPlan splits a file of size into chunks of chunkSize and works
+// out which of them are unchanged so can be copied from the remote
+// object of oldSize.
+//
+// It returns the chunks and the number of bytes which can be copied.
+func deltaPlan(size, oldSize, chunkSize int64, changed ranges.Ranges) (chunks []deltaChunk, copied int64) {
+	if chunkSize <= 0 {
+		return nil, 0
+	}
+	for pos := int64(0); pos < size; pos += chunkSize {
+		r := ranges.Range{Pos: pos, Size: chunkSize}
+		r.Clip(size)
+		unchanged := r.End() <= oldSize && len(changed.Intersection(r)) == 0
+		if unchanged {
+			copied += r.Size
+		}
+		chunks = append(chunks, deltaChunk{r: r, copy: unchanged})
+	}
+	return chunks, copied
+}
+
+// deltaUpload uploads a cache file by copying the parts of it which
+// haven't changed from the remote object server-side and uploading
+// the rest.
+type deltaUpload struct {
+	c           *Cache
+	name        string
+	osPath      string
+	src         fs.Object     // the cache file
+	fingerprint string        // fingerprint of the remote object the changes are relative to
+	changed     ranges.Ranges // parts of the file which have changed
+	present     ranges.Ranges // parts of the file which are in the cache
+}
+
+// _newDeltaUpload returns a deltaUpload for the item or nil if the
+// item can't be uploaded that way.
+//
+// call with lock held
+func (item *Item) _newDeltaUpload(src fs.Object) *deltaUpload {
+	if !item.c.opt.DeltaUpload || item.o == nil || item.info.ChangedFrom == "" {
+		return nil
+	}
+	features := item.c.fremote.Features()
+	if features.OpenChunkWriter == nil || !features.ChunkWriterCopies {
+		return nil
+	}
+	return &deltaUpload{
+		c:           item.c,
+		name:        item.name,
+		osPath:      item.c.toOSPath(item.name),
+		src:         src,
+		fingerprint: item.info.ChangedFrom,
+		changed:     append(ranges.Ranges(nil), item.info.Changed...),
+		present:     append(ranges.Ranges(nil), item.info.Rs...),
+	}
+}
+
+// upload does the delta upload returning the new object.
+//
+// It returns false if the file needs to be uploaded in full instead.
+func (d *deltaUpload) upload(ctx context.Context) (o fs.Object, ok bool) {
+	o, err := d.do(ctx)
+	if errors.Is(err, errDeltaUnsupported) {
+		fs.Debugf(d.name, "vfs cache: uploading in full: %v", err)
+		return nil, false
+	} else if err != nil {
+		fs.Errorf(d.name, "vfs cache: delta upload failed - uploading in full: %v", err)
+		return nil, false
+	}
+	return o, true
+}
+
+// do the delta upload
+func (d *deltaUpload) do(ctx context.Context) (o fs.Object, err error) {
+	f := d.c.fremote
+	size := d.src.Size()
+	if size <= 0 {
+		return nil, errDeltaUnsupported
+	}
+
+	// Check the chunks can be copied before starting an upload
+	// which would only have to be aborted
+	if !f.Features().ChunkWriterCopies {
+		return nil, fmt.Errorf("%w: backend can't copy chunks", errDeltaUnsupported)
+	}
+
+	// Only copy from the remote object if it is the one the
+	// changes were made to
+	old, err := f.NewObject(ctx, d.name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't find remote object: %v", errDeltaUnsupported, err)
+	}
+	if fs.Fingerprint(ctx, old, d.c.opt.FastFingerprint) != d.fingerprint {
+		return nil, fmt.Errorf("%w: remote object has changed", errDeltaUnsupported)
+	}
+
+	info, w, err := f.Features().OpenChunkWriter(ctx, d.name, d.src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chunk writer: %w", err)
+	}
+	defer func() {
+		if err != nil && !info.LeavePartsOnError {
+			if abortErr := w.Abort(ctx); abortErr != nil {
+				fs.Errorf(d.name, "vfs cache: failed to abort delta upload: %v", abortErr)
+			}
+		}
+	}()
+	copier, ok := w.(fs.ChunkWriterCopier)
+	if !ok {
+		return nil, fmt.Errorf("%w: backend can't copy chunks", errDeltaUnsupported)
+	}
+	chunks, copied := deltaPlan(size, old.Size(), info.ChunkSize, d.changed)
+	if copied == 0 {
+		return nil, fmt.Errorf("%w: no unchanged chunks to copy", errDeltaUnsupported)
+	}
+	for _, chunk := range chunks {
+		if !chunk.copy && !d.present.Present(chunk.r) {
+			return nil, fmt.Errorf("%w: changed chunk at %d not in cache", errDeltaUnsupported, chunk.r.Pos)
+		}
+	}
+
+	fd, err := os.Open(d.osPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache file: %w", err)
+	}
+	defer fs.CheckClose(fd, &err)
+
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(max(info.Concurrency, 1))
+	for i, chunk := range chunks {
+		i, chunk := i, chunk
+		g.Go(func() error {
+			if chunk.copy {
+				_, err := copier.CopyChunk(gCtx, i, old, chunk.r.Pos, chunk.r.Size)
+				return err
+			}
+			_, err := w.WriteChunk(gCtx, i, io.NewSectionReader(fd, chunk.r.Pos, chunk.r.Size))
+			return err
+		})
+	}
+	err = g.Wait()
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finalise delta upload: %w", err)
+	}
+	o, err = f.NewObject(ctx, d.name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find object after delta upload: %w", err)
+	}
+	fs.Infof(d.name, "vfs cache: delta upload copied %v and uploaded %v", fs.SizeSuffix(copied), fs.SizeSuffix(size-copied))
+	return o, nil
+}
@@ -0,0 +1,223 @@
+package vfscache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/ranges"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltaPlan(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		size      int64
+		oldSize   int64
+		chunkSize int64
+		changed   ranges.Ranges
+		want      string
+		copied    int64
+	}{
+		{"unchanged", 10, 10, 3, nil, "cccc", 10},
+		{"middle", 10, 10, 3, ranges.Ranges{{Pos: 5, Size: 1}}, "cucc", 7},
+		{"spanning", 10, 10, 3, ranges.Ranges{{Pos: 2, Size: 2}}, "uucc", 4},
+		{"extended", 14, 10, 4, ranges.Ranges{{Pos: 10, Size: 4}}, "ccuu", 8},
+		{"grown last chunk", 10, 9, 4, ranges.Ranges{{Pos: 9, Size: 1}}, "ccu", 8},
+		{"shrunk", 7, 10, 4, nil, "cc", 7},
+		{"empty", 0, 10, 4, nil, "", 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			chunks, copied := deltaPlan(test.size, test.oldSize, test.chunkSize, test.changed)
+			var (
+				got []byte
+				end int64
+			)
+			for _, chunk := range chunks {
+				assert.Equal(t, end, chunk.r.Pos)
+				end = chunk.r.End()
+				if chunk.copy {
+					got = append(got, 'c')
+				} else {
+					got = append(got, 'u')
+				}
+			}
+			assert.Equal(t, test.size, end)
+			assert.Equal(t, test.want, string(got))
+			assert.Equal(t, test.copied, copied)
+		})
+	}
+}
+
+// deltaFs adds a chunk writer which can copy chunks to an Fs
+type deltaFs struct {
+	fs.Fs
+	noCopy  bool // set to say the chunk writer can't copy chunks
+	mu      sync.Mutex
+	opened  int
+	copied  []int
+	written []int
+}
+
+func (f *deltaFs) Features() *fs.Features {
+	ft := *f.Fs.Features()
+	ft.OpenChunkWriter = f.OpenChunkWriter
+	ft.ChunkWriterCopies = !f.noCopy
+	return &ft
+}
+
+func (f *deltaFs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (fs.ChunkWriterInfo, fs.ChunkWriter, error) {
+	f.mu.Lock()
+	f.opened++
+	f.mu.Unlock()
+	w := &deltaWriter{f: f, remote: remote, src: src, chunks: map[int][]byte{}}
+	return fs.ChunkWriterInfo{ChunkSize: 4, Concurrency: 2}, w, nil
+}
+
+// reset returns the chunks copied and written and clears them
+func (f *deltaFs) reset() (copied, written []int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied, written = f.copied, f.written
+	sort.Ints(copied)
+	sort.Ints(written)
+	f.copied, f.written = nil, nil
+	return copied, written
+}
+
+type deltaWriter struct {
+	f      *deltaFs
+	remote string
+	src    fs.ObjectInfo
+	mu     sync.Mutex
+	chunks map[int][]byte
+}
+
+func (w *deltaWriter) setChunk(chunkNumber int, data []byte, copied bool) {
+	w.mu.Lock()
+	w.chunks[chunkNumber] = data
+	w.mu.Unlock()
+	w.f.mu.Lock()
+	if copied {
+		w.f.copied = append(w.f.copied, chunkNumber)
+	} else {
+		w.f.written = append(w.f.written, chunkNumber)
+	}
+	w.f.mu.Unlock()
+}
+
+func (w *deltaWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return -1, err
+	}
+	w.setChunk(chunkNumber, data, false)
+	return int64(len(data)), nil
+}
+
+func (w *deltaWriter) CopyChunk(ctx context.Context, chunkNumber int, src fs.Object, offset, length int64) (int64, error) {
+	in, err := src.Open(ctx, &fs.RangeOption{Start: offset, End: offset + length - 1})
+	if err != nil {
+		return -1, err
+	}
+	data, err := io.ReadAll(in)
+	_ = in.Close()
+	if err != nil {
+		return -1, err
+	}
+	w.setChunk(chunkNumber, data, true)
+	return int64(len(data)), nil
+}
+
+func (w *deltaWriter) Close(ctx context.Context) error {
+	var buf bytes.Buffer
+	for i := 0; i < len(w.chunks); i++ {
+		buf.Write(w.chunks[i])
+	}
+	info := object.NewStaticObjectInfo(w.remote, w.src.ModTime(ctx), int64(buf.Len()), true, nil, nil)
+	_, err := w.f.Fs.Put(ctx, &buf, info)
+	return err
+}
+
+func (w *deltaWriter) Abort(ctx context.Context) error {
+	return nil
+}
+
+func TestItemDeltaUpload(t *testing.T) {
+	r := fstest.NewRun(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := &deltaFs{Fs: r.Fremote}
+
+	opt := vfscommon.Opt
+	opt.CachePollInterval = 0
+	opt.WriteBack = 0
+	opt.DeltaUpload = true
+	c, err := New(ctx, f, &opt, addVirtual)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.CleanUp())
+	}()
+
+	r.WriteObject(ctx, "file", "0123456789abcdefghij", time.Now())
+	obj, err := f.NewObject(ctx, "file")
+	require.NoError(t, err)
+	item, _ := c.get("file")
+
+	// Change a single chunk
+	require.NoError(t, item.Open(obj))
+	_, err = item.WriteAt([]byte("XY"), 5)
+	require.NoError(t, err)
+	require.NoError(t, item.Close(nil))
+	checkObject(t, r, "file", "01234XY789abcdefghij")
+	copied, written := f.reset()
+	assert.Equal(t, []int{0, 2, 3, 4}, copied)
+	assert.Equal(t, []int{1}, written)
+	assert.Nil(t, item.info.Changed)
+
+	// Extend the file - changes are relative to the new object now
+	obj, err = f.NewObject(ctx, "file")
+	require.NoError(t, err)
+	require.NoError(t, item.Open(obj))
+	_, err = item.WriteAt([]byte("ZZ"), 22)
+	require.NoError(t, err)
+	require.NoError(t, item.Close(nil))
+	checkObject(t, r, "file", "01234XY789abcdefghij\x00\x00ZZ")
+	copied, written = f.reset()
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, copied)
+	assert.Equal(t, []int{5}, written)
+
+	// If the remote has changed under us upload the file in full
+	obj, err = f.NewObject(ctx, "file")
+	require.NoError(t, err)
+	require.NoError(t, item.Open(obj))
+	_, err = item.WriteAt([]byte("!"), 0)
+	require.NoError(t, err)
+	r.WriteObject(ctx, "file", "changed on the remote", time.Now().Add(time.Minute))
+	require.NoError(t, item.Close(nil))
+	checkObject(t, r, "file", "!1234XY789abcdefghij\x00\x00ZZ")
+	copied, written = f.reset()
+	assert.Nil(t, copied)
+	assert.Nil(t, written)
+
+	// If the backend can't copy chunks no chunk writer is opened
+	f.noCopy = true
+	f.opened = 0
+	obj, err = f.NewObject(ctx, "file")
+	require.NoError(t, err)
+	require.NoError(t, item.Open(obj))
+	_, err = item.WriteAt([]byte("?"), 1)
+	require.NoError(t, err)
+	require.NoError(t, item.Close(nil))
+	checkObject(t, r, "file", "!?234XY789abcdefghij\x00\x00ZZ")
+	assert.Equal(t, 0, f.opened)
+}
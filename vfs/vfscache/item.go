@@ -78,6 +78,8 @@ type Info struct {
 	Rs          ranges.Ranges // which parts of the file are present
 	Fingerprint string        // fingerprint of remote object
 	Dirty       bool          // set if the backing file has been modified
+	Changed     ranges.Ranges // which parts of the file have been modified since ChangedFrom
+	ChangedFrom string        // fingerprint of the remote object Changed is relative to
 }
 
 // Items are a slice of *Item ordered by ATime
@@ -350,9 +352,12 @@ func (item *Item) Truncate(size int64) (err error) {
 		// read as zeros. In this case we must show we have written to
 		// the new parts of the file.
 		item._written(oldSize, size)
+		item._changed(oldSize, size)
 	} else if size < oldSize {
 		// Truncate shrinks the file so clip the downloaded ranges
 		item.info.Rs = item.info.Rs.Intersection(ranges.Range{Pos: 0, Size: size})
+		item._changed(size, 0)
+		item.info.Changed = item.info.Changed.Intersection(ranges.Range{Pos: 0, Size: size})
 	} else {
 		changed = item.o == nil
 	}
@@ -598,8 +603,16 @@ func (item *Item) _store(ctx context.Context, storeFn StoreFn) (err error) {
 	// Object has disappeared if cacheObj == nil
 	if cacheObj != nil {
 		o, name := item.o, item.name
+		changed := append(ranges.Ranges(nil), item.info.Changed...)
+		delta := item._newDeltaUpload(cacheObj)
 		unlockMutexForCall(&item.mu, func() {
-			o, err = operations.Copy(ctx, item.c.fremote, o, name, cacheObj)
+			uploaded := false
+			if delta != nil {
+				o, uploaded = delta.upload(ctx)
+			}
+			if !uploaded {
+				o, err = operations.Copy(ctx, item.c.fremote, o, name, cacheObj)
+			}
 		})
 		if err != nil {
 			if errors.Is(err, fs.ErrorCantUploadEmptyFiles) {
@@ -610,6 +623,13 @@ func (item *Item) _store(ctx context.Context, storeFn StoreFn) (err error) {
 		}
 		item.o = o
 		item._updateFingerprint()
+
+		// Changes are now relative to the uploaded object. Keep
+		// them if the file was written while it was uploading.
+		if item.info.Changed.Equal(changed) {
+			item.info.Changed = nil
+		}
+		item.info.ChangedFrom = item.info.Fingerprint
 	}
 
 	// Write the object back to the VFS layer before we mark it as
@@ -1175,6 +1195,27 @@ func (item *Item) _ensure(offset, size int64) (err error) {
 	return item.downloaders.Download(r)
 }
 
+// Prefetch downloads all of the object o into the cache.
+//
+// It returns true if any data needed downloading.
+func (item *Item) Prefetch(o fs.Object) (downloaded bool, err error) {
+	err = item.Open(o)
+	if err != nil {
+		return false, err
+	}
+	item.mu.Lock()
+	downloaded = !item._present()
+	if downloaded {
+		err = item._ensure(0, item.info.Size)
+	}
+	item.mu.Unlock()
+	closeErr := item.Close(nil)
+	if err == nil {
+		err = closeErr
+	}
+	return downloaded, err
+}
+
 // _written marks the (offset, size) as present in the backing file
 //
 // This is called by the downloader downloading file segments and the
@@ -1190,6 +1231,19 @@ func (item *Item) _written(offset, size int64) {
 	item.info.Rs.Insert(ranges.Range{Pos: offset, Size: size})
 }
 
+// _changed marks the (offset, size) as modified so it needs uploading
+// even if the rest of the file can be copied from the remote object.
+//
+// call with lock held
+func (item *Item) _changed(offset, size int64) {
+	if item.info.ChangedFrom == "" {
+		item.info.ChangedFrom = item.info.Fingerprint
+	}
+	if size > 0 {
+		item.info.Changed.Insert(ranges.Range{Pos: offset, Size: size})
+	}
+}
+
 // update the fingerprint of the object if any
 //
 // call with lock held
@@ -1320,6 +1374,7 @@ func (item *Item) WriteAt(b []byte, off int64) (n int, err error) {
 	}
 	item.mu.Lock()
 	item._written(off, int64(n))
+	item._changed(off, int64(n))
 	if n > 0 {
 		item._dirty()
 	}
@@ -1329,6 +1384,7 @@ func (item *Item) WriteAt(b []byte, off int64) (n int, err error) {
 	// new parts of the file.
 	if off > item.info.Size {
 		item._written(item.info.Size, off-item.info.Size)
+		item._changed(item.info.Size, off-item.info.Size)
 		item._dirty()
 	}
 	// Update size
@@ -0,0 +1,372 @@
+package vfscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// journalRetryInterval is how often to try replaying the journal
+// while the remote is unreachable
+var journalRetryInterval = 10 * time.Second
+
+// JournalOp is the type of a metadata operation in the journal
+type JournalOp string
+
+// Metadata operations which can be journalled
+const (
+	JournalMkdir      JournalOp = "mkdir"      // make directory Name
+	JournalRmdir      JournalOp = "rmdir"      // remove empty directory Name
+	JournalRemove     JournalOp = "remove"     // remove file Name
+	JournalRename     JournalOp = "rename"     // rename Name to NewName
+	JournalSetModTime JournalOp = "setmodtime" // set the modtime of file Name to ModTime
+)
+
+// JournalEntry is a metadata operation queued while the remote was
+// unreachable
+type JournalEntry struct {
+	ID      int64     `json:"id"`                // id of the entry
+	Op      JournalOp `json:"op"`                // operation to do
+	Name    string    `json:"name"`              // full path of the file or directory
+	NewName string    `json:"newName,omitempty"` // full path to rename to
+	IsDir   bool      `json:"isDir,omitempty"`   // set if Name is a directory
+	ModTime time.Time `json:"modTime"`           // modtime for setmodtime
+	Queued  time.Time `json:"queued"`            // when the entry was queued
+	Tries   int       `json:"tries"`             // number of times we have tried to replay it
+	Error   string    `json:"error,omitempty"`   // last error replaying it
+}
+
+// journal is the persisted state of the metadata journal
+type journal struct {
+	NextID    int64           `json:"nextId"`
+	Pending   []*JournalEntry `json:"pending"`   // waiting to be replayed in order
+	Conflicts []*JournalEntry `json:"conflicts"` // failed to replay
+}
+
+// errJournalConflict wraps errors which mean the entry can't be replayed
+type errJournalConflict struct {
+	err error
+}
+
+func (e errJournalConflict) Error() string { return e.err.Error() }
+func (e errJournalConflict) Unwrap() error { return e.err }
+
+// conflictf makes a conflict error
+func conflictf(format string, a ...any) error {
+	return errJournalConflict{err: fmt.Errorf(format, a...)}
+}
+
+// IsOfflineError returns true if err means the remote couldn't be
+// reached rather than the operation failed.
+//
+// Only failures to look up or connect to the server count, so errors
+// from a server which is up, including timeouts and errors which are
+// worth retrying, don't put the VFS offline.
+func IsOfflineError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var (
+		opErr  *net.OpError
+		dnsErr *net.DNSError
+	)
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// journalPath returns the OS path of the file the journal is saved in
+func (c *Cache) journalPath() string {
+	return c.statePath("journal")
+}
+
+// loadJournal reads the journal from disk
+func (c *Cache) loadJournal() error {
+	c.journalKick = make(chan struct{}, 1)
+	data, err := os.ReadFile(c.journalPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.journal); err != nil {
+		return fmt.Errorf("failed to parse journal: %w", err)
+	}
+	if len(c.journal.Pending) > 0 {
+		fs.Infof(nil, "vfs cache: %d changes queued for replay", len(c.journal.Pending))
+	}
+	return nil
+}
+
+// _saveJournal writes the journal to disk
+//
+// must be called with journalMu held
+func (c *Cache) _saveJournal() error {
+	data, err := json.Marshal(&c.journal)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal: %w", err)
+	}
+	err = createDir(c.stateRoot)
+	if err != nil {
+		return fmt.Errorf("failed to save journal: %w", err)
+	}
+	tmp := c.journalPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save journal: %w", err)
+	}
+	if err := os.Rename(tmp, c.journalPath()); err != nil {
+		return fmt.Errorf("failed to save journal: %w", err)
+	}
+	return nil
+}
+
+// Journal queues entry to be replayed on the remote once it is
+// reachable again.
+//
+// Entries are replayed in the order they are queued.
+func (c *Cache) Journal(entry JournalEntry) error {
+	entry.Name = clean(entry.Name)
+	if entry.NewName != "" {
+		entry.NewName = clean(entry.NewName)
+	}
+	entry.Queued = time.Now()
+	c.journalMu.Lock()
+	c.journal.NextID++
+	entry.ID = c.journal.NextID
+	c.journal.Pending = append(c.journal.Pending, &entry)
+	err := c._saveJournal()
+	if err != nil {
+		c.journal.Pending = c.journal.Pending[:len(c.journal.Pending)-1]
+	}
+	c.journalMu.Unlock()
+	if err != nil {
+		return err
+	}
+	fs.Infof(entry.Name, "vfs cache: queued %s for replay", entry.Op)
+	return nil
+}
+
+// JournalLen returns the number of operations waiting to be replayed
+func (c *Cache) JournalLen() int {
+	c.journalMu.Lock()
+	defer c.journalMu.Unlock()
+	return len(c.journal.Pending)
+}
+
+// touches returns true if x affects the listing of dir
+func touches(dir, x string) bool {
+	if x == "" {
+		return false
+	}
+	parent := path.Dir(x)
+	if parent == "." {
+		parent = ""
+	}
+	return parent == dir || x == dir || strings.HasPrefix(dir, x+"/")
+}
+
+// JournalPending returns true if there are operations waiting to be
+// replayed which change the listing of dir.
+//
+// These directories shouldn't be re-read from the remote until the
+// journal has been replayed otherwise the changes will disappear.
+func (c *Cache) JournalPending(dir string) bool {
+	dir = clean(dir)
+	c.journalMu.Lock()
+	defer c.journalMu.Unlock()
+	for _, entry := range c.journal.Pending {
+		if touches(dir, entry.Name) || touches(dir, entry.NewName) {
+			return true
+		}
+	}
+	return false
+}
+
+// JournalQueue returns info about the journal
+func (c *Cache) JournalQueue() (out rc.Params) {
+	c.journalMu.Lock()
+	defer c.journalMu.Unlock()
+	copyEntries := func(entries []*JournalEntry) []JournalEntry {
+		out := make([]JournalEntry, len(entries))
+		for i, entry := range entries {
+			out[i] = *entry
+		}
+		return out
+	}
+	return rc.Params{
+		"pending":   copyEntries(c.journal.Pending),
+		"conflicts": copyEntries(c.journal.Conflicts),
+	}
+}
+
+// JournalClearConflicts removes the record of operations which
+// couldn't be replayed
+func (c *Cache) JournalClearConflicts() error {
+	c.journalMu.Lock()
+	defer c.journalMu.Unlock()
+	c.journal.Conflicts = nil
+	return c._saveJournal()
+}
+
+// JournalReplay tries to replay the journal now rather than waiting
+// for the next retry.
+func (c *Cache) JournalReplay() {
+	select {
+	case c.journalKick <- struct{}{}:
+	default:
+	}
+}
+
+// journaller replays the journal in the background until ctx is
+// cancelled
+func (c *Cache) journaller(ctx context.Context) {
+	timer := time.NewTicker(journalRetryInterval)
+	defer timer.Stop()
+	for {
+		c.replayJournal(ctx)
+		select {
+		case <-c.journalKick:
+		case <-timer.C:
+		case <-ctx.Done():
+			fs.Debugf(nil, "vfs cache: journaller exiting")
+			return
+		}
+	}
+}
+
+// isRetryReplayError returns true if replaying an entry failed with
+// err because the remote is unreachable or had a temporary problem, so
+// the entry should be tried again later rather than being a conflict.
+func isRetryReplayError(err error) bool {
+	return IsOfflineError(err) || errors.Is(err, context.DeadlineExceeded) || fserrors.ShouldRetry(err)
+}
+
+// replayJournal replays the pending entries in order until it runs
+// out or the remote is unreachable.
+//
+// Entries which fail for any other reason are moved to the
+// conflicts list.
+func (c *Cache) replayJournal(ctx context.Context) {
+	for ctx.Err() == nil {
+		// Only this goroutine removes pending entries so the
+		// first entry stays put while we replay it
+		c.journalMu.Lock()
+		if len(c.journal.Pending) == 0 {
+			c.journalMu.Unlock()
+			return
+		}
+		entry := *c.journal.Pending[0]
+		c.journalMu.Unlock()
+
+		err := c.replayEntry(ctx, &entry)
+
+		c.journalMu.Lock()
+		stored := c.journal.Pending[0]
+		stored.Tries++
+		retry := err != nil && !errors.As(err, &errJournalConflict{}) && isRetryReplayError(err)
+		switch {
+		case err == nil:
+			fs.Infof(entry.Name, "vfs cache: replayed %s", entry.Op)
+			c.journal.Pending = c.journal.Pending[1:]
+		case retry:
+			fs.Debugf(entry.Name, "vfs cache: will retry replaying %s: %v", entry.Op, err)
+			stored.Error = err.Error()
+		default:
+			fs.Errorf(entry.Name, "vfs cache: conflict replaying %s: %v", entry.Op, err)
+			stored.Error = err.Error()
+			c.journal.Pending = c.journal.Pending[1:]
+			c.journal.Conflicts = append(c.journal.Conflicts, stored)
+		}
+		if err := c._saveJournal(); err != nil {
+			fs.Errorf(nil, "vfs cache: %v", err)
+		}
+		c.journalMu.Unlock()
+		if retry {
+			return
+		}
+	}
+}
+
+// changedSince returns a conflict error if o has been modified on
+// the remote after the entry was queued
+func changedSince(ctx context.Context, o fs.Object, entry *JournalEntry) error {
+	if o.ModTime(ctx).After(entry.Queued) {
+		return conflictf("%q was modified on the remote after the %s was queued", o.Remote(), entry.Op)
+	}
+	return nil
+}
+
+// replayEntry does the operation in entry on the remote
+func (c *Cache) replayEntry(ctx context.Context, entry *JournalEntry) error {
+	f := c.fremote
+	switch entry.Op {
+	case JournalMkdir:
+		return f.Mkdir(ctx, entry.Name)
+	case JournalRmdir:
+		err := f.Rmdir(ctx, entry.Name)
+		if errors.Is(err, fs.ErrorDirNotFound) {
+			return nil
+		}
+		return err
+	case JournalRemove:
+		o, err := f.NewObject(ctx, entry.Name)
+		if errors.Is(err, fs.ErrorObjectNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := changedSince(ctx, o, entry); err != nil {
+			return err
+		}
+		return o.Remove(ctx)
+	case JournalRename:
+		if entry.IsDir {
+			return operations.DirMove(ctx, f, entry.Name, entry.NewName)
+		}
+		o, err := f.NewObject(ctx, entry.Name)
+		if errors.Is(err, fs.ErrorObjectNotFound) {
+			// Already renamed if the destination exists
+			if _, err := f.NewObject(ctx, entry.NewName); err == nil {
+				return nil
+			}
+			return conflictf("%q not found on the remote", entry.Name)
+		} else if err != nil {
+			return err
+		}
+		dst, err := f.NewObject(ctx, entry.NewName)
+		if err == nil {
+			if err := changedSince(ctx, dst, entry); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, fs.ErrorObjectNotFound) {
+			return err
+		}
+		_, err = operations.Move(ctx, f, dst, entry.NewName, o)
+		return err
+	case JournalSetModTime:
+		o, err := f.NewObject(ctx, entry.Name)
+		if errors.Is(err, fs.ErrorObjectNotFound) {
+			return conflictf("%q not found on the remote", entry.Name)
+		} else if err != nil {
+			return err
+		}
+		err = o.SetModTime(ctx, entry.ModTime)
+		if errors.Is(err, fs.ErrorCantSetModTime) || errors.Is(err, fs.ErrorCantSetModTimeWithoutDelete) {
+			return nil
+		}
+		return err
+	}
+	return conflictf("unknown journal operation %q", entry.Op)
+}
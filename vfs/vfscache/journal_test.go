@@ -0,0 +1,126 @@
+package vfscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsOfflineError(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("potato"), false},
+		{fs.ErrorObjectNotFound, false},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{fmt.Errorf("wrapped: %w", &net.DNSError{Err: "no such host", Name: "example.com"}), true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.EHOSTUNREACH}, true},
+		{&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, false},
+		{context.DeadlineExceeded, false},
+		{syscall.ECONNRESET, false},
+	} {
+		assert.Equal(t, test.want, IsOfflineError(test.err), fmt.Sprint(test.err))
+	}
+}
+
+func TestJournalTouches(t *testing.T) {
+	_, c := newTestCache(t)
+
+	require.NoError(t, c.Journal(JournalEntry{Op: JournalRename, Name: "a/b", NewName: "c/d/e", IsDir: true}))
+	for _, test := range []struct {
+		dir  string
+		want bool
+	}{
+		{"", false},
+		{"a", true},
+		{"a/b", true},
+		{"a/b/c", true},
+		{"a/bb", false},
+		{"c", false},
+		{"c/d", true},
+		{"c/d/e", true},
+		{"c/d/e/f/g", true},
+		{"x", false},
+	} {
+		assert.Equal(t, test.want, c.JournalPending(test.dir), test.dir)
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	r, c := newTestCache(t)
+	ctx := context.Background()
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	t2 := fstest.Time("2011-12-25T12:59:59.123456789Z")
+	file1 := r.WriteObject(ctx, "dir/file1", "file1", t1)
+	file2 := r.WriteObject(ctx, "dir/file2", "file2", t1)
+	file3 := r.WriteObject(ctx, "file3", "file3", t1)
+	file4 := r.WriteObject(ctx, "file4", "file4", t1)
+	require.NoError(t, r.Fremote.Mkdir(ctx, "emptydir"))
+	r.CheckRemoteItems(t, file1, file2, file3, file4)
+
+	for _, entry := range []JournalEntry{
+		{Op: JournalMkdir, Name: "newdir", IsDir: true},
+		{Op: JournalRename, Name: "/file3", NewName: "newdir/file3"},
+		{Op: JournalSetModTime, Name: "newdir/file3", ModTime: t2},
+		{Op: JournalRename, Name: "dir", NewName: "dir2", IsDir: true},
+		{Op: JournalRemove, Name: "dir2/file2"},
+		{Op: JournalRemove, Name: "file4"},
+		{Op: JournalRemove, Name: "notfound"},
+		{Op: JournalRmdir, Name: "emptydir", IsDir: true},
+		{Op: JournalSetModTime, Name: "notfound", ModTime: t2},
+	} {
+		require.NoError(t, c.Journal(entry))
+	}
+	assert.Equal(t, 9, c.JournalLen())
+	assert.Equal(t, 9, c.Stats()["journalQueued"])
+
+	// Modify file4 on the remote after the remove was queued
+	// which should make a conflict
+	time.Sleep(10 * time.Millisecond)
+	file4 = r.WriteObject(ctx, "file4", "file4 changed", time.Now())
+
+	// Check the journal is reloaded - use a cancelled context so
+	// the new cache doesn't replay the journal itself
+	ctx2, cancel := context.WithCancel(ctx)
+	cancel()
+	c2, err := New(ctx2, r.Fremote, c.opt, nil)
+	require.NoError(t, err)
+	pending := c2.JournalQueue()["pending"].([]JournalEntry)
+	require.Equal(t, 9, len(pending))
+	assert.Equal(t, int64(1), pending[0].ID)
+	assert.Equal(t, "file3", pending[1].Name)
+	assert.Equal(t, "newdir/file3", pending[1].NewName)
+	assert.Equal(t, []string(nil), itemAsString(c2))
+
+	c.replayJournal(ctx)
+	assert.Equal(t, 0, c.JournalLen())
+
+	file3.Path = "newdir/file3"
+	file3.ModTime = t2
+	file1.Path = "dir2/file1"
+	r.CheckRemoteListing(t, []fstest.Item{file1, file3, file4}, []string{"dir2", "newdir"})
+
+	conflicts := c.JournalQueue()["conflicts"].([]JournalEntry)
+	require.Equal(t, 2, len(conflicts))
+	assert.Equal(t, JournalRemove, conflicts[0].Op)
+	assert.Equal(t, "file4", conflicts[0].Name)
+	assert.Contains(t, conflicts[0].Error, "modified on the remote")
+	assert.Equal(t, 1, conflicts[0].Tries)
+	assert.Equal(t, JournalSetModTime, conflicts[1].Op)
+	assert.Contains(t, conflicts[1].Error, "not found")
+
+	require.NoError(t, c.JournalClearConflicts())
+	assert.Equal(t, 0, len(c.JournalQueue()["conflicts"].([]JournalEntry)))
+}
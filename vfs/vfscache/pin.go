@@ -0,0 +1,170 @@
+package vfscache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// pinsPath returns the OS path of the file the pins are saved in
+func (c *Cache) pinsPath() string {
+	return c.statePath("pins")
+}
+
+// loadPins reads the pinned paths from disk
+func (c *Cache) loadPins() error {
+	c.pins = map[string]struct{}{}
+	data, err := os.ReadFile(c.pinsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read pins: %w", err)
+	}
+	var pins []string
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return fmt.Errorf("failed to parse pins: %w", err)
+	}
+	for _, name := range pins {
+		c.pins[clean(name)] = struct{}{}
+	}
+	return nil
+}
+
+// _savePins writes the pinned paths to disk
+//
+// must be called with mu held
+func (c *Cache) _savePins() error {
+	data, err := json.Marshal(c._pinList())
+	if err != nil {
+		return fmt.Errorf("failed to marshal pins: %w", err)
+	}
+	err = createDir(c.stateRoot)
+	if err != nil {
+		return fmt.Errorf("failed to save pins: %w", err)
+	}
+	tmp := c.pinsPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save pins: %w", err)
+	}
+	if err := os.Rename(tmp, c.pinsPath()); err != nil {
+		return fmt.Errorf("failed to save pins: %w", err)
+	}
+	return nil
+}
+
+// _pinList returns the pinned paths sorted
+//
+// must be called with mu held
+func (c *Cache) _pinList() []string {
+	pins := make([]string, 0, len(c.pins))
+	for name := range c.pins {
+		pins = append(pins, name)
+	}
+	sort.Strings(pins)
+	return pins
+}
+
+// _isPinned returns true if name or any directory above it is pinned
+//
+// must be called with mu held
+func (c *Cache) _isPinned(name string) bool {
+	if len(c.pins) == 0 {
+		return false
+	}
+	for {
+		if _, ok := c.pins[name]; ok {
+			return true
+		}
+		if name == "" {
+			return false
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			name = ""
+		} else {
+			name = name[:i]
+		}
+	}
+}
+
+// IsPinned returns true if name or any directory above it is pinned
+// so it won't be removed from the cache to make space.
+func (c *Cache) IsPinned(name string) bool {
+	name = clean(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c._isPinned(name)
+}
+
+// Pin marks the file or directory tree name so that it won't be
+// removed from the cache to make space.
+func (c *Cache) Pin(name string) error {
+	name = clean(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.pins[name]; ok {
+		return nil
+	}
+	c.pins[name] = struct{}{}
+	if err := c._savePins(); err != nil {
+		delete(c.pins, name)
+		return err
+	}
+	fs.Infof(name, "vfs cache: pinned")
+	return nil
+}
+
+// Unpin removes the pin on name made with Pin so it can be removed
+// from the cache as normal.
+func (c *Cache) Unpin(name string) error {
+	name = clean(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.pins[name]; !ok {
+		return fmt.Errorf("%q is not pinned", name)
+	}
+	delete(c.pins, name)
+	if err := c._savePins(); err != nil {
+		c.pins[name] = struct{}{}
+		return err
+	}
+	fs.Infof(name, "vfs cache: unpinned")
+	return nil
+}
+
+// Pins returns the pinned paths
+func (c *Cache) Pins() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c._pinList()
+}
+
+// _renamePins moves any pins on oldName or below it to newName
+//
+// must be called with mu held
+func (c *Cache) _renamePins(oldName, newName string) {
+	changed := false
+	for name := range c.pins {
+		var rest string
+		switch {
+		case name == oldName:
+		case strings.HasPrefix(name, oldName+"/"):
+			rest = name[len(oldName):]
+		default:
+			continue
+		}
+		delete(c.pins, name)
+		c.pins[newName+rest] = struct{}{}
+		changed = true
+	}
+	if changed {
+		if err := c._savePins(); err != nil {
+			fs.Errorf(newName, "vfs cache: %v", err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package vfscache
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachePins(t *testing.T) {
+	r, c := newTestCache(t)
+
+	assert.Equal(t, []string{}, c.Pins())
+	require.NoError(t, c.Pin("/sub/dir/"))
+	require.NoError(t, c.Pin("file"))
+	require.NoError(t, c.Pin("file")) // pinning twice is OK
+	assert.Equal(t, []string{"file", "sub/dir"}, c.Pins())
+
+	assert.True(t, c.IsPinned("sub/dir"))
+	assert.True(t, c.IsPinned("sub/dir/potato"))
+	assert.True(t, c.IsPinned("sub/dir/a/b/c"))
+	assert.True(t, c.IsPinned("file"))
+	assert.False(t, c.IsPinned("sub"))
+	assert.False(t, c.IsPinned("sub/dir2/potato"))
+	assert.False(t, c.IsPinned("file2"))
+	assert.Equal(t, 2, c.Stats()["pins"])
+
+	// Pinned items aren't purged
+	for _, name := range []string{"sub/dir/potato", "sub/dir2/potato2", "file"} {
+		item := c.Item(name)
+		require.NoError(t, item.Open(nil))
+		require.NoError(t, item.Close(nil))
+	}
+	c.purgeOld(-10 * time.Second)
+	assert.Equal(t, []string{
+		`name="file" opens=0 size=0`,
+		`name="sub/dir/potato" opens=0 size=0`,
+	}, itemAsString(c))
+
+	// Renaming moves the pins
+	require.NoError(t, c.DirRename("sub/dir", "sub/moved"))
+	require.NoError(t, c.Rename("file", "file3", nil))
+	assert.Equal(t, []string{"file3", "sub/moved"}, c.Pins())
+	assert.True(t, c.IsPinned("sub/moved/potato"))
+	assert.False(t, c.IsPinned("sub/dir/potato"))
+
+	// Unpin
+	require.NoError(t, c.Unpin("file3"))
+	assert.ErrorContains(t, c.Unpin("file3"), "not pinned")
+	assert.Equal(t, []string{"sub/moved"}, c.Pins())
+	c.purgeOld(-10 * time.Second)
+	assert.Equal(t, []string{
+		`name="sub/moved/potato" opens=0 size=0`,
+	}, itemAsString(c))
+
+	// The pins are reloaded and the pins file isn't an item
+	_, err := os.Stat(c.pinsPath())
+	require.NoError(t, err)
+	assert.False(t, strings.HasPrefix(c.pinsPath(), c.metaRoot))
+	assert.False(t, strings.HasPrefix(c.pinsPath(), c.root))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c2, err := New(ctx, r.Fremote, c.opt, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sub/moved"}, c2.Pins())
+	assert.Equal(t, []string{
+		`name="sub/moved/potato" opens=0 size=0`,
+	}, itemAsString(c2))
+}
+
+func TestCachePinRoot(t *testing.T) {
+	_, c := newTestCache(t)
+
+	require.NoError(t, c.Pin(""))
+	assert.True(t, c.IsPinned(""))
+	assert.True(t, c.IsPinned("potato"))
+	assert.True(t, c.IsPinned("sub/dir/potato"))
+	require.NoError(t, c.Unpin("/"))
+	assert.False(t, c.IsPinned("potato"))
+}
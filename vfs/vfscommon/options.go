@@ -29,6 +29,11 @@ var OptionsInfo = fs.Options{{
 	Default: fs.Duration(5 * 60 * time.Second),
 	Help:    "Time to cache directory entries for",
 	Groups:  "VFS",
+}, {
+	Name:    "vfs_persist_dir_cache",
+	Default: false,
+	Help:    "Save the directory cache to disk and use it after a restart",
+	Groups:  "VFS",
 }, {
 	Name:    "vfs_refresh",
 	Default: false,
@@ -69,6 +74,11 @@ var OptionsInfo = fs.Options{{
 	Default: fs.SizeSuffix(-1),
 	Help:    "Target minimum free space on the disk containing the cache",
 	Groups:  "VFS",
+}, {
+	Name:    "vfs_offline",
+	Default: false,
+	Help:    "Queue directory changes while the remote is unreachable and replay them later",
+	Groups:  "VFS",
 }, {
 	Name:    "vfs_read_chunk_size",
 	Default: 128 * fs.Mebi,
@@ -119,6 +129,11 @@ var OptionsInfo = fs.Options{{
 	Default: fs.Duration(5 * time.Second),
 	Help:    "Time to writeback files after last use when using cache",
 	Groups:  "VFS",
+}, {
+	Name:    "vfs_delta_upload",
+	Default: false,
+	Help:    "Only upload the changed parts of modified files if the backend can copy the rest",
+	Groups:  "VFS",
 }, {
 	Name:    "vfs_read_ahead",
 	Default: 0 * fs.Mebi,
@@ -162,12 +177,13 @@ func init() {
 
 // Options is options for creating the vfs
 type Options struct {
-	NoSeek             bool          `config:"no_seek"`        // don't allow seeking if set
-	NoChecksum         bool          `config:"no_checksum"`    // don't check checksums if set
-	ReadOnly           bool          `config:"read_only"`      // if set VFS is read only
-	NoModTime          bool          `config:"no_modtime"`     // don't read mod times for files
-	DirCacheTime       fs.Duration   `config:"dir_cache_time"` // how long to consider directory listing cache valid
-	Refresh            bool          `config:"vfs_refresh"`    // refreshes the directory listing recursively on start
+	NoSeek             bool          `config:"no_seek"`               // don't allow seeking if set
+	NoChecksum         bool          `config:"no_checksum"`           // don't check checksums if set
+	ReadOnly           bool          `config:"read_only"`             // if set VFS is read only
+	NoModTime          bool          `config:"no_modtime"`            // don't read mod times for files
+	DirCacheTime       fs.Duration   `config:"dir_cache_time"`        // how long to consider directory listing cache valid
+	Refresh            bool          `config:"vfs_refresh"`           // refreshes the directory listing recursively on start
+	PersistDirCache    bool          `config:"vfs_persist_dir_cache"` // save the directory cache to disk
 	PollInterval       fs.Duration   `config:"poll_interval"`
 	Umask              FileMode      `config:"umask"`
 	UID                uint32        `config:"uid"`
@@ -182,11 +198,13 @@ type Options struct {
 	CacheMaxSize       fs.SizeSuffix `config:"vfs_cache_max_size"`
 	CacheMinFreeSpace  fs.SizeSuffix `config:"vfs_cache_min_free_space"`
 	CachePollInterval  fs.Duration   `config:"vfs_cache_poll_interval"`
+	Offline            bool          `config:"vfs_offline"` // queue metadata changes if the remote is unreachable
 	CaseInsensitive    bool          `config:"vfs_case_insensitive"`
 	BlockNormDupes     bool          `config:"vfs_block_norm_dupes"`
 	WriteWait          fs.Duration   `config:"vfs_write_wait"`       // time to wait for in-sequence write
 	ReadWait           fs.Duration   `config:"vfs_read_wait"`        // time to wait for in-sequence read
 	WriteBack          fs.Duration   `config:"vfs_write_back"`       // time to wait before writing back dirty files
+	DeltaUpload        bool          `config:"vfs_delta_upload"`     // server-side copy unchanged parts on writeback
 	ReadAhead          fs.SizeSuffix `config:"vfs_read_ahead"`       // bytes to read ahead in cache mode "full"
 	UsedIsSize         bool          `config:"vfs_used_is_size"`     // if true, use the `rclone size` algorithm for Used size
 	FastFingerprint    bool          `config:"vfs_fast_fingerprint"` // if set use fast fingerprints
@@ -0,0 +1,311 @@
+package vfs
+
+import (
+	"context"
+	"encoding/base64"
+	"sort"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// XattrPrefix is the prefix of the extended attributes which show
+// the metadata of the object backing a file
+const XattrPrefix = "user.rclone."
+
+// POSIX ACL extended attributes and the metadata keys they are
+// stored in. The ACLs are binary so they are stored base64 encoded.
+var aclXattrs = map[string]string{
+	"system.posix_acl_access":  "posix-acl-access",
+	"system.posix_acl_default": "posix-acl-default",
+}
+
+// xattrKey returns the metadata key for the extended attribute name
+func xattrKey(name string) (key string, ok bool) {
+	if key, ok = aclXattrs[name]; ok {
+		return key, true
+	}
+	key, ok = strings.CutPrefix(name, XattrPrefix)
+	return key, ok && key != ""
+}
+
+// xattrValue returns the value of the extended attribute name from
+// metadata.
+//
+// It returns ENOATTR if it isn't set.
+func xattrValue(metadata fs.Metadata, name string) (value []byte, err error) {
+	key, ok := xattrKey(name)
+	if !ok {
+		return nil, ENOATTR
+	}
+	v, found := metadata[key]
+	if !found {
+		return nil, ENOATTR
+	}
+	if _, isACL := aclXattrs[name]; isACL {
+		value, err = base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			fs.Debugf(nil, "Ignoring badly encoded %q metadata: %v", key, err)
+			return nil, ENOATTR
+		}
+		return value, nil
+	}
+	return []byte(v), nil
+}
+
+// xattrMetadataValue returns the metadata key and value to store the
+// extended attribute name with value in.
+//
+// It returns ENOTSUP if name isn't in the XattrPrefix namespace or
+// an ACL.
+func xattrMetadataValue(name string, value []byte) (key, v string, err error) {
+	key, ok := xattrKey(name)
+	if !ok {
+		return "", "", ENOTSUP
+	}
+	if _, isACL := aclXattrs[name]; isACL {
+		return key, base64.StdEncoding.EncodeToString(value), nil
+	}
+	return key, string(value), nil
+}
+
+// xattrNames returns the sorted names of the extended attributes for
+// metadata.
+func xattrNames(metadata fs.Metadata) (names []string) {
+	acls := make(map[string]string, len(aclXattrs))
+	for name, key := range aclXattrs {
+		acls[key] = name
+	}
+	for key := range metadata {
+		if name, isACL := acls[key]; isACL {
+			names = append(names, name)
+		} else {
+			names = append(names, XattrPrefix+key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setMetadata sets metadata on o which may be an fs.Object or an
+// fs.Directory.
+//
+// It returns ENOTSUP if the backend can't set metadata.
+func setMetadata(ctx context.Context, path string, o fs.DirEntry, metadata fs.Metadata) error {
+	do, ok := o.(fs.SetMetadataer)
+	if !ok {
+		return ENOTSUP
+	}
+	err := do.SetMetadata(ctx, metadata)
+	if err == fs.ErrorNotImplemented {
+		return ENOTSUP
+	} else if err != nil {
+		fs.Errorf(path, "Failed to set metadata: %v", err)
+		return err
+	}
+	fs.Debugf(path, "Applied metadata %v OK", metadata)
+	return nil
+}
+
+// metadata returns the metadata of the file including any not yet
+// applied.
+//
+// The mutex is only held to read the object and pending metadata so
+// the backend isn't called with it held.
+func (f *File) metadata(ctx context.Context) (metadata fs.Metadata, err error) {
+	f.mu.RLock()
+	o := f.o
+	var pending fs.Metadata
+	if len(f.pendingMetadata) > 0 {
+		pending = make(fs.Metadata, len(f.pendingMetadata))
+		pending.Merge(f.pendingMetadata)
+	}
+	f.mu.RUnlock()
+
+	if o != nil {
+		o, err = resolveObject(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		metadata, err = fs.GetMetadata(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(pending) > 0 {
+		if metadata == nil {
+			metadata = make(fs.Metadata, len(pending))
+		}
+		metadata.Merge(pending)
+	}
+	return metadata, nil
+}
+
+// ListXattr returns the names of the extended attributes of the file.
+//
+// These are the metadata keys of the object with XattrPrefix added
+// and any ACLs stored in the metadata.
+func (f *File) ListXattr() (names []string, err error) {
+	metadata, err := f.metadata(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return xattrNames(metadata), nil
+}
+
+// GetXattr returns the value of the extended attribute name.
+//
+// It returns ENOATTR if it isn't set.
+func (f *File) GetXattr(name string) (value []byte, err error) {
+	if _, ok := xattrKey(name); !ok {
+		return nil, ENOATTR
+	}
+	metadata, err := f.metadata(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return xattrValue(metadata, name)
+}
+
+// SetXattr sets the extended attribute name to value by setting the
+// metadata of the object.
+//
+// It returns ENOTSUP if name isn't in the XattrPrefix namespace or an
+// ACL, or the backend can't set metadata. If the file is being
+// written the metadata is set once it has been uploaded.
+func (f *File) SetXattr(name string, value []byte) error {
+	key, v, err := xattrMetadataValue(name, value)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	if f.d.vfs.Opt.ReadOnly {
+		f.mu.Unlock()
+		return EROFS
+	}
+	f.pendingMetadata.Set(key, v)
+	if f._writingInProgress() {
+		// queue up for later, hoping f.o becomes available
+		f.mu.Unlock()
+		return nil
+	}
+	metadata := f.pendingMetadata
+	f.pendingMetadata = nil
+	o := f.o
+	path := f._path()
+	f.mu.Unlock()
+
+	if o == nil {
+		fs.Errorf(path, "Cannot apply metadata, file object is not available")
+		return ENOTSUP
+	}
+	ctx := context.TODO()
+	o, err = resolveObject(ctx, o)
+	if err != nil {
+		return err
+	}
+	return setMetadata(ctx, path, o, metadata)
+}
+
+// RemoveXattr removes the extended attribute name.
+//
+// The backend metadata interface can only add and change metadata
+// so this returns ENOTSUP unless the attribute doesn't exist.
+func (f *File) RemoveXattr(name string) error {
+	if _, err := f.GetXattr(name); err != nil {
+		return err
+	}
+	return ENOTSUP
+}
+
+// Apply any pending metadata
+//
+// Call with the mutex held
+func (f *File) _applyPendingMetadata() error {
+	if len(f.pendingMetadata) == 0 {
+		return nil
+	}
+	metadata := f.pendingMetadata
+	f.pendingMetadata = nil
+	if f.o == nil {
+		fs.Errorf(f._path(), "Cannot apply metadata, file object is not available")
+		return ENOTSUP
+	}
+	ctx := context.TODO()
+	o, err := resolveObject(ctx, f.o)
+	if err != nil {
+		return err
+	}
+	return setMetadata(ctx, f._path(), o, metadata)
+}
+
+// metadata returns the metadata of the directory.
+func (d *Dir) metadata(ctx context.Context) (metadata fs.Metadata, err error) {
+	d.mu.RLock()
+	entry := d.entry
+	d.mu.RUnlock()
+	if entry == nil {
+		return nil, nil
+	}
+	return fs.GetMetadata(ctx, entry)
+}
+
+// ListXattr returns the names of the extended attributes of the
+// directory.
+//
+// These are the metadata keys of the directory with XattrPrefix
+// added and any ACLs stored in the metadata.
+func (d *Dir) ListXattr() (names []string, err error) {
+	metadata, err := d.metadata(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return xattrNames(metadata), nil
+}
+
+// GetXattr returns the value of the extended attribute name.
+//
+// It returns ENOATTR if it isn't set.
+func (d *Dir) GetXattr(name string) (value []byte, err error) {
+	if _, ok := xattrKey(name); !ok {
+		return nil, ENOATTR
+	}
+	metadata, err := d.metadata(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return xattrValue(metadata, name)
+}
+
+// SetXattr sets the extended attribute name to value by setting the
+// metadata of the directory.
+//
+// It returns ENOTSUP if name isn't in the XattrPrefix namespace or an
+// ACL, or the backend can't set directory metadata.
+func (d *Dir) SetXattr(name string, value []byte) error {
+	key, v, err := xattrMetadataValue(name, value)
+	if err != nil {
+		return err
+	}
+	if d.vfs.Opt.ReadOnly {
+		return EROFS
+	}
+	d.mu.RLock()
+	entry := d.entry
+	d.mu.RUnlock()
+	if entry == nil {
+		return ENOTSUP
+	}
+	return setMetadata(context.TODO(), d.Path(), entry, fs.Metadata{key: v})
+}
+
+// RemoveXattr removes the extended attribute name.
+//
+// The backend metadata interface can only add and change metadata
+// so this returns ENOTSUP unless the attribute doesn't exist.
+func (d *Dir) RemoveXattr(name string) error {
+	if _, err := d.GetXattr(name); err != nil {
+		return err
+	}
+	return ENOTSUP
+}
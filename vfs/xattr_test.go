@@ -0,0 +1,126 @@
+package vfs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileXattr(t *testing.T) {
+	r, vfs := newTestVFS(t)
+	if !r.Fremote.Features().ReadMetadata {
+		t.Skip("Metadata not supported")
+	}
+	ctx := context.Background()
+	file1 := r.WriteObject(ctx, "file1", "file1 contents", t1)
+	r.CheckRemoteItems(t, file1)
+
+	node, err := vfs.Stat("file1")
+	require.NoError(t, err)
+	file := node.(*File)
+
+	names, err := file.ListXattr()
+	require.NoError(t, err)
+	assert.Contains(t, names, "user.rclone.mtime")
+
+	value, err := file.GetXattr("user.rclone.mtime")
+	require.NoError(t, err)
+	mtime, err := time.Parse(time.RFC3339Nano, string(value))
+	require.NoError(t, err)
+	assert.True(t, mtime.Equal(t1), "%v != %v", mtime, t1)
+
+	for _, name := range []string{"user.rclone.potato", "user.rclone.", "security.capability"} {
+		_, err = file.GetXattr(name)
+		assert.Equal(t, ENOATTR, err, name)
+	}
+
+	assert.Equal(t, ENOTSUP, file.SetXattr("security.selinux", []byte("label")))
+	assert.Equal(t, ENOATTR, file.RemoveXattr("user.rclone.potato"))
+	assert.Equal(t, ENOTSUP, file.RemoveXattr("user.rclone.mtime"))
+
+	if !r.Fremote.Features().WriteMetadata {
+		return
+	}
+	newTime := "2011-12-25T12:59:59Z"
+	require.NoError(t, file.SetXattr("user.rclone.mtime", []byte(newTime)))
+	value, err = file.GetXattr("user.rclone.mtime")
+	require.NoError(t, err)
+	mtime, err = time.Parse(time.RFC3339Nano, string(value))
+	require.NoError(t, err)
+	assert.True(t, mtime.Equal(fstest.Time(newTime)), "%v != %v", mtime, newTime)
+}
+
+func TestFileXattrACL(t *testing.T) {
+	r, vfs := newTestVFS(t)
+	if !r.Fremote.Features().UserMetadata {
+		t.Skip("User metadata not supported")
+	}
+	ctx := context.Background()
+	file1 := r.WriteObject(ctx, "file1", "file1 contents", t1)
+	r.CheckRemoteItems(t, file1)
+
+	node, err := vfs.Stat("file1")
+	require.NoError(t, err)
+
+	_, err = node.GetXattr("system.posix_acl_access")
+	assert.Equal(t, ENOATTR, err)
+
+	acl := []byte{2, 0, 0, 0, 1, 0, 6, 0, 0xff, 0xff, 0xff, 0xff}
+	err = node.SetXattr("system.posix_acl_access", acl)
+	if err == ENOTSUP {
+		t.Skip("Setting metadata not supported")
+	}
+	require.NoError(t, err)
+
+	value, err := node.GetXattr("system.posix_acl_access")
+	require.NoError(t, err)
+	assert.Equal(t, acl, value)
+	value, err = node.GetXattr("user.rclone.posix-acl-access")
+	require.NoError(t, err)
+	assert.Equal(t, "AgAAAAEABgD/////", string(value))
+
+	names, err := node.ListXattr()
+	require.NoError(t, err)
+	assert.Contains(t, names, "system.posix_acl_access")
+	assert.NotContains(t, names, "user.rclone.posix-acl-access")
+}
+
+func TestDirXattr(t *testing.T) {
+	r, vfs := newTestVFS(t)
+	if !r.Fremote.Features().ReadDirMetadata {
+		t.Skip("Directory metadata not supported")
+	}
+	ctx := context.Background()
+	file1 := r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	r.CheckRemoteItems(t, file1)
+
+	node, err := vfs.Stat("dir")
+	require.NoError(t, err)
+	require.True(t, node.IsDir())
+
+	names, err := node.ListXattr()
+	require.NoError(t, err)
+	assert.Contains(t, names, "user.rclone.mtime")
+
+	_, err = node.GetXattr("user.rclone.mtime")
+	require.NoError(t, err)
+	_, err = node.GetXattr("user.rclone.potato")
+	assert.Equal(t, ENOATTR, err)
+	assert.Equal(t, ENOTSUP, node.SetXattr("security.selinux", []byte("label")))
+	assert.Equal(t, ENOTSUP, node.RemoveXattr("user.rclone.mtime"))
+
+	if !r.Fremote.Features().WriteDirMetadata {
+		return
+	}
+	newTime := "2011-12-25T12:59:59Z"
+	require.NoError(t, node.SetXattr("user.rclone.mtime", []byte(newTime)))
+	value, err := node.GetXattr("user.rclone.mtime")
+	require.NoError(t, err)
+	mtime, err := time.Parse(time.RFC3339Nano, string(value))
+	require.NoError(t, err)
+	assert.True(t, mtime.Equal(fstest.Time(newTime)), "%v != %v", mtime, newTime)
+}